@@ -21,6 +21,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/Peripli/service-manager/operations"
 	"github.com/Peripli/service-manager/pkg/env"
@@ -44,7 +45,7 @@ import (
 	"github.com/Peripli/service-manager/storage"
 )
 
-const osbVersion = "2.13"
+const osbVersion = "2.15"
 
 // Settings type to be loaded from the environment
 type Settings struct {
@@ -55,6 +56,33 @@ type Settings struct {
 	OSBVersion      string   `mapstructure:"-"`
 	MaxPageSize     int      `mapstructure:"max_page_size" description:"maximum number of items that could be returned in a single page"`
 	DefaultPageSize int      `mapstructure:"default_page_size" description:"default number of items returned in a single page if not specified in request"`
+
+	OriginatingIdentityPolicy      string `mapstructure:"originating_identity_policy" description:"specifies how an originating identity supplied by the platform is treated - trust, override or append"`
+	OriginatingIdentityTenantClaim string `mapstructure:"originating_identity_tenant_claim" description:"token claim which holds the tenant that is included in the originating identity of OAuth initiated OSB requests"`
+
+	AsyncToSyncPollInterval time.Duration `mapstructure:"async_to_sync_poll_interval" description:"interval for polling the broker last operation on behalf of platforms which support only synchronous OSB operations"`
+	AsyncToSyncTimeout      time.Duration `mapstructure:"async_to_sync_timeout" description:"maximum time to wait for asynchronous broker operations on behalf of platforms which support only synchronous OSB operations (server request timeout should be greater)"`
+
+	OSBValidationMode string `mapstructure:"osb_validation_mode" description:"specifies how OSB requests which are not compliant with the OSB specification are treated - strict, warn or off; context which does not match the context schema of the platform type is rejected unless off"`
+
+	OSBContextEnrichment  bool     `mapstructure:"osb_context_enrichment" description:"specifies if Service Manager metadata should be injected in the OSB context of provision, update and bind requests"`
+	OSBContextTenantLabel string   `mapstructure:"osb_context_tenant_label" description:"label which holds the tenant that is included in the Service Manager block of the OSB context"`
+	OSBContextLabels      []string `mapstructure:"osb_context_labels" description:"instance and platform labels which are included in the Service Manager block of the OSB context"`
+
+	BrokerURLRequireHTTPS bool     `mapstructure:"broker_url_require_https" description:"specifies if the urls of new and updated brokers must use https"`
+	BrokerURLAllowedHosts []string `mapstructure:"broker_url_allowed_hosts" description:"host names or wildcard host names such as *.example.com which broker urls are allowed to point at - all hosts are allowed if empty"`
+	BrokerURLDeniedHosts  []string `mapstructure:"broker_url_denied_hosts" description:"host names or wildcard host names such as *.example.com which broker urls are not allowed to point at"`
+	BrokerURLAllowedCIDRs []string `mapstructure:"broker_url_allowed_cidrs" description:"networks in CIDR notation in which brokers are allowed to be called - all addresses which are not link-local or metadata addresses are allowed if empty"`
+	BrokerURLDeniedCIDRs  []string `mapstructure:"broker_url_denied_cidrs" description:"networks in CIDR notation in which brokers are not allowed to be called"`
+
+	BrokerStagingLabel string `mapstructure:"broker_staging_label" description:"platform label which marks the platforms to which the plans of brokers in staging state are visible - new brokers start in staging state only if it is set"`
+
+	PlatformCredentialsGracePeriod     time.Duration `mapstructure:"platform_credentials_grace_period" description:"time for which the platform credentials replaced by a rotation remain valid"`
+	PlatformCredentialsCleanupInterval time.Duration `mapstructure:"platform_credentials_cleanup_interval" description:"interval for purging the expired platform credentials replaced by rotations"`
+
+	StrictPlatformTypes bool `mapstructure:"strict_platform_types" description:"specifies if platforms can be registered only with the platform types known to the Service Manager"`
+
+	BrokerErrorMappings []*types.BrokerErrorMapping `mapstructure:"broker_error_mappings" description:"error mappings which normalize the broker error responses not matched by the error mappings of the broker"`
 }
 
 // DefaultSettings returns default values for API settings
@@ -67,6 +95,33 @@ func DefaultSettings() *Settings {
 		MaxPageSize:     200,
 		DefaultPageSize: 50,
 		ProtectedLabels: []string{},
+
+		OriginatingIdentityPolicy:      string(osb.OverrideOriginatingIdentity),
+		OriginatingIdentityTenantClaim: "zid",
+
+		AsyncToSyncPollInterval: 2 * time.Second,
+		AsyncToSyncTimeout:      time.Minute,
+
+		OSBValidationMode: string(osb.WarnOSBValidation),
+
+		OSBContextEnrichment:  false,
+		OSBContextTenantLabel: "",
+		OSBContextLabels:      []string{},
+
+		BrokerURLRequireHTTPS: false,
+		BrokerURLAllowedHosts: []string{},
+		BrokerURLDeniedHosts:  []string{},
+		BrokerURLAllowedCIDRs: []string{},
+		BrokerURLDeniedCIDRs:  []string{},
+
+		BrokerStagingLabel: "",
+
+		PlatformCredentialsGracePeriod:     24 * time.Hour,
+		PlatformCredentialsCleanupInterval: time.Hour,
+
+		StrictPlatformTypes: false,
+
+		BrokerErrorMappings: []*types.BrokerErrorMapping{},
 	}
 }
 
@@ -75,9 +130,40 @@ func (s *Settings) Validate() error {
 	if (len(s.TokenIssuerURL)) == 0 {
 		return fmt.Errorf("validate Settings: APITokenIssuerURL missing")
 	}
+	if err := osb.OriginatingIdentityPolicy(s.OriginatingIdentityPolicy).Validate(); err != nil {
+		return fmt.Errorf("validate Settings: %s", err)
+	}
+	if s.AsyncToSyncPollInterval <= 0 {
+		return fmt.Errorf("validate Settings: AsyncToSyncPollInterval must be larger than 0")
+	}
+	if s.AsyncToSyncTimeout < s.AsyncToSyncPollInterval {
+		return fmt.Errorf("validate Settings: AsyncToSyncTimeout must be larger than AsyncToSyncPollInterval")
+	}
+	if err := osb.OSBValidationMode(s.OSBValidationMode).Validate(); err != nil {
+		return fmt.Errorf("validate Settings: %s", err)
+	}
+	if _, err := s.BrokerURLPolicy(); err != nil {
+		return fmt.Errorf("validate Settings: %s", err)
+	}
+	if s.PlatformCredentialsGracePeriod < 0 {
+		return fmt.Errorf("validate Settings: PlatformCredentialsGracePeriod must not be negative")
+	}
+	if s.PlatformCredentialsCleanupInterval <= 0 {
+		return fmt.Errorf("validate Settings: PlatformCredentialsCleanupInterval must be larger than 0")
+	}
+	for _, errorMapping := range s.BrokerErrorMappings {
+		if err := errorMapping.Validate(); err != nil {
+			return fmt.Errorf("validate Settings: %s", err)
+		}
+	}
 	return nil
 }
 
+// BrokerURLPolicy builds the broker url policy specified by the settings
+func (s *Settings) BrokerURLPolicy() (*osb.BrokerURLPolicy, error) {
+	return osb.NewBrokerURLPolicy(s.BrokerURLRequireHTTPS, s.BrokerURLAllowedHosts, s.BrokerURLDeniedHosts, s.BrokerURLAllowedCIDRs, s.BrokerURLDeniedCIDRs)
+}
+
 type Options struct {
 	Repository        storage.TransactionalRepository
 	APISettings       *Settings
@@ -85,19 +171,40 @@ type Options struct {
 	WSSettings        *ws.Settings
 	Notificator       storage.Notificator
 	WaitGroup         *sync.WaitGroup
+	BrokerClients     *osb.BrokerClients
+	BrokerURLPolicy   *osb.BrokerURLPolicy
+	PlatformTypes     *osb.PlatformTypeRegistry
+
+	// FulfillmentHandler fulfills the OSB requests for catalog-only brokers. Requests are tracked as operations
+	// completed through the API if not set.
+	FulfillmentHandler osb.FulfillmentHandler
 }
 
 // New returns the minimum set of REST APIs needed for the Service Manager
 func New(ctx context.Context, e env.Environment, options *Options) (*web.API, error) {
+	brokerFetcher := func(ctx context.Context, brokerID string) (*types.ServiceBroker, error) {
+		byID := query.ByField(query.EqualsOperator, "id", brokerID)
+		br, err := options.Repository.Get(ctx, types.ServiceBrokerType, byID)
+		if err != nil {
+			return nil, util.HandleStorageError(err, "broker")
+		}
+		return br.(*types.ServiceBroker), nil
+	}
+	shadowMirror := osb.NewShadowMirror(options.BrokerClients)
+	brokerStaging := &osb.BrokerStaging{
+		Repository: options.Repository,
+		Label:      options.APISettings.BrokerStagingLabel,
+	}
+	fulfillmentHandler := options.FulfillmentHandler
+	if fulfillmentHandler == nil {
+		fulfillmentHandler = osb.NewOperationFulfillmentHandler(options.Repository)
+	}
+
 	return &web.API{
 		// Default controllers - more filters can be registered using the relevant API methods
 		Controllers: []web.Controller{
-			NewAsyncController(ctx, options, web.ServiceBrokersURL, types.ServiceBrokerType, func() types.Object {
-				return &types.ServiceBroker{}
-			}),
-			NewController(options, web.PlatformsURL, types.PlatformType, func() types.Object {
-				return &types.Platform{}
-			}),
+			NewServiceBrokerController(ctx, options),
+			NewPlatformController(options),
 			NewController(options, web.VisibilitiesURL, types.VisibilityType, func() types.Object {
 				return &types.Visibility{}
 			}),
@@ -110,14 +217,30 @@ func New(ctx context.Context, e env.Environment, options *Options) (*web.API, er
 				TokenBasicAuth: options.APISettings.TokenBasicAuth,
 			},
 			&osb.Controller{
-				BrokerFetcher: func(ctx context.Context, brokerID string) (*types.ServiceBroker, error) {
-					byID := query.ByField(query.EqualsOperator, "id", brokerID)
-					br, err := options.Repository.Get(ctx, types.ServiceBrokerType, byID)
+				BrokerFetcher: brokerFetcher,
+				BrokerClients: options.BrokerClients,
+				InstanceEndpointFetcher: func(ctx context.Context, instanceID string) (string, error) {
+					criteria := []query.Criterion{
+						query.ByField(query.EqualsOperator, "resource_id", instanceID),
+						query.ByField(query.NotEqualsOperator, "broker_endpoint", ""),
+						query.OrderResultBy("paging_sequence", query.DescOrder),
+					}
+					op, err := options.Repository.Get(ctx, types.OperationType, criteria...)
 					if err != nil {
-						return nil, util.HandleStorageError(err, "broker")
+						if err == util.ErrNotFoundInStorage {
+							return "", nil
+						}
+						return "", util.HandleStorageError(err, string(types.OperationType))
 					}
-					return br.(*types.ServiceBroker), nil
+					return op.(*types.Operation).BrokerEndpoint, nil
 				},
+				ShadowMirror:       shadowMirror,
+				FulfillmentHandler: fulfillmentHandler,
+				ErrorMappings:      options.APISettings.BrokerErrorMappings,
+			},
+			&osb.ShadowDiffsController{
+				BrokerFetcher: brokerFetcher,
+				ShadowMirror:  shadowMirror,
 			},
 			&configuration.Controller{
 				Environment: e,
@@ -128,11 +251,16 @@ func New(ctx context.Context, e env.Environment, options *Options) (*web.API, er
 			&filters.Logging{},
 			&filters.SelectionCriteria{},
 			filters.NewProtectedLabelsFilter(options.APISettings.ProtectedLabels),
-			&filters.PlatformAwareVisibilityFilter{},
+			filters.NewPlatformAwareVisibilityFilter(options.Repository),
 			&filters.PatchOnlyLabelsFilter{},
-			filters.NewPlansFilterByVisibility(options.Repository),
-			filters.NewServicesFilterByVisibility(options.Repository),
+			filters.NewPlansFilterByVisibility(options.Repository, brokerStaging),
+			filters.NewServicesFilterByVisibility(options.Repository, brokerStaging),
 			&filters.CheckBrokerCredentialsFilter{},
+			filters.NewBrokerURLPolicyFilter(options.BrokerURLPolicy),
+			filters.NewPlatformTypeFilter(options.PlatformTypes, options.APISettings.StrictPlatformTypes),
+			&filters.PlatformSuspendedFilter{},
+			&filters.VisibilitySelectorFilter{},
+			filters.NewBrokerStateFilter(brokerStaging),
 		},
 		Registry: health.NewDefaultRegistry(),
 	}, nil
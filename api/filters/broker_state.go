@@ -0,0 +1,92 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package filters
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Peripli/service-manager/api/osb"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/pkg/web"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// BrokerStateFilterName is the name of the broker state filter
+const BrokerStateFilterName = "BrokerStateFilter"
+
+// BrokerStateFilter makes new brokers start in staging state if broker staging is enabled and prevents the state
+// of the brokers from being modified other than by promoting them
+type BrokerStateFilter struct {
+	staging *osb.BrokerStaging
+}
+
+// NewBrokerStateFilter creates new filter which sets the initial state of the brokers
+func NewBrokerStateFilter(staging *osb.BrokerStaging) *BrokerStateFilter {
+	return &BrokerStateFilter{
+		staging: staging,
+	}
+}
+
+// Name returns the name of the filter
+func (f *BrokerStateFilter) Name() string {
+	return BrokerStateFilterName
+}
+
+// Run rejects requests which specify the broker state and sets the state of the brokers which are being created
+func (f *BrokerStateFilter) Run(req *web.Request, next web.Handler) (*web.Response, error) {
+	if gjson.GetBytes(req.Body, "state").Exists() {
+		return nil, &util.HTTPError{
+			ErrorType:   "BadRequest",
+			Description: fmt.Sprintf("broker state cannot be set directly, brokers in %s state are activated by promoting them", types.BrokerStaging),
+			StatusCode:  http.StatusBadRequest,
+		}
+	}
+	if req.Method != http.MethodPost || !gjson.ValidBytes(req.Body) {
+		return next.Handle(req)
+	}
+
+	state := types.BrokerActive
+	if f.staging.Enabled() {
+		state = types.BrokerStaging
+	}
+	var err error
+	if req.Body, err = sjson.SetBytes(req.Body, "state", state); err != nil {
+		return nil, err
+	}
+	return next.Handle(req)
+}
+
+// FilterMatchers returns the broker create and update matchers
+func (f *BrokerStateFilter) FilterMatchers() []web.FilterMatcher {
+	return []web.FilterMatcher{
+		{
+			Matchers: []web.Matcher{
+				web.Path(web.ServiceBrokersURL),
+				web.Methods(http.MethodPost),
+			},
+		},
+		{
+			Matchers: []web.Matcher{
+				web.Path(web.ServiceBrokersURL + "/*"),
+				web.Methods(http.MethodPatch),
+			},
+		},
+	}
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package filters
+
+import (
+	"net/http"
+
+	"github.com/Peripli/service-manager/api/osb"
+	"github.com/Peripli/service-manager/pkg/web"
+	"github.com/tidwall/gjson"
+)
+
+// BrokerURLPolicyFilterName is the name of the broker url policy filter
+const BrokerURLPolicyFilterName = "BrokerURLPolicyFilter"
+
+// BrokerURLPolicyFilter rejects the registration and the update of brokers with urls forbidden by the broker url policy
+type BrokerURLPolicyFilter struct {
+	policy *osb.BrokerURLPolicy
+}
+
+// NewBrokerURLPolicyFilter creates new filter which verifies the broker urls against the provided policy
+func NewBrokerURLPolicyFilter(policy *osb.BrokerURLPolicy) *BrokerURLPolicyFilter {
+	return &BrokerURLPolicyFilter{
+		policy: policy,
+	}
+}
+
+// Name returns the name of the filter
+func (f *BrokerURLPolicyFilter) Name() string {
+	return BrokerURLPolicyFilterName
+}
+
+// Run verifies the broker url, the failover urls and the shadow url of the broker in the request body
+func (f *BrokerURLPolicyFilter) Run(req *web.Request, next web.Handler) (*web.Response, error) {
+	var brokerURLs []string
+	for _, field := range []string{"broker_url", "failover_urls", "shadow_url"} {
+		value := gjson.GetBytes(req.Body, field)
+		if value.IsArray() {
+			for _, item := range value.Array() {
+				brokerURLs = append(brokerURLs, item.String())
+			}
+		} else if len(value.String()) != 0 {
+			brokerURLs = append(brokerURLs, value.String())
+		}
+	}
+
+	for _, brokerURL := range brokerURLs {
+		if err := f.policy.CheckURL(req.Context(), brokerURL); err != nil {
+			return nil, err
+		}
+	}
+	return next.Handle(req)
+}
+
+// FilterMatchers returns the broker create and update matchers
+func (f *BrokerURLPolicyFilter) FilterMatchers() []web.FilterMatcher {
+	return []web.FilterMatcher{
+		{
+			Matchers: []web.Matcher{
+				web.Path(web.ServiceBrokersURL + "/**"),
+				web.Methods(http.MethodPost, http.MethodPatch),
+			},
+		},
+	}
+}
@@ -25,19 +25,42 @@ func servicesCriteria(ctx context.Context, repository storage.Repository, planQu
 	return &c, nil
 }
 
-func plansCriteria(ctx context.Context, repository storage.Repository, platformID string) (*query.Criterion, error) {
-	objectList, err := repository.List(ctx, types.VisibilityType, query.ByField(query.EqualsOrNilOperator, "platform_id", platformID))
+func plansCriteria(ctx context.Context, repository storage.Repository, platform *types.Platform) (*query.Criterion, error) {
+	visibilities, err := storage.ListPlatformVisibilities(ctx, repository, platform)
 	if err != nil {
 		return nil, err
 	}
-	visibilityList := objectList.(*types.Visibilities)
-	if visibilityList.Len() < 1 {
+	if len(visibilities) < 1 {
 		return nil, nil
 	}
-	planIDs := make([]string, 0, visibilityList.Len())
-	for _, vis := range visibilityList.Visibilities {
+	planIDs := make([]string, 0, len(visibilities))
+	offeringVisibilities := make([]*types.Visibility, 0)
+	offeringIDs := make([]string, 0)
+	for _, vis := range visibilities {
+		if vis.ServiceOfferingID != "" {
+			offeringVisibilities = append(offeringVisibilities, vis)
+			offeringIDs = append(offeringIDs, vis.ServiceOfferingID)
+			continue
+		}
 		planIDs = append(planIDs, vis.ServicePlanID)
 	}
+	if len(offeringVisibilities) > 0 {
+		objectList, err := repository.List(ctx, types.ServicePlanType, query.ByField(query.InOperator, "service_offering_id", offeringIDs...))
+		if err != nil {
+			return nil, err
+		}
+		for _, plan := range objectList.(*types.ServicePlans).ServicePlans {
+			for _, vis := range offeringVisibilities {
+				if vis.CoversPlan(plan) {
+					planIDs = append(planIDs, plan.ID)
+					break
+				}
+			}
+		}
+	}
+	if len(planIDs) < 1 {
+		return nil, nil
+	}
 	c := query.ByField(query.InOperator, "id", planIDs...)
 	return &c, nil
 }
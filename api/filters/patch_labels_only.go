@@ -21,11 +21,13 @@ import (
 	"github.com/Peripli/service-manager/pkg/web"
 	"github.com/tidwall/gjson"
 	"net/http"
+	"strings"
 )
 
 const PatchOnlyLabelsFilterName = "PatchOnlyLabelsFilter"
 
-// PatchOnlyLabelsFilter checks patch request for service offerings and plans include only label changes
+// PatchOnlyLabelsFilter checks patch request for service offerings include only label changes and
+// patch requests for plans include only label and default parameters changes
 type PatchOnlyLabelsFilter struct {
 }
 
@@ -36,11 +38,14 @@ func (*PatchOnlyLabelsFilter) Name() string {
 func (*PatchOnlyLabelsFilter) Run(req *web.Request, next web.Handler) (*web.Response, error) {
 	jsonMap := gjson.ParseBytes(req.Body).Map()
 	delete(jsonMap, "labels")
+	if strings.HasPrefix(req.URL.Path, web.ServicePlansURL) {
+		delete(jsonMap, "default_parameters")
+	}
 
 	if len(jsonMap) > 0 {
 		return nil, &util.HTTPError{
 			ErrorType:   "BadRequest",
-			Description: "Only labels can be patched for service offerings and plans",
+			Description: "Only labels can be patched for service offerings and only labels and default parameters can be patched for plans",
 			StatusCode:  http.StatusBadRequest,
 		}
 	}
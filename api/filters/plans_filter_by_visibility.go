@@ -4,22 +4,27 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/Peripli/service-manager/api/osb"
+
 	"github.com/Peripli/service-manager/pkg/types"
 
 	"github.com/Peripli/service-manager/pkg/query"
 
 	"github.com/Peripli/service-manager/storage"
 
+	"github.com/Peripli/service-manager/pkg/util"
 	"github.com/Peripli/service-manager/pkg/web"
 )
 
 const PlanVisibilityFilterName = "PlanFilterByVisibility"
 
-func NewPlansFilterByVisibility(repository storage.Repository) *PlanFilterByVisibility {
+func NewPlansFilterByVisibility(repository storage.Repository, staging *osb.BrokerStaging) *PlanFilterByVisibility {
 	return &PlanFilterByVisibility{
 		visibilityFilteringMiddleware: &visibilityFilteringMiddleware{
 			ListResourcesCriteria: plansCriteriaFunc(repository),
 			IsResourceVisible:     isPlanVisibile(repository),
+			StagingResourceIDs:    staging.StagingPlanIDs,
+			Staging:               staging,
 		},
 	}
 }
@@ -28,17 +33,23 @@ type PlanFilterByVisibility struct {
 	*visibilityFilteringMiddleware
 }
 
-func isPlanVisibile(repository storage.Repository) func(ctx context.Context, planID, platformID string) (bool, error) {
-	return func(ctx context.Context, planID, platformID string) (bool, error) {
-		visibilities, err := repository.List(ctx, types.VisibilityType, query.ByField(query.EqualsOperator, "service_plan_id", planID),
-			query.ByField(query.EqualsOrNilOperator, "platform_id", platformID))
-		return visibilities.Len() > 0, err
+func isPlanVisibile(repository storage.Repository) func(ctx context.Context, planID string, platform *types.Platform) (bool, error) {
+	return func(ctx context.Context, planID string, platform *types.Platform) (bool, error) {
+		plan, err := repository.Get(ctx, types.ServicePlanType, query.ByField(query.EqualsOperator, "id", planID))
+		if err != nil {
+			if err == util.ErrNotFoundInStorage {
+				return false, nil
+			}
+			return false, err
+		}
+		visibilities, err := storage.ListPlatformPlansVisibilities(ctx, repository, platform, []*types.ServicePlan{plan.(*types.ServicePlan)})
+		return len(visibilities[planID]) > 0, err
 	}
 }
 
-func plansCriteriaFunc(repository storage.Repository) func(context.Context, string) (*query.Criterion, error) {
-	return func(ctx context.Context, platformID string) (*query.Criterion, error) {
-		planQuery, err := plansCriteria(ctx, repository, platformID)
+func plansCriteriaFunc(repository storage.Repository) func(context.Context, *types.Platform) (*query.Criterion, error) {
+	return func(ctx context.Context, platform *types.Platform) (*query.Criterion, error) {
+		planQuery, err := plansCriteria(ctx, repository, platform)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,69 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package filters
+
+import (
+	"net/http"
+
+	"github.com/Peripli/service-manager/pkg/web"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// PlatformSuspendedFilterName is the name of the platform suspended filter
+const PlatformSuspendedFilterName = "PlatformSuspendedFilter"
+
+// PlatformSuspendedFilter ignores the suspended flag in the platform create and update requests, as the platforms
+// are suspended only by the Service Manager when they are inactive for too long
+type PlatformSuspendedFilter struct {
+}
+
+// Name returns the name of the filter
+func (*PlatformSuspendedFilter) Name() string {
+	return PlatformSuspendedFilterName
+}
+
+// Run removes the suspended flag from the request body
+func (*PlatformSuspendedFilter) Run(req *web.Request, next web.Handler) (*web.Response, error) {
+	if !gjson.GetBytes(req.Body, "suspended").Exists() {
+		return next.Handle(req)
+	}
+
+	var err error
+	if req.Body, err = sjson.DeleteBytes(req.Body, "suspended"); err != nil {
+		return nil, err
+	}
+	return next.Handle(req)
+}
+
+// FilterMatchers returns the platform create and update matchers
+func (*PlatformSuspendedFilter) FilterMatchers() []web.FilterMatcher {
+	return []web.FilterMatcher{
+		{
+			Matchers: []web.Matcher{
+				web.Path(web.PlatformsURL),
+				web.Methods(http.MethodPost),
+			},
+		},
+		{
+			Matchers: []web.Matcher{
+				web.Path(web.PlatformsURL + "/*"),
+				web.Methods(http.MethodPatch),
+			},
+		},
+	}
+}
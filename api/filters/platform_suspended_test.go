@@ -0,0 +1,59 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filters_test
+
+import (
+	"net/http"
+
+	"github.com/Peripli/service-manager/api/filters"
+	"github.com/Peripli/service-manager/pkg/web/webfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Platform suspended filter", func() {
+	var filter *filters.PlatformSuspendedFilter
+	var handler *webfakes.FakeHandler
+
+	BeforeEach(func() {
+		handler = &webfakes.FakeHandler{}
+		filter = &filters.PlatformSuspendedFilter{}
+	})
+
+	handledBody := func() string {
+		Expect(handler.HandleCallCount()).To(Equal(1))
+		req := handler.HandleArgsForCall(0)
+		return string(req.Body)
+	}
+
+	When("suspended flag is provided", func() {
+		It("should remove it from the request body", func() {
+			_, err := filter.Run(mockedRequest(http.MethodPatch, `{"description": "descr", "suspended": true}`), handler)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(handledBody()).To(MatchJSON(`{"description": "descr"}`))
+		})
+	})
+
+	When("suspended flag is not provided", func() {
+		It("should leave the request body unchanged", func() {
+			_, err := filter.Run(mockedRequest(http.MethodPost, `{"name": "platform"}`), handler)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(handledBody()).To(MatchJSON(`{"name": "platform"}`))
+		})
+	})
+})
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package filters
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Peripli/service-manager/api/osb"
+	"github.com/Peripli/service-manager/pkg/log"
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/pkg/web"
+	"github.com/tidwall/gjson"
+)
+
+// PlatformTypeFilterName is the name of the platform type filter
+const PlatformTypeFilterName = "PlatformTypeFilter"
+
+// PlatformTypeFilter verifies the types of the registered and updated platforms against the platform type registry
+type PlatformTypeFilter struct {
+	platformTypes *osb.PlatformTypeRegistry
+	strict        bool
+}
+
+// NewPlatformTypeFilter creates new filter which verifies the platform types against the provided registry.
+// Platforms with unknown types are rejected in strict mode, otherwise only a warning is logged for them.
+func NewPlatformTypeFilter(platformTypes *osb.PlatformTypeRegistry, strict bool) *PlatformTypeFilter {
+	return &PlatformTypeFilter{
+		platformTypes: platformTypes,
+		strict:        strict,
+	}
+}
+
+// Name returns the name of the filter
+func (f *PlatformTypeFilter) Name() string {
+	return PlatformTypeFilterName
+}
+
+// Run verifies the platform type in the request body
+func (f *PlatformTypeFilter) Run(req *web.Request, next web.Handler) (*web.Response, error) {
+	platformType := gjson.GetBytes(req.Body, "type")
+	if !platformType.Exists() || f.platformTypes.IsRegistered(platformType.String()) {
+		return next.Handle(req)
+	}
+
+	if f.strict {
+		return nil, &util.HTTPError{
+			ErrorType:   "BadRequest",
+			Description: fmt.Sprintf("unknown platform type %s, supported types are %s", platformType.String(), strings.Join(f.platformTypes.Names(), ", ")),
+			StatusCode:  http.StatusBadRequest,
+		}
+	}
+	log.C(req.Context()).Warnf("Platform type %s is not known to the Service Manager, the OSB context of its platforms will not be validated", platformType.String())
+	return next.Handle(req)
+}
+
+// FilterMatchers returns the platform create and update matchers
+func (f *PlatformTypeFilter) FilterMatchers() []web.FilterMatcher {
+	return []web.FilterMatcher{
+		{
+			Matchers: []web.Matcher{
+				web.Path(web.PlatformsURL + "/**"),
+				web.Methods(http.MethodPost, http.MethodPatch),
+			},
+		},
+	}
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filters_test
+
+import (
+	"net/http"
+
+	"github.com/Peripli/service-manager/api/filters"
+	"github.com/Peripli/service-manager/api/osb"
+
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/pkg/web/webfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Platform type filter", func() {
+	var filter *filters.PlatformTypeFilter
+	var handler *webfakes.FakeHandler
+	var strict bool
+
+	BeforeEach(func() {
+		strict = true
+	})
+
+	JustBeforeEach(func() {
+		platformTypes, err := osb.NewPlatformTypeRegistry(osb.DefaultPlatformTypes()...)
+		Expect(err).ToNot(HaveOccurred())
+		handler = &webfakes.FakeHandler{}
+		filter = filters.NewPlatformTypeFilter(platformTypes, strict)
+	})
+
+	When("platform type is registered", func() {
+		It("should call next filter in chain", func() {
+			_, err := filter.Run(mockedRequest(http.MethodPost, `{"type": "cloudfoundry"}`), handler)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(handler.HandleCallCount()).To(Equal(1))
+		})
+	})
+
+	When("platform type is not provided", func() {
+		It("should call next filter in chain", func() {
+			_, err := filter.Run(mockedRequest(http.MethodPatch, `{"description": "descr"}`), handler)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(handler.HandleCallCount()).To(Equal(1))
+		})
+	})
+
+	When("platform type is unknown", func() {
+		It("should return 400", func() {
+			_, err := filter.Run(mockedRequest(http.MethodPost, `{"type": "unknown"}`), handler)
+			httpErr, ok := err.(*util.HTTPError)
+			Expect(ok).To(BeTrue())
+			Expect(httpErr.StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(httpErr.Description).To(ContainSubstring("cloudfoundry, kubernetes"))
+			Expect(handler.HandleCallCount()).To(Equal(0))
+		})
+
+		Context("and the filter is not strict", func() {
+			BeforeEach(func() {
+				strict = false
+			})
+
+			It("should call next filter in chain", func() {
+				_, err := filter.Run(mockedRequest(http.MethodPost, `{"type": "unknown"}`), handler)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(handler.HandleCallCount()).To(Equal(1))
+			})
+		})
+	})
+})
@@ -4,6 +4,8 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/Peripli/service-manager/api/osb"
+
 	"github.com/Peripli/service-manager/pkg/query"
 	"github.com/Peripli/service-manager/pkg/types"
 
@@ -14,11 +16,13 @@ import (
 
 const ServicesVisibilityFilterName = "ServicesFilterByVisibility"
 
-func NewServicesFilterByVisibility(repository storage.Repository) *ServicesFilterByVisibility {
+func NewServicesFilterByVisibility(repository storage.Repository, staging *osb.BrokerStaging) *ServicesFilterByVisibility {
 	return &ServicesFilterByVisibility{
 		visibilityFilteringMiddleware: &visibilityFilteringMiddleware{
 			ListResourcesCriteria: servicesCriteriaFunc(repository),
 			IsResourceVisible:     isServiceVisible(repository),
+			StagingResourceIDs:    staging.StagingOfferingIDs,
+			Staging:               staging,
 		},
 	}
 }
@@ -27,26 +31,20 @@ type ServicesFilterByVisibility struct {
 	*visibilityFilteringMiddleware
 }
 
-func isServiceVisible(repository storage.Repository) func(ctx context.Context, serviceID, platformID string) (bool, error) {
-	return func(ctx context.Context, serviceID, platformID string) (bool, error) {
+func isServiceVisible(repository storage.Repository) func(ctx context.Context, serviceID string, platform *types.Platform) (bool, error) {
+	return func(ctx context.Context, serviceID string, platform *types.Platform) (bool, error) {
 		plansList, err := repository.List(ctx, types.ServicePlanType, query.ByField(query.EqualsOperator, "service_offering_id", serviceID))
 		if err != nil {
 			return false, err
 		}
-		planIds := make([]string, 0, plansList.Len())
-		for i := 0; i < plansList.Len(); i++ {
-			planIds = append(planIds, plansList.ItemAt(i).GetID())
-		}
-
-		visibilities, err := repository.List(ctx, types.VisibilityType, query.ByField(query.InOperator, "service_plan_id", planIds...),
-			query.ByField(query.EqualsOrNilOperator, "platform_id", platformID))
-		return visibilities.Len() > 0, err
+		visibilities, err := storage.ListPlatformPlansVisibilities(ctx, repository, platform, plansList.(*types.ServicePlans).ServicePlans)
+		return len(visibilities) > 0, err
 	}
 }
 
-func servicesCriteriaFunc(repository storage.Repository) func(ctx context.Context, platformID string) (*query.Criterion, error) {
-	return func(ctx context.Context, platformID string) (*query.Criterion, error) {
-		planQuery, err := plansCriteria(ctx, repository, platformID)
+func servicesCriteriaFunc(repository storage.Repository) func(ctx context.Context, platform *types.Platform) (*query.Criterion, error) {
+	return func(ctx context.Context, platform *types.Platform) (*query.Criterion, error) {
+		planQuery, err := plansCriteria(ctx, repository, platform)
 		if err != nil {
 			return nil, err
 		}
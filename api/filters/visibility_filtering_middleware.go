@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/Peripli/service-manager/api/osb"
 	"github.com/Peripli/service-manager/pkg/util"
 
 	"github.com/Peripli/service-manager/pkg/log"
@@ -15,8 +16,10 @@ import (
 )
 
 type visibilityFilteringMiddleware struct {
-	IsResourceVisible     func(ctx context.Context, resourceID, platformID string) (bool, error)
-	ListResourcesCriteria func(ctx context.Context, platformID string) (*query.Criterion, error)
+	IsResourceVisible     func(ctx context.Context, resourceID string, platform *types.Platform) (bool, error)
+	ListResourcesCriteria func(ctx context.Context, platform *types.Platform) (*query.Criterion, error)
+	StagingResourceIDs    func(ctx context.Context) (map[string]bool, error)
+	Staging               *osb.BrokerStaging
 }
 
 func (m visibilityFilteringMiddleware) Run(req *web.Request, next web.Handler) (*web.Response, error) {
@@ -33,16 +36,43 @@ func (m visibilityFilteringMiddleware) Run(req *web.Request, next web.Handler) (
 	if err := userCtx.Data(platform); err != nil {
 		return nil, err
 	}
-	if platform.Type != types.K8sPlatformType {
-		log.C(ctx).Debugf("Platform type is %s, which is not kubernetes. Skip filtering on visibilities", platform.Type)
-		return next.Handle(req)
+	// the staging resources are looked up only if broker staging is enabled as it is done on every request
+	var stagingResourceIDs map[string]bool
+	var err error
+	if m.Staging.Enabled() {
+		if stagingResourceIDs, err = m.StagingResourceIDs(ctx); err != nil {
+			return nil, err
+		}
 	}
+	isStagingPlatform := m.Staging.IsStagingPlatform(platform)
 
 	resourceID := req.PathParams["resource_id"]
 	isSingleResource := (resourceID != "")
 
+	if isSingleResource && stagingResourceIDs[resourceID] {
+		if isStagingPlatform {
+			return next.Handle(req)
+		}
+		return nil, &util.HTTPError{
+			ErrorType:   "NotFound",
+			Description: fmt.Sprintf("could not find resource"),
+			StatusCode:  http.StatusNotFound,
+		}
+	}
+
+	if platform.Type != types.K8sPlatformType {
+		log.C(ctx).Debugf("Platform type is %s, which is not kubernetes. Skip filtering on visibilities", platform.Type)
+		if !isSingleResource && !isStagingPlatform && len(stagingResourceIDs) != 0 {
+			if ctx, err = query.AddCriteria(ctx, query.ByField(query.NotInOperator, "id", keys(stagingResourceIDs)...)); err != nil {
+				return nil, err
+			}
+			req.Request = req.WithContext(ctx)
+		}
+		return next.Handle(req)
+	}
+
 	if isSingleResource {
-		if isResourceVisible, err := m.IsResourceVisible(ctx, resourceID, platform.ID); err != nil {
+		if isResourceVisible, err := m.IsResourceVisible(ctx, resourceID, platform); err != nil {
 			return nil, err
 		} else if !isResourceVisible {
 			return nil, &util.HTTPError{
@@ -55,10 +85,11 @@ func (m visibilityFilteringMiddleware) Run(req *web.Request, next web.Handler) (
 		return next.Handle(req)
 	}
 
-	finalQuery, err := m.ListResourcesCriteria(ctx, platform.ID)
+	finalQuery, err := m.ListResourcesCriteria(ctx, platform)
 	if err != nil {
 		return nil, err
 	}
+	finalQuery = withStagingResources(finalQuery, stagingResourceIDs, isStagingPlatform)
 	if finalQuery == nil {
 		return util.NewJSONResponse(http.StatusOK, types.ObjectPage{Items: make([]types.Object, 0)})
 	}
@@ -72,3 +103,35 @@ func (m visibilityFilteringMiddleware) Run(req *web.Request, next web.Handler) (
 	req.Request = req.WithContext(ctx)
 	return next.Handle(req)
 }
+
+// withStagingResources adds the resources of the brokers in staging state to the visible resources of staging
+// platforms and removes them from the visible resources of all other platforms
+func withStagingResources(criterion *query.Criterion, stagingResourceIDs map[string]bool, isStagingPlatform bool) *query.Criterion {
+	if len(stagingResourceIDs) == 0 {
+		return criterion
+	}
+	var resourceIDs []string
+	if criterion != nil {
+		for _, resourceID := range criterion.RightOp {
+			if !stagingResourceIDs[resourceID] {
+				resourceIDs = append(resourceIDs, resourceID)
+			}
+		}
+	}
+	if isStagingPlatform {
+		resourceIDs = append(resourceIDs, keys(stagingResourceIDs)...)
+	}
+	if len(resourceIDs) == 0 {
+		return nil
+	}
+	result := query.ByField(query.InOperator, "id", resourceIDs...)
+	return &result
+}
+
+func keys(set map[string]bool) []string {
+	result := make([]string, 0, len(set))
+	for key := range set {
+		result = append(result, key)
+	}
+	return result
+}
@@ -17,24 +17,37 @@
 package filters
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 
 	"github.com/Peripli/service-manager/pkg/query"
 	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/util"
 	"github.com/Peripli/service-manager/pkg/web"
+	"github.com/Peripli/service-manager/storage"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
 )
 
 const PlatformAwareVisibilityFilterName = "PlatformAwareVisibilityFilter"
 
+// NewPlatformAwareVisibilityFilter returns a filter which restricts the visibilities listed by platforms
+func NewPlatformAwareVisibilityFilter(repository storage.Repository) *PlatformAwareVisibilityFilter {
+	return &PlatformAwareVisibilityFilter{
+		repository: repository,
+	}
+}
+
 type PlatformAwareVisibilityFilter struct {
+	repository storage.Repository
 }
 
 func (*PlatformAwareVisibilityFilter) Name() string {
 	return PlatformAwareVisibilityFilterName
 }
 
-func (*PlatformAwareVisibilityFilter) Run(req *web.Request, next web.Handler) (*web.Response, error) {
+func (f *PlatformAwareVisibilityFilter) Run(req *web.Request, next web.Handler) (*web.Response, error) {
 	ctx := req.Context()
 	user, ok := web.UserFromContext(ctx)
 	if !ok {
@@ -55,13 +68,74 @@ func (*PlatformAwareVisibilityFilter) Run(req *web.Request, next web.Handler) (*
 	}
 
 	byPlatformID := query.ByField(query.EqualsOrNilOperator, "platform_id", p.ID)
+	// platforms see only the visibilities which they have been notified about
+	activeOnly := query.ByField(query.EqualsOperator, "active", "true")
 	var err error
-	if ctx, err = query.AddCriteria(ctx, byPlatformID); err != nil {
+	if ctx, err = query.AddCriteria(ctx, byPlatformID, activeOnly); err != nil {
 		return nil, err
 	}
 	req.Request = req.WithContext(ctx)
 
-	return next.Handle(req)
+	resp, err := next.Handle(req)
+	if err != nil || resp.StatusCode != http.StatusOK || !gjson.GetBytes(resp.Body, "items").IsArray() {
+		return resp, err
+	}
+	if resp.Body, err = f.expandOfferingVisibilities(req, resp.Body); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// expandOfferingVisibilities replaces each listed service offering visibility with one visibility for each plan of the
+// service offering which it applies to, as platforms are notified about them. The expanded visibilities have ids
+// <visibility id>:<plan id>, so the number of items may differ from the number of stored visibilities.
+func (f *PlatformAwareVisibilityFilter) expandOfferingVisibilities(req *web.Request, body []byte) ([]byte, error) {
+	ctx := req.Context()
+	items := gjson.GetBytes(body, "items").Array()
+	offeringPlans := make(map[string][]*types.ServicePlan)
+	expanded := false
+	result := make([]json.RawMessage, 0, len(items))
+	for _, item := range items {
+		if len(item.Get("service_offering_id").String()) == 0 {
+			result = append(result, json.RawMessage(item.Raw))
+			continue
+		}
+		expanded = true
+
+		visibility := &types.Visibility{}
+		if err := json.Unmarshal([]byte(item.Raw), visibility); err != nil {
+			return nil, err
+		}
+		plans, found := offeringPlans[visibility.ServiceOfferingID]
+		if !found {
+			objectList, err := f.repository.List(ctx, types.ServicePlanType,
+				query.ByField(query.EqualsOperator, "service_offering_id", visibility.ServiceOfferingID))
+			if err != nil {
+				return nil, util.HandleStorageError(err, string(types.ServicePlanType))
+			}
+			plans = objectList.(*types.ServicePlans).ServicePlans
+			offeringPlans[visibility.ServiceOfferingID] = plans
+		}
+		for _, plan := range plans {
+			if !visibility.CoversPlan(plan) {
+				continue
+			}
+			planVisibility, err := json.Marshal(visibility.ForPlan(plan.ID))
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, planVisibility)
+		}
+	}
+	if !expanded {
+		return body, nil
+	}
+
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return sjson.SetRawBytes(body, "items", resultBytes)
 }
 
 func (*PlatformAwareVisibilityFilter) FilterMatchers() []web.FilterMatcher {
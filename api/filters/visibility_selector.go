@@ -0,0 +1,74 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package filters
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/pkg/web"
+	"github.com/tidwall/gjson"
+)
+
+// VisibilitySelectorFilterName is the name of the visibility selector filter
+const VisibilitySelectorFilterName = "VisibilitySelectorFilter"
+
+// VisibilitySelectorFilter rejects visibilities with selectors which are not valid label queries
+type VisibilitySelectorFilter struct {
+}
+
+// Name returns the name of the filter
+func (*VisibilitySelectorFilter) Name() string {
+	return VisibilitySelectorFilterName
+}
+
+// Run verifies the selector of the visibility in the request body
+func (*VisibilitySelectorFilter) Run(req *web.Request, next web.Handler) (*web.Response, error) {
+	selector := gjson.GetBytes(req.Body, "selector")
+	if !selector.Exists() || len(selector.String()) == 0 {
+		return next.Handle(req)
+	}
+	if selector.Type != gjson.String {
+		return nil, &util.HTTPError{
+			ErrorType:   "BadRequest",
+			Description: "visibility selector should be a string",
+			StatusCode:  http.StatusBadRequest,
+		}
+	}
+	if _, err := query.ParseSelector(selector.String()); err != nil {
+		return nil, &util.HTTPError{
+			ErrorType:   "BadRequest",
+			Description: fmt.Sprintf("invalid visibility selector: %s", err),
+			StatusCode:  http.StatusBadRequest,
+		}
+	}
+	return next.Handle(req)
+}
+
+// FilterMatchers returns the visibility create and update matchers
+func (*VisibilitySelectorFilter) FilterMatchers() []web.FilterMatcher {
+	return []web.FilterMatcher{
+		{
+			Matchers: []web.Matcher{
+				web.Path(web.VisibilitiesURL + "/**"),
+				web.Methods(http.MethodPost, http.MethodPatch),
+			},
+		},
+	}
+}
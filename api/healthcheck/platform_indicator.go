@@ -67,7 +67,8 @@ func (pi *platformIndicator) Status() (interface{}, error) {
 		} else {
 			details[platform.Name] = health.New().WithStatus(health.StatusDown).
 				WithDetail("since", platform.LastActive).
-				WithDetail("type", platform.Type)
+				WithDetail("type", platform.Type).
+				WithDetail("suspended", platform.Suspended)
 			inactivePlatforms++
 			if pi.fatal(platform) {
 				fatalInactivePlatforms++
@@ -44,7 +44,7 @@ func (c *Controller) Routes() []web.Route {
 				Method: http.MethodGet,
 				Path:   web.NotificationsURL,
 			},
-			Handler: c.handleWS,
+			Handler: c.handleNotifications,
 		},
 	}
 }
@@ -24,12 +24,19 @@ const (
 	LastKnownRevisionQueryParam = "last_notification_revision"
 )
 
-func (c *Controller) handleWS(req *web.Request) (*web.Response, error) {
+func (c *Controller) handleNotifications(req *web.Request) (*web.Response, error) {
 	ctx := req.Context()
 	logger := log.C(ctx)
 
 	revisionKnownToProxy := types.InvalidRevision
 	revisionKnownToProxyStr := req.URL.Query().Get(LastKnownRevisionQueryParam)
+	revisionSource := fmt.Sprintf("%s query parameter", LastKnownRevisionQueryParam)
+	isEventStream := isEventStreamRequest(req.Request)
+	if lastEventID := req.Header.Get(LastEventIDHeader); isEventStream && lastEventID != "" {
+		// clients of event streams send the id of the last received event when reconnecting
+		revisionKnownToProxyStr = lastEventID
+		revisionSource = fmt.Sprintf("%s header", LastEventIDHeader)
+	}
 	if revisionKnownToProxyStr != "" {
 		var err error
 		revisionKnownToProxy, err = strconv.ParseInt(revisionKnownToProxyStr, 10, 64)
@@ -37,7 +44,7 @@ func (c *Controller) handleWS(req *web.Request) (*web.Response, error) {
 			logger.Errorf("could not convert string %s to number: %v", revisionKnownToProxyStr, err)
 			return nil, &util.HTTPError{
 				StatusCode:  http.StatusBadRequest,
-				Description: fmt.Sprintf("invalid %s query parameter", LastKnownRevisionQueryParam),
+				Description: fmt.Sprintf("invalid %s", revisionSource),
 				ErrorType:   "BadRequest",
 			}
 		}
@@ -60,7 +67,20 @@ func (c *Controller) handleWS(req *web.Request) (*web.Response, error) {
 		return nil, err
 	}
 
-	correlationID := logger.Data[log.FieldCorrelationID].(string)
+	responseHeaders := http.Header{}
+	if lastKnownToSMRevision != types.InvalidRevision {
+		responseHeaders.Add(LastKnownRevisionHeader, strconv.FormatInt(lastKnownToSMRevision, 10))
+	}
+
+	if isEventStream {
+		return c.handleEventStream(req, platform, notificationQueue, responseHeaders)
+	}
+	return c.handleWS(req, platform, notificationQueue, responseHeaders)
+}
+
+func (c *Controller) handleWS(req *web.Request, platform *types.Platform, notificationQueue storage.NotificationQueue, responseHeaders http.Header) (*web.Response, error) {
+	ctx := req.Context()
+	correlationID := log.C(ctx).Data[log.FieldCorrelationID].(string)
 	childCtx, childCtxCancel := newContextWithCorrelationID(c.baseCtx, correlationID)
 
 	defer func() {
@@ -70,10 +90,6 @@ func (c *Controller) handleWS(req *web.Request) (*web.Response, error) {
 	}()
 
 	rw := req.HijackResponseWriter()
-	responseHeaders := http.Header{}
-	if lastKnownToSMRevision != types.InvalidRevision {
-		responseHeaders.Add(LastKnownRevisionHeader, strconv.FormatInt(lastKnownToSMRevision, 10))
-	}
 
 	conn, err := c.upgrade(childCtx, c.repository, platform, rw, req.Request, responseHeaders)
 	if err != nil {
@@ -202,6 +218,8 @@ func updatePlatformStatus(ctx context.Context, repository storage.TransactionalR
 			platform.Active = desiredStatus
 			if !platform.Active {
 				platform.LastActive = time.Now()
+			} else {
+				platform.Suspended = false
 			}
 
 			if _, err := storage.Update(ctx, platform, nil); err != nil {
@@ -0,0 +1,240 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"time"
+
+	"github.com/Peripli/service-manager/pkg/log"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/web"
+	"github.com/Peripli/service-manager/storage"
+)
+
+const (
+	EventStreamContentType = "text/event-stream"
+	LastEventIDHeader      = "Last-Event-ID"
+	HeartbeatPeriodHeader  = "X-Heartbeat-Period"
+)
+
+// eventStream writes server-sent events to a hijacked connection using chunked transfer encoding
+type eventStream struct {
+	conn         net.Conn
+	writer       io.Writer
+	writeTimeout time.Duration
+}
+
+func (s *eventStream) write(data []byte) error {
+	if err := s.conn.SetWriteDeadline(time.Now().Add(s.writeTimeout)); err != nil {
+		return err
+	}
+	_, err := s.writer.Write(data)
+	return err
+}
+
+func isEventStreamRequest(req *http.Request) bool {
+	for _, accept := range req.Header[http.CanonicalHeaderKey("Accept")] {
+		if strings.Contains(accept, EventStreamContentType) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Controller) handleEventStream(req *web.Request, platform *types.Platform, notificationQueue storage.NotificationQueue, responseHeaders http.Header) (*web.Response, error) {
+	ctx := req.Context()
+	correlationID := log.C(ctx).Data[log.FieldCorrelationID].(string)
+	childCtx, childCtxCancel := newContextWithCorrelationID(c.baseCtx, correlationID)
+
+	defer func() {
+		if err := recover(); err != nil {
+			log.C(childCtx).Errorf("recovered from panic while establishing event stream: %s", err)
+		}
+	}()
+
+	rw := req.HijackResponseWriter()
+
+	stream, err := c.openEventStream(childCtx, rw, responseHeaders)
+	if err != nil {
+		childCtxCancel()
+		c.unregisterConsumer(ctx, notificationQueue)
+		return nil, err
+	}
+
+	done := make(chan struct{}, 2)
+
+	go c.closeEventStream(childCtx, childCtxCancel, stream, done)
+	go c.eventStreamWriteLoop(childCtx, c.repository, platform, stream, notificationQueue, done)
+	go c.eventStreamReadLoop(childCtx, c.repository, platform, stream, done)
+
+	return &web.Response{}, nil
+}
+
+// openEventStream takes over the connection of the response writer so that the event stream is not limited by the
+// request timeout of the server and writes the response headers
+func (c *Controller) openEventStream(ctx context.Context, rw http.ResponseWriter, header http.Header) (*eventStream, error) {
+	hijacker, ok := rw.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("response writer does not support event streams")
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := c.startEventStream(conn, header)
+	if err != nil {
+		if closeErr := conn.Close(); closeErr != nil {
+			log.C(ctx).WithError(closeErr).Error("Could not close event stream connection")
+		}
+		return nil, err
+	}
+	return stream, nil
+}
+
+func (c *Controller) startEventStream(conn net.Conn, header http.Header) (*eventStream, error) {
+	// the platform is not expected to send anything, so instead of a read deadline like the ping timeout of websockets
+	// the liveness of the platform is checked through the acknowledgement of the heartbeats within the ping timeout
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	if err := setLivenessTimeout(conn, c.wsSettings.PingTimeout); err != nil {
+		return nil, err
+	}
+
+	header.Set("Content-Type", EventStreamContentType)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Transfer-Encoding", "chunked")
+	header.Set(HeartbeatPeriodHeader, c.wsSettings.HeartbeatInterval.String())
+
+	response := &bytes.Buffer{}
+	response.WriteString(fmt.Sprintf("HTTP/1.1 %d %s\r\n", http.StatusOK, http.StatusText(http.StatusOK)))
+	if err := header.Write(response); err != nil {
+		return nil, err
+	}
+	response.WriteString("\r\n")
+
+	stream := &eventStream{
+		conn:         conn,
+		writer:       conn,
+		writeTimeout: c.wsSettings.WriteTimeout,
+	}
+	if err := stream.write(response.Bytes()); err != nil {
+		return nil, err
+	}
+	stream.writer = httputil.NewChunkedWriter(conn)
+
+	return stream, nil
+}
+
+func (c *Controller) eventStreamWriteLoop(ctx context.Context, repository storage.TransactionalRepository, platform *types.Platform, stream *eventStream, q storage.NotificationQueue, done chan<- struct{}) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.C(ctx).Errorf("recovered from panic while writing to event stream: %s", err)
+		}
+	}()
+
+	defer func() {
+		done <- struct{}{}
+	}()
+	defer c.unregisterConsumer(ctx, q)
+
+	// the platform is active while the event stream is open, the same way as while its websocket is connected,
+	// so its status is updated only when the stream is opened and when it fails
+	if err := updatePlatformStatus(ctx, repository, platform.ID, true); err != nil {
+		log.C(ctx).WithError(err).Error("could not update platform status")
+	}
+
+	heartbeats := time.NewTicker(c.wsSettings.HeartbeatInterval)
+	defer heartbeats.Stop()
+
+	notificationChannel := q.Channel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.C(ctx).Infof("Event stream shutting down")
+			return
+		case <-heartbeats.C:
+			if err := stream.write([]byte(": heartbeat\n\n")); err != nil {
+				log.C(ctx).WithError(err).Error("sse: could not write heartbeat")
+				if err = updatePlatformStatus(ctx, repository, platform.ID, false); err != nil {
+					log.C(ctx).WithError(err).Error("could not update platform status")
+				}
+				return
+			}
+		case notification, ok := <-notificationChannel:
+			if !ok {
+				log.C(ctx).Infof("Notifications channel is closed. Closing event stream...")
+				return
+			}
+
+			if err := c.sendEvent(stream, notification); err != nil {
+				log.C(ctx).WithError(err).Error("sse: could not send notification")
+				if err = updatePlatformStatus(ctx, repository, platform.ID, false); err != nil {
+					log.C(ctx).WithError(err).Error("could not update platform status")
+				}
+				return
+			}
+		}
+	}
+}
+
+func (c *Controller) eventStreamReadLoop(ctx context.Context, repository storage.TransactionalRepository, platform *types.Platform, stream *eventStream, done chan<- struct{}) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.C(ctx).Errorf("recovered from panic while reading from event stream: %s", err)
+		}
+	}()
+
+	defer func() {
+		done <- struct{}{}
+	}()
+
+	buffer := make([]byte, 512)
+	for {
+		// reading is needed only to detect when the platform closes the connection
+		// currently we don't expect to receive anything from the proxies
+		if _, err := stream.conn.Read(buffer); err != nil {
+			log.C(ctx).WithError(err).Error("sse: could not read")
+			if err = updatePlatformStatus(ctx, repository, platform.ID, false); err != nil {
+				log.C(ctx).WithError(err).Error("could not update platform status")
+			}
+			return
+		}
+	}
+}
+
+func (c *Controller) sendEvent(stream *eventStream, notification *types.Notification) error {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	// the revision is the id of the event so that the platform can resume from it with the Last-Event-ID header
+	event := fmt.Sprintf("id: %d\ndata: %s\n\n", notification.Revision, data)
+	return stream.write([]byte(event))
+}
+
+func (c *Controller) closeEventStream(ctx context.Context, cancel context.CancelFunc, stream *eventStream, done <-chan struct{}) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.C(ctx).Errorf("recovered from panic while closing event stream: %s", err)
+		}
+	}()
+	defer cancel()
+	// if base context is cancelled, write loop will quit and write to done
+	<-done
+
+	if err := stream.conn.Close(); err != nil {
+		log.C(ctx).WithError(err).Error("Could not close event stream connection")
+	}
+}
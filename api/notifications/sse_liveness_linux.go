@@ -0,0 +1,31 @@
+//go:build linux
+// +build linux
+
+package notifications
+
+import (
+	"net"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// setLivenessTimeout makes the kernel drop the connection if sent data stays unacknowledged by the peer for longer
+// than the timeout, so that heartbeats to a platform which vanished without closing the connection fail in time
+func setLivenessTimeout(conn net.Conn, timeout time.Duration) error {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+	rawConn, err := tcpConn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_USER_TIMEOUT, int(timeout/time.Millisecond))
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
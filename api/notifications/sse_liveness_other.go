@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package notifications
+
+import (
+	"net"
+	"time"
+)
+
+// setLivenessTimeout is not supported on this platform. Platforms which vanish without closing the connection are
+// detected when the heartbeats can no longer be written.
+func setLivenessTimeout(conn net.Conn, timeout time.Duration) error {
+	return nil
+}
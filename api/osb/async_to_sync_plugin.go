@@ -0,0 +1,261 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Peripli/service-manager/pkg/log"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/pkg/web"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// AsyncToSyncPluginName is the name of the async to sync plugin
+	AsyncToSyncPluginName = "AsyncToSyncPlugin"
+
+	// SyncOSBPlatformLabel is the platform label which marks platforms that support only synchronous OSB operations
+	SyncOSBPlatformLabel = "sync_osb"
+)
+
+type asyncToSyncPlugin struct {
+	api          *web.API
+	pollInterval time.Duration
+	timeout      time.Duration
+}
+
+// NewAsyncToSyncPlugin creates new plugin that bridges asynchronous broker operations for platforms which support only
+// synchronous OSB operations. For such platforms the requests are sent to the broker with accepts_incomplete=true and the
+// last operation of the broker is polled through the Service Manager OSB API until the operation is finished or the timeout
+// is reached. Polling through the API ensures that the operations are recorded by the rest of the OSB plugins.
+func NewAsyncToSyncPlugin(api *web.API, pollInterval, timeout time.Duration) *asyncToSyncPlugin {
+	return &asyncToSyncPlugin{
+		api:          api,
+		pollInterval: pollInterval,
+		timeout:      timeout,
+	}
+}
+
+// Name returns the name of the plugin
+func (p *asyncToSyncPlugin) Name() string {
+	return AsyncToSyncPluginName
+}
+
+// Provision intercepts provision requests and waits for asynchronous provisioning to finish
+func (p *asyncToSyncPlugin) Provision(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.bridge(req, next, serviceInstanceLastOperationURL, http.StatusCreated)
+}
+
+// UpdateService intercepts update service instance requests and waits for asynchronous updates to finish
+func (p *asyncToSyncPlugin) UpdateService(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.bridge(req, next, serviceInstanceLastOperationURL, http.StatusOK)
+}
+
+// Deprovision intercepts deprovision requests and waits for asynchronous deprovisioning to finish
+func (p *asyncToSyncPlugin) Deprovision(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.bridge(req, next, serviceInstanceLastOperationURL, http.StatusOK)
+}
+
+// Bind intercepts bind requests and waits for asynchronous binding to finish
+func (p *asyncToSyncPlugin) Bind(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.bridge(req, next, serviceBindingLastOperationURL, http.StatusCreated)
+}
+
+// Unbind intercepts unbind requests and waits for asynchronous unbinding to finish
+func (p *asyncToSyncPlugin) Unbind(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.bridge(req, next, serviceBindingLastOperationURL, http.StatusOK)
+}
+
+func (p *asyncToSyncPlugin) bridge(req *web.Request, next web.Handler, lastOperationRoute string, syncStatusCode int) (*web.Response, error) {
+	ctx := req.Context()
+	platform, err := extractPlatformFromContext(ctx)
+	if err != nil || !isSyncOSBPlatform(platform) {
+		return next.Handle(req)
+	}
+
+	queryParams := req.URL.Query()
+	if queryParams.Get("accepts_incomplete") == "true" {
+		return next.Handle(req)
+	}
+	queryParams.Set("accepts_incomplete", "true")
+	req.URL.RawQuery = queryParams.Encode()
+
+	resp, err := next.Handle(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		return resp, nil
+	}
+
+	log.C(ctx).Infof("Broker responded asynchronously to %s %s. Polling last operation on behalf of synchronous platform %s...", req.Method, req.URL.Path, platform.ID)
+	if err := p.waitForOperation(req, lastOperationRoute, gjson.GetBytes(resp.Body, "operation").String()); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case req.Method == http.MethodDelete:
+		return util.NewJSONResponse(syncStatusCode, map[string]interface{}{})
+	case lastOperationRoute == serviceBindingLastOperationURL:
+		return p.fetchBinding(req)
+	default:
+		body, err := sjson.DeleteBytes(resp.Body, "operation")
+		if err != nil {
+			return nil, err
+		}
+		return &web.Response{
+			StatusCode: syncStatusCode,
+			Header:     resp.Header,
+			Body:       body,
+		}, nil
+	}
+}
+
+func (p *asyncToSyncPlugin) waitForOperation(req *web.Request, lastOperationRoute, operation string) error {
+	ctx := req.Context()
+	pollHandler, err := p.routeHandler(http.MethodGet, lastOperationRoute)
+	if err != nil {
+		return err
+	}
+
+	queryParams := url.Values{}
+	for _, param := range []string{"service_id", "plan_id"} {
+		if value := gjson.GetBytes(req.Body, param).String(); len(value) != 0 {
+			queryParams.Set(param, value)
+		} else if value := req.URL.Query().Get(param); len(value) != 0 {
+			queryParams.Set(param, value)
+		}
+	}
+	if len(operation) != 0 {
+		queryParams.Set("operation", operation)
+	}
+
+	deadline := time.Now().Add(p.timeout)
+	for {
+		pollRequest, err := newSubRequest(req, req.URL.Path+"/last_operation", queryParams)
+		if err != nil {
+			return err
+		}
+		resp, err := pollHandler.Handle(pollRequest)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode == http.StatusGone && req.Method == http.MethodDelete {
+			return nil
+		}
+		if resp.StatusCode != http.StatusOK {
+			return &util.HTTPError{
+				ErrorType:   "ServiceBrokerErr",
+				Description: fmt.Sprintf("polling last operation failed with status %d: %s", resp.StatusCode, gjson.GetBytes(resp.Body, "description").String()),
+				StatusCode:  http.StatusBadGateway,
+			}
+		}
+
+		switch types.OperationState(gjson.GetBytes(resp.Body, "state").String()) {
+		case types.SUCCEEDED:
+			return nil
+		case types.FAILED:
+			return &util.HTTPError{
+				ErrorType:   "ServiceBrokerErr",
+				Description: fmt.Sprintf("asynchronous operation failed: %s", gjson.GetBytes(resp.Body, "description").String()),
+				StatusCode:  http.StatusBadGateway,
+			}
+		}
+
+		if time.Now().Add(p.pollInterval).After(deadline) {
+			return &util.HTTPError{
+				ErrorType:   "ServiceBrokerErr",
+				Description: fmt.Sprintf("asynchronous operation did not finish within %s", p.timeout),
+				StatusCode:  http.StatusGatewayTimeout,
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(p.pollInterval):
+		}
+	}
+}
+
+func (p *asyncToSyncPlugin) fetchBinding(req *web.Request) (*web.Response, error) {
+	fetchHandler, err := p.routeHandler(http.MethodGet, serviceBindingURL)
+	if err != nil {
+		return nil, err
+	}
+	fetchRequest, err := newSubRequest(req, req.URL.Path, url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := fetchHandler.Handle(fetchRequest)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	resp.StatusCode = http.StatusCreated
+	return resp, nil
+}
+
+// routeHandler returns the handler of the Service Manager API route with the specified method and path
+// chained with all filters and plugins matching the route
+func (p *asyncToSyncPlugin) routeHandler(method, path string) (web.Handler, error) {
+	for _, controller := range p.api.Controllers {
+		for _, route := range controller.Routes() {
+			if route.Endpoint.Method == method && route.Endpoint.Path == path {
+				return web.Filters(p.api.Filters).ChainMatching(route), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("could not find route %s %s", method, path)
+}
+
+// newSubRequest creates a GET request towards the specified path which is issued on behalf of the original request
+func newSubRequest(req *web.Request, path string, queryParams url.Values) (*web.Request, error) {
+	subRequestURL := *req.URL
+	subRequestURL.Path = path
+	subRequestURL.RawQuery = queryParams.Encode()
+	httpRequest, err := http.NewRequest(http.MethodGet, subRequestURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for header, values := range req.Header {
+		httpRequest.Header[header] = append([]string{}, values...)
+	}
+	httpRequest.Header.Del("Content-Type")
+
+	pathParams := make(map[string]string, len(req.PathParams))
+	for key, value := range req.PathParams {
+		pathParams[key] = value
+	}
+	return &web.Request{
+		Request:    httpRequest.WithContext(req.Context()),
+		PathParams: pathParams,
+	}, nil
+}
+
+func isSyncOSBPlatform(platform *types.Platform) bool {
+	values, found := platform.Labels[SyncOSBPlatformLabel]
+	return found && len(values) != 0 && values[0] == "true"
+}
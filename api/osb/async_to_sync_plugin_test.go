@@ -0,0 +1,137 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Peripli/service-manager/api/osb"
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/pkg/web"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type routesController []web.Route
+
+func (c routesController) Routes() []web.Route {
+	return c
+}
+
+var _ = Describe("Async to sync plugin", func() {
+	const instancePath = web.OSBURL + "/broker-id/v2/service_instances/instance-id"
+
+	var (
+		platformLabels  string
+		lastOpResponses []string
+		lastOpQueries   []string
+		brokerRequest   *web.Request
+		plugin          web.Provisioner
+	)
+
+	provision := func() (*web.Response, error) {
+		httpRequest, err := http.NewRequest(http.MethodPut, instancePath, strings.NewReader(""))
+		Expect(err).ToNot(HaveOccurred())
+		user := &web.UserContext{
+			AuthenticationType: web.Basic,
+			Name:               "platform-user",
+			Data: func(data interface{}) error {
+				return json.Unmarshal([]byte(`{"id":"platform-id","name":"platform","type":"cloudfoundry","labels":`+platformLabels+`}`), data)
+			},
+		}
+		request := &web.Request{
+			Request:    httpRequest.WithContext(web.ContextWithUser(httpRequest.Context(), user)),
+			PathParams: map[string]string{osb.BrokerIDPathParam: "broker-id", osb.InstanceIDPathParam: "instance-id"},
+			Body:       []byte(`{"service_id":"service-id","plan_id":"plan-id"}`),
+		}
+		return plugin.Provision(request, web.HandlerFunc(func(req *web.Request) (*web.Response, error) {
+			brokerRequest = req
+			return &web.Response{
+				StatusCode: http.StatusAccepted,
+				Header:     http.Header{},
+				Body:       []byte(`{"dashboard_url":"http://dashboard","operation":"op-id"}`),
+			}, nil
+		}))
+	}
+
+	BeforeEach(func() {
+		platformLabels = `{"sync_osb":["true"]}`
+		lastOpResponses = []string{`{"state":"in progress"}`, `{"state":"succeeded"}`}
+		lastOpQueries = nil
+		brokerRequest = nil
+
+		api := &web.API{
+			Controllers: []web.Controller{routesController{
+				{
+					Endpoint: web.Endpoint{Method: http.MethodGet, Path: web.OSBURL + "/{brokerID}/v2/service_instances/{instance_id}/last_operation"},
+					Handler: func(req *web.Request) (*web.Response, error) {
+						lastOpQueries = append(lastOpQueries, req.URL.RawQuery)
+						body := lastOpResponses[0]
+						lastOpResponses = lastOpResponses[1:]
+						return &web.Response{StatusCode: http.StatusOK, Body: []byte(body)}, nil
+					},
+				},
+			}},
+		}
+		plugin = osb.NewAsyncToSyncPlugin(api, time.Millisecond, time.Second)
+	})
+
+	Context("when platform supports only synchronous operations", func() {
+		It("polls the last operation and responds synchronously", func() {
+			resp, err := provision()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(brokerRequest.URL.Query().Get("accepts_incomplete")).To(Equal("true"))
+			Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+			Expect(string(resp.Body)).To(MatchJSON(`{"dashboard_url":"http://dashboard"}`))
+			Expect(lastOpQueries).To(HaveLen(2))
+			Expect(lastOpQueries[0]).To(Equal("operation=op-id&plan_id=plan-id&service_id=service-id"))
+		})
+
+		It("returns error when the operation fails", func() {
+			lastOpResponses = []string{`{"state":"failed","description":"no capacity"}`}
+			_, err := provision()
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*util.HTTPError).StatusCode).To(Equal(http.StatusBadGateway))
+			Expect(err.Error()).To(ContainSubstring("no capacity"))
+		})
+
+		It("returns error when the operation does not finish in time", func() {
+			lastOpResponses = make([]string, 2000)
+			for i := range lastOpResponses {
+				lastOpResponses[i] = `{"state":"in progress"}`
+			}
+			_, err := provision()
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*util.HTTPError).StatusCode).To(Equal(http.StatusGatewayTimeout))
+		})
+	})
+
+	Context("when platform supports asynchronous operations", func() {
+		It("returns the broker response", func() {
+			platformLabels = `{}`
+			resp, err := provision()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(brokerRequest.URL.Query().Get("accepts_incomplete")).To(BeEmpty())
+			Expect(resp.StatusCode).To(Equal(http.StatusAccepted))
+			Expect(lastOpQueries).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,133 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Peripli/service-manager/pkg/httpclient"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/util"
+)
+
+// DoRequestFuncProvider provides the function through which requests towards the specified broker should be sent
+type DoRequestFuncProvider func(broker *types.ServiceBroker) (util.DoRequestFunc, error)
+
+// BrokerClients builds and caches the http clients used for the calls towards the service brokers.
+// Brokers which do not specify transport settings are called using http.DefaultClient. Calls towards brokers
+// which specify failover urls are sent to the first available broker endpoint. If a broker url policy is provided,
+// calls towards urls and addresses forbidden by the policy are rejected.
+type BrokerClients struct {
+	cache  *httpclient.ClientCache
+	health *endpointsHealth
+	policy *BrokerURLPolicy
+}
+
+// NewBrokerClients creates broker clients which are built on top of the provided global httpclient settings
+func NewBrokerClients(settings *httpclient.Settings, policy *BrokerURLPolicy) *BrokerClients {
+	return &BrokerClients{
+		cache:  httpclient.NewClientCache(settings),
+		health: newEndpointsHealth(),
+		policy: policy,
+	}
+}
+
+// Client returns the http client that should be used for calls towards the specified broker
+func (bc *BrokerClients) Client(broker *types.ServiceBroker) (*http.Client, error) {
+	if bc == nil {
+		return http.DefaultClient, nil
+	}
+	client, err := bc.transportClient(broker)
+	if err != nil {
+		return nil, err
+	}
+	if len(broker.FailoverURLs) == 0 {
+		return client, nil
+	}
+
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &http.Client{
+		Transport: &failoverTransport{
+			base:   base,
+			broker: broker,
+			health: bc.health,
+		},
+		Timeout: client.Timeout,
+	}, nil
+}
+
+func (bc *BrokerClients) transportClient(broker *types.ServiceBroker) (*http.Client, error) {
+	client, err := bc.settingsClient(broker)
+	if err != nil || bc.policy == nil {
+		return client, err
+	}
+
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &http.Client{
+		Transport: &brokerURLPolicyTransport{
+			base:   base,
+			policy: bc.policy,
+		},
+		Timeout: client.Timeout,
+	}, nil
+}
+
+func (bc *BrokerClients) settingsClient(broker *types.ServiceBroker) (*http.Client, error) {
+	if broker.TransportSettings == nil {
+		// drops the client cached while the broker had transport settings
+		return bc.cache.Get(broker.ID, nil)
+	}
+
+	timeout, err := broker.TransportSettings.Timeout()
+	if err != nil {
+		return nil, fmt.Errorf("invalid transport settings for broker %s: %s", broker.Name, err)
+	}
+	client, err := bc.cache.Get(broker.ID, &httpclient.TransportSettings{
+		RequestTimeout:    timeout,
+		CACertificates:    broker.TransportSettings.CACertificates,
+		SkipSSLValidation: broker.TransportSettings.SkipSSLValidation,
+		ProxyURL:          broker.TransportSettings.ProxyURL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not build http client for broker %s: %s", broker.Name, err)
+	}
+	return client, nil
+}
+
+// DoRequestFunc implements DoRequestFuncProvider and returns the Do function of the client for the specified broker
+func (bc *BrokerClients) DoRequestFunc(broker *types.ServiceBroker) (util.DoRequestFunc, error) {
+	client, err := bc.Client(broker)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do, nil
+}
+
+// Evict removes the cached client for the broker with the specified id
+func (bc *BrokerClients) Evict(brokerID string) {
+	if bc == nil {
+		return
+	}
+	bc.cache.Remove(brokerID)
+}
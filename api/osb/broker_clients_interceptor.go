@@ -0,0 +1,84 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"context"
+
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/storage"
+)
+
+// BrokerClientsUpdateInterceptorProvider provides an interceptor which evicts the cached clients of updated brokers
+type BrokerClientsUpdateInterceptorProvider struct {
+	Clients *BrokerClients
+}
+
+// Name returns the name of the provider
+func (*BrokerClientsUpdateInterceptorProvider) Name() string {
+	return "BrokerClientsUpdateInterceptorProvider"
+}
+
+// Provide returns the interceptor
+func (p *BrokerClientsUpdateInterceptorProvider) Provide() storage.UpdateOnTxInterceptor {
+	return &brokerClientsEvictionInterceptor{clients: p.Clients}
+}
+
+// BrokerClientsDeleteInterceptorProvider provides an interceptor which evicts the cached clients of deleted brokers
+type BrokerClientsDeleteInterceptorProvider struct {
+	Clients *BrokerClients
+}
+
+// Name returns the name of the provider
+func (*BrokerClientsDeleteInterceptorProvider) Name() string {
+	return "BrokerClientsDeleteInterceptorProvider"
+}
+
+// Provide returns the interceptor
+func (p *BrokerClientsDeleteInterceptorProvider) Provide() storage.DeleteOnTxInterceptor {
+	return &brokerClientsEvictionInterceptor{clients: p.Clients}
+}
+
+type brokerClientsEvictionInterceptor struct {
+	clients *BrokerClients
+}
+
+// OnTxUpdate evicts the cached client of the broker so that the next call is sent with its current transport settings
+func (i *brokerClientsEvictionInterceptor) OnTxUpdate(h storage.InterceptUpdateOnTxFunc) storage.InterceptUpdateOnTxFunc {
+	return func(ctx context.Context, txStorage storage.Repository, oldObj, newObj types.Object, labelChanges ...*query.LabelChange) (types.Object, error) {
+		updatedObj, err := h(ctx, txStorage, oldObj, newObj, labelChanges...)
+		if err != nil {
+			return nil, err
+		}
+		i.clients.Evict(updatedObj.GetID())
+		return updatedObj, nil
+	}
+}
+
+// OnTxDelete evicts the cached clients of the deleted brokers
+func (i *brokerClientsEvictionInterceptor) OnTxDelete(h storage.InterceptDeleteOnTxFunc) storage.InterceptDeleteOnTxFunc {
+	return func(ctx context.Context, txStorage storage.Repository, objects types.ObjectList, deletionCriteria ...query.Criterion) error {
+		if err := h(ctx, txStorage, objects, deletionCriteria...); err != nil {
+			return err
+		}
+		for j := 0; j < objects.Len(); j++ {
+			i.clients.Evict(objects.ItemAt(j).GetID())
+		}
+		return nil
+	}
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"context"
+
+	"github.com/Peripli/service-manager/pkg/httpclient"
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/storage"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Broker clients eviction", func() {
+	var (
+		clients *BrokerClients
+		broker  *types.ServiceBroker
+	)
+
+	BeforeEach(func() {
+		clients = NewBrokerClients(httpclient.DefaultSettings(), nil)
+		broker = &types.ServiceBroker{
+			Base:              types.Base{ID: "broker-id"},
+			Name:              "broker",
+			TransportSettings: &types.BrokerTransportSettings{RequestTimeout: "5s"},
+		}
+	})
+
+	cachedClientIsReused := func() bool {
+		client, err := clients.Client(broker)
+		Expect(err).ToNot(HaveOccurred())
+		again, err := clients.Client(broker)
+		Expect(err).ToNot(HaveOccurred())
+		return client == again
+	}
+
+	It("evicts the client of updated brokers", func() {
+		Expect(cachedClientIsReused()).To(BeTrue())
+		client, _ := clients.Client(broker)
+
+		interceptor := (&BrokerClientsUpdateInterceptorProvider{Clients: clients}).Provide()
+		_, err := interceptor.OnTxUpdate(func(ctx context.Context, txStorage storage.Repository, oldObj, newObj types.Object, labelChanges ...*query.LabelChange) (types.Object, error) {
+			return newObj, nil
+		})(context.Background(), nil, broker, broker)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(clients.Client(broker)).ToNot(BeIdenticalTo(client))
+	})
+
+	It("evicts the clients of deleted brokers", func() {
+		client, _ := clients.Client(broker)
+
+		interceptor := (&BrokerClientsDeleteInterceptorProvider{Clients: clients}).Provide()
+		err := interceptor.OnTxDelete(func(ctx context.Context, txStorage storage.Repository, objects types.ObjectList, deletionCriteria ...query.Criterion) error {
+			return nil
+		})(context.Background(), nil, types.NewObjectArray(broker))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(clients.Client(broker)).ToNot(BeIdenticalTo(client))
+	})
+})
@@ -0,0 +1,218 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Peripli/service-manager/pkg/log"
+	"github.com/Peripli/service-manager/pkg/types"
+)
+
+// unhealthyEndpointCooldown is the period for which a failed broker endpoint is tried only after the healthy ones
+var unhealthyEndpointCooldown = 30 * time.Second
+
+type brokerEndpointKey struct{}
+
+// brokerEndpoint holds the endpoint that should be preferred for an OSB call and the endpoint that actually served it
+type brokerEndpoint struct {
+	Preferred string
+	Used      string
+}
+
+// contextWithBrokerEndpoint returns a context that tracks the broker endpoint used for the OSB call.
+// If the provided context already tracks the broker endpoint, it is returned unchanged.
+func contextWithBrokerEndpoint(ctx context.Context) (context.Context, *brokerEndpoint) {
+	if endpoint, ok := ctx.Value(brokerEndpointKey{}).(*brokerEndpoint); ok {
+		return ctx, endpoint
+	}
+	endpoint := &brokerEndpoint{}
+	return context.WithValue(ctx, brokerEndpointKey{}, endpoint), endpoint
+}
+
+// usedBrokerEndpoint returns the broker endpoint which served the OSB call tracked by the provided context
+func usedBrokerEndpoint(ctx context.Context) string {
+	if endpoint, ok := ctx.Value(brokerEndpointKey{}).(*brokerEndpoint); ok {
+		return endpoint.Used
+	}
+	return ""
+}
+
+// endpointsHealth keeps track of the broker endpoints which recently failed
+type endpointsHealth struct {
+	mutex          sync.RWMutex
+	unhealthyUntil map[string]time.Time
+}
+
+func newEndpointsHealth() *endpointsHealth {
+	return &endpointsHealth{
+		unhealthyUntil: make(map[string]time.Time),
+	}
+}
+
+func (h *endpointsHealth) isHealthy(endpoint string) bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	until, found := h.unhealthyUntil[endpoint]
+	return !found || time.Now().After(until)
+}
+
+func (h *endpointsHealth) markUnhealthy(endpoint string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.unhealthyUntil[endpoint] = time.Now().Add(unhealthyEndpointCooldown)
+}
+
+func (h *endpointsHealth) markHealthy(endpoint string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	delete(h.unhealthyUntil, endpoint)
+}
+
+// order returns the endpoints in the order in which they should be tried - the healthy endpoints keep their
+// configured order and are followed by the unhealthy ones
+func (h *endpointsHealth) order(endpoints []string) []string {
+	healthy := make([]string, 0, len(endpoints))
+	var unhealthy []string
+	for _, endpoint := range endpoints {
+		if h.isHealthy(endpoint) {
+			healthy = append(healthy, endpoint)
+		} else {
+			unhealthy = append(unhealthy, endpoint)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// failoverTransport sends the requests towards a broker to the first available broker endpoint
+type failoverTransport struct {
+	base   http.RoundTripper
+	broker *types.ServiceBroker
+	health *endpointsHealth
+}
+
+// RoundTrip implements http.RoundTripper and retries the request on the next broker endpoint if the current one cannot be reached.
+// Only idempotent requests are retried after the endpoint received them, the others are retried only if the connection failed.
+func (t *failoverTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	ctx := request.Context()
+	endpoints := t.health.order(t.broker.URLs())
+	if tracked, ok := ctx.Value(brokerEndpointKey{}).(*brokerEndpoint); ok && len(tracked.Preferred) != 0 {
+		endpoints = []string{tracked.Preferred}
+	}
+
+	var body []byte
+	if request.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(request.Body); err != nil {
+			return nil, err
+		}
+		request.Body.Close()
+	}
+
+	var lastErr error
+	for i, endpoint := range endpoints {
+		endpointRequest, err := rewriteToEndpoint(request, t.broker.BrokerURL, endpoint, body)
+		if err != nil {
+			return nil, err
+		}
+
+		log.C(ctx).Infof("Sending request %s %s to endpoint %s of broker %s", request.Method, request.URL.Path, endpoint, t.broker.Name)
+		response, err := t.base.RoundTrip(endpointRequest)
+		if err == nil && !isEndpointFailure(response.StatusCode) {
+			t.health.markHealthy(endpoint)
+			if tracked, ok := ctx.Value(brokerEndpointKey{}).(*brokerEndpoint); ok {
+				tracked.Used = endpoint
+			}
+			return response, nil
+		}
+
+		t.health.markUnhealthy(endpoint)
+		if i == len(endpoints)-1 || !canFailOver(request, err) {
+			if err == nil {
+				return response, nil
+			}
+			lastErr = err
+			break
+		}
+		if err == nil {
+			lastErr = fmt.Errorf("endpoint responded with status %d", response.StatusCode)
+			response.Body.Close()
+		} else {
+			lastErr = err
+		}
+		log.C(ctx).WithError(lastErr).Warnf("Endpoint %s of broker %s is unavailable. Failing over to endpoint %s...", endpoint, t.broker.Name, endpoints[i+1])
+	}
+
+	return nil, lastErr
+}
+
+func isEndpointFailure(statusCode int) bool {
+	return statusCode == http.StatusBadGateway || statusCode == http.StatusServiceUnavailable || statusCode == http.StatusGatewayTimeout
+}
+
+// canFailOver checks whether a request which failed on an endpoint can be sent to the next one without executing it twice
+func canFailOver(request *http.Request, err error) bool {
+	if request.Method == http.MethodGet {
+		return true
+	}
+	return err != nil && isDialError(err)
+}
+
+// isDialError checks whether the connection to the endpoint could not be established so nothing was sent to it
+func isDialError(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	opErr, ok := err.(*net.OpError)
+	return ok && opErr.Op == "dial"
+}
+
+// rewriteToEndpoint creates a copy of the request which is sent to the specified endpoint instead of the broker url
+func rewriteToEndpoint(request *http.Request, brokerURL, endpoint string, body []byte) (*http.Request, error) {
+	primaryURL, err := url.Parse(brokerURL)
+	if err != nil {
+		return nil, err
+	}
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	endpointRequest := request.WithContext(request.Context())
+	rewrittenURL := *request.URL
+	rewrittenURL.Scheme = endpointURL.Scheme
+	rewrittenURL.Host = endpointURL.Host
+	rewrittenURL.Path = strings.TrimSuffix(endpointURL.Path, "/") + strings.TrimPrefix(request.URL.Path, strings.TrimSuffix(primaryURL.Path, "/"))
+	rewrittenURL.RawPath = ""
+	endpointRequest.URL = &rewrittenURL
+	endpointRequest.Host = endpointURL.Host
+
+	if body != nil {
+		endpointRequest.Body = ioutil.NopCloser(bytes.NewReader(body))
+		endpointRequest.ContentLength = int64(len(body))
+	}
+	return endpointRequest, nil
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/Peripli/service-manager/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Broker endpoints", func() {
+	var (
+		primary, secondary *httptest.Server
+		primaryStatus      int
+		primaryRequests    int
+		secondaryBodies    []string
+		broker             *types.ServiceBroker
+		clients            *BrokerClients
+	)
+
+	BeforeEach(func() {
+		primaryStatus = http.StatusOK
+		primaryRequests = 0
+		secondaryBodies = nil
+		primary = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			primaryRequests++
+			w.WriteHeader(primaryStatus)
+		}))
+		secondary = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			secondaryBodies = append(secondaryBodies, r.URL.Path+" "+string(body))
+			w.WriteHeader(http.StatusOK)
+		}))
+		broker = &types.ServiceBroker{
+			Name:         "broker",
+			BrokerURL:    primary.URL + "/primary",
+			FailoverURLs: []string{secondary.URL + "/secondary"},
+		}
+		clients = &BrokerClients{health: newEndpointsHealth()}
+	})
+
+	AfterEach(func() {
+		primary.Close()
+		secondary.Close()
+	})
+
+	sendWithMethod := func(method string, ctx *brokerEndpoint) (*http.Response, error) {
+		client, err := clients.Client(broker)
+		Expect(err).ToNot(HaveOccurred())
+		request, err := http.NewRequest(method, broker.BrokerURL+"/v2/service_instances/1", strings.NewReader(`{"plan_id":"p"}`))
+		Expect(err).ToNot(HaveOccurred())
+		if ctx != nil {
+			reqCtx, tracked := contextWithBrokerEndpoint(request.Context())
+			*tracked = *ctx
+			request = request.WithContext(reqCtx)
+			defer func() { *ctx = *tracked }()
+		}
+		return client.Do(request)
+	}
+
+	send := func(ctx *brokerEndpoint) (*http.Response, error) {
+		return sendWithMethod(http.MethodPut, ctx)
+	}
+
+	It("sends the request to the primary endpoint when it is available", func() {
+		tracked := &brokerEndpoint{}
+		resp, err := send(tracked)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(primaryRequests).To(Equal(1))
+		Expect(secondaryBodies).To(BeEmpty())
+		Expect(tracked.Used).To(Equal(broker.BrokerURL))
+	})
+
+	It("fails over to the next endpoint when the primary cannot be connected to", func() {
+		primary.Close()
+		tracked := &brokerEndpoint{}
+		resp, err := send(tracked)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(secondaryBodies).To(Equal([]string{`/secondary/v2/service_instances/1 {"plan_id":"p"}`}))
+		Expect(tracked.Used).To(Equal(broker.FailoverURLs[0]))
+
+		By("preferring the healthy endpoint for subsequent requests")
+		_, err = send(nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(secondaryBodies).To(HaveLen(2))
+	})
+
+	It("fails over idempotent requests when the primary responds with an endpoint failure", func() {
+		primaryStatus = http.StatusServiceUnavailable
+		resp, err := sendWithMethod(http.MethodGet, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(primaryRequests).To(Equal(1))
+		Expect(secondaryBodies).To(HaveLen(1))
+	})
+
+	It("does not fail over non-idempotent requests which reached the primary", func() {
+		primaryStatus = http.StatusServiceUnavailable
+		resp, err := send(nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+		Expect(primaryRequests).To(Equal(1))
+		Expect(secondaryBodies).To(BeEmpty())
+	})
+
+	It("sends the request only to the preferred endpoint when one is set", func() {
+		primaryStatus = http.StatusServiceUnavailable
+		resp, err := send(&brokerEndpoint{Preferred: broker.BrokerURL})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+		Expect(secondaryBodies).To(BeEmpty())
+	})
+
+	It("uses the default client when the broker has no failover urls", func() {
+		broker.FailoverURLs = nil
+		client, err := clients.Client(broker)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(client).To(Equal(http.DefaultClient))
+	})
+})
@@ -0,0 +1,151 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/Peripli/service-manager/pkg/types"
+)
+
+// SMErrorKey is the key of the normalized Service Manager error which is added to the broker error responses
+const SMErrorKey = "sm_error"
+
+// Error types of the connection-level failures when calling a broker
+const (
+	// BrokerTimeoutErr is returned when the broker does not respond within the request timeout
+	BrokerTimeoutErr = "BrokerTimeout"
+
+	// BrokerTLSErr is returned when the TLS handshake with the broker fails
+	BrokerTLSErr = "BrokerTLSError"
+
+	// BrokerDNSErr is returned when the broker host cannot be resolved
+	BrokerDNSErr = "BrokerDNSError"
+
+	// BrokerConnectionRefusedErr is returned when the broker refuses the connection
+	BrokerConnectionRefusedErr = "BrokerConnectionRefused"
+
+	// BrokerUnreachableErr is returned when the broker cannot be reached for any other reason
+	BrokerUnreachableErr = "ServiceBrokerErr"
+)
+
+// smError is the normalized Service Manager error of a broker error response
+type smError struct {
+	Code      string `json:"code"`
+	Retryable bool   `json:"retryable"`
+	Message   string `json:"message"`
+}
+
+// connectionError classifies a connection-level failure when calling a broker and returns its error type and
+// whether the call can be retried
+func connectionError(err error) (string, bool) {
+	for unwrapped := false; !unwrapped; {
+		switch e := err.(type) {
+		case *url.Error:
+			err = e.Err
+		case *net.OpError:
+			err = e.Err
+		case *os.SyscallError:
+			err = e.Err
+		default:
+			unwrapped = true
+		}
+	}
+
+	switch e := err.(type) {
+	case *brokerURLPolicyError:
+		return BrokerURLForbiddenErr, false
+	case *net.DNSError:
+		return BrokerDNSErr, true
+	case x509.UnknownAuthorityError, x509.HostnameError, x509.CertificateInvalidError, x509.SystemRootsError, tls.RecordHeaderError:
+		return BrokerTLSErr, false
+	case syscall.Errno:
+		if e == syscall.ECONNREFUSED {
+			return BrokerConnectionRefusedErr, true
+		}
+	}
+	if err == context.DeadlineExceeded {
+		return BrokerTimeoutErr, true
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return BrokerTimeoutErr, true
+	}
+	if strings.HasPrefix(err.Error(), "tls: ") {
+		return BrokerTLSErr, false
+	}
+	return BrokerUnreachableErr, true
+}
+
+// mapBrokerError maps the broker error response with the provided status code and OSB error to a normalized Service
+// Manager error. The first matching error mapping of the broker is used, falling back to the first matching global
+// error mapping, and if none matches the error is derived from the connection-level failure, if any, or from the
+// status code.
+func mapBrokerError(broker *types.ServiceBroker, globalMappings []*types.BrokerErrorMapping, statusCode int, brokerError, description string, transportErr error) *smError {
+	for _, mapping := range append(append([]*types.BrokerErrorMapping{}, broker.ErrorMappings...), globalMappings...) {
+		if mapping.Matches(statusCode, brokerError) {
+			message := mapping.Message
+			if len(message) == 0 {
+				message = description
+			}
+			return &smError{
+				Code:      mapping.ErrorCode,
+				Retryable: mapping.Retryable,
+				Message:   message,
+			}
+		}
+	}
+
+	result := &smError{Message: description}
+	if transportErr != nil {
+		result.Code, result.Retryable = connectionError(transportErr)
+		return result
+	}
+	switch {
+	case statusCode == http.StatusBadRequest:
+		result.Code = "BrokerBadRequest"
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		result.Code = "BrokerUnauthorized"
+	case statusCode == http.StatusNotFound:
+		result.Code = "BrokerNotFound"
+	case statusCode == http.StatusConflict:
+		result.Code = "BrokerConflict"
+	case statusCode == http.StatusGone:
+		result.Code = "BrokerGone"
+	case statusCode == http.StatusUnprocessableEntity:
+		result.Code = "BrokerUnprocessableEntity"
+	case statusCode == http.StatusTooManyRequests:
+		result.Code = "BrokerRateLimited"
+		result.Retryable = true
+	case statusCode == http.StatusBadGateway || statusCode == http.StatusServiceUnavailable || statusCode == http.StatusGatewayTimeout:
+		result.Code = "BrokerUnavailable"
+		result.Retryable = true
+	case statusCode >= 500:
+		result.Code = "BrokerInternalError"
+		result.Retryable = true
+	default:
+		result.Code = "BrokerRequestRejected"
+	}
+	return result
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+
+	"github.com/Peripli/service-manager/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Broker errors", func() {
+	DescribeTable("connectionError",
+		func(err error, expectedType string, expectedRetryable bool) {
+			errorType, retryable := connectionError(err)
+			Expect(errorType).To(Equal(expectedType))
+			Expect(retryable).To(Equal(expectedRetryable))
+		},
+		Entry("classifies deadline exceeded as timeout", context.DeadlineExceeded, BrokerTimeoutErr, true),
+		Entry("classifies wrapped dns errors",
+			&url.Error{Op: "Get", URL: "http://broker", Err: &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "broker"}}},
+			BrokerDNSErr, true),
+		Entry("classifies unknown certificate authority as TLS error",
+			&url.Error{Op: "Get", URL: "https://broker", Err: x509.UnknownAuthorityError{}},
+			BrokerTLSErr, false),
+		Entry("classifies refused connections",
+			&net.OpError{Op: "dial", Err: &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}},
+			BrokerConnectionRefusedErr, true),
+		Entry("classifies other errors as unreachable broker", errors.New("unexpected EOF"), BrokerUnreachableErr, true),
+	)
+
+	Describe("mapBrokerError", func() {
+		var broker *types.ServiceBroker
+
+		BeforeEach(func() {
+			broker = &types.ServiceBroker{
+				ErrorMappings: []*types.BrokerErrorMapping{
+					{StatusCodes: []int{http.StatusUnprocessableEntity}, Error: "ConcurrencyError", ErrorCode: "OperationInProgress", Retryable: true, Message: "try again later"},
+					{StatusCodes: []int{http.StatusBadRequest}, ErrorCode: "InvalidParameters"},
+					{Error: BrokerTimeoutErr, ErrorCode: "Slow", Retryable: false},
+				},
+			}
+		})
+
+		It("uses the first matching mapping", func() {
+			Expect(mapBrokerError(broker, nil, http.StatusUnprocessableEntity, "ConcurrencyError", "description", nil)).To(Equal(&smError{
+				Code:      "OperationInProgress",
+				Retryable: true,
+				Message:   "try again later",
+			}))
+		})
+
+		It("uses the broker description when the mapping has no message", func() {
+			Expect(mapBrokerError(broker, nil, http.StatusBadRequest, "", "description", nil)).To(Equal(&smError{
+				Code:    "InvalidParameters",
+				Message: "description",
+			}))
+		})
+
+		It("falls back to the global mappings", func() {
+			globalMappings := []*types.BrokerErrorMapping{
+				{StatusCodes: []int{http.StatusBadRequest}, ErrorCode: "GlobalBadRequest"},
+				{Error: "AsyncRequired", ErrorCode: "AsyncRequired", Message: "asynchronous operations are required"},
+			}
+			Expect(mapBrokerError(broker, globalMappings, http.StatusBadRequest, "", "description", nil)).To(Equal(&smError{
+				Code:    "InvalidParameters",
+				Message: "description",
+			}))
+			Expect(mapBrokerError(broker, globalMappings, http.StatusUnprocessableEntity, "AsyncRequired", "description", nil)).To(Equal(&smError{
+				Code:    "AsyncRequired",
+				Message: "asynchronous operations are required",
+			}))
+		})
+
+		It("derives the error from the status code when no mapping matches", func() {
+			Expect(mapBrokerError(broker, nil, http.StatusUnprocessableEntity, "AsyncRequired", "description", nil)).To(Equal(&smError{
+				Code:    "BrokerUnprocessableEntity",
+				Message: "description",
+			}))
+			Expect(mapBrokerError(broker, nil, http.StatusServiceUnavailable, "", "description", nil)).To(Equal(&smError{
+				Code:      "BrokerUnavailable",
+				Retryable: true,
+				Message:   "description",
+			}))
+		})
+
+		It("derives the error from the connection-level failure when no mapping matches", func() {
+			Expect(mapBrokerError(broker, nil, http.StatusBadGateway, BrokerTLSErr, "description", x509.HostnameError{})).To(Equal(&smError{
+				Code:    BrokerTLSErr,
+				Message: "description",
+			}))
+		})
+
+		It("maps connection-level failures by their error type", func() {
+			Expect(mapBrokerError(broker, nil, http.StatusBadGateway, BrokerTimeoutErr, "description", context.DeadlineExceeded)).To(Equal(&smError{
+				Code:    "Slow",
+				Message: "description",
+			}))
+		})
+	})
+})
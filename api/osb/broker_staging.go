@@ -0,0 +1,136 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"context"
+
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/storage"
+)
+
+// BrokerStaging restricts the visibility of the plans of brokers in staging state to the platforms which have the
+// staging label. Staging is disabled if no staging label is configured.
+type BrokerStaging struct {
+	Repository storage.Repository
+	Label      string
+}
+
+// Enabled returns true if a staging label is configured
+func (s *BrokerStaging) Enabled() bool {
+	return s != nil && len(s.Label) != 0
+}
+
+// IsStagingPlatform returns true if the platform has the staging label
+func (s *BrokerStaging) IsStagingPlatform(platform *types.Platform) bool {
+	if !s.Enabled() {
+		return false
+	}
+	_, found := platform.Labels[s.Label]
+	return found
+}
+
+// IsStagingBroker returns true if the broker with the specified id is in staging state
+func (s *BrokerStaging) IsStagingBroker(ctx context.Context, brokerID string) (bool, error) {
+	if !s.Enabled() {
+		return false, nil
+	}
+	broker, err := s.Repository.Get(ctx, types.ServiceBrokerType, query.ByField(query.EqualsOperator, "id", brokerID))
+	if err != nil {
+		return false, util.HandleStorageError(err, string(types.ServiceBrokerType))
+	}
+	return broker.(*types.ServiceBroker).IsStaging(), nil
+}
+
+// StagingOfferingIDs returns the ids of the service offerings of the brokers in staging state
+func (s *BrokerStaging) StagingOfferingIDs(ctx context.Context) (map[string]bool, error) {
+	offeringIDs, err := s.stagingOfferingIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]bool, len(offeringIDs))
+	for _, offeringID := range offeringIDs {
+		result[offeringID] = true
+	}
+	return result, nil
+}
+
+// StagingPlanIDs returns the ids of the service plans of the brokers in staging state
+func (s *BrokerStaging) StagingPlanIDs(ctx context.Context) (map[string]bool, error) {
+	offeringIDs, err := s.stagingOfferingIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]bool)
+	if len(offeringIDs) == 0 {
+		return result, nil
+	}
+	plans, err := s.Repository.List(ctx, types.ServicePlanType, query.ByField(query.InOperator, "service_offering_id", offeringIDs...))
+	if err != nil {
+		return nil, util.HandleStorageError(err, string(types.ServicePlanType))
+	}
+	for i := 0; i < plans.Len(); i++ {
+		result[plans.ItemAt(i).GetID()] = true
+	}
+	return result, nil
+}
+
+// IsStagingPlan returns true if the service plan with the specified id belongs to a broker in staging state
+func (s *BrokerStaging) IsStagingPlan(ctx context.Context, planID string) (bool, error) {
+	if !s.Enabled() {
+		return false, nil
+	}
+	plan, err := s.Repository.Get(ctx, types.ServicePlanType, query.ByField(query.EqualsOperator, "id", planID))
+	if err != nil {
+		return false, util.HandleStorageError(err, string(types.ServicePlanType))
+	}
+	byOfferingID := query.ByField(query.EqualsOperator, "id", plan.(*types.ServicePlan).ServiceOfferingID)
+	offering, err := s.Repository.Get(ctx, types.ServiceOfferingType, byOfferingID)
+	if err != nil {
+		return false, util.HandleStorageError(err, string(types.ServiceOfferingType))
+	}
+	return s.IsStagingBroker(ctx, offering.(*types.ServiceOffering).BrokerID)
+}
+
+func (s *BrokerStaging) stagingOfferingIDs(ctx context.Context) ([]string, error) {
+	if !s.Enabled() {
+		return nil, nil
+	}
+	brokers, err := s.Repository.List(ctx, types.ServiceBrokerType, query.ByField(query.EqualsOperator, "state", types.BrokerStaging))
+	if err != nil {
+		return nil, util.HandleStorageError(err, string(types.ServiceBrokerType))
+	}
+	if brokers.Len() == 0 {
+		return nil, nil
+	}
+	brokerIDs := make([]string, 0, brokers.Len())
+	for i := 0; i < brokers.Len(); i++ {
+		brokerIDs = append(brokerIDs, brokers.ItemAt(i).GetID())
+	}
+
+	offerings, err := s.Repository.List(ctx, types.ServiceOfferingType, query.ByField(query.InOperator, "broker_id", brokerIDs...))
+	if err != nil {
+		return nil, util.HandleStorageError(err, string(types.ServiceOfferingType))
+	}
+	offeringIDs := make([]string, 0, offerings.Len())
+	for i := 0; i < offerings.Len(); i++ {
+		offeringIDs = append(offeringIDs, offerings.ItemAt(i).GetID())
+	}
+	return offeringIDs, nil
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb_test
+
+import (
+	"context"
+
+	"github.com/Peripli/service-manager/api/osb"
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/storage/storagefakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Broker staging", func() {
+	var (
+		fakeStorage *storagefakes.FakeStorage
+		staging     *osb.BrokerStaging
+	)
+
+	BeforeEach(func() {
+		fakeStorage = &storagefakes.FakeStorage{}
+		fakeStorage.ListStub = func(ctx context.Context, objectType types.ObjectType, criteria ...query.Criterion) (types.ObjectList, error) {
+			switch objectType {
+			case types.ServiceBrokerType:
+				return &types.ServiceBrokers{ServiceBrokers: []*types.ServiceBroker{
+					{Base: types.Base{ID: "staging-broker-id"}, State: types.BrokerStaging},
+				}}, nil
+			case types.ServiceOfferingType:
+				return &types.ServiceOfferings{ServiceOfferings: []*types.ServiceOffering{
+					{Base: types.Base{ID: "staging-offering-id"}, BrokerID: "staging-broker-id"},
+				}}, nil
+			default:
+				return &types.ServicePlans{ServicePlans: []*types.ServicePlan{
+					{Base: types.Base{ID: "staging-plan-id"}, ServiceOfferingID: "staging-offering-id"},
+				}}, nil
+			}
+		}
+		staging = &osb.BrokerStaging{Repository: fakeStorage, Label: "staging"}
+	})
+
+	It("recognizes the platforms with the staging label", func() {
+		Expect(staging.IsStagingPlatform(&types.Platform{Labels: types.Labels{"staging": {"true"}}})).To(BeTrue())
+		Expect(staging.IsStagingPlatform(&types.Platform{})).To(BeFalse())
+	})
+
+	It("returns the offerings and plans of the brokers in staging state", func() {
+		offeringIDs, err := staging.StagingOfferingIDs(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(offeringIDs).To(Equal(map[string]bool{"staging-offering-id": true}))
+
+		planIDs, err := staging.StagingPlanIDs(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(planIDs).To(Equal(map[string]bool{"staging-plan-id": true}))
+
+		_, _, criteria := fakeStorage.ListArgsForCall(0)
+		Expect(criteria).To(ConsistOf(query.ByField(query.EqualsOperator, "state", types.BrokerStaging)))
+	})
+
+	It("does nothing when no staging label is configured", func() {
+		staging.Label = ""
+		planIDs, err := staging.StagingPlanIDs(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(planIDs).To(BeEmpty())
+		Expect(staging.IsStagingPlatform(&types.Platform{Labels: types.Labels{"staging": {"true"}}})).To(BeFalse())
+		Expect(fakeStorage.ListCallCount()).To(Equal(0))
+	})
+})
@@ -0,0 +1,259 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+
+	"github.com/Peripli/service-manager/pkg/httpclient"
+	"github.com/Peripli/service-manager/pkg/log"
+	"github.com/Peripli/service-manager/pkg/util"
+)
+
+// BrokerURLForbiddenErr is the error type of calls towards broker urls and addresses forbidden by the broker url policy
+const BrokerURLForbiddenErr = "BrokerURLForbidden"
+
+// forbiddenNetworks are the link-local, unspecified and cloud metadata networks which brokers can never be called at
+var forbiddenNetworks = mustParseCIDRs(
+	"0.0.0.0/8",
+	"169.254.0.0/16",
+	"100.100.100.200/32",
+	"::/128",
+	"fe80::/10",
+	"fd00:ec2::254/128",
+)
+
+// brokerURLPolicyError is returned when a broker url or address is forbidden by the broker url policy
+type brokerURLPolicyError struct {
+	reason string
+}
+
+func (e *brokerURLPolicyError) Error() string {
+	return e.reason
+}
+
+// BrokerURLPolicy restricts the urls and the addresses at which the Service Manager calls the brokers
+type BrokerURLPolicy struct {
+	requireHTTPS    bool
+	allowedHosts    []string
+	deniedHosts     []string
+	allowedNetworks []*net.IPNet
+	deniedNetworks  []*net.IPNet
+}
+
+// NewBrokerURLPolicy creates a broker url policy. Hosts can be either host names or wildcard host names such as
+// *.example.com and networks are specified in CIDR notation. Empty allowed hosts and networks allow all hosts and
+// addresses which are not denied. Link-local, unspecified and cloud metadata addresses are always denied.
+func NewBrokerURLPolicy(requireHTTPS bool, allowedHosts, deniedHosts, allowedCIDRs, deniedCIDRs []string) (*BrokerURLPolicy, error) {
+	allowedNetworks, err := parseCIDRs(allowedCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	deniedNetworks, err := parseCIDRs(deniedCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	return &BrokerURLPolicy{
+		requireHTTPS:    requireHTTPS,
+		allowedHosts:    normalizeHosts(allowedHosts),
+		deniedHosts:     normalizeHosts(deniedHosts),
+		allowedNetworks: allowedNetworks,
+		deniedNetworks:  deniedNetworks,
+	}, nil
+}
+
+// CheckURL verifies that the broker url and the addresses its host currently resolves to are allowed by the policy.
+// As the host can resolve to different addresses later, the addresses are also verified each time a connection
+// towards the broker is established.
+func (p *BrokerURLPolicy) CheckURL(ctx context.Context, brokerURL string) error {
+	if p == nil {
+		return nil
+	}
+	parsedURL, err := p.checkURL(brokerURL, p.requireHTTPS)
+	if err == nil {
+		err = p.checkHostAddresses(ctx, parsedURL.Hostname())
+	}
+	if err != nil {
+		return &util.HTTPError{
+			ErrorType:   "BadRequest",
+			Description: fmt.Sprintf("broker url %s is not allowed: %s", brokerURL, err),
+			StatusCode:  http.StatusBadRequest,
+		}
+	}
+	return nil
+}
+
+// checkHostAddresses verifies the addresses which the host currently resolves to. Hosts which cannot be resolved
+// are allowed, as they may still be reachable through a proxy.
+func (p *BrokerURLPolicy) checkHostAddresses(ctx context.Context, host string) error {
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		log.C(ctx).WithError(err).Debugf("Could not resolve broker host %s", host)
+	}
+	for _, address := range addresses {
+		if err := p.checkIP(address.IP); err != nil {
+			return &brokerURLPolicyError{reason: fmt.Sprintf("host %s resolves to a forbidden address: %s", host, err)}
+		}
+	}
+	return nil
+}
+
+func (p *BrokerURLPolicy) checkURL(brokerURL string, requireHTTPS bool) (*url.URL, error) {
+	parsedURL, err := url.Parse(brokerURL)
+	if err != nil {
+		return nil, &brokerURLPolicyError{reason: fmt.Sprintf("invalid url: %s", err)}
+	}
+	switch {
+	case parsedURL.Scheme == "https":
+	case parsedURL.Scheme == "http" && !requireHTTPS:
+	case parsedURL.Scheme == "http":
+		return nil, &brokerURLPolicyError{reason: "broker urls should use https"}
+	default:
+		return nil, &brokerURLPolicyError{reason: fmt.Sprintf("unsupported scheme %s", parsedURL.Scheme)}
+	}
+
+	host := strings.ToLower(parsedURL.Hostname())
+	if len(host) == 0 {
+		return nil, &brokerURLPolicyError{reason: "missing host"}
+	}
+	if matchesHost(p.deniedHosts, host) {
+		return nil, &brokerURLPolicyError{reason: fmt.Sprintf("host %s is denied", host)}
+	}
+	if len(p.allowedHosts) != 0 && !matchesHost(p.allowedHosts, host) {
+		return nil, &brokerURLPolicyError{reason: fmt.Sprintf("host %s is not in the allowed hosts", host)}
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if err := p.checkIP(ip); err != nil {
+			return nil, err
+		}
+	}
+	return parsedURL, nil
+}
+
+func (p *BrokerURLPolicy) checkIP(ip net.IP) error {
+	if network := findNetwork(forbiddenNetworks, ip); network != nil {
+		return &brokerURLPolicyError{reason: fmt.Sprintf("address %s is a link-local, unspecified or metadata address", ip)}
+	}
+	if network := findNetwork(p.deniedNetworks, ip); network != nil {
+		return &brokerURLPolicyError{reason: fmt.Sprintf("address %s is in the denied network %s", ip, network)}
+	}
+	if len(p.allowedNetworks) != 0 && findNetwork(p.allowedNetworks, ip) == nil {
+		return &brokerURLPolicyError{reason: fmt.Sprintf("address %s is not in the allowed networks", ip)}
+	}
+	return nil
+}
+
+// dialControl implements httpclient.DialControlFunc and rejects connections towards addresses forbidden by the policy
+func (p *BrokerURLPolicy) dialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return &brokerURLPolicyError{reason: fmt.Sprintf("invalid address %s: %s", address, err)}
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return &brokerURLPolicyError{reason: fmt.Sprintf("invalid address %s", address)}
+	}
+	return p.checkIP(ip)
+}
+
+// brokerURLPolicyTransport rejects requests towards broker urls forbidden by the policy and makes the underlying
+// transport verify the address of each connection it establishes, so that DNS rebinding cannot bypass the policy.
+// Https is required only when brokers are registered or their urls are updated, so that brokers registered with
+// http urls before https was required can still be called.
+type brokerURLPolicyTransport struct {
+	base   http.RoundTripper
+	policy *BrokerURLPolicy
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *brokerURLPolicyTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if _, err := t.policy.checkURL(request.URL.String(), false); err != nil {
+		return nil, err
+	}
+	if t.usesProxy(request) {
+		// the connections are established towards the proxy, so the addresses of the broker host are verified here
+		if err := t.policy.checkHostAddresses(request.Context(), request.URL.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+	ctx := httpclient.ContextWithDialControl(request.Context(), t.policy.dialControl)
+	return t.base.RoundTrip(request.WithContext(ctx))
+}
+
+func (t *brokerURLPolicyTransport) usesProxy(request *http.Request) bool {
+	transport, ok := t.base.(*http.Transport)
+	if !ok || transport.Proxy == nil {
+		return false
+	}
+	proxyURL, err := transport.Proxy(request)
+	return err == nil && proxyURL != nil
+}
+
+func matchesHost(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if pattern == host || (strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+func findNetwork(networks []*net.IPNet, ip net.IP) *net.IPNet {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return network
+		}
+	}
+	return nil
+}
+
+func normalizeHosts(hosts []string) []string {
+	result := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		result = append(result, strings.ToLower(host))
+	}
+	return result
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %s: %s", cidr, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks, err := parseCIDRs(cidrs)
+	if err != nil {
+		panic(err)
+	}
+	return networks
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/Peripli/service-manager/pkg/httpclient"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Broker URL policy", func() {
+	DescribeTable("CheckURL",
+		func(policy *BrokerURLPolicy, brokerURL string, expectedReason string) {
+			err := policy.CheckURL(context.Background(), brokerURL)
+			if len(expectedReason) == 0 {
+				Expect(err).ToNot(HaveOccurred())
+				return
+			}
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*util.HTTPError).StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(err.Error()).To(ContainSubstring(expectedReason))
+		},
+		Entry("allows https urls", newPolicy(true, nil, nil, nil, nil), "https://10.0.0.1/broker", ""),
+		Entry("rejects http urls when https is required", newPolicy(true, nil, nil, nil, nil), "http://10.0.0.1", "should use https"),
+		Entry("allows http urls when https is not required", newPolicy(false, nil, nil, nil, nil), "http://10.0.0.1", ""),
+		Entry("rejects unsupported schemes", newPolicy(false, nil, nil, nil, nil), "ftp://10.0.0.1", "unsupported scheme ftp"),
+		Entry("rejects metadata addresses", newPolicy(false, nil, nil, nil, nil), "http://169.254.169.254/latest", "link-local"),
+		Entry("rejects link-local ipv6 addresses", newPolicy(false, nil, nil, nil, nil), "http://[fe80::1]:8080", "link-local"),
+		Entry("rejects denied hosts", newPolicy(false, nil, []string{"*.internal.example.com"}, nil, nil), "http://db.Internal.example.com", "is denied"),
+		Entry("rejects hosts which are not allowed", newPolicy(false, []string{"*.brokers.example.com"}, nil, nil, nil), "http://10.0.0.1", "not in the allowed hosts"),
+		Entry("allows allowed hosts", newPolicy(false, []string{"10.0.0.1"}, nil, nil, nil), "http://10.0.0.1", ""),
+		Entry("rejects addresses in denied networks", newPolicy(false, nil, nil, nil, []string{"10.0.0.0/8"}), "http://10.1.2.3", "denied network 10.0.0.0/8"),
+		Entry("rejects addresses outside the allowed networks", newPolicy(false, nil, nil, []string{"192.168.0.0/16"}, nil), "http://10.1.2.3", "not in the allowed networks"),
+		Entry("rejects host names resolving to denied networks", newPolicy(false, nil, nil, nil, []string{"127.0.0.0/8", "::1/128"}), "http://localhost:8080", "resolves to a forbidden address"),
+		Entry("allows any url without policy", nil, "ftp://169.254.169.254", ""),
+	)
+
+	It("fails to create a policy with invalid networks", func() {
+		_, err := NewBrokerURLPolicy(false, nil, nil, []string{"10.0.0.0/33"}, nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	Describe("broker clients", func() {
+		var (
+			server *httptest.Server
+			broker *types.ServiceBroker
+		)
+
+		BeforeEach(func() {
+			httpclient.Configure(httpclient.DefaultSettings())
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			broker = &types.ServiceBroker{Name: "broker", BrokerURL: server.URL}
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		send := func(policy *BrokerURLPolicy, brokerURL string) error {
+			client, err := NewBrokerClients(httpclient.DefaultSettings(), policy).Client(broker)
+			Expect(err).ToNot(HaveOccurred())
+			request, err := http.NewRequest(http.MethodGet, brokerURL, nil)
+			Expect(err).ToNot(HaveOccurred())
+			response, err := client.Do(request)
+			if err != nil {
+				return err
+			}
+			response.Body.Close()
+			return nil
+		}
+
+		It("calls brokers at allowed addresses", func() {
+			Expect(send(newPolicy(false, nil, nil, nil, nil), server.URL)).To(Succeed())
+		})
+
+		It("calls brokers registered with http urls when https is required", func() {
+			Expect(send(newPolicy(true, nil, nil, nil, nil), server.URL)).To(Succeed())
+		})
+
+		It("rejects connections towards denied addresses which the broker host resolves to", func() {
+			localhostURL := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+			err := send(newPolicy(false, []string{"localhost"}, nil, nil, []string{"127.0.0.0/8", "::1/128"}), localhostURL)
+			Expect(err).To(HaveOccurred())
+			errorType, retryable := connectionError(err)
+			Expect(errorType).To(Equal(BrokerURLForbiddenErr))
+			Expect(retryable).To(BeFalse())
+		})
+
+		It("rejects requests through a proxy towards denied addresses which the broker host resolves to", func() {
+			proxyURL, err := url.Parse("http://10.255.255.1:3128")
+			Expect(err).ToNot(HaveOccurred())
+			transport := &brokerURLPolicyTransport{
+				base:   &http.Transport{Proxy: http.ProxyURL(proxyURL)},
+				policy: newPolicy(false, nil, nil, nil, []string{"127.0.0.0/8", "::1/128"}),
+			}
+			request, err := http.NewRequest(http.MethodGet, "http://localhost:8080", nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = transport.RoundTrip(request)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("resolves to a forbidden address"))
+		})
+	})
+})
+
+func newPolicy(requireHTTPS bool, allowedHosts, deniedHosts, allowedCIDRs, deniedCIDRs []string) *BrokerURLPolicy {
+	policy, err := NewBrokerURLPolicy(requireHTTPS, allowedHosts, deniedHosts, allowedCIDRs, deniedCIDRs)
+	if err != nil {
+		panic(err)
+	}
+	return policy
+}
@@ -29,38 +29,64 @@ import (
 const brokerCatalogURL = "%s/v2/catalog"
 const brokerAPIVersionHeader = "X-Broker-API-Version"
 
-// CatalogFetcher creates a broker catalog fetcher that uses the provided request function to call the specified broker's catalog endpoint
-func CatalogFetcher(doRequestFunc util.DoRequestFunc, brokerAPIVersion string) func(ctx context.Context, broker *types.ServiceBroker) ([]byte, error) {
+// CatalogFetcher creates a broker catalog fetcher that uses the request function provided for the specified broker to call its catalog endpoint.
+// The fetcher negotiates the OSB version with the broker starting from the specified version and stores the negotiated version in the broker.
+// The static catalog of catalog-only brokers is returned without calling the broker.
+func CatalogFetcher(doRequestFuncProvider DoRequestFuncProvider, brokerAPIVersion string) func(ctx context.Context, broker *types.ServiceBroker) ([]byte, error) {
 	return func(ctx context.Context, broker *types.ServiceBroker) ([]byte, error) {
+		if broker.IsCatalogOnly() {
+			log.C(ctx).Debugf("Using the static catalog of broker with name %s", broker.Name)
+			return broker.StaticCatalog, nil
+		}
 		log.C(ctx).Debugf("Attempting to fetch catalog from broker with name %s and URL %s", broker.Name, broker.BrokerURL)
-		requestWithBasicAuth := util.BasicAuthDecorator(broker.Credentials.Basic.Username, broker.Credentials.Basic.Password, doRequestFunc)
-		response, err := util.SendRequestWithHeaders(ctx, requestWithBasicAuth, http.MethodGet, fmt.Sprintf(brokerCatalogURL, broker.BrokerURL), map[string]string{}, nil, map[string]string{
-			brokerAPIVersionHeader: brokerAPIVersion,
-		})
+		doRequestFunc, err := doRequestFuncProvider(broker)
 		if err != nil {
-			log.C(ctx).WithError(err).Errorf("Error while forwarding request to service broker %s", broker.Name)
 			return nil, &util.HTTPError{
-				ErrorType:   "ServiceBrokerErr",
-				Description: fmt.Sprintf("could not reach service broker %s at %s", broker.Name, broker.BrokerURL),
-				StatusCode:  http.StatusBadGateway,
+				ErrorType:   "BadRequest",
+				Description: err.Error(),
+				StatusCode:  http.StatusBadRequest,
 			}
 		}
+		requestWithBasicAuth := util.BasicAuthDecorator(broker.Credentials.Basic.Username, broker.Credentials.Basic.Password, doRequestFunc)
 
-		var responseBytes []byte
-		if responseBytes, err = util.BodyToBytes(response.Body); err != nil {
-			return nil, fmt.Errorf("error getting content from body of response with status %s: %s", response.Status, err)
-		}
+		versions := negotiableVersions(brokerAPIVersion)
+		for i, version := range versions {
+			response, err := util.SendRequestWithHeaders(ctx, requestWithBasicAuth, http.MethodGet, fmt.Sprintf(brokerCatalogURL, broker.BrokerURL), map[string]string{}, nil, map[string]string{
+				brokerAPIVersionHeader: version,
+			})
+			if err != nil {
+				log.C(ctx).WithError(err).Errorf("Error while forwarding request to service broker %s", broker.Name)
+				return nil, &util.HTTPError{
+					ErrorType:   "ServiceBrokerErr",
+					Description: fmt.Sprintf("could not reach service broker %s at %s", broker.Name, broker.BrokerURL),
+					StatusCode:  http.StatusBadGateway,
+				}
+			}
 
-		if response.StatusCode != http.StatusOK {
-			log.C(ctx).WithError(err).Errorf("error fetching catalog for broker with name %s: %s", broker.Name, util.HandleResponseError(response))
-			return nil, &util.HTTPError{
-				ErrorType:   "ServiceBrokerErr",
-				Description: fmt.Sprintf("error fetching catalog for broker with name %s: broker responded with %s", broker.Name, response.Status),
-				StatusCode:  http.StatusBadRequest,
+			var responseBytes []byte
+			if responseBytes, err = util.BodyToBytes(response.Body); err != nil {
+				return nil, fmt.Errorf("error getting content from body of response with status %s: %s", response.Status, err)
+			}
+
+			if response.StatusCode == http.StatusPreconditionFailed && i < len(versions)-1 {
+				log.C(ctx).Infof("Broker with name %s does not support OSB version %s. Retrying with OSB version %s...", broker.Name, version, versions[i+1])
+				continue
 			}
+
+			if response.StatusCode != http.StatusOK {
+				log.C(ctx).WithError(err).Errorf("error fetching catalog for broker with name %s: %s", broker.Name, util.HandleResponseError(response))
+				return nil, &util.HTTPError{
+					ErrorType:   "ServiceBrokerErr",
+					Description: fmt.Sprintf("error fetching catalog for broker with name %s: broker responded with %s", broker.Name, response.Status),
+					StatusCode:  http.StatusBadRequest,
+				}
+			}
+			log.C(ctx).Debugf("Successfully fetched catalog from broker with name %s and URL %s using OSB version %s", broker.Name, broker.BrokerURL, version)
+
+			broker.OSBVersion = version
+			return responseBytes, nil
 		}
-		log.C(ctx).Debugf("Successfully fetched catalog from broker with name %s and URL %s", broker.Name, broker.BrokerURL)
 
-		return responseBytes, nil
+		return nil, fmt.Errorf("could not negotiate OSB version with broker with name %s", broker.Name)
 	}
 }
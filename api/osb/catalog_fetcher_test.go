@@ -77,7 +77,9 @@ var _ = Describe("Catalog CatalogFetcher", func() {
 	}
 
 	newFetcher := func(t testCase) func(ctx context.Context, broker *types.ServiceBroker) ([]byte, error) {
-		return osb.CatalogFetcher(common.DoHTTP(t.reaction, t.expectations), version)
+		return osb.CatalogFetcher(func(*types.ServiceBroker) (util.DoRequestFunc, error) {
+			return common.DoHTTP(t.reaction, t.expectations), nil
+		}, version)
 	}
 
 	basicAuth := func(username, password string) string {
@@ -151,6 +153,42 @@ var _ = Describe("Catalog CatalogFetcher", func() {
 		}),
 	}
 
+	Describe("OSB version negotiation", func() {
+		var requestedVersions []string
+
+		newNegotiatingFetcher := func(supportedVersion string) func(ctx context.Context, broker *types.ServiceBroker) ([]byte, error) {
+			return osb.CatalogFetcher(func(*types.ServiceBroker) (util.DoRequestFunc, error) {
+				return func(request *http.Request) (*http.Response, error) {
+					requestedVersion := request.Header.Get("X-Broker-API-Version")
+					requestedVersions = append(requestedVersions, requestedVersion)
+					if requestedVersion != supportedVersion {
+						return common.DoHTTP(&common.HTTPReaction{Status: http.StatusPreconditionFailed, Body: "{}"}, nil)(request)
+					}
+					return common.DoHTTP(&common.HTTPReaction{Status: http.StatusOK, Body: simpleCatalog}, nil)(request)
+				}, nil
+			}, "2.15")
+		}
+
+		BeforeEach(func() {
+			requestedVersions = nil
+		})
+
+		It("falls back to older versions and stores the negotiated version in the broker", func() {
+			rawCatalog, err := newNegotiatingFetcher("2.14")(context.TODO(), testBroker)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rawCatalog).To(Equal([]byte(simpleCatalog)))
+			Expect(requestedVersions).To(Equal([]string{"2.15", "2.14"}))
+			Expect(testBroker.OSBVersion).To(Equal("2.14"))
+		})
+
+		It("returns error if the broker supports none of the versions", func() {
+			_, err := newNegotiatingFetcher("3.0")(context.TODO(), testBroker)
+			Expect(err).To(HaveOccurred())
+			Expect(requestedVersions).To(Equal([]string{"2.15", "2.14", "2.13"}))
+			Expect(testBroker.OSBVersion).To(BeEmpty())
+		})
+	})
+
 	DescribeTable("Fetch", func(t testCase) {
 		fetcher := newFetcher(t)
 		rawCatalog, err := fetcher(context.TODO(), testBroker)
@@ -18,9 +18,10 @@ import (
 
 const CatalogFilterByVisibilityPluginName = "CatalogFilterByVisibilityPlugin"
 
-func NewCatalogFilterByVisibilityPlugin(repository storage.Repository) *CatalogFilterByVisibilityPlugin {
+func NewCatalogFilterByVisibilityPlugin(repository storage.Repository, staging *BrokerStaging) *CatalogFilterByVisibilityPlugin {
 	return &CatalogFilterByVisibilityPlugin{
 		repository: repository,
+		staging:    staging,
 	}
 }
 
@@ -30,6 +31,7 @@ func (c *CatalogFilterByVisibilityPlugin) Name() string {
 
 type CatalogFilterByVisibilityPlugin struct {
 	repository storage.Repository
+	staging    *BrokerStaging
 }
 
 func (c *CatalogFilterByVisibilityPlugin) FetchCatalog(req *web.Request, next web.Handler) (*web.Response, error) {
@@ -53,57 +55,120 @@ func (c *CatalogFilterByVisibilityPlugin) FetchCatalog(req *web.Request, next we
 	if err := userCtx.Data(platform); err != nil {
 		return nil, err
 	}
-	if platform.Type != types.K8sPlatformType {
-		log.C(ctx).Debugf("Platform type is %s, which is not kubernetes. Skip filtering on visibilities", platform.Type)
-		return res, nil
-	}
 
 	brokerID := req.PathParams[BrokerIDPathParam]
-	visibleCatalogPlans, err := getVisiblePlansByBrokerIDAndPlatformID(ctx, c.repository, brokerID, platform.ID)
+	plans, err := getPlansByBrokerID(ctx, c.repository, brokerID)
 	if err != nil {
 		return nil, err
 	}
+	grants, err := VisiblePlanGrants(ctx, c.repository, c.staging, brokerID, platform, plans)
+	if err != nil {
+		return nil, err
+	}
+
+	visibleCatalogPlans := make(map[string]bool, len(grants))
+	for _, plan := range plans {
+		if grants[plan.ID] != nil {
+			visibleCatalogPlans[plan.CatalogID] = true
+		}
+	}
 	res.Body, err = filterCatalogByVisiblePlans(res.Body, visibleCatalogPlans)
 	return res, err
 }
 
-func getVisiblePlansByBrokerIDAndPlatformID(ctx context.Context, repository storage.Repository, brokerID, platformID string) (map[string]bool, error) {
-	offeringIDs, err := getOfferingIDsByBrokerID(ctx, repository, brokerID)
+const (
+	// PlanGrantedByVisibility is the reason for plans which are visible because of a visibility
+	PlanGrantedByVisibility = "visibility"
+	// PlanGrantedByStaging is the reason for plans of brokers in staging state which are visible to staging platforms
+	PlanGrantedByStaging = "staging_platform"
+	// PlanGrantedByPlatformType is the reason for plans which are visible because the catalogs are not filtered
+	// for the type of the platform
+	PlanGrantedByPlatformType = "platform_type"
+)
+
+// PlanGrant describes why a service plan is visible to a platform
+type PlanGrant struct {
+	Reason string `json:"reason"`
+	// Visibility is the visibility which makes the plan visible if the reason is a visibility
+	Visibility *types.Visibility `json:"visibility,omitempty"`
+}
+
+// VisiblePlanGrants returns the grants for the plans of the broker which are visible to the platform in the catalog
+// of the broker mapped by the ids of the plans. Plans which are not visible to the platform have no grants.
+func VisiblePlanGrants(ctx context.Context, repository storage.Repository, staging *BrokerStaging, brokerID string, platform *types.Platform, plans []*types.ServicePlan) (map[string]*PlanGrant, error) {
+	grants := make(map[string]*PlanGrant)
+	grantAll := func(reason string) map[string]*PlanGrant {
+		for _, plan := range plans {
+			grants[plan.ID] = &PlanGrant{Reason: reason}
+		}
+		return grants
+	}
+
+	isStagingBroker, err := staging.IsStagingBroker(ctx, brokerID)
 	if err != nil {
 		return nil, err
 	}
+	if isStagingBroker {
+		if staging.IsStagingPlatform(platform) {
+			log.C(ctx).Debugf("Broker %s is in staging state and platform %s is a staging platform. Skip filtering on visibilities", brokerID, platform.ID)
+			return grantAll(PlanGrantedByStaging), nil
+		}
+		log.C(ctx).Debugf("Broker %s is in staging state and platform %s is not a staging platform. Hiding all plans", brokerID, platform.ID)
+		return grants, nil
+	}
 
-	plansList, err := repository.List(ctx, types.ServicePlanType, query.ByField(query.InOperator, "service_offering_id", offeringIDs...))
+	if platform.Type != types.K8sPlatformType {
+		log.C(ctx).Debugf("Platform type is %s, which is not kubernetes. Skip filtering on visibilities", platform.Type)
+		return grantAll(PlanGrantedByPlatformType), nil
+	}
+
+	plansVisibilities, err := storage.ListPlatformPlansVisibilities(ctx, repository, platform, plans)
 	if err != nil {
-		log.C(ctx).Errorf("Could not get %s: %v", types.ServicePlanType, err)
+		log.C(ctx).Errorf("Could not get %s: %v", types.VisibilityType, err)
 		return nil, err
 	}
-	planIDs := make([]string, 0, plansList.Len())
-	for i := 0; i < plansList.Len(); i++ {
-		planIDs = append(planIDs, plansList.ItemAt(i).GetID())
+	for planID, visibilities := range plansVisibilities {
+		for _, v := range visibilities {
+			// the most specific visibility is reported if several visibilities make the plan visible
+			if grant, found := grants[planID]; !found || visibilitySpecificity(v) > visibilitySpecificity(grant.Visibility) {
+				grants[planID] = &PlanGrant{Reason: PlanGrantedByVisibility, Visibility: v}
+			}
+		}
+	}
+	return grants, nil
+}
+
+// visibilitySpecificity ranks platform visibilities before visibilities with selectors before public visibilities.
+// Visibilities for a plan rank before visibilities for the service offering of the plan with the same target.
+func visibilitySpecificity(visibility *types.Visibility) int {
+	specificity := 0
+	switch {
+	case visibility.PlatformID != "":
+		specificity = 4
+	case visibility.Selector != "":
+		specificity = 2
+	}
+	if visibility.ServicePlanID != "" {
+		specificity++
 	}
+	return specificity
+}
 
-	visibilitiesList, err := repository.List(ctx, types.VisibilityType,
-		query.ByField(query.EqualsOrNilOperator, "platform_id", platformID),
-		query.ByField(query.InOperator, "service_plan_id", planIDs...))
+func getPlansByBrokerID(ctx context.Context, repository storage.Repository, brokerID string) ([]*types.ServicePlan, error) {
+	offeringIDs, err := getOfferingIDsByBrokerID(ctx, repository, brokerID)
 	if err != nil {
-		log.C(ctx).Errorf("Could not get %s: %v", types.VisibilityType, err)
 		return nil, err
 	}
-	visibilities := (visibilitiesList.(*types.Visibilities)).Visibilities
-	visiblePlans := make(map[string]bool)
-	for _, v := range visibilities {
-		visiblePlans[v.ServicePlanID] = true
+	if len(offeringIDs) == 0 {
+		return []*types.ServicePlan{}, nil
 	}
 
-	plans := (plansList.(*types.ServicePlans)).ServicePlans
-	visibleCatalogPlans := make(map[string]bool)
-	for _, p := range plans {
-		if visiblePlans[p.ID] {
-			visibleCatalogPlans[p.CatalogID] = true
-		}
+	plansList, err := repository.List(ctx, types.ServicePlanType, query.ByField(query.InOperator, "service_offering_id", offeringIDs...))
+	if err != nil {
+		log.C(ctx).Errorf("Could not get %s: %v", types.ServicePlanType, err)
+		return nil, err
 	}
-	return visibleCatalogPlans, nil
+	return plansList.(*types.ServicePlans).ServicePlans, nil
 }
 
 func getOfferingIDsByBrokerID(ctx context.Context, repository storage.Repository, brokerID string) ([]string, error) {
@@ -17,12 +17,15 @@ const CheckVisibilityPluginName = "CheckVisibilityPlugin"
 
 type checkVisibilityPlugin struct {
 	repository storage.Repository
+	staging    *BrokerStaging
 }
 
-// NewCheckVisibilityPlugin creates new plugin that checks if a plan is visible to the user on provision request
-func NewCheckVisibilityPlugin(repository storage.Repository) *checkVisibilityPlugin {
+// NewCheckVisibilityPlugin creates new plugin that checks if a plan is visible to the user on provision request.
+// The plans of brokers in staging state are visible only to the staging platforms.
+func NewCheckVisibilityPlugin(repository storage.Repository, staging *BrokerStaging) *checkVisibilityPlugin {
 	return &checkVisibilityPlugin{
 		repository: repository,
+		staging:    staging,
 	}
 }
 
@@ -68,12 +71,30 @@ func (p *checkVisibilityPlugin) checkVisibility(req *web.Request, next web.Handl
 	if err != nil {
 		return nil, err
 	}
-	byPlanID := query.ByField(query.EqualsOperator, "service_plan_id", planID)
-	visibilitiesList, err := p.repository.List(ctx, types.VisibilityType, byPlanID)
+	isStagingPlan, err := p.staging.IsStagingPlan(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+	if isStagingPlan {
+		if p.staging.IsStagingPlatform(platform) {
+			return next.Handle(req)
+		}
+		log.C(ctx).Errorf("Service plan %v belongs to a broker in staging state and is not visible on platform %v", planID, platform.ID)
+		return nil, &util.HTTPError{
+			ErrorType:   "NotFound",
+			Description: "could not find such service plan",
+			StatusCode:  http.StatusNotFound,
+		}
+	}
+	plan, err := p.repository.Get(ctx, types.ServicePlanType, query.ByField(query.EqualsOperator, "id", planID))
+	if err != nil {
+		return nil, util.HandleStorageError(err, string(types.ServicePlanType))
+	}
+	plansVisibilities, err := storage.ListPlansVisibilities(ctx, p.repository, []*types.ServicePlan{plan.(*types.ServicePlan)})
 	if err != nil {
 		return nil, util.HandleStorageError(err, string(types.VisibilityType))
 	}
-	visibilities := visibilitiesList.(*types.Visibilities)
+	visibilities := plansVisibilities[planID]
 
 	switch platform.Type {
 	case "cloudfoundry":
@@ -94,23 +115,21 @@ func (p *checkVisibilityPlugin) checkVisibility(req *web.Request, next web.Handl
 				StatusCode:  http.StatusBadRequest,
 			}
 		}
-		for _, v := range visibilities.Visibilities {
-			if v.PlatformID == "" { // public visibility
+		for _, v := range visibilities {
+			if !storage.VisibilityAppliesTo(ctx, v, platform) {
+				continue
+			}
+			if v.IsPublic() || v.Labels == nil {
 				return next.Handle(req)
 			}
-			if v.PlatformID == platform.ID {
-				if v.Labels == nil {
-					return next.Handle(req)
-				}
-				orgGUIDs, ok := v.Labels["organization_guid"]
-				if !ok {
+			orgGUIDs, ok := v.Labels["organization_guid"]
+			if !ok {
+				return next.Handle(req)
+			}
+			for _, orgGUID := range orgGUIDs {
+				if payloadOrgGUID == orgGUID {
 					return next.Handle(req)
 				}
-				for _, orgGUID := range orgGUIDs {
-					if payloadOrgGUID == orgGUID {
-						return next.Handle(req)
-					}
-				}
 			}
 		}
 		log.C(ctx).Errorf("Service plan %v is not visible on platform %v", planID, platform.ID)
@@ -120,11 +139,8 @@ func (p *checkVisibilityPlugin) checkVisibility(req *web.Request, next web.Handl
 			StatusCode:  http.StatusNotFound,
 		}
 	default:
-		for _, v := range visibilities.Visibilities {
-			if v.PlatformID == "" { // public visibility
-				return next.Handle(req)
-			}
-			if v.PlatformID == platform.ID {
+		for _, v := range visibilities {
+			if storage.VisibilityAppliesTo(ctx, v, platform) {
 				return next.Handle(req)
 			}
 		}
@@ -0,0 +1,171 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"encoding/json"
+
+	"github.com/Peripli/service-manager/pkg/log"
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/pkg/web"
+	"github.com/Peripli/service-manager/storage"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// ContextEnrichmentPluginName is the name of the context enrichment plugin
+	ContextEnrichmentPluginName = "ContextEnrichmentPlugin"
+
+	// SMContextKey is the key of the OSB context block which holds the Service Manager metadata
+	SMContextKey = "sm"
+)
+
+type smContextPlatform struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// smContext is the Service Manager metadata which is injected in the OSB context
+type smContext struct {
+	Platform       *smContextPlatform `json:"platform,omitempty"`
+	Tenant         string             `json:"tenant,omitempty"`
+	InstanceLabels types.Labels       `json:"instance_labels,omitempty"`
+	PlatformLabels types.Labels       `json:"platform_labels,omitempty"`
+}
+
+type contextEnrichmentPlugin struct {
+	repository  storage.Repository
+	tenantLabel string
+	labels      map[string]bool
+}
+
+// NewContextEnrichmentPlugin creates new plugin that injects Service Manager metadata in the context of provision, update
+// and bind requests. The metadata contains the platform which sent the request, the tenant specified by the tenant label
+// and the instance and platform labels which are present in the provided allow-list.
+func NewContextEnrichmentPlugin(repository storage.Repository, tenantLabel string, labels []string) *contextEnrichmentPlugin {
+	allowedLabels := make(map[string]bool, len(labels))
+	for _, label := range labels {
+		allowedLabels[label] = true
+	}
+	return &contextEnrichmentPlugin{
+		repository:  repository,
+		tenantLabel: tenantLabel,
+		labels:      allowedLabels,
+	}
+}
+
+// Name returns the name of the plugin
+func (p *contextEnrichmentPlugin) Name() string {
+	return ContextEnrichmentPluginName
+}
+
+// Provision intercepts provision requests and injects the Service Manager metadata in the context
+func (p *contextEnrichmentPlugin) Provision(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.enrich(req, next, false)
+}
+
+// UpdateService intercepts update service instance requests and injects the Service Manager metadata in the context
+func (p *contextEnrichmentPlugin) UpdateService(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.enrich(req, next, true)
+}
+
+// Bind intercepts bind requests and injects the Service Manager metadata in the context
+func (p *contextEnrichmentPlugin) Bind(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.enrich(req, next, true)
+}
+
+func (p *contextEnrichmentPlugin) enrich(req *web.Request, next web.Handler, instanceExists bool) (*web.Response, error) {
+	ctx := req.Context()
+	metadata := &smContext{}
+
+	if platform, err := extractPlatformFromContext(ctx); err == nil {
+		metadata.Platform = &smContextPlatform{
+			ID:   platform.ID,
+			Name: platform.Name,
+			Type: platform.Type,
+		}
+		metadata.PlatformLabels = p.allowedLabels(platform.Labels)
+		metadata.Tenant = firstLabelValue(platform.Labels, p.tenantLabel)
+	}
+
+	if instanceExists {
+		instanceID := req.PathParams[InstanceIDPathParam]
+		byID := query.ByField(query.EqualsOperator, "id", instanceID)
+		object, err := p.repository.Get(ctx, types.ServiceInstanceType, byID)
+		if err != nil && err != util.ErrNotFoundInStorage {
+			return nil, util.HandleStorageError(err, string(types.ServiceInstanceType))
+		}
+		if object != nil {
+			instance := object.(*types.ServiceInstance)
+			metadata.InstanceLabels = p.allowedLabels(instance.Labels)
+			if tenant := firstLabelValue(instance.Labels, p.tenantLabel); len(tenant) != 0 {
+				metadata.Tenant = tenant
+			}
+		}
+	}
+
+	// the tenant in the context supplied by the platform is used only if the labels do not specify one
+	// so that platforms cannot act on behalf of other tenants
+	if len(p.tenantLabel) != 0 {
+		if tenant := gjson.GetBytes(req.Body, "context."+p.tenantLabel).String(); len(tenant) != 0 {
+			if len(metadata.Tenant) == 0 {
+				metadata.Tenant = tenant
+			} else if tenant != metadata.Tenant {
+				log.C(ctx).Warnf("Ignoring tenant %s from the context of %s %s as it differs from the labeled tenant %s", tenant, req.Method, req.URL.Path, metadata.Tenant)
+			}
+		}
+	}
+
+	metadataBytes, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	if req.Body, err = sjson.SetRawBytes(req.Body, "context."+SMContextKey, metadataBytes); err != nil {
+		return nil, err
+	}
+	log.C(ctx).Debugf("Injected Service Manager metadata in the context of %s %s", req.Method, req.URL.Path)
+
+	return next.Handle(req)
+}
+
+func (p *contextEnrichmentPlugin) allowedLabels(labels types.Labels) types.Labels {
+	var result types.Labels
+	for key, values := range labels {
+		if !p.labels[key] {
+			continue
+		}
+		if result == nil {
+			result = types.Labels{}
+		}
+		result[key] = values
+	}
+	return result
+}
+
+func firstLabelValue(labels types.Labels, key string) string {
+	if len(key) == 0 {
+		return ""
+	}
+	if values := labels[key]; len(values) != 0 {
+		return values[0]
+	}
+	return ""
+}
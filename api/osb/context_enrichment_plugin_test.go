@@ -0,0 +1,111 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/Peripli/service-manager/api/osb"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/pkg/web"
+	"github.com/Peripli/service-manager/storage/storagefakes"
+	"github.com/tidwall/gjson"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Context enrichment plugin", func() {
+	var (
+		fakeStorage *storagefakes.FakeStorage
+		brokerBody  []byte
+	)
+
+	newRequest := func(body string) *web.Request {
+		httpRequest, err := http.NewRequest(http.MethodPut, "http://localhost/v1/osb/broker-id/v2/service_instances/instance-id", strings.NewReader(body))
+		Expect(err).ToNot(HaveOccurred())
+		user := &web.UserContext{
+			AuthenticationType: web.Basic,
+			Name:               "platform-user",
+			Data: func(data interface{}) error {
+				return json.Unmarshal([]byte(`{"id":"platform-id","name":"platform","type":"kubernetes","labels":{"tenant":["platform-tenant"],"region":["eu"],"secret":["s"]}}`), data)
+			},
+		}
+		return &web.Request{
+			Request:    httpRequest.WithContext(web.ContextWithUser(httpRequest.Context(), user)),
+			PathParams: map[string]string{osb.BrokerIDPathParam: "broker-id", osb.InstanceIDPathParam: "instance-id"},
+			Body:       []byte(body),
+		}
+	}
+
+	next := web.HandlerFunc(func(req *web.Request) (*web.Response, error) {
+		brokerBody = req.Body
+		return &web.Response{StatusCode: http.StatusOK}, nil
+	})
+
+	BeforeEach(func() {
+		fakeStorage = &storagefakes.FakeStorage{}
+		fakeStorage.GetReturns(nil, util.ErrNotFoundInStorage)
+		brokerBody = nil
+	})
+
+	It("injects the platform, tenant and allowed platform labels on provision", func() {
+		plugin := osb.NewContextEnrichmentPlugin(fakeStorage, "tenant", []string{"region"})
+		_, err := plugin.Provision(newRequest(`{"service_id":"s","plan_id":"p","context":{"platform":"kubernetes"}}`), next)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gjson.GetBytes(brokerBody, "context.platform").String()).To(Equal("kubernetes"))
+		Expect(gjson.GetBytes(brokerBody, "context.sm").Raw).To(MatchJSON(`{
+			"platform": {"id": "platform-id", "name": "platform", "type": "kubernetes"},
+			"tenant": "platform-tenant",
+			"platform_labels": {"region": ["eu"]}
+		}`))
+		Expect(fakeStorage.GetCallCount()).To(Equal(0))
+	})
+
+	It("injects the tenant and allowed labels of the instance on bind", func() {
+		fakeStorage.GetReturns(&types.ServiceInstance{
+			Base: types.Base{
+				ID:     "instance-id",
+				Labels: types.Labels{"tenant": []string{"instance-tenant"}, "cost_center": []string{"cc-1"}},
+			},
+		}, nil)
+		plugin := osb.NewContextEnrichmentPlugin(fakeStorage, "tenant", []string{"cost_center"})
+		_, err := plugin.Bind(newRequest(`{"service_id":"s","plan_id":"p"}`), next)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gjson.GetBytes(brokerBody, "context.sm.tenant").String()).To(Equal("instance-tenant"))
+		Expect(gjson.GetBytes(brokerBody, "context.sm.instance_labels").Raw).To(MatchJSON(`{"cost_center":["cc-1"]}`))
+		Expect(gjson.GetBytes(brokerBody, "context.sm.platform_labels").Exists()).To(BeFalse())
+	})
+
+	It("ignores a tenant in the context which differs from the labeled tenant", func() {
+		plugin := osb.NewContextEnrichmentPlugin(fakeStorage, "tenant", nil)
+		_, err := plugin.Provision(newRequest(`{"service_id":"s","plan_id":"p","context":{"tenant":"other-tenant"}}`), next)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gjson.GetBytes(brokerBody, "context.sm.tenant").String()).To(Equal("platform-tenant"))
+	})
+
+	It("overrides the sm block supplied by the platform", func() {
+		plugin := osb.NewContextEnrichmentPlugin(fakeStorage, "", nil)
+		_, err := plugin.UpdateService(newRequest(`{"service_id":"s","context":{"sm":{"tenant":"spoofed"}}}`), next)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gjson.GetBytes(brokerBody, "context.sm.tenant").Exists()).To(BeFalse())
+		Expect(gjson.GetBytes(brokerBody, "context.sm.platform.id").String()).To(Equal("platform-id"))
+	})
+})
@@ -0,0 +1,157 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/Peripli/service-manager/pkg/log"
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/pkg/web"
+	"github.com/Peripli/service-manager/storage"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// DefaultParametersPluginName is the name of the default parameters plugin
+	DefaultParametersPluginName = "DefaultParametersPlugin"
+
+	// AppliedDefaultParametersLabel is the operation label which holds the names of the plan default parameters
+	// that were applied to the request of the operation
+	AppliedDefaultParametersLabel = "applied_default_parameters"
+)
+
+type appliedDefaultParametersKey struct{}
+
+type defaultParametersPlugin struct {
+	repository storage.Repository
+}
+
+// NewDefaultParametersPlugin creates new plugin that merges the default parameters of the plan into the parameters
+// of provision and update requests. Only the default parameters which are not specified by the caller are applied.
+func NewDefaultParametersPlugin(repository storage.Repository) *defaultParametersPlugin {
+	return &defaultParametersPlugin{
+		repository: repository,
+	}
+}
+
+// Name returns the name of the plugin
+func (p *defaultParametersPlugin) Name() string {
+	return DefaultParametersPluginName
+}
+
+// Provision intercepts provision requests and applies the default create parameters of the plan
+func (p *defaultParametersPlugin) Provision(req *web.Request, next web.Handler) (*web.Response, error) {
+	ctx := req.Context()
+	requestPayload := &provisionRequest{}
+	if err := decodeRequestBody(req, requestPayload); err != nil {
+		return nil, err
+	}
+	planID, err := findServicePlanIDByCatalogIDs(ctx, p.repository, requestPayload.BrokerID, requestPayload.ServiceID, requestPayload.PlanID)
+	if err != nil {
+		return nil, err
+	}
+	return p.applyDefaults(req, next, planID, "create")
+}
+
+// UpdateService intercepts update service instance requests and applies the default update parameters of the plan
+func (p *defaultParametersPlugin) UpdateService(req *web.Request, next web.Handler) (*web.Response, error) {
+	ctx := req.Context()
+	requestPayload := &updateRequest{}
+	if err := decodeRequestBody(req, requestPayload); err != nil {
+		return nil, err
+	}
+
+	var planID string
+	var err error
+	if len(requestPayload.PlanID) != 0 {
+		if planID, err = findServicePlanIDByCatalogIDs(ctx, p.repository, requestPayload.BrokerID, requestPayload.ServiceID, requestPayload.PlanID); err != nil {
+			return nil, err
+		}
+	} else {
+		byID := query.ByField(query.EqualsOperator, "id", requestPayload.InstanceID)
+		instance, err := p.repository.Get(ctx, types.ServiceInstanceType, byID)
+		if err != nil {
+			if err == util.ErrNotFoundInStorage {
+				return next.Handle(req)
+			}
+			return nil, util.HandleStorageError(err, string(types.ServiceInstanceType))
+		}
+		planID = instance.(*types.ServiceInstance).ServicePlanID
+	}
+	return p.applyDefaults(req, next, planID, "update")
+}
+
+func (p *defaultParametersPlugin) applyDefaults(req *web.Request, next web.Handler, planID, operation string) (*web.Response, error) {
+	ctx := req.Context()
+	byID := query.ByField(query.EqualsOperator, "id", planID)
+	plan, err := p.repository.Get(ctx, types.ServicePlanType, byID)
+	if err != nil {
+		return nil, util.HandleStorageError(err, string(types.ServicePlanType))
+	}
+
+	defaults := gjson.GetBytes(plan.(*types.ServicePlan).DefaultParameters, operation)
+	if !defaults.IsObject() {
+		return next.Handle(req)
+	}
+	parameters := gjson.GetBytes(req.Body, "parameters")
+	if parameters.Exists() && !parameters.IsObject() {
+		log.C(ctx).Warnf("Parameters of %s %s are not an object. Default parameters of plan %s will not be applied", req.Method, req.URL.Path, planID)
+		return next.Handle(req)
+	}
+
+	mergedParameters := make(map[string]json.RawMessage)
+	parameters.ForEach(func(key, value gjson.Result) bool {
+		mergedParameters[key.String()] = json.RawMessage(value.Raw)
+		return true
+	})
+	var applied []string
+	defaults.ForEach(func(key, value gjson.Result) bool {
+		if _, found := mergedParameters[key.String()]; !found {
+			mergedParameters[key.String()] = json.RawMessage(value.Raw)
+			applied = append(applied, key.String())
+		}
+		return true
+	})
+	if len(applied) == 0 {
+		return next.Handle(req)
+	}
+	sort.Strings(applied)
+
+	mergedParametersBytes, err := json.Marshal(mergedParameters)
+	if err != nil {
+		return nil, err
+	}
+	if req.Body, err = sjson.SetRawBytes(req.Body, "parameters", mergedParametersBytes); err != nil {
+		return nil, err
+	}
+	log.C(ctx).Infof("Applied default %s parameters %v of plan %s", operation, applied, planID)
+
+	req.Request = req.WithContext(context.WithValue(ctx, appliedDefaultParametersKey{}, applied))
+	return next.Handle(req)
+}
+
+// appliedDefaultParameters returns the names of the plan default parameters which were applied to the request with the provided context
+func appliedDefaultParameters(ctx context.Context) []string {
+	applied, _ := ctx.Value(appliedDefaultParametersKey{}).([]string)
+	return applied
+}
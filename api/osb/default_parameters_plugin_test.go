@@ -0,0 +1,96 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/Peripli/service-manager/api/osb"
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/web"
+	"github.com/Peripli/service-manager/storage/storagefakes"
+	"github.com/tidwall/gjson"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Default parameters plugin", func() {
+	var (
+		fakeStorage *storagefakes.FakeStorage
+		plan        *types.ServicePlan
+		brokerBody  []byte
+	)
+
+	provision := func(body string) {
+		httpRequest, err := http.NewRequest(http.MethodPut, "http://localhost/v1/osb/broker-id/v2/service_instances/instance-id", strings.NewReader(body))
+		Expect(err).ToNot(HaveOccurred())
+		user := &web.UserContext{
+			AuthenticationType: web.Basic,
+			Name:               "platform-user",
+			Data: func(data interface{}) error {
+				return json.Unmarshal([]byte(`{"id":"platform-id","name":"platform","type":"kubernetes"}`), data)
+			},
+		}
+		request := &web.Request{
+			Request:    httpRequest.WithContext(web.ContextWithUser(httpRequest.Context(), user)),
+			PathParams: map[string]string{osb.BrokerIDPathParam: "broker-id", osb.InstanceIDPathParam: "instance-id"},
+			Body:       []byte(body),
+		}
+		plugin := osb.NewDefaultParametersPlugin(fakeStorage)
+		_, err = plugin.Provision(request, web.HandlerFunc(func(req *web.Request) (*web.Response, error) {
+			brokerBody = req.Body
+			return &web.Response{StatusCode: http.StatusCreated}, nil
+		}))
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		brokerBody = nil
+		plan = &types.ServicePlan{
+			Base:              types.Base{ID: "plan-id"},
+			DefaultParameters: json.RawMessage(`{"create":{"region":"eu","backup":{"enabled":true}},"update":{"region":"us"}}`),
+		}
+		fakeStorage = &storagefakes.FakeStorage{}
+		fakeStorage.GetStub = func(ctx context.Context, objectType types.ObjectType, criteria ...query.Criterion) (types.Object, error) {
+			if objectType == types.ServiceOfferingType {
+				return &types.ServiceOffering{Base: types.Base{ID: "offering-id"}}, nil
+			}
+			return plan, nil
+		}
+	})
+
+	It("applies the default create parameters which are not specified by the caller", func() {
+		provision(`{"service_id":"s","plan_id":"p","parameters":{"region":"asia"}}`)
+		Expect(gjson.GetBytes(brokerBody, "parameters").Raw).To(MatchJSON(`{"region":"asia","backup":{"enabled":true}}`))
+	})
+
+	It("adds the parameters when the caller specified none", func() {
+		provision(`{"service_id":"s","plan_id":"p"}`)
+		Expect(gjson.GetBytes(brokerBody, "parameters").Raw).To(MatchJSON(`{"region":"eu","backup":{"enabled":true}}`))
+	})
+
+	It("does not modify the request when the plan has no default parameters", func() {
+		plan.DefaultParameters = nil
+		provision(`{"service_id":"s","plan_id":"p"}`)
+		Expect(string(brokerBody)).To(Equal(`{"service_id":"s","plan_id":"p"}`))
+	})
+})
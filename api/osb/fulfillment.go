@@ -0,0 +1,295 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Peripli/service-manager/pkg/log"
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/pkg/web"
+	"github.com/Peripli/service-manager/storage"
+	"github.com/gofrs/uuid"
+)
+
+// FulfillmentHandler fulfills the OSB requests for the service instances and bindings of catalog-only brokers
+// which have no OSB endpoint to proxy the requests to
+type FulfillmentHandler interface {
+	// Provision handles a provision request
+	Provision(req *web.Request, broker *types.ServiceBroker) (*web.Response, error)
+	// Deprovision handles a deprovision request
+	Deprovision(req *web.Request, broker *types.ServiceBroker) (*web.Response, error)
+	// Bind handles a bind request
+	Bind(req *web.Request, broker *types.ServiceBroker) (*web.Response, error)
+	// Unbind handles an unbind request
+	Unbind(req *web.Request, broker *types.ServiceBroker) (*web.Response, error)
+	// FetchBinding handles a request for fetching a service binding
+	FetchBinding(req *web.Request, broker *types.ServiceBroker) (*web.Response, error)
+	// LastOperation handles a last operation request for either a service instance or a service binding
+	LastOperation(req *web.Request, broker *types.ServiceBroker) (*web.Response, error)
+}
+
+// Fulfillment is the content of the operations which track the fulfillment of the OSB requests for catalog-only brokers
+type Fulfillment struct {
+	InstanceID string          `json:"instance_id"`
+	BindingID  string          `json:"binding_id,omitempty"`
+	Request    json.RawMessage `json:"request,omitempty"`
+	Response   json.RawMessage `json:"response,omitempty"`
+}
+
+// FulfillmentCompletion completes the fulfillment of an OSB request for a catalog-only broker. The response is
+// returned to the platform when it fetches the service binding, so it should contain the binding credentials.
+type FulfillmentCompletion struct {
+	State       types.OperationState `json:"state"`
+	Description string               `json:"description"`
+	Response    json.RawMessage      `json:"response,omitempty"`
+}
+
+// Validate implements InputValidator and verifies that the completion is either successful or failed
+func (c *FulfillmentCompletion) Validate() error {
+	if c.State != types.SUCCEEDED && c.State != types.FAILED {
+		return fmt.Errorf("invalid state %s: should be either %s or %s", c.State, types.SUCCEEDED, types.FAILED)
+	}
+	if len(c.Response) != 0 {
+		if err := json.Unmarshal(c.Response, &map[string]json.RawMessage{}); err != nil {
+			return errors.New("response should be a JSON object")
+		}
+	}
+	return nil
+}
+
+// Complete sets the state and the response of the fulfillment operation
+func (c *FulfillmentCompletion) Complete(operation *types.Operation) error {
+	if operation.ResourceType != types.FulfillmentResourceType || operation.State != types.IN_PROGRESS {
+		return &util.HTTPError{
+			ErrorType:   "Conflict",
+			Description: fmt.Sprintf("operation %s is not a pending fulfillment", operation.ID),
+			StatusCode:  http.StatusConflict,
+		}
+	}
+	fulfillment := &Fulfillment{}
+	if err := json.Unmarshal(operation.Fulfillment, fulfillment); err != nil {
+		return fmt.Errorf("could not read fulfillment of operation %s: %s", operation.ID, err)
+	}
+	fulfillment.Response = c.Response
+	fulfillmentBytes, err := json.Marshal(fulfillment)
+	if err != nil {
+		return err
+	}
+
+	operation.Fulfillment = fulfillmentBytes
+	operation.State = c.State
+	operation.UpdatedAt = time.Now().UTC()
+	if len(c.Description) != 0 {
+		operation.Description = c.Description
+	}
+	if c.State == types.FAILED {
+		if operation.Errors, err = json.Marshal(map[string]string{"description": operation.Description}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OperationFulfillmentHandler is the default fulfillment handler. It creates a pending operation for each request
+// which an administrator completes through the Service Manager API once the request is fulfilled.
+type OperationFulfillmentHandler struct {
+	repository storage.Repository
+}
+
+// NewOperationFulfillmentHandler creates a fulfillment handler which tracks the requests as pending operations
+func NewOperationFulfillmentHandler(repository storage.Repository) *OperationFulfillmentHandler {
+	return &OperationFulfillmentHandler{
+		repository: repository,
+	}
+}
+
+var _ FulfillmentHandler = &OperationFulfillmentHandler{}
+
+// Provision creates a pending provision operation
+func (h *OperationFulfillmentHandler) Provision(req *web.Request, broker *types.ServiceBroker) (*web.Response, error) {
+	return h.request(req, broker, types.CREATE, "provisioning")
+}
+
+// Deprovision creates a pending deprovision operation
+func (h *OperationFulfillmentHandler) Deprovision(req *web.Request, broker *types.ServiceBroker) (*web.Response, error) {
+	return h.request(req, broker, types.DELETE, "deprovisioning")
+}
+
+// Bind creates a pending bind operation
+func (h *OperationFulfillmentHandler) Bind(req *web.Request, broker *types.ServiceBroker) (*web.Response, error) {
+	return h.request(req, broker, types.CREATE, "binding")
+}
+
+// Unbind creates a pending unbind operation
+func (h *OperationFulfillmentHandler) Unbind(req *web.Request, broker *types.ServiceBroker) (*web.Response, error) {
+	return h.request(req, broker, types.DELETE, "unbinding")
+}
+
+// FetchBinding returns the response with which the bind operation of the service binding was completed
+func (h *OperationFulfillmentHandler) FetchBinding(req *web.Request, broker *types.ServiceBroker) (*web.Response, error) {
+	bindingID := req.PathParams[BindingIDPathParam]
+	operation, err := h.lastOperation(req.Context(), broker, fulfillmentKey(req), "")
+	if err != nil && err != util.ErrNotFoundInStorage {
+		return nil, util.HandleStorageError(err, string(types.OperationType))
+	}
+	if operation == nil || operation.Type != types.CREATE || operation.State != types.SUCCEEDED {
+		return nil, &util.HTTPError{
+			ErrorType:   "NotFound",
+			Description: fmt.Sprintf("service binding %s not found", bindingID),
+			StatusCode:  http.StatusNotFound,
+		}
+	}
+
+	fulfillment := &Fulfillment{}
+	if err := json.Unmarshal(operation.Fulfillment, fulfillment); err != nil {
+		return nil, fmt.Errorf("could not read fulfillment of operation %s: %s", operation.ID, err)
+	}
+	response := fulfillment.Response
+	if len(response) == 0 {
+		response = json.RawMessage(`{}`)
+	}
+	return util.NewJSONResponse(http.StatusOK, response)
+}
+
+// LastOperation returns the state of the requested or the last operation for the service instance or binding
+func (h *OperationFulfillmentHandler) LastOperation(req *web.Request, broker *types.ServiceBroker) (*web.Response, error) {
+	operation, err := h.lastOperation(req.Context(), broker, fulfillmentKey(req), req.URL.Query().Get("operation"))
+	if err != nil {
+		return nil, util.HandleStorageError(err, string(types.OperationType))
+	}
+
+	response := map[string]string{
+		"state": string(operation.State),
+	}
+	if len(operation.Description) != 0 {
+		response["description"] = operation.Description
+	}
+	return util.NewJSONResponse(http.StatusOK, response)
+}
+
+func (h *OperationFulfillmentHandler) request(req *web.Request, broker *types.ServiceBroker, category types.OperationCategory, action string) (*web.Response, error) {
+	ctx := req.Context()
+	if req.URL.Query().Get("accepts_incomplete") != "true" {
+		return nil, &util.HTTPError{
+			ErrorType:   "AsyncRequired",
+			Description: fmt.Sprintf("broker %s supports only asynchronous requests", broker.Name),
+			StatusCode:  http.StatusUnprocessableEntity,
+		}
+	}
+
+	key := fulfillmentKey(req)
+	operation, err := h.lastOperation(ctx, broker, key, "")
+	if err != nil && err != util.ErrNotFoundInStorage {
+		return nil, util.HandleStorageError(err, string(types.OperationType))
+	}
+	if operation != nil && operation.State == types.IN_PROGRESS {
+		if operation.Type != category {
+			return nil, &util.HTTPError{
+				ErrorType:   "ConcurrencyError",
+				Description: fmt.Sprintf("another operation for %s is in progress", key),
+				StatusCode:  http.StatusUnprocessableEntity,
+			}
+		}
+		return util.NewJSONResponse(http.StatusAccepted, map[string]string{"operation": operation.ID})
+	}
+
+	fulfillment, err := json.Marshal(&Fulfillment{
+		InstanceID: req.PathParams[InstanceIDPathParam],
+		BindingID:  req.PathParams[BindingIDPathParam],
+		Request:    requestContent(req),
+	})
+	if err != nil {
+		return nil, err
+	}
+	UUID, err := uuid.NewV4()
+	if err != nil {
+		return nil, fmt.Errorf("could not generate GUID for operation: %s", err)
+	}
+	now := time.Now().UTC()
+	operation = &types.Operation{
+		Base: types.Base{
+			ID:        UUID.String(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			Labels:    make(map[string][]string),
+		},
+		Description:   fmt.Sprintf("%s of %s awaits fulfillment", action, key),
+		Type:          category,
+		State:         types.IN_PROGRESS,
+		ResourceID:    broker.ID,
+		ResourceType:  types.FulfillmentResourceType,
+		CorrelationID: log.CorrelationIDForRequest(req.Request),
+		ExternalID:    key,
+		Fulfillment:   fulfillment,
+	}
+	if _, err := h.repository.Create(ctx, operation); err != nil {
+		return nil, util.HandleStorageError(err, string(types.OperationType))
+	}
+	log.C(ctx).Infof("Created operation %s for the %s of %s of broker %s", operation.ID, action, key, broker.Name)
+
+	return util.NewJSONResponse(http.StatusAccepted, map[string]string{"operation": operation.ID})
+}
+
+func (h *OperationFulfillmentHandler) lastOperation(ctx context.Context, broker *types.ServiceBroker, key, operationID string) (*types.Operation, error) {
+	criteria := []query.Criterion{
+		query.ByField(query.EqualsOperator, "resource_id", broker.ID),
+		query.ByField(query.EqualsOperator, "resource_type", types.FulfillmentResourceType),
+		query.ByField(query.EqualsOperator, "external_id", key),
+		query.OrderResultBy("paging_sequence", query.DescOrder),
+	}
+	if len(operationID) != 0 {
+		criteria = append(criteria, query.ByField(query.EqualsOperator, "id", operationID))
+	}
+	operation, err := h.repository.Get(ctx, types.OperationType, criteria...)
+	if err != nil {
+		return nil, err
+	}
+	return operation.(*types.Operation), nil
+}
+
+// fulfillmentKey identifies the service instance or binding of the request
+func fulfillmentKey(req *web.Request) string {
+	key := "service instance " + req.PathParams[InstanceIDPathParam]
+	if bindingID, found := req.PathParams[BindingIDPathParam]; found {
+		key = "service binding " + bindingID + " of " + key
+	}
+	return key
+}
+
+// requestContent returns the body of the request or its query parameters for requests without body
+func requestContent(req *web.Request) json.RawMessage {
+	if len(req.Body) != 0 {
+		return req.Body
+	}
+	parameters := make(map[string]string)
+	for name := range req.URL.Query() {
+		parameters[name] = req.URL.Query().Get(name)
+	}
+	content, err := json.Marshal(parameters)
+	if err != nil {
+		return nil
+	}
+	return content
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/Peripli/service-manager/api/osb"
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/pkg/web"
+	"github.com/Peripli/service-manager/storage/storagefakes"
+	"github.com/tidwall/gjson"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Operation fulfillment handler", func() {
+	var (
+		fakeStorage *storagefakes.FakeStorage
+		handler     *osb.OperationFulfillmentHandler
+		broker      *types.ServiceBroker
+		operations  []*types.Operation
+	)
+
+	newRequest := func(method, url string, body string, pathParams map[string]string) *web.Request {
+		httpRequest, err := http.NewRequest(method, url, nil)
+		Expect(err).ToNot(HaveOccurred())
+		return &web.Request{
+			Request:    httpRequest,
+			PathParams: pathParams,
+			Body:       []byte(body),
+		}
+	}
+
+	provision := func() *web.Response {
+		request := newRequest(http.MethodPut, "http://localhost/v1/osb/broker-id/v2/service_instances/instance-id?accepts_incomplete=true",
+			`{"service_id":"s","plan_id":"p"}`, map[string]string{osb.InstanceIDPathParam: "instance-id"})
+		response, err := handler.Provision(request, broker)
+		Expect(err).ToNot(HaveOccurred())
+		return response
+	}
+
+	BeforeEach(func() {
+		operations = nil
+		broker = &types.ServiceBroker{
+			Base:          types.Base{ID: "broker-id"},
+			Name:          "manual-broker",
+			StaticCatalog: json.RawMessage(`{"services":[]}`),
+		}
+		fakeStorage = &storagefakes.FakeStorage{}
+		fakeStorage.CreateStub = func(ctx context.Context, object types.Object) (types.Object, error) {
+			operations = append(operations, object.(*types.Operation))
+			return object, nil
+		}
+		fakeStorage.GetStub = func(ctx context.Context, objectType types.ObjectType, criteria ...query.Criterion) (types.Object, error) {
+			if len(operations) == 0 {
+				return nil, util.ErrNotFoundInStorage
+			}
+			return operations[len(operations)-1], nil
+		}
+		handler = osb.NewOperationFulfillmentHandler(fakeStorage)
+	})
+
+	It("creates a pending operation for the request", func() {
+		response := provision()
+		Expect(response.StatusCode).To(Equal(http.StatusAccepted))
+		Expect(operations).To(HaveLen(1))
+
+		operation := operations[0]
+		Expect(gjson.GetBytes(response.Body, "operation").String()).To(Equal(operation.ID))
+		Expect(operation.State).To(Equal(types.IN_PROGRESS))
+		Expect(operation.ResourceID).To(Equal(broker.ID))
+		Expect(operation.ResourceType).To(Equal(types.FulfillmentResourceType))
+		Expect(gjson.GetBytes(operation.Fulfillment, "instance_id").String()).To(Equal("instance-id"))
+		Expect(gjson.GetBytes(operation.Fulfillment, "request.plan_id").String()).To(Equal("p"))
+	})
+
+	It("returns the pending operation for repeated requests", func() {
+		first := provision()
+		second := provision()
+		Expect(operations).To(HaveLen(1))
+		Expect(second.StatusCode).To(Equal(http.StatusAccepted))
+		Expect(second.Body).To(MatchJSON(first.Body))
+	})
+
+	It("requires asynchronous requests", func() {
+		request := newRequest(http.MethodPut, "http://localhost/v1/osb/broker-id/v2/service_instances/instance-id",
+			`{}`, map[string]string{osb.InstanceIDPathParam: "instance-id"})
+		_, err := handler.Provision(request, broker)
+		Expect(err).To(HaveOccurred())
+		Expect(err.(*util.HTTPError).StatusCode).To(Equal(http.StatusUnprocessableEntity))
+	})
+
+	It("returns the binding response after the bind operation is completed", func() {
+		bindingParams := map[string]string{osb.InstanceIDPathParam: "instance-id", osb.BindingIDPathParam: "binding-id"}
+		bindingURL := "http://localhost/v1/osb/broker-id/v2/service_instances/instance-id/service_bindings/binding-id"
+		_, err := handler.Bind(newRequest(http.MethodPut, bindingURL+"?accepts_incomplete=true", `{}`, bindingParams), broker)
+		Expect(err).ToNot(HaveOccurred())
+
+		lastOperation, err := handler.LastOperation(newRequest(http.MethodGet, bindingURL+"/last_operation", "", bindingParams), broker)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gjson.GetBytes(lastOperation.Body, "state").String()).To(Equal(string(types.IN_PROGRESS)))
+
+		_, err = handler.FetchBinding(newRequest(http.MethodGet, bindingURL, "", bindingParams), broker)
+		Expect(err).To(HaveOccurred())
+
+		completion := &osb.FulfillmentCompletion{
+			State:    types.SUCCEEDED,
+			Response: json.RawMessage(`{"credentials":{"password":"secret"}}`),
+		}
+		Expect(completion.Validate()).To(Succeed())
+		Expect(completion.Complete(operations[0])).To(Succeed())
+
+		lastOperation, err = handler.LastOperation(newRequest(http.MethodGet, bindingURL+"/last_operation", "", bindingParams), broker)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gjson.GetBytes(lastOperation.Body, "state").String()).To(Equal(string(types.SUCCEEDED)))
+
+		binding, err := handler.FetchBinding(newRequest(http.MethodGet, bindingURL, "", bindingParams), broker)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(binding.Body).To(MatchJSON(`{"credentials":{"password":"secret"}}`))
+	})
+
+	It("does not complete operations which are not pending", func() {
+		provision()
+		completion := &osb.FulfillmentCompletion{State: types.FAILED, Description: "rejected"}
+		Expect(completion.Complete(operations[0])).To(Succeed())
+		Expect(operations[0].State).To(Equal(types.FAILED))
+		Expect(completion.Complete(operations[0])).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,131 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/storage"
+)
+
+// MarketplaceOffering is a service offering with the service plans which are visible to a platform
+type MarketplaceOffering struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	CatalogID   string             `json:"catalog_id"`
+	BrokerID    string             `json:"broker_id"`
+	BrokerName  string             `json:"broker_name"`
+	Plans       []*MarketplacePlan `json:"plans"`
+}
+
+// MarketplacePlan is a service plan which is visible to a platform together with the reason for its visibility
+type MarketplacePlan struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	CatalogID   string     `json:"catalog_id"`
+	Free        bool       `json:"free"`
+	GrantedBy   *PlanGrant `json:"granted_by"`
+}
+
+// PlatformMarketplace returns the service offerings and plans which are visible to the platform. The plans are
+// resolved in the same way as the plans in the catalogs which are returned to the platform.
+func PlatformMarketplace(ctx context.Context, repository storage.Repository, staging *BrokerStaging, platform *types.Platform) ([]*MarketplaceOffering, error) {
+	brokerList, err := repository.List(ctx, types.ServiceBrokerType, query.OrderResultBy("name", query.AscOrder))
+	if err != nil {
+		return nil, err
+	}
+
+	marketplace := make([]*MarketplaceOffering, 0)
+	for _, broker := range brokerList.(*types.ServiceBrokers).ServiceBrokers {
+		offerings, err := brokerMarketplace(ctx, repository, staging, platform, broker)
+		if err != nil {
+			return nil, err
+		}
+		marketplace = append(marketplace, offerings...)
+	}
+	return marketplace, nil
+}
+
+func brokerMarketplace(ctx context.Context, repository storage.Repository, staging *BrokerStaging, platform *types.Platform, broker *types.ServiceBroker) ([]*MarketplaceOffering, error) {
+	offeringList, err := repository.List(ctx, types.ServiceOfferingType,
+		query.ByField(query.EqualsOperator, "broker_id", broker.ID),
+		query.OrderResultBy("name", query.AscOrder))
+	if err != nil {
+		return nil, err
+	}
+	offerings := offeringList.(*types.ServiceOfferings).ServiceOfferings
+	if len(offerings) == 0 {
+		return []*MarketplaceOffering{}, nil
+	}
+	offeringIDs := make([]string, 0, len(offerings))
+	for _, offering := range offerings {
+		offeringIDs = append(offeringIDs, offering.ID)
+	}
+
+	planList, err := repository.List(ctx, types.ServicePlanType, query.ByField(query.InOperator, "service_offering_id", offeringIDs...))
+	if err != nil {
+		return nil, err
+	}
+	plans := planList.(*types.ServicePlans).ServicePlans
+	sort.Slice(plans, func(i, j int) bool {
+		return plans[i].Name < plans[j].Name
+	})
+
+	grants, err := VisiblePlanGrants(ctx, repository, staging, broker.ID, platform, plans)
+	if err != nil {
+		return nil, err
+	}
+
+	visiblePlans := make(map[string][]*MarketplacePlan)
+	for _, plan := range plans {
+		grant, visible := grants[plan.ID]
+		if !visible {
+			continue
+		}
+		visiblePlans[plan.ServiceOfferingID] = append(visiblePlans[plan.ServiceOfferingID], &MarketplacePlan{
+			ID:          plan.ID,
+			Name:        plan.Name,
+			Description: plan.Description,
+			CatalogID:   plan.CatalogID,
+			Free:        plan.Free,
+			GrantedBy:   grant,
+		})
+	}
+
+	result := make([]*MarketplaceOffering, 0, len(visiblePlans))
+	for _, offering := range offerings {
+		// offerings without visible plans are not part of the catalog of the platform
+		if len(visiblePlans[offering.ID]) == 0 {
+			continue
+		}
+		result = append(result, &MarketplaceOffering{
+			ID:          offering.ID,
+			Name:        offering.Name,
+			Description: offering.Description,
+			CatalogID:   offering.CatalogID,
+			BrokerID:    broker.ID,
+			BrokerName:  broker.Name,
+			Plans:       visiblePlans[offering.ID],
+		})
+	}
+	return result, nil
+}
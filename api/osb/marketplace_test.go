@@ -0,0 +1,115 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb_test
+
+import (
+	"context"
+
+	"github.com/Peripli/service-manager/api/osb"
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/storage/storagefakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Platform marketplace", func() {
+	var (
+		fakeStorage *storagefakes.FakeStorage
+		staging     *osb.BrokerStaging
+		platform    *types.Platform
+	)
+
+	BeforeEach(func() {
+		fakeStorage = &storagefakes.FakeStorage{}
+		fakeStorage.ListStub = func(ctx context.Context, objectType types.ObjectType, criteria ...query.Criterion) (types.ObjectList, error) {
+			switch objectType {
+			case types.ServiceBrokerType:
+				return &types.ServiceBrokers{ServiceBrokers: []*types.ServiceBroker{
+					{Base: types.Base{ID: "broker-id"}, Name: "broker"},
+				}}, nil
+			case types.ServiceOfferingType:
+				return &types.ServiceOfferings{ServiceOfferings: []*types.ServiceOffering{
+					{Base: types.Base{ID: "offering-id"}, Name: "offering", BrokerID: "broker-id"},
+					{Base: types.Base{ID: "hidden-offering-id"}, Name: "hidden-offering", BrokerID: "broker-id"},
+				}}, nil
+			case types.ServicePlanType:
+				return &types.ServicePlans{ServicePlans: []*types.ServicePlan{
+					{Base: types.Base{ID: "public-plan-id"}, Name: "public", ServiceOfferingID: "offering-id"},
+					{Base: types.Base{ID: "platform-plan-id"}, Name: "platform", ServiceOfferingID: "offering-id"},
+					{Base: types.Base{ID: "selected-plan-id"}, Name: "selected", ServiceOfferingID: "offering-id"},
+					{Base: types.Base{ID: "hidden-plan-id"}, Name: "hidden", ServiceOfferingID: "hidden-offering-id"},
+				}}, nil
+			default:
+				return &types.Visibilities{Visibilities: []*types.Visibility{
+					{Base: types.Base{ID: "public"}, Active: true, ServicePlanID: "public-plan-id"},
+					{Base: types.Base{ID: "public-platform"}, Active: true, ServicePlanID: "platform-plan-id"},
+					{Base: types.Base{ID: "platform"}, Active: true, ServicePlanID: "platform-plan-id", PlatformID: "platform-id"},
+					{Base: types.Base{ID: "selected"}, Active: true, ServicePlanID: "selected-plan-id", Selector: "env eq 'dev'"},
+					{Base: types.Base{ID: "not-selected"}, Active: true, ServicePlanID: "hidden-plan-id", Selector: "env eq 'prod'"},
+				}}, nil
+			}
+		}
+		staging = &osb.BrokerStaging{Repository: fakeStorage}
+		platform = &types.Platform{
+			Base: types.Base{ID: "platform-id", Labels: types.Labels{"env": {"dev"}}},
+			Type: types.K8sPlatformType,
+		}
+	})
+
+	grantedBy := func(offerings []*osb.MarketplaceOffering) map[string]*osb.PlanGrant {
+		grants := make(map[string]*osb.PlanGrant)
+		for _, offering := range offerings {
+			for _, plan := range offering.Plans {
+				grants[plan.ID] = plan.GrantedBy
+			}
+		}
+		return grants
+	}
+
+	It("returns the plans which are visible to the platform with the most specific visibilities which grant them", func() {
+		offerings, err := osb.PlatformMarketplace(context.Background(), fakeStorage, staging, platform)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(offerings).To(HaveLen(1))
+		Expect(offerings[0].ID).To(Equal("offering-id"))
+		Expect(offerings[0].BrokerName).To(Equal("broker"))
+
+		grants := grantedBy(offerings)
+		Expect(grants).To(HaveLen(3))
+		Expect(grants["public-plan-id"].Visibility.ID).To(Equal("public"))
+		Expect(grants["platform-plan-id"].Visibility.ID).To(Equal("platform"))
+		Expect(grants["selected-plan-id"].Visibility.ID).To(Equal("selected"))
+		for _, grant := range grants {
+			Expect(grant.Reason).To(Equal(osb.PlanGrantedByVisibility))
+		}
+	})
+
+	It("returns all plans to platforms whose catalogs are not filtered", func() {
+		platform.Type = "cloudfoundry"
+		offerings, err := osb.PlatformMarketplace(context.Background(), fakeStorage, staging, platform)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(offerings).To(HaveLen(2))
+
+		grants := grantedBy(offerings)
+		Expect(grants).To(HaveLen(4))
+		for _, grant := range grants {
+			Expect(grant.Reason).To(Equal(osb.PlanGrantedByPlatformType))
+			Expect(grant.Visibility).To(BeNil())
+		}
+	})
+})
@@ -0,0 +1,229 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Peripli/service-manager/pkg/log"
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/pkg/web"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// OriginatingIdentityPluginName is the name of the originating identity plugin
+	OriginatingIdentityPluginName = "OriginatingIdentityPlugin"
+
+	// OriginatingIdentityHeader is the OSB header which carries the identity of the user that initiated the request
+	OriginatingIdentityHeader = "X-Broker-API-Originating-Identity"
+
+	// SMOriginatingIdentityPlatform is the platform value used in the originating identity of requests
+	// which were not initiated by a platform
+	SMOriginatingIdentityPlatform = "service-manager"
+)
+
+// OriginatingIdentityPolicy specifies how an originating identity supplied by the platform is treated
+type OriginatingIdentityPolicy string
+
+const (
+	// TrustOriginatingIdentity forwards the identity supplied by the platform and builds one only if none was supplied
+	TrustOriginatingIdentity OriginatingIdentityPolicy = "trust"
+
+	// OverrideOriginatingIdentity replaces the identity supplied by the platform with the one built from the user context
+	OverrideOriginatingIdentity OriginatingIdentityPolicy = "override"
+
+	// AppendOriginatingIdentity builds the identity from the user context and includes the identity supplied by the
+	// platform in its value, so that a single identity is forwarded as required by the OSB spec
+	AppendOriginatingIdentity OriginatingIdentityPolicy = "append"
+)
+
+// Validate validates the originating identity policy
+func (p OriginatingIdentityPolicy) Validate() error {
+	switch p {
+	case TrustOriginatingIdentity, OverrideOriginatingIdentity, AppendOriginatingIdentity:
+		return nil
+	default:
+		return fmt.Errorf("unsupported originating identity policy %s, supported values are %s, %s and %s",
+			p, TrustOriginatingIdentity, OverrideOriginatingIdentity, AppendOriginatingIdentity)
+	}
+}
+
+type originatingIdentityPlugin struct {
+	policy      OriginatingIdentityPolicy
+	tenantClaim string
+}
+
+// NewOriginatingIdentityPlugin creates new plugin that sets the originating identity header on the OSB requests
+// based on the authenticated user. For platforms the identity contains the platform type and user, while for
+// OAuth users it contains the token subject and the tenant taken from the specified token claim.
+func NewOriginatingIdentityPlugin(policy OriginatingIdentityPolicy, tenantClaim string) *originatingIdentityPlugin {
+	return &originatingIdentityPlugin{
+		policy:      policy,
+		tenantClaim: tenantClaim,
+	}
+}
+
+// Name returns the name of the plugin
+func (p *originatingIdentityPlugin) Name() string {
+	return OriginatingIdentityPluginName
+}
+
+// Provision intercepts provision requests and sets the originating identity header
+func (p *originatingIdentityPlugin) Provision(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.setOriginatingIdentity(req, next)
+}
+
+// Deprovision intercepts deprovision requests and sets the originating identity header
+func (p *originatingIdentityPlugin) Deprovision(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.setOriginatingIdentity(req, next)
+}
+
+// UpdateService intercepts update service instance requests and sets the originating identity header
+func (p *originatingIdentityPlugin) UpdateService(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.setOriginatingIdentity(req, next)
+}
+
+// FetchService intercepts get service instance requests and sets the originating identity header
+func (p *originatingIdentityPlugin) FetchService(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.setOriginatingIdentity(req, next)
+}
+
+// Bind intercepts bind requests and sets the originating identity header
+func (p *originatingIdentityPlugin) Bind(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.setOriginatingIdentity(req, next)
+}
+
+// Unbind intercepts unbind requests and sets the originating identity header
+func (p *originatingIdentityPlugin) Unbind(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.setOriginatingIdentity(req, next)
+}
+
+// FetchBinding intercepts get service binding requests and sets the originating identity header
+func (p *originatingIdentityPlugin) FetchBinding(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.setOriginatingIdentity(req, next)
+}
+
+// PollInstance intercepts poll instance operation requests and sets the originating identity header
+func (p *originatingIdentityPlugin) PollInstance(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.setOriginatingIdentity(req, next)
+}
+
+// PollBinding intercepts poll binding operation requests and sets the originating identity header
+func (p *originatingIdentityPlugin) PollBinding(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.setOriginatingIdentity(req, next)
+}
+
+func (p *originatingIdentityPlugin) setOriginatingIdentity(req *web.Request, next web.Handler) (*web.Response, error) {
+	ctx := req.Context()
+	supplied := req.Header.Get(OriginatingIdentityHeader)
+	if len(supplied) != 0 && p.policy == TrustOriginatingIdentity {
+		return next.Handle(req)
+	}
+
+	platform, value, err := p.buildOriginatingIdentity(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(platform) == 0 {
+		log.C(ctx).Debugf("No user found in request context. %s header will not be modified", OriginatingIdentityHeader)
+		return next.Handle(req)
+	}
+
+	if len(supplied) != 0 && p.policy == AppendOriginatingIdentity {
+		suppliedPlatform, suppliedValue, err := parseOriginatingIdentity(supplied)
+		if err != nil {
+			return nil, &util.HTTPError{
+				ErrorType:   "BadRequest",
+				Description: fmt.Sprintf("invalid %s header: %s", OriginatingIdentityHeader, err),
+				StatusCode:  http.StatusBadRequest,
+			}
+		}
+		value["originating_identity"] = map[string]interface{}{
+			"platform": suppliedPlatform,
+			"value":    suppliedValue,
+		}
+	}
+
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	// the OSB spec allows a single originating identity so the supplied one is replaced
+	req.Header.Set(OriginatingIdentityHeader, fmt.Sprintf("%s %s", platform, base64.StdEncoding.EncodeToString(bytes)))
+
+	return next.Handle(req)
+}
+
+// buildOriginatingIdentity returns the platform and value of the identity of the user in the request context.
+// The platform is empty if there is no user.
+func (p *originatingIdentityPlugin) buildOriginatingIdentity(req *web.Request) (string, map[string]interface{}, error) {
+	user, ok := web.UserFromContext(req.Context())
+	if !ok {
+		return "", nil, nil
+	}
+
+	var platform string
+	value := make(map[string]interface{})
+	switch user.AuthenticationType {
+	case web.Basic:
+		smPlatform, err := extractPlatformFromContext(req.Context())
+		if err != nil {
+			return "", nil, err
+		}
+		platform = smPlatform.Type
+		value["user_id"] = user.Name
+		value["platform_id"] = smPlatform.ID
+	case web.Bearer:
+		var claims json.RawMessage
+		if err := user.Data(&claims); err != nil {
+			return "", nil, fmt.Errorf("could not unmarshal claims from token: %s", err)
+		}
+		platform = SMOriginatingIdentityPlatform
+		value["user_id"] = gjson.GetBytes(claims, "sub").String()
+		value["user_name"] = user.Name
+		if len(p.tenantClaim) != 0 {
+			if tenant := gjson.GetBytes(claims, p.tenantClaim).String(); len(tenant) != 0 {
+				value["tenant_id"] = tenant
+			}
+		}
+	default:
+		return "", nil, nil
+	}
+	return platform, value, nil
+}
+
+// parseOriginatingIdentity returns the platform and the decoded value of an originating identity header
+func parseOriginatingIdentity(identity string) (string, json.RawMessage, error) {
+	parts := strings.Split(identity, " ")
+	if len(parts) != 2 || len(parts[0]) == 0 {
+		return "", nil, errors.New("expected format is <platform> <base64 encoded JSON value>")
+	}
+	bytes, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, fmt.Errorf("could not decode value: %s", err)
+	}
+	if !json.Valid(bytes) {
+		return "", nil, errors.New("value is not a valid JSON")
+	}
+	return parts[0], json.RawMessage(bytes), nil
+}
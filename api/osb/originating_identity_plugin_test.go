@@ -0,0 +1,143 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/Peripli/service-manager/api/osb"
+	"github.com/Peripli/service-manager/pkg/web"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Originating identity plugin", func() {
+	const platformIdentity = "cloudfoundry eyJ1c2VyX2lkIjoicGxhdGZvcm0tdXNlciJ9"
+
+	var (
+		policy  osb.OriginatingIdentityPolicy
+		user    *web.UserContext
+		request *web.Request
+		headers []string
+	)
+
+	decodeIdentity := func(identity string) (string, map[string]string) {
+		parts := strings.Split(identity, " ")
+		Expect(parts).To(HaveLen(2))
+		bytes, err := base64.StdEncoding.DecodeString(parts[1])
+		Expect(err).ToNot(HaveOccurred())
+		value := make(map[string]string)
+		Expect(json.Unmarshal(bytes, &value)).To(Succeed())
+		return parts[0], value
+	}
+
+	provision := func() {
+		plugin := osb.NewOriginatingIdentityPlugin(policy, "zid")
+		httpRequest, err := http.NewRequest(http.MethodPut, "http://localhost/v1/osb/broker-id/v2/service_instances/instance-id", nil)
+		Expect(err).ToNot(HaveOccurred())
+		httpRequest = httpRequest.WithContext(web.ContextWithUser(httpRequest.Context(), user))
+		request = &web.Request{Request: httpRequest}
+		request.Header.Set(osb.OriginatingIdentityHeader, platformIdentity)
+
+		_, err = plugin.Provision(request, web.HandlerFunc(func(req *web.Request) (*web.Response, error) {
+			headers = req.Header[osb.OriginatingIdentityHeader]
+			return &web.Response{StatusCode: http.StatusCreated}, nil
+		}))
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		policy = osb.OverrideOriginatingIdentity
+		user = &web.UserContext{
+			AuthenticationType: web.Basic,
+			Name:               "platform-user",
+			Data: func(data interface{}) error {
+				return json.Unmarshal([]byte(`{"id":"platform-id","name":"platform","type":"kubernetes"}`), data)
+			},
+		}
+	})
+
+	Context("when the request is initiated by a platform", func() {
+		It("builds the identity from the platform type and user", func() {
+			provision()
+			Expect(headers).To(HaveLen(1))
+			platform, value := decodeIdentity(headers[0])
+			Expect(platform).To(Equal("kubernetes"))
+			Expect(value).To(Equal(map[string]string{"user_id": "platform-user", "platform_id": "platform-id"}))
+		})
+	})
+
+	Context("when the request is initiated by an OAuth user", func() {
+		BeforeEach(func() {
+			user = &web.UserContext{
+				AuthenticationType: web.Bearer,
+				Name:               "john",
+				Data: func(data interface{}) error {
+					return json.Unmarshal([]byte(`{"sub":"subject-id","zid":"tenant-id"}`), data)
+				},
+			}
+		})
+
+		It("builds the identity from the token subject and tenant", func() {
+			provision()
+			Expect(headers).To(HaveLen(1))
+			platform, value := decodeIdentity(headers[0])
+			Expect(platform).To(Equal(osb.SMOriginatingIdentityPlatform))
+			Expect(value).To(Equal(map[string]string{"user_id": "subject-id", "user_name": "john", "tenant_id": "tenant-id"}))
+		})
+	})
+
+	Context("when policy is trust", func() {
+		It("keeps the identity supplied by the platform", func() {
+			policy = osb.TrustOriginatingIdentity
+			provision()
+			Expect(headers).To(Equal([]string{platformIdentity}))
+		})
+	})
+
+	Context("when policy is override", func() {
+		It("replaces the identity supplied by the platform with a single built one", func() {
+			provision()
+			Expect(headers).To(HaveLen(1))
+			Expect(headers[0]).ToNot(Equal(platformIdentity))
+		})
+	})
+
+	Context("when policy is append", func() {
+		It("includes the identity supplied by the platform in a single built one", func() {
+			policy = osb.AppendOriginatingIdentity
+			provision()
+			Expect(headers).To(HaveLen(1))
+			parts := strings.Split(headers[0], " ")
+			Expect(parts).To(HaveLen(2))
+			Expect(parts[0]).To(Equal("kubernetes"))
+			bytes, err := base64.StdEncoding.DecodeString(parts[1])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(bytes).To(MatchJSON(`{"user_id":"platform-user","platform_id":"platform-id","originating_identity":{"platform":"cloudfoundry","value":{"user_id":"platform-user"}}}`))
+		})
+	})
+
+	Describe("policy validation", func() {
+		It("fails for unsupported policies", func() {
+			Expect(osb.OriginatingIdentityPolicy("ignore").Validate()).To(HaveOccurred())
+		})
+	})
+})
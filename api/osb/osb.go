@@ -27,6 +27,7 @@ const (
 	// BrokerIDPathParam is a service broker ID path parameter
 	BrokerIDPathParam   = "brokerID"
 	InstanceIDPathParam = "instance_id"
+	BindingIDPathParam  = "binding_id"
 
 	// baseURL is the OSB API Controller path
 	baseURL = web.OSBURL + "/{" + BrokerIDPathParam + "}"
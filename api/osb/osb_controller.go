@@ -26,6 +26,7 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"regexp"
+	"strings"
 
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -43,13 +44,40 @@ var osbPathPattern = regexp.MustCompile("^" + web.OSBURL + "/[^/]+(/.*)$")
 // BrokerFetcherFunc is implemented by OSB proxy providers
 type BrokerFetcherFunc func(ctx context.Context, brokerID string) (*types.ServiceBroker, error)
 
+// InstanceEndpointFetcherFunc returns the broker endpoint which last served the service instance with the specified id
+type InstanceEndpointFetcherFunc func(ctx context.Context, instanceID string) (string, error)
+
 // Controller implements api.Controller by providing OSB API logic
 type Controller struct {
-	BrokerFetcher BrokerFetcherFunc
+	BrokerFetcher           BrokerFetcherFunc
+	BrokerClients           *BrokerClients
+	InstanceEndpointFetcher InstanceEndpointFetcherFunc
+	ShadowMirror            *ShadowMirror
+	FulfillmentHandler      FulfillmentHandler
+
+	// ErrorMappings normalize the broker error responses which are not matched by the error mappings of the broker
+	ErrorMappings []*types.BrokerErrorMapping
 }
 
 var _ web.Controller = &Controller{}
 
+type brokerKey struct{}
+
+// contextWithBroker returns a context that carries the broker of the OSB call so that
+// plugins which already loaded the broker can share it with the controller
+func contextWithBroker(ctx context.Context, broker *types.ServiceBroker) context.Context {
+	return context.WithValue(ctx, brokerKey{}, broker)
+}
+
+// brokerFromContext returns the broker of the OSB call if it was already loaded
+func brokerFromContext(ctx context.Context, brokerID string) (*types.ServiceBroker, bool) {
+	broker, ok := ctx.Value(brokerKey{}).(*types.ServiceBroker)
+	if !ok || broker.ID != brokerID {
+		return nil, false
+	}
+	return broker, true
+}
+
 func (c *Controller) proxyHandler(r *web.Request) (*web.Response, error) {
 	return c.handler(r, c.proxy)
 }
@@ -73,14 +101,20 @@ func (c *Controller) handler(request *web.Request, f func(r *web.Request, logger
 	}
 	logger.Debugf("Obtained path parameter [brokerID = %s] from path params", brokerID)
 
-	broker, err := c.BrokerFetcher(ctx, brokerID)
-	if err != nil {
-		return nil, err
+	broker, found := brokerFromContext(ctx, brokerID)
+	if !found {
+		var err error
+		if broker, err = c.BrokerFetcher(ctx, brokerID); err != nil {
+			return nil, err
+		}
 	}
 	logger.Debugf("Fetched broker %s with id %s accessible at %s", broker.ID, broker.Name, broker.BrokerURL)
 
 	response, err := f(request, logger, broker)
 	if err != nil {
+		if broker.IsCatalogOnly() {
+			return nil, err
+		}
 		logger.WithError(err).Errorf("error proxying call to service broker with id %s", brokerID)
 		return nil, &util.HTTPError{
 			ErrorType:   "ServiceBrokerErr",
@@ -101,15 +135,32 @@ func (c *Controller) catalog(r *web.Request, logger *logrus.Entry, broker *types
 }
 
 func (c *Controller) proxy(r *web.Request, logger *logrus.Entry, broker *types.ServiceBroker) (*web.Response, error) {
+	if broker.IsCatalogOnly() {
+		return c.fulfill(r, logger, broker)
+	}
 	ctx := r.Context()
 
 	targetBrokerURL, _ := url.Parse(broker.BrokerURL)
 
+	client, err := c.BrokerClients.Client(broker)
+	if err != nil {
+		return nil, err
+	}
+	if client.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, client.Timeout)
+		defer cancel()
+	}
+
 	m := osbPathPattern.FindStringSubmatch(r.URL.Path)
 	if m == nil || len(m) < 2 {
 		return nil, fmt.Errorf("could not get OSB path from URL %s", r.URL)
 	}
 
+	if ctx, err = c.stickToInstanceEndpoint(ctx, r, broker); err != nil {
+		return nil, err
+	}
+
 	modifiedRequest := r.Request.WithContext(ctx)
 	modifiedRequest.SetBasicAuth(broker.Credentials.Basic.Username, broker.Credentials.Basic.Password)
 	modifiedRequest.Body = ioutil.NopCloser(bytes.NewReader(r.Body))
@@ -120,7 +171,11 @@ func (c *Controller) proxy(r *web.Request, logger *logrus.Entry, broker *types.S
 	// This sets the host header to point to the service broker that the request will be proxied to
 	modifiedRequest.Host = targetBrokerURL.Host
 
-	proxy := buildProxy(targetBrokerURL, logger, broker)
+	var transportErr error
+	proxy := buildProxy(targetBrokerURL, logger, broker, func(e error) {
+		transportErr = e
+	})
+	proxy.Transport = client.Transport
 
 	recorder := httptest.NewRecorder()
 
@@ -130,25 +185,37 @@ func (c *Controller) proxy(r *web.Request, logger *logrus.Entry, broker *types.S
 	if err != nil {
 		return nil, err
 	}
+	c.ShadowMirror.Mirror(ctx, broker, modifiedRequest, m[1], r.Body, recorder.Code, brokerResponseBody)
 
 	responseBody := brokerResponseBody
+	failed := recorder.Code > 399 || recorder.Code < 100
+	message := gjson.GetBytes(brokerResponseBody, "description").String()
 
 	if !gjson.ValidBytes(brokerResponseBody) {
 		recorder.Header().Set("Content-Type", "application/json")
-		responseBody, err = sjson.SetBytes(nil, "description", fmt.Sprintf("Service broker %s responded with invalid JSON: %s", broker.Name, brokerResponseBody))
+		message = fmt.Sprintf("Service broker %s responded with invalid JSON: %s", broker.Name, brokerResponseBody)
+		responseBody, err = sjson.SetBytes(nil, "description", message)
 		if err != nil {
 			return nil, err
 		}
-	} else if recorder.Code > 399 || recorder.Code < 100 {
+	} else if failed && transportErr == nil {
 		recorder.Header().Set("Content-Type", "application/json")
-		description := gjson.GetBytes(brokerResponseBody, "description").String()
-		if description == "" {
-			description = string(brokerResponseBody)
+		if message == "" {
+			message = string(brokerResponseBody)
 		}
 		if !gjson.ParseBytes(brokerResponseBody).IsObject() {
 			brokerResponseBody = nil
 		}
-		responseBody, err = sjson.SetBytes(brokerResponseBody, "description", fmt.Sprintf("Service broker %s failed with: %s", broker.Name, description))
+		responseBody, err = sjson.SetBytes(brokerResponseBody, "description", fmt.Sprintf("Service broker %s failed with: %s", broker.Name, message))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if failed {
+		brokerError := gjson.GetBytes(responseBody, "error").String()
+		normalizedError := mapBrokerError(broker, c.ErrorMappings, recorder.Code, brokerError, message, transportErr)
+		responseBody, err = sjson.SetBytes(responseBody, SMErrorKey, normalizedError)
 		if err != nil {
 			return nil, err
 		}
@@ -162,7 +229,67 @@ func (c *Controller) proxy(r *web.Request, logger *logrus.Entry, broker *types.S
 	return resp, nil
 }
 
-func buildProxy(targetBrokerURL *url.URL, logger *logrus.Entry, broker *types.ServiceBroker) *httputil.ReverseProxy {
+// fulfill passes the requests for catalog-only brokers to the fulfillment handler
+func (c *Controller) fulfill(r *web.Request, logger *logrus.Entry, broker *types.ServiceBroker) (*web.Response, error) {
+	_, isBindingRequest := r.PathParams[BindingIDPathParam]
+	isLastOperationRequest := strings.HasSuffix(r.URL.Path, "/last_operation")
+
+	var handle func(req *web.Request, broker *types.ServiceBroker) (*web.Response, error)
+	if c.FulfillmentHandler != nil {
+		switch {
+		case isLastOperationRequest && r.Method == http.MethodGet:
+			handle = c.FulfillmentHandler.LastOperation
+		case isBindingRequest && r.Method == http.MethodPut:
+			handle = c.FulfillmentHandler.Bind
+		case isBindingRequest && r.Method == http.MethodDelete:
+			handle = c.FulfillmentHandler.Unbind
+		case isBindingRequest && r.Method == http.MethodGet:
+			handle = c.FulfillmentHandler.FetchBinding
+		case !isBindingRequest && r.Method == http.MethodPut:
+			handle = c.FulfillmentHandler.Provision
+		case !isBindingRequest && r.Method == http.MethodDelete:
+			handle = c.FulfillmentHandler.Deprovision
+		}
+	}
+	if handle == nil {
+		return nil, &util.HTTPError{
+			ErrorType:   "BadRequest",
+			Description: fmt.Sprintf("operation %s %s is not supported by catalog-only broker %s", r.Method, r.URL.Path, broker.Name),
+			StatusCode:  http.StatusBadRequest,
+		}
+	}
+	logger.Debugf("Fulfilling %s %s for catalog-only broker %s", r.Method, r.URL.Path, broker.Name)
+	return handle(r, broker)
+}
+
+// stickToInstanceEndpoint makes the calls for a service instance of a broker with sticky instances go to the
+// broker endpoint which served the previous calls for the same instance
+func (c *Controller) stickToInstanceEndpoint(ctx context.Context, r *web.Request, broker *types.ServiceBroker) (context.Context, error) {
+	instanceID, found := r.PathParams[InstanceIDPathParam]
+	if !broker.StickyInstances || len(broker.FailoverURLs) == 0 || !found || c.InstanceEndpointFetcher == nil {
+		return ctx, nil
+	}
+
+	endpoint, err := c.InstanceEndpointFetcher(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(endpoint) == 0 {
+		return ctx, nil
+	}
+	for _, brokerURL := range broker.URLs() {
+		if brokerURL == endpoint {
+			ctx, tracked := contextWithBrokerEndpoint(ctx)
+			tracked.Preferred = endpoint
+			log.C(ctx).Debugf("Service instance %s sticks to endpoint %s of broker %s", instanceID, endpoint, broker.Name)
+			return ctx, nil
+		}
+	}
+	log.C(ctx).Warnf("Endpoint %s of service instance %s is no longer an endpoint of broker %s", endpoint, instanceID, broker.Name)
+	return ctx, nil
+}
+
+func buildProxy(targetBrokerURL *url.URL, logger *logrus.Entry, broker *types.ServiceBroker, onError func(error)) *httputil.ReverseProxy {
 	proxy := httputil.NewSingleHostReverseProxy(targetBrokerURL)
 	director := proxy.Director
 	proxy.Director = func(request *http.Request) {
@@ -175,8 +302,10 @@ func buildProxy(targetBrokerURL *url.URL, logger *logrus.Entry, broker *types.Se
 	}
 	proxy.ErrorHandler = func(writer http.ResponseWriter, request *http.Request, e error) {
 		logger.WithError(e).Errorf("Error while forwarding request to service broker %s", broker.Name)
+		onError(e)
+		errorType, _ := connectionError(e)
 		util.WriteError(request.Context(), &util.HTTPError{
-			ErrorType:   "ServiceBrokerErr",
+			ErrorType:   errorType,
 			Description: fmt.Sprintf("could not reach service broker %s at %s", broker.Name, request.URL),
 			StatusCode:  http.StatusBadGateway,
 		}, writer)
@@ -0,0 +1,333 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/Peripli/service-manager/pkg/log"
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/pkg/web"
+	"github.com/Peripli/service-manager/storage"
+	"github.com/tidwall/gjson"
+)
+
+// OSBValidationPluginName is the name of the OSB validation plugin
+const OSBValidationPluginName = "OSBValidationPlugin"
+
+// OSBValidationMode specifies what happens with OSB requests which are not compliant with the OSB specification
+type OSBValidationMode string
+
+const (
+	// StrictOSBValidation rejects non-compliant requests with an OSB error
+	StrictOSBValidation OSBValidationMode = "strict"
+
+	// WarnOSBValidation logs a warning for non-compliant requests and forwards them to the broker. Requests with context
+	// which does not match the context schema of the platform type are still rejected.
+	WarnOSBValidation OSBValidationMode = "warn"
+
+	// OffOSBValidation disables the validation of OSB requests
+	OffOSBValidation OSBValidationMode = "off"
+)
+
+// Validate validates the OSB validation mode
+func (m OSBValidationMode) Validate() error {
+	switch m {
+	case StrictOSBValidation, WarnOSBValidation, OffOSBValidation:
+		return nil
+	default:
+		return fmt.Errorf("unsupported OSB validation mode %s, supported values are %s, %s and %s",
+			m, StrictOSBValidation, WarnOSBValidation, OffOSBValidation)
+	}
+}
+
+var osbIDPattern = regexp.MustCompile(`^[A-Za-z0-9._~-]{1,255}$`)
+
+type osbRequestCheck func(req *web.Request) error
+
+type osbValidationPlugin struct {
+	repository    storage.Repository
+	mode          OSBValidationMode
+	platformTypes *PlatformTypeRegistry
+}
+
+// NewOSBValidationPlugin creates new plugin that validates the OSB requests against the OSB specification before they
+// are forwarded to the broker. It checks the OSB version header, the instance and binding ids, the accepts_incomplete
+// parameter, the required body fields, the context required by the type of the platform and whether another operation
+// for the same service instance is in progress. The context of the platform type is enforced in both strict and warn
+// mode, as the brokers rely on it to identify where the service instances are created.
+func NewOSBValidationPlugin(repository storage.Repository, mode OSBValidationMode, platformTypes *PlatformTypeRegistry) *osbValidationPlugin {
+	return &osbValidationPlugin{
+		repository:    repository,
+		mode:          mode,
+		platformTypes: platformTypes,
+	}
+}
+
+// Name returns the name of the plugin
+func (p *osbValidationPlugin) Name() string {
+	return OSBValidationPluginName
+}
+
+// FetchCatalog validates catalog requests
+func (p *osbValidationPlugin) FetchCatalog(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.validate(req, next)
+}
+
+// Provision validates provision requests
+func (p *osbValidationPlugin) Provision(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.validateWithPlatformContext(req, next,
+		requireBodyFields("service_id", "plan_id"),
+		requireContext(true),
+		p.checkNoConcurrentOperation(types.CREATE))
+}
+
+// UpdateService validates update service instance requests
+func (p *osbValidationPlugin) UpdateService(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.validateWithPlatformContext(req, next,
+		requireBodyFields("service_id"),
+		requireContext(false),
+		p.checkNoConcurrentOperation(types.UPDATE))
+}
+
+// Deprovision validates deprovision requests
+func (p *osbValidationPlugin) Deprovision(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.validate(req, next,
+		requireQueryParams("service_id", "plan_id"),
+		p.checkNoConcurrentOperation(types.DELETE))
+}
+
+// FetchService validates fetch service instance requests
+func (p *osbValidationPlugin) FetchService(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.validate(req, next)
+}
+
+// PollInstance validates service instance last operation requests
+func (p *osbValidationPlugin) PollInstance(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.validate(req, next)
+}
+
+// Bind validates bind requests
+func (p *osbValidationPlugin) Bind(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.validate(req, next,
+		requireBodyFields("service_id", "plan_id"),
+		requireContext(false),
+		p.checkNoConcurrentOperation(""))
+}
+
+// Unbind validates unbind requests
+func (p *osbValidationPlugin) Unbind(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.validate(req, next,
+		requireQueryParams("service_id", "plan_id"),
+		p.checkNoConcurrentOperation(""))
+}
+
+// FetchBinding validates fetch binding requests
+func (p *osbValidationPlugin) FetchBinding(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.validate(req, next)
+}
+
+// PollBinding validates binding last operation requests
+func (p *osbValidationPlugin) PollBinding(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.validate(req, next)
+}
+
+func (p *osbValidationPlugin) validate(req *web.Request, next web.Handler, checks ...osbRequestCheck) (*web.Response, error) {
+	if p.mode == OffOSBValidation {
+		return next.Handle(req)
+	}
+
+	checks = append([]osbRequestCheck{checkbrokerAPIVersionHeader, checkIDs, checkAcceptsIncomplete}, checks...)
+	for _, check := range checks {
+		if err := check(req); err != nil {
+			if p.mode == StrictOSBValidation {
+				return nil, err
+			}
+			log.C(req.Context()).WithError(err).Warnf("OSB request %s %s is not compliant with the OSB specification", req.Method, req.URL.Path)
+		}
+	}
+	return next.Handle(req)
+}
+
+// validateWithPlatformContext rejects requests with context which does not match the context schema of the platform
+// type regardless of the validation mode unless the validation is off, and then applies the rest of the checks
+func (p *osbValidationPlugin) validateWithPlatformContext(req *web.Request, next web.Handler, checks ...osbRequestCheck) (*web.Response, error) {
+	if p.mode != OffOSBValidation {
+		if err := p.checkPlatformContext(req); err != nil {
+			return nil, err
+		}
+	}
+	return p.validate(req, next, checks...)
+}
+
+// checkNoConcurrentOperation returns a check which fails if another operation is in progress for the service instance.
+// Repeating the operation which is in progress is allowed as brokers should respond to it with the operation status.
+// Binding requests specify no operation category and are not allowed during any service instance operation.
+func (p *osbValidationPlugin) checkNoConcurrentOperation(category types.OperationCategory) osbRequestCheck {
+	return func(req *web.Request) error {
+		ctx := req.Context()
+		instanceID := req.PathParams[InstanceIDPathParam]
+		criteria := []query.Criterion{
+			query.ByField(query.EqualsOperator, "resource_id", instanceID),
+			query.OrderResultBy("paging_sequence", query.DescOrder),
+		}
+		op, err := p.repository.Get(ctx, types.OperationType, criteria...)
+		if err != nil {
+			if err == util.ErrNotFoundInStorage {
+				return nil
+			}
+			return util.HandleStorageError(err, string(types.OperationType))
+		}
+
+		operation := op.(*types.Operation)
+		if operation.State != types.IN_PROGRESS || operation.Type == category {
+			return nil
+		}
+		// the OSB spec defines ConcurrencyError for operations rejected because of another operation in progress
+		// regardless of whether the request accepts incomplete operations
+		return &util.HTTPError{
+			ErrorType:   "ConcurrencyError",
+			Description: fmt.Sprintf("another %s operation for service instance %s is in progress", operation.Type, instanceID),
+			StatusCode:  http.StatusUnprocessableEntity,
+		}
+	}
+}
+
+// checkPlatformContext validates the context against the context schema of the type of the platform sending the request
+func (p *osbValidationPlugin) checkPlatformContext(req *web.Request) error {
+	osbContext := gjson.GetBytes(req.Body, "context")
+	if p.platformTypes == nil || !osbContext.IsObject() {
+		return nil
+	}
+
+	user, found := web.UserFromContext(req.Context())
+	if !found || user.AuthenticationType != web.Basic {
+		return nil
+	}
+	platform := &types.Platform{}
+	if err := user.Data(platform); err != nil {
+		return err
+	}
+	return p.platformTypes.ValidateContext(platform.Type, []byte(osbContext.Raw))
+}
+
+func checkbrokerAPIVersionHeader(req *web.Request) error {
+	version := req.Header.Get(brokerAPIVersionHeader)
+	if len(version) == 0 {
+		return &util.HTTPError{
+			ErrorType:   "PreconditionFailed",
+			Description: fmt.Sprintf("missing %s header", brokerAPIVersionHeader),
+			StatusCode:  http.StatusPreconditionFailed,
+		}
+	}
+	if major, _ := parseOSBVersion(version); major < 0 {
+		return &util.HTTPError{
+			ErrorType:   "PreconditionFailed",
+			Description: fmt.Sprintf("invalid %s header %s", brokerAPIVersionHeader, version),
+			StatusCode:  http.StatusPreconditionFailed,
+		}
+	}
+	return nil
+}
+
+func checkIDs(req *web.Request) error {
+	for _, param := range []string{InstanceIDPathParam, BindingIDPathParam} {
+		if id, found := req.PathParams[param]; found && !osbIDPattern.MatchString(id) {
+			return &util.HTTPError{
+				ErrorType:   "BadRequest",
+				Description: fmt.Sprintf("invalid %s %s: should be a non-empty string of at most 255 unreserved URL characters", param, id),
+				StatusCode:  http.StatusBadRequest,
+			}
+		}
+	}
+	return nil
+}
+
+func checkAcceptsIncomplete(req *web.Request) error {
+	values, found := req.URL.Query()["accepts_incomplete"]
+	if !found {
+		return nil
+	}
+	if len(values) != 1 || (values[0] != "true" && values[0] != "false") {
+		return &util.HTTPError{
+			ErrorType:   "BadRequest",
+			Description: "accepts_incomplete query parameter should be either true or false",
+			StatusCode:  http.StatusBadRequest,
+		}
+	}
+	return nil
+}
+
+func requireBodyFields(fields ...string) osbRequestCheck {
+	return func(req *web.Request) error {
+		if !gjson.ValidBytes(req.Body) || !gjson.ParseBytes(req.Body).IsObject() {
+			return &util.HTTPError{
+				ErrorType:   "BadRequest",
+				Description: "request body should be a JSON object",
+				StatusCode:  http.StatusBadRequest,
+			}
+		}
+		for _, field := range fields {
+			value := gjson.GetBytes(req.Body, field)
+			if value.Type != gjson.String || len(value.String()) == 0 {
+				return &util.HTTPError{
+					ErrorType:   "BadRequest",
+					Description: fmt.Sprintf("%s should be a non-empty string", field),
+					StatusCode:  http.StatusBadRequest,
+				}
+			}
+		}
+		return nil
+	}
+}
+
+func requireContext(required bool) osbRequestCheck {
+	return func(req *web.Request) error {
+		osbContext := gjson.GetBytes(req.Body, "context")
+		if !osbContext.Exists() && !required {
+			return nil
+		}
+		if !osbContext.IsObject() {
+			return &util.HTTPError{
+				ErrorType:   "BadRequest",
+				Description: "context should be a JSON object",
+				StatusCode:  http.StatusBadRequest,
+			}
+		}
+		return nil
+	}
+}
+
+func requireQueryParams(params ...string) osbRequestCheck {
+	return func(req *web.Request) error {
+		queryParams := req.URL.Query()
+		for _, param := range params {
+			if len(queryParams.Get(param)) == 0 {
+				return &util.HTTPError{
+					ErrorType:   "BadRequest",
+					Description: fmt.Sprintf("missing %s query parameter", param),
+					StatusCode:  http.StatusBadRequest,
+				}
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,182 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/Peripli/service-manager/api/osb"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/pkg/web"
+	"github.com/Peripli/service-manager/storage/storagefakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OSB validation plugin", func() {
+	const instancePath = "http://localhost/v1/osb/broker-id/v2/service_instances/instance-id"
+
+	var (
+		fakeStorage   *storagefakes.FakeStorage
+		mode          osb.OSBValidationMode
+		platformTypes *osb.PlatformTypeRegistry
+		platformType  string
+		headers       http.Header
+		nextCalled    bool
+	)
+
+	provision := func(rawQuery, body string) error {
+		httpRequest, err := http.NewRequest(http.MethodPut, instancePath+"?"+rawQuery, strings.NewReader(body))
+		Expect(err).ToNot(HaveOccurred())
+		httpRequest.Header = headers
+		user := &web.UserContext{
+			AuthenticationType: web.Basic,
+			Name:               "platform-user",
+			Data: func(data interface{}) error {
+				return json.Unmarshal([]byte(`{"id":"platform-id","name":"platform","type":"`+platformType+`"}`), data)
+			},
+		}
+		request := &web.Request{
+			Request:    httpRequest.WithContext(web.ContextWithUser(httpRequest.Context(), user)),
+			PathParams: map[string]string{osb.BrokerIDPathParam: "broker-id", osb.InstanceIDPathParam: "instance-id"},
+			Body:       []byte(body),
+		}
+		plugin := osb.NewOSBValidationPlugin(fakeStorage, mode, platformTypes)
+		_, err = plugin.Provision(request, web.HandlerFunc(func(req *web.Request) (*web.Response, error) {
+			nextCalled = true
+			return &web.Response{StatusCode: http.StatusCreated}, nil
+		}))
+		return err
+	}
+
+	expectError := func(err error, errorType string, statusCode int) {
+		Expect(err).To(HaveOccurred())
+		Expect(err.(*util.HTTPError).ErrorType).To(Equal(errorType))
+		Expect(err.(*util.HTTPError).StatusCode).To(Equal(statusCode))
+		Expect(nextCalled).To(BeFalse())
+	}
+
+	BeforeEach(func() {
+		fakeStorage = &storagefakes.FakeStorage{}
+		fakeStorage.GetReturns(nil, util.ErrNotFoundInStorage)
+		mode = osb.StrictOSBValidation
+		var err error
+		platformTypes, err = osb.NewPlatformTypeRegistry(osb.DefaultPlatformTypes()...)
+		Expect(err).ToNot(HaveOccurred())
+		platformType = "custom"
+		headers = http.Header{"X-Broker-Api-Version": []string{"2.15"}}
+		nextCalled = false
+	})
+
+	Context("in strict mode", func() {
+		It("forwards compliant requests", func() {
+			Expect(provision("accepts_incomplete=true", `{"service_id":"s","plan_id":"p","context":{}}`)).To(Succeed())
+			Expect(nextCalled).To(BeTrue())
+		})
+
+		It("rejects requests without OSB version header", func() {
+			headers = http.Header{}
+			expectError(provision("", `{"service_id":"s","plan_id":"p","context":{}}`), "PreconditionFailed", http.StatusPreconditionFailed)
+		})
+
+		It("rejects requests with invalid accepts_incomplete", func() {
+			expectError(provision("accepts_incomplete=yes", `{"service_id":"s","plan_id":"p","context":{}}`), "BadRequest", http.StatusBadRequest)
+		})
+
+		It("rejects requests with missing body fields", func() {
+			expectError(provision("", `{"service_id":"s","context":{}}`), "BadRequest", http.StatusBadRequest)
+			expectError(provision("", `{"service_id":"s","plan_id":"p"}`), "BadRequest", http.StatusBadRequest)
+		})
+
+		Context("when the platform type has a context schema", func() {
+			BeforeEach(func() {
+				platformType = osb.CloudFoundryPlatformType
+			})
+
+			It("forwards requests with valid context", func() {
+				Expect(provision("", `{"service_id":"s","plan_id":"p","context":{"organization_guid":"o","space_guid":"s"}}`)).To(Succeed())
+				Expect(nextCalled).To(BeTrue())
+			})
+
+			It("rejects requests with missing context properties", func() {
+				err := provision("", `{"service_id":"s","plan_id":"p","context":{"organization_guid":"o"}}`)
+				expectError(err, "BadRequest", http.StatusBadRequest)
+				Expect(err.(*util.HTTPError).Description).To(ContainSubstring("space_guid"))
+			})
+
+			It("validates the context of kubernetes platforms", func() {
+				platformType = types.K8sPlatformType
+				expectError(provision("", `{"service_id":"s","plan_id":"p","context":{"organization_guid":"o","space_guid":"s"}}`), "BadRequest", http.StatusBadRequest)
+				Expect(provision("", `{"service_id":"s","plan_id":"p","context":{"namespace":"n","clusterid":"c"}}`)).To(Succeed())
+			})
+		})
+
+		Context("when the platform type is registered with a custom context schema", func() {
+			It("validates the context against it", func() {
+				Expect(platformTypes.Register(&osb.PlatformType{
+					Name:          platformType,
+					ContextSchema: `{"type":"object","required":["tenant"]}`,
+				})).To(Succeed())
+				expectError(provision("", `{"service_id":"s","plan_id":"p","context":{}}`), "BadRequest", http.StatusBadRequest)
+				Expect(provision("", `{"service_id":"s","plan_id":"p","context":{"tenant":"t"}}`)).To(Succeed())
+			})
+		})
+
+		Context("when another operation is in progress for the instance", func() {
+			BeforeEach(func() {
+				fakeStorage.GetReturns(&types.Operation{Type: types.UPDATE, State: types.IN_PROGRESS}, nil)
+			})
+
+			It("returns ConcurrencyError for asynchronous requests", func() {
+				expectError(provision("accepts_incomplete=true", `{"service_id":"s","plan_id":"p","context":{}}`), "ConcurrencyError", http.StatusUnprocessableEntity)
+			})
+
+			It("returns ConcurrencyError for synchronous requests", func() {
+				expectError(provision("", `{"service_id":"s","plan_id":"p","context":{}}`), "ConcurrencyError", http.StatusUnprocessableEntity)
+			})
+		})
+	})
+
+	Context("in warn mode", func() {
+		It("forwards non-compliant requests", func() {
+			mode = osb.WarnOSBValidation
+			headers = http.Header{}
+			Expect(provision("", `{}`)).To(Succeed())
+			Expect(nextCalled).To(BeTrue())
+		})
+
+		It("rejects requests with context which does not match the platform type", func() {
+			mode = osb.WarnOSBValidation
+			platformType = osb.CloudFoundryPlatformType
+			err := provision("", `{"service_id":"s","plan_id":"p","context":{"organization_guid":"o"}}`)
+			expectError(err, "BadRequest", http.StatusBadRequest)
+		})
+	})
+
+	Context("when validation is off", func() {
+		It("does not validate requests", func() {
+			mode = osb.OffOSBValidation
+			headers = http.Header{}
+			Expect(provision("", `{}`)).To(Succeed())
+			Expect(fakeStorage.GetCallCount()).To(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,138 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"github.com/Peripli/service-manager/pkg/log"
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/pkg/web"
+	"github.com/Peripli/service-manager/storage"
+)
+
+// OSBVersionTranslationPluginName is the name of the OSB version translation plugin
+const OSBVersionTranslationPluginName = "OSBVersionTranslationPlugin"
+
+type osbVersionTranslationPlugin struct {
+	repository storage.Repository
+}
+
+// NewOSBVersionTranslationPlugin creates new plugin that translates the OSB requests and responses between
+// the OSB version used by the platform and the OSB version negotiated with the broker. The plugin should be
+// registered before the other OSB plugins so that they work with the untranslated broker responses.
+func NewOSBVersionTranslationPlugin(repository storage.Repository) *osbVersionTranslationPlugin {
+	return &osbVersionTranslationPlugin{
+		repository: repository,
+	}
+}
+
+// Name returns the name of the plugin
+func (p *osbVersionTranslationPlugin) Name() string {
+	return OSBVersionTranslationPluginName
+}
+
+// FetchCatalog intercepts get catalog requests and translates the catalog to the platform OSB version
+func (p *osbVersionTranslationPlugin) FetchCatalog(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.translate(req, next)
+}
+
+// Provision intercepts provision requests and translates them between the platform and broker OSB versions
+func (p *osbVersionTranslationPlugin) Provision(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.translate(req, next)
+}
+
+// Deprovision intercepts deprovision requests and translates them between the platform and broker OSB versions
+func (p *osbVersionTranslationPlugin) Deprovision(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.translate(req, next)
+}
+
+// UpdateService intercepts update service instance requests and translates them between the platform and broker OSB versions
+func (p *osbVersionTranslationPlugin) UpdateService(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.translate(req, next)
+}
+
+// FetchService intercepts get service instance requests and translates them between the platform and broker OSB versions
+func (p *osbVersionTranslationPlugin) FetchService(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.translate(req, next)
+}
+
+// Bind intercepts bind requests and translates them between the platform and broker OSB versions
+func (p *osbVersionTranslationPlugin) Bind(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.translate(req, next)
+}
+
+// Unbind intercepts unbind requests and translates them between the platform and broker OSB versions
+func (p *osbVersionTranslationPlugin) Unbind(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.translate(req, next)
+}
+
+// FetchBinding intercepts get service binding requests and translates them between the platform and broker OSB versions
+func (p *osbVersionTranslationPlugin) FetchBinding(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.translate(req, next)
+}
+
+// PollInstance intercepts poll instance operation requests and translates them between the platform and broker OSB versions
+func (p *osbVersionTranslationPlugin) PollInstance(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.translate(req, next)
+}
+
+// PollBinding intercepts poll binding operation requests and translates them between the platform and broker OSB versions
+func (p *osbVersionTranslationPlugin) PollBinding(req *web.Request, next web.Handler) (*web.Response, error) {
+	return p.translate(req, next)
+}
+
+func (p *osbVersionTranslationPlugin) translate(req *web.Request, next web.Handler) (*web.Response, error) {
+	ctx := req.Context()
+	brokerID := req.PathParams[BrokerIDPathParam]
+	broker, found := brokerFromContext(ctx, brokerID)
+	if !found {
+		byID := query.ByField(query.EqualsOperator, "id", brokerID)
+		object, err := p.repository.Get(ctx, types.ServiceBrokerType, byID)
+		if err != nil {
+			if err == util.ErrNotFoundInStorage {
+				return next.Handle(req)
+			}
+			return nil, util.HandleStorageError(err, string(types.ServiceBrokerType))
+		}
+		broker = object.(*types.ServiceBroker)
+		// the OSB controller reuses the loaded broker instead of fetching it again
+		req.Request = req.WithContext(contextWithBroker(ctx, broker))
+	}
+
+	translator := newVersionTranslator(req.Header.Get(brokerAPIVersionHeader), broker.OSBVersion)
+	if !translator.needed() {
+		return next.Handle(req)
+	}
+
+	log.C(ctx).Debugf("Translating OSB request from platform OSB version %s to broker %s OSB version %s", translator.platformVersion, broker.Name, translator.brokerVersion)
+	var err error
+	if req.Body, err = translator.translateRequest(req.Method, req.URL.Path, req.Body); err != nil {
+		return nil, err
+	}
+	req.Header.Set(brokerAPIVersionHeader, translator.brokerVersion)
+
+	resp, err := next.Handle(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Body, err = translator.translateResponse(req.Method, req.URL.Path, resp.Body); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	osbVersion213 = "2.13"
+	osbVersion214 = "2.14"
+	osbVersion215 = "2.15"
+)
+
+// SupportedOSBVersions are the OSB API versions that the Service Manager can negotiate with brokers ordered from newest to oldest
+var SupportedOSBVersions = []string{osbVersion215, osbVersion214, osbVersion213}
+
+// negotiableVersions returns the supported OSB versions which are not newer than the specified version ordered from newest to oldest
+func negotiableVersions(maxVersion string) []string {
+	result := make([]string, 0, len(SupportedOSBVersions))
+	for _, version := range SupportedOSBVersions {
+		if compareOSBVersions(version, maxVersion) <= 0 {
+			result = append(result, version)
+		}
+	}
+	if len(result) == 0 {
+		result = append(result, maxVersion)
+	}
+	return result
+}
+
+// compareOSBVersions returns a negative number if a is older than b, a positive number if a is newer than b and 0 if they are the same.
+// Versions that cannot be parsed are considered older than all valid versions.
+func compareOSBVersions(a, b string) int {
+	aMajor, aMinor := parseOSBVersion(a)
+	bMajor, bMinor := parseOSBVersion(b)
+	if aMajor != bMajor {
+		return aMajor - bMajor
+	}
+	return aMinor - bMinor
+}
+
+func parseOSBVersion(version string) (int, int) {
+	parts := strings.SplitN(strings.TrimSpace(version), ".", 2)
+	if len(parts) != 2 {
+		return -1, -1
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return -1, -1
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return -1, -1
+	}
+	return major, minor
+}
+
+// versionTranslator translates the OSB requests and responses between the OSB version used by the platform and the one supported by the broker
+type versionTranslator struct {
+	platformVersion string
+	brokerVersion   string
+}
+
+func newVersionTranslator(platformVersion, brokerVersion string) *versionTranslator {
+	if len(platformVersion) == 0 {
+		platformVersion = brokerVersion
+	}
+	return &versionTranslator{
+		platformVersion: platformVersion,
+		brokerVersion:   brokerVersion,
+	}
+}
+
+// needed returns whether the platform and the broker use different OSB versions
+func (t *versionTranslator) needed() bool {
+	return len(t.brokerVersion) != 0 && compareOSBVersions(t.platformVersion, t.brokerVersion) != 0
+}
+
+// translateRequest adapts the body of a request sent by the platform so that it can be understood by the broker
+func (t *versionTranslator) translateRequest(method, path string, body []byte) ([]byte, error) {
+	if !t.needed() || len(body) == 0 || !gjson.ValidBytes(body) {
+		return body, nil
+	}
+
+	var err error
+	isInstanceModification := (method == http.MethodPut || method == http.MethodPatch) && !strings.Contains(path, "/service_bindings/")
+	if isInstanceModification && compareOSBVersions(t.brokerVersion, osbVersion215) < 0 {
+		if body, err = deleteJSONPaths(body, "maintenance_info", "previous_values.maintenance_info"); err != nil {
+			return nil, fmt.Errorf("could not translate request to OSB version %s: %s", t.brokerVersion, err)
+		}
+	}
+	return body, nil
+}
+
+// translateResponse adapts the body of a broker response so that it can be understood by the platform
+func (t *versionTranslator) translateResponse(method, path string, body []byte) ([]byte, error) {
+	if !t.needed() || len(body) == 0 || !gjson.ValidBytes(body) {
+		return body, nil
+	}
+	if compareOSBVersions(t.platformVersion, osbVersion215) >= 0 {
+		return body, nil
+	}
+
+	var err error
+	switch {
+	case method == http.MethodGet && strings.HasSuffix(path, "/last_operation"):
+		body, err = translateLastOperationResponse(body)
+	case method == http.MethodGet && strings.HasSuffix(path, "/v2/catalog"):
+		body, err = translateCatalogResponse(body)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not translate response to OSB version %s: %s", t.platformVersion, err)
+	}
+	return body, nil
+}
+
+// translateLastOperationResponse maps the instance_usable field which is not known prior to OSB 2.15 to the operation description
+func translateLastOperationResponse(body []byte) ([]byte, error) {
+	instanceUsable := gjson.GetBytes(body, "instance_usable")
+	if !instanceUsable.Exists() {
+		return body, nil
+	}
+
+	var err error
+	if !instanceUsable.Bool() {
+		description := gjson.GetBytes(body, "description").String()
+		if len(description) != 0 {
+			description += " "
+		}
+		description += "(service instance is not usable)"
+		if body, err = sjson.SetBytes(body, "description", description); err != nil {
+			return nil, err
+		}
+	}
+	return deleteJSONPaths(body, "instance_usable", "update_repeatable")
+}
+
+// translateCatalogResponse removes the catalog fields which are not known prior to OSB 2.15
+func translateCatalogResponse(body []byte) ([]byte, error) {
+	var paths []string
+	for i, service := range gjson.GetBytes(body, "services").Array() {
+		paths = append(paths, fmt.Sprintf("services.%d.allow_context_updates", i))
+		for j := range service.Get("plans").Array() {
+			paths = append(paths,
+				fmt.Sprintf("services.%d.plans.%d.maintenance_info", i, j),
+				fmt.Sprintf("services.%d.plans.%d.maximum_polling_duration", i, j))
+		}
+	}
+	return deleteJSONPaths(body, paths...)
+}
+
+func deleteJSONPaths(body []byte, paths ...string) ([]byte, error) {
+	var err error
+	for _, path := range paths {
+		if !gjson.GetBytes(body, path).Exists() {
+			continue
+		}
+		if body, err = sjson.DeleteBytes(body, path); err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
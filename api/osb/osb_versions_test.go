@@ -0,0 +1,86 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"net/http"
+
+	"github.com/tidwall/gjson"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OSB versions", func() {
+	Describe("compareOSBVersions", func() {
+		It("compares major and minor versions", func() {
+			Expect(compareOSBVersions("2.13", "2.15")).To(BeNumerically("<", 0))
+			Expect(compareOSBVersions("2.15", "2.9")).To(BeNumerically(">", 0))
+			Expect(compareOSBVersions("3.0", "2.15")).To(BeNumerically(">", 0))
+			Expect(compareOSBVersions("2.14", "2.14")).To(Equal(0))
+			Expect(compareOSBVersions("invalid", "2.13")).To(BeNumerically("<", 0))
+		})
+	})
+
+	Describe("negotiableVersions", func() {
+		It("returns the supported versions which are not newer than the specified one", func() {
+			Expect(negotiableVersions("2.14")).To(Equal([]string{"2.14", "2.13"}))
+			Expect(negotiableVersions("2.13")).To(Equal([]string{"2.13"}))
+		})
+	})
+
+	Describe("versionTranslator", func() {
+		const instancePath = "/v1/osb/broker-id/v2/service_instances/instance-id"
+
+		It("is not needed when platform and broker use the same version", func() {
+			Expect(newVersionTranslator("2.15", "2.15").needed()).To(BeFalse())
+			Expect(newVersionTranslator("", "2.13").needed()).To(BeFalse())
+			Expect(newVersionTranslator("2.13", "").needed()).To(BeFalse())
+		})
+
+		It("strips maintenance_info from instance requests towards older brokers", func() {
+			body := []byte(`{"plan_id":"plan","maintenance_info":{"version":"new"},"previous_values":{"maintenance_info":{"version":"old"}}}`)
+			translated, err := newVersionTranslator("2.15", "2.13").translateRequest(http.MethodPatch, instancePath, body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(gjson.GetBytes(translated, "maintenance_info").Exists()).To(BeFalse())
+			Expect(gjson.GetBytes(translated, "previous_values.maintenance_info").Exists()).To(BeFalse())
+			Expect(gjson.GetBytes(translated, "plan_id").String()).To(Equal("plan"))
+		})
+
+		It("maps instance_usable in last operation responses for older platforms", func() {
+			body := []byte(`{"state":"failed","description":"update failed","instance_usable":false}`)
+			translated, err := newVersionTranslator("2.13", "2.15").translateResponse(http.MethodGet, instancePath+"/last_operation", body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(gjson.GetBytes(translated, "instance_usable").Exists()).To(BeFalse())
+			Expect(gjson.GetBytes(translated, "description").String()).To(Equal("update failed (service instance is not usable)"))
+		})
+
+		It("strips newer catalog fields for older platforms", func() {
+			body := []byte(`{"services":[{"id":"s","allow_context_updates":true,"plans":[{"id":"p","maintenance_info":{"version":"1"},"maximum_polling_duration":10}]}]}`)
+			translated, err := newVersionTranslator("2.13", "2.15").translateResponse(http.MethodGet, "/v1/osb/broker-id/v2/catalog", body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(translated)).To(MatchJSON(`{"services":[{"id":"s","plans":[{"id":"p"}]}]}`))
+		})
+
+		It("does not modify responses for newer platforms", func() {
+			body := []byte(`{"state":"failed","instance_usable":false}`)
+			translated, err := newVersionTranslator("2.15", "2.13").translateResponse(http.MethodGet, instancePath+"/last_operation", body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(translated).To(Equal(body))
+		})
+	})
+})
@@ -0,0 +1,157 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// CloudFoundryPlatformType is the type of Cloud Foundry platforms
+const CloudFoundryPlatformType = "cloudfoundry"
+
+// PlatformType describes a type of platforms and the OSB context which the platforms of the type send
+type PlatformType struct {
+	// Name is the value of the type field of the platforms
+	Name string
+
+	// ContextSchema is a JSON schema which the OSB context of the provision and update requests of the platforms must
+	// conform to - the context is not validated if it is empty
+	ContextSchema string
+}
+
+// DefaultPlatformTypes returns the platform types which are known to the Service Manager
+func DefaultPlatformTypes() []*PlatformType {
+	return []*PlatformType{
+		{
+			Name:          CloudFoundryPlatformType,
+			ContextSchema: contextSchema("organization_guid", "space_guid"),
+		},
+		{
+			Name:          types.K8sPlatformType,
+			ContextSchema: contextSchema("namespace", "clusterid"),
+		},
+	}
+}
+
+func contextSchema(requiredProperties ...string) string {
+	properties := make([]string, 0, len(requiredProperties))
+	required := make([]string, 0, len(requiredProperties))
+	for _, property := range requiredProperties {
+		properties = append(properties, fmt.Sprintf(`"%s":{"type":"string","minLength":1}`, property))
+		required = append(required, fmt.Sprintf(`"%s"`, property))
+	}
+	return fmt.Sprintf(`{"type":"object","properties":{%s},"required":[%s]}`, strings.Join(properties, ","), strings.Join(required, ","))
+}
+
+// PlatformTypeRegistry holds the platform types which are known to the Service Manager
+type PlatformTypeRegistry struct {
+	mutex         sync.RWMutex
+	platformTypes map[string]*gojsonschema.Schema
+}
+
+// NewPlatformTypeRegistry creates a registry with the provided platform types
+func NewPlatformTypeRegistry(platformTypes ...*PlatformType) (*PlatformTypeRegistry, error) {
+	registry := &PlatformTypeRegistry{
+		platformTypes: make(map[string]*gojsonschema.Schema),
+	}
+	for _, platformType := range platformTypes {
+		if err := registry.Register(platformType); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// Register adds the platform type to the registry replacing the platform type with the same name if any
+func (r *PlatformTypeRegistry) Register(platformType *PlatformType) error {
+	if len(platformType.Name) == 0 {
+		return fmt.Errorf("platform type name must not be empty")
+	}
+
+	var schema *gojsonschema.Schema
+	if len(platformType.ContextSchema) != 0 {
+		var err error
+		if schema, err = gojsonschema.NewSchema(gojsonschema.NewStringLoader(platformType.ContextSchema)); err != nil {
+			return fmt.Errorf("invalid context schema of platform type %s: %s", platformType.Name, err)
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.platformTypes[platformType.Name] = schema
+	return nil
+}
+
+// IsRegistered returns whether the platform type is known to the Service Manager
+func (r *PlatformTypeRegistry) IsRegistered(platformType string) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	_, found := r.platformTypes[platformType]
+	return found
+}
+
+// Names returns the sorted names of the registered platform types
+func (r *PlatformTypeRegistry) Names() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	names := make([]string, 0, len(r.platformTypes))
+	for name := range r.platformTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateContext validates the OSB context sent by a platform of the provided type against the context schema
+// of the platform type. Contexts of platform types which are not registered or have no context schema are not validated.
+func (r *PlatformTypeRegistry) ValidateContext(platformType string, osbContext []byte) error {
+	r.mutex.RLock()
+	schema := r.platformTypes[platformType]
+	r.mutex.RUnlock()
+	if schema == nil {
+		return nil
+	}
+
+	result, err := schema.Validate(gojsonschema.NewBytesLoader(osbContext))
+	if err != nil {
+		return &util.HTTPError{
+			ErrorType:   "BadRequest",
+			Description: fmt.Sprintf("could not validate context: %s", err),
+			StatusCode:  http.StatusBadRequest,
+		}
+	}
+	if !result.Valid() {
+		violations := make([]string, 0, len(result.Errors()))
+		for _, violation := range result.Errors() {
+			violations = append(violations, violation.String())
+		}
+		return &util.HTTPError{
+			ErrorType:   "BadRequest",
+			Description: fmt.Sprintf("context is not valid for platform type %s: %s", platformType, strings.Join(violations, ", ")),
+			StatusCode:  http.StatusBadRequest,
+		}
+	}
+	return nil
+}
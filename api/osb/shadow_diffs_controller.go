@@ -0,0 +1,68 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"net/http"
+
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/pkg/web"
+)
+
+const shadowDiffsURL = web.ServiceBrokersURL + "/{" + BrokerIDPathParam + "}/shadow_diffs"
+
+type shadowDiffsResponse struct {
+	ShadowURL string        `json:"shadow_url"`
+	Diffs     []*ShadowDiff `json:"diffs"`
+}
+
+// ShadowDiffsController exposes the differences between the responses of the brokers and their shadow brokers.
+// It is a debugging API which returns only the differences recorded by the Service Manager instance serving the call.
+type ShadowDiffsController struct {
+	BrokerFetcher BrokerFetcherFunc
+	ShadowMirror  *ShadowMirror
+}
+
+var _ web.Controller = &ShadowDiffsController{}
+
+// Routes implements api.Controller.Routes by providing the routes for the shadow differences API
+func (c *ShadowDiffsController) Routes() []web.Route {
+	return []web.Route{
+		{Endpoint: web.Endpoint{Method: http.MethodGet, Path: shadowDiffsURL}, Handler: c.listDiffs},
+		{Endpoint: web.Endpoint{Method: http.MethodDelete, Path: shadowDiffsURL}, Handler: c.clearDiffs},
+	}
+}
+
+func (c *ShadowDiffsController) listDiffs(r *web.Request) (*web.Response, error) {
+	broker, err := c.BrokerFetcher(r.Context(), r.PathParams[BrokerIDPathParam])
+	if err != nil {
+		return nil, err
+	}
+	return util.NewJSONResponse(http.StatusOK, &shadowDiffsResponse{
+		ShadowURL: broker.ShadowURL,
+		Diffs:     c.ShadowMirror.Diffs(broker.ID),
+	})
+}
+
+func (c *ShadowDiffsController) clearDiffs(r *web.Request) (*web.Response, error) {
+	broker, err := c.BrokerFetcher(r.Context(), r.PathParams[BrokerIDPathParam])
+	if err != nil {
+		return nil, err
+	}
+	c.ShadowMirror.Clear(broker.ID)
+	return util.NewJSONResponse(http.StatusOK, map[string]string{})
+}
@@ -0,0 +1,291 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Peripli/service-manager/pkg/log"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// maxShadowDiffsPerBroker is the number of most recent differences kept for each broker
+	maxShadowDiffsPerBroker = 100
+
+	// maxConcurrentShadowRequests is the number of mirrored requests which can be in flight at the same time.
+	// Requests which exceed it are not mirrored.
+	maxConcurrentShadowRequests = 50
+
+	// defaultShadowRequestTimeout is used for mirrored requests towards brokers which do not specify a request timeout
+	defaultShadowRequestTimeout = 30 * time.Second
+)
+
+// shadowRequestHeaders are the only headers copied to the mirrored requests so that neither the credentials for the
+// broker nor the originating identity of the user reach the shadow broker
+var shadowRequestHeaders = []string{brokerAPIVersionHeader, "X-Broker-API-Request-Identity", "Content-Type", "Accept"}
+
+var mirroredPaths = map[string]*regexp.Regexp{
+	http.MethodGet: regexp.MustCompile("^/v2/catalog$"),
+	http.MethodPut: regexp.MustCompile("^/v2/service_instances/[^/]+(/service_bindings/[^/]+)?$"),
+}
+
+// ShadowDiff describes a difference between the responses of a broker and its shadow broker for the same request
+type ShadowDiff struct {
+	Method           string    `json:"method"`
+	Path             string    `json:"path"`
+	PrimaryStatus    int       `json:"primary_status"`
+	ShadowStatus     int       `json:"shadow_status,omitempty"`
+	MissingFields    []string  `json:"missing_fields,omitempty"`
+	UnexpectedFields []string  `json:"unexpected_fields,omitempty"`
+	ChangedFields    []string  `json:"changed_fields,omitempty"`
+	Error            string    `json:"error,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// ShadowMirror copies the OSB requests towards brokers which have a shadow url to the shadow broker and
+// records the differences between the responses of the broker and the shadow broker.
+// The differences are meant for debugging broker rollouts only - they are kept in memory of the Service Manager
+// instance which served the request, so they are lost on restart and are not shared between instances.
+type ShadowMirror struct {
+	clients   *BrokerClients
+	semaphore chan struct{}
+
+	mutex sync.RWMutex
+	diffs map[string][]*ShadowDiff
+}
+
+// NewShadowMirror creates a shadow mirror which sends the mirrored requests using the provided broker clients
+func NewShadowMirror(clients *BrokerClients) *ShadowMirror {
+	return &ShadowMirror{
+		clients:   clients,
+		semaphore: make(chan struct{}, maxConcurrentShadowRequests),
+		diffs:     make(map[string][]*ShadowDiff),
+	}
+}
+
+// Mirror asynchronously sends a copy of the request with the specified OSB path to the shadow broker of the broker and
+// compares the shadow response with the provided primary broker response. The response of the shadow broker is discarded.
+func (m *ShadowMirror) Mirror(ctx context.Context, broker *types.ServiceBroker, request *http.Request, osbPath string, body []byte, primaryStatus int, primaryBody []byte) {
+	if m == nil || len(broker.ShadowURL) == 0 {
+		return
+	}
+	pathPattern, found := mirroredPaths[request.Method]
+	if !found || !pathPattern.MatchString(osbPath) {
+		return
+	}
+
+	logger := log.C(ctx)
+	select {
+	case m.semaphore <- struct{}{}:
+	default:
+		logger.Warnf("Too many mirrored requests in flight. Request %s %s will not be mirrored to shadow broker of %s", request.Method, osbPath, broker.Name)
+		return
+	}
+
+	shadowRequest, err := newShadowRequest(broker, request, osbPath, body)
+	if err != nil {
+		<-m.semaphore
+		logger.WithError(err).Errorf("Could not build request towards shadow broker of %s", broker.Name)
+		return
+	}
+
+	go func() {
+		defer func() { <-m.semaphore }()
+
+		diff := &ShadowDiff{
+			Method:        request.Method,
+			Path:          osbPath,
+			PrimaryStatus: primaryStatus,
+			CreatedAt:     time.Now().UTC(),
+		}
+		shadowStatus, shadowBody, err := m.send(broker, shadowRequest)
+		if err != nil {
+			logger.WithError(err).Warnf("Mirrored request %s %s to shadow broker of %s failed", request.Method, osbPath, broker.Name)
+			diff.Error = err.Error()
+			m.record(broker.ID, diff)
+			return
+		}
+
+		diff.ShadowStatus = shadowStatus
+		diff.MissingFields, diff.UnexpectedFields, diff.ChangedFields = compareJSONShapes(primaryBody, shadowBody)
+		if shadowStatus != primaryStatus || len(diff.MissingFields) != 0 || len(diff.UnexpectedFields) != 0 || len(diff.ChangedFields) != 0 {
+			logger.Infof("Shadow broker of %s responded differently to %s %s", broker.Name, request.Method, osbPath)
+			m.record(broker.ID, diff)
+		}
+	}()
+}
+
+// Diffs returns the recorded differences for the broker with the specified id starting with the most recent one
+func (m *ShadowMirror) Diffs(brokerID string) []*ShadowDiff {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	brokerDiffs := m.diffs[brokerID]
+	result := make([]*ShadowDiff, 0, len(brokerDiffs))
+	for i := len(brokerDiffs) - 1; i >= 0; i-- {
+		result = append(result, brokerDiffs[i])
+	}
+	return result
+}
+
+// Clear removes the recorded differences for the broker with the specified id
+func (m *ShadowMirror) Clear(brokerID string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.diffs, brokerID)
+}
+
+func (m *ShadowMirror) record(brokerID string, diff *ShadowDiff) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	brokerDiffs := append(m.diffs[brokerID], diff)
+	if len(brokerDiffs) > maxShadowDiffsPerBroker {
+		brokerDiffs = brokerDiffs[len(brokerDiffs)-maxShadowDiffsPerBroker:]
+	}
+	m.diffs[brokerID] = brokerDiffs
+}
+
+func (m *ShadowMirror) send(broker *types.ServiceBroker, request *http.Request) (int, []byte, error) {
+	client := http.DefaultClient
+	if m.clients != nil {
+		var err error
+		if client, err = m.clients.transportClient(broker); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	timeout := client.Timeout
+	if timeout <= 0 {
+		timeout = defaultShadowRequestTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	response, err := client.Do(request.WithContext(ctx))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return response.StatusCode, body, nil
+}
+
+// newShadowRequest creates a copy of the request towards the broker which is sent to the shadow url of the broker
+func newShadowRequest(broker *types.ServiceBroker, request *http.Request, osbPath string, body []byte) (*http.Request, error) {
+	shadowURL, err := url.Parse(broker.ShadowURL)
+	if err != nil {
+		return nil, err
+	}
+	shadowURL.Path = strings.TrimSuffix(shadowURL.Path, "/") + osbPath
+	shadowURL.RawQuery = request.URL.RawQuery
+
+	shadowRequest, err := http.NewRequest(request.Method, shadowURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	// the shadow broker is called without credentials as the credentials for the broker are not meant for it
+	for _, header := range shadowRequestHeaders {
+		if values, found := request.Header[header]; found {
+			shadowRequest.Header[header] = append([]string{}, values...)
+		}
+	}
+	return shadowRequest, nil
+}
+
+// compareJSONShapes returns the fields which are present only in the primary body, the fields which are present only
+// in the shadow body and the fields which have different types in both bodies
+func compareJSONShapes(primaryBody, shadowBody []byte) (missing, unexpected, changed []string) {
+	primaryShape := jsonShape(primaryBody)
+	shadowShape := jsonShape(shadowBody)
+
+	for field, primaryType := range primaryShape {
+		shadowType, found := shadowShape[field]
+		switch {
+		case !found:
+			missing = append(missing, field)
+		case shadowType != primaryType:
+			changed = append(changed, field)
+		}
+	}
+	for field := range shadowShape {
+		if _, found := primaryShape[field]; !found {
+			unexpected = append(unexpected, field)
+		}
+	}
+
+	sort.Strings(missing)
+	sort.Strings(unexpected)
+	sort.Strings(changed)
+	return missing, unexpected, changed
+}
+
+// jsonShape returns the paths of all fields in the JSON body mapped to their types. Array elements are represented by [].
+func jsonShape(body []byte) map[string]gjson.Type {
+	shape := make(map[string]gjson.Type)
+	if !gjson.ValidBytes(body) {
+		return shape
+	}
+
+	var walk func(path string, value gjson.Result)
+	walk = func(path string, value gjson.Result) {
+		switch {
+		case value.IsObject():
+			value.ForEach(func(key, field gjson.Result) bool {
+				fieldPath := key.String()
+				if len(path) != 0 {
+					fieldPath = path + "." + fieldPath
+				}
+				shape[fieldPath] = shapeType(field)
+				walk(fieldPath, field)
+				return true
+			})
+		case value.IsArray():
+			value.ForEach(func(_, element gjson.Result) bool {
+				elementPath := path + "[]"
+				shape[elementPath] = shapeType(element)
+				walk(elementPath, element)
+				return true
+			})
+		}
+	}
+	walk("", gjson.ParseBytes(body))
+	return shape
+}
+
+// shapeType returns the type of the JSON value treating true and false as the same type
+func shapeType(value gjson.Result) gjson.Type {
+	if value.Type == gjson.False {
+		return gjson.True
+	}
+	return value.Type
+}
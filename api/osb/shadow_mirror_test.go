@@ -0,0 +1,115 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package osb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/Peripli/service-manager/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Shadow mirror", func() {
+	Describe("compareJSONShapes", func() {
+		It("returns missing, unexpected and changed fields", func() {
+			missing, unexpected, changed := compareJSONShapes(
+				[]byte(`{"dashboard_url":"http://dashboard","operation":"op","metadata":{"labels":[{"key":"a"}]},"usable":true}`),
+				[]byte(`{"dashboard_url":"http://dashboard","operation":1,"metadata":{"labels":[{"name":"a"}]},"usable":false}`))
+			Expect(missing).To(Equal([]string{"metadata.labels[].key"}))
+			Expect(unexpected).To(Equal([]string{"metadata.labels[].name"}))
+			Expect(changed).To(Equal([]string{"operation"}))
+		})
+	})
+
+	Describe("Mirror", func() {
+		var (
+			shadow         *httptest.Server
+			shadowRequests chan *http.Request
+			shadowBody     string
+			broker         *types.ServiceBroker
+			mirror         *ShadowMirror
+		)
+
+		BeforeEach(func() {
+			shadowRequests = make(chan *http.Request, 10)
+			shadowBody = `{"dashboard_url":"http://dashboard"}`
+			shadow = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				shadowRequests <- r
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(shadowBody))
+			}))
+			broker = &types.ServiceBroker{
+				Base:      types.Base{ID: "broker-id"},
+				Name:      "broker",
+				BrokerURL: "http://primary",
+				ShadowURL: shadow.URL + "/shadow",
+				Credentials: &types.Credentials{
+					Basic: &types.Basic{Username: "user", Password: "pass"},
+				},
+			}
+			mirror = NewShadowMirror(nil)
+		})
+
+		AfterEach(func() {
+			shadow.Close()
+		})
+
+		mirrorRequest := func(method, osbPath string, primaryStatus int, primaryBody string) {
+			request, err := http.NewRequest(method, "http://primary"+osbPath+"?accepts_incomplete=true", strings.NewReader(""))
+			Expect(err).ToNot(HaveOccurred())
+			request.SetBasicAuth("user", "pass")
+			request.Header.Set(brokerAPIVersionHeader, "2.14")
+			request.Header.Set(OriginatingIdentityHeader, "cloudfoundry eyJ1c2VyX2lkIjoicGxhdGZvcm0tdXNlciJ9")
+			mirror.Mirror(context.Background(), broker, request, osbPath, []byte(`{"plan_id":"p"}`), primaryStatus, []byte(primaryBody))
+		}
+
+		It("sends a copy of the request to the shadow broker", func() {
+			mirrorRequest(http.MethodPut, "/v2/service_instances/1", http.StatusCreated, `{"dashboard_url":"http://dashboard"}`)
+			var request *http.Request
+			Eventually(shadowRequests).Should(Receive(&request))
+			Expect(request.URL.Path).To(Equal("/shadow/v2/service_instances/1"))
+			Expect(request.URL.RawQuery).To(Equal("accepts_incomplete=true"))
+			Expect(request.Header.Get(brokerAPIVersionHeader)).To(Equal("2.14"))
+			Expect(request.Header.Get("Authorization")).To(BeEmpty())
+			Expect(request.Header.Get(OriginatingIdentityHeader)).To(BeEmpty())
+			Consistently(func() []*ShadowDiff { return mirror.Diffs(broker.ID) }).Should(BeEmpty())
+		})
+
+		It("records differences between the primary and the shadow responses", func() {
+			shadowBody = `{}`
+			mirrorRequest(http.MethodPut, "/v2/service_instances/1", http.StatusAccepted, `{"operation":"op"}`)
+			Eventually(func() []*ShadowDiff { return mirror.Diffs(broker.ID) }).Should(HaveLen(1))
+			diff := mirror.Diffs(broker.ID)[0]
+			Expect(diff.PrimaryStatus).To(Equal(http.StatusAccepted))
+			Expect(diff.ShadowStatus).To(Equal(http.StatusCreated))
+			Expect(diff.MissingFields).To(Equal([]string{"operation"}))
+
+			mirror.Clear(broker.ID)
+			Expect(mirror.Diffs(broker.ID)).To(BeEmpty())
+		})
+
+		It("does not mirror requests which are not provision, bind or catalog requests", func() {
+			mirrorRequest(http.MethodDelete, "/v2/service_instances/1", http.StatusOK, `{}`)
+			Consistently(shadowRequests).ShouldNot(Receive())
+		})
+	})
+})
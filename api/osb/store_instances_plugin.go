@@ -188,7 +188,8 @@ func (*StoreServiceInstancePlugin) Name() string {
 }
 
 func (ssi *StoreServiceInstancePlugin) Provision(request *web.Request, next web.Handler) (*web.Response, error) {
-	ctx := request.Context()
+	ctx, _ := contextWithBrokerEndpoint(request.Context())
+	request.Request = request.WithContext(ctx)
 
 	requestPayload := &provisionRequest{}
 	if err := decodeRequestBody(request, requestPayload); err != nil {
@@ -245,7 +246,8 @@ func (ssi *StoreServiceInstancePlugin) Provision(request *web.Request, next web.
 }
 
 func (ssi *StoreServiceInstancePlugin) Deprovision(request *web.Request, next web.Handler) (*web.Response, error) {
-	ctx := request.Context()
+	ctx, _ := contextWithBrokerEndpoint(request.Context())
+	request.Request = request.WithContext(ctx)
 
 	requestPayload := &deprovisionRequest{}
 	if err := parseRequestForm(request, requestPayload); err != nil {
@@ -294,7 +296,8 @@ func (ssi *StoreServiceInstancePlugin) Deprovision(request *web.Request, next we
 }
 
 func (ssi *StoreServiceInstancePlugin) UpdateService(request *web.Request, next web.Handler) (*web.Response, error) {
-	ctx := request.Context()
+	ctx, _ := contextWithBrokerEndpoint(request.Context())
+	request.Request = request.WithContext(ctx)
 
 	requestPayload := &updateRequest{}
 	if err := decodeRequestBody(request, requestPayload); err != nil {
@@ -340,7 +343,8 @@ func (ssi *StoreServiceInstancePlugin) UpdateService(request *web.Request, next
 }
 
 func (ssi *StoreServiceInstancePlugin) PollInstance(request *web.Request, next web.Handler) (*web.Response, error) {
-	ctx := request.Context()
+	ctx, _ := contextWithBrokerEndpoint(request.Context())
+	request.Request = request.WithContext(ctx)
 
 	requestPayload := &lastOperationRequest{}
 	if err := parseRequestForm(request, requestPayload); err != nil {
@@ -461,6 +465,9 @@ func (ssi *StoreServiceInstancePlugin) PollInstance(request *web.Request, next w
 func (ssi *StoreServiceInstancePlugin) updateOperation(ctx context.Context, operation *types.Operation, storage storage.Repository, req commonOSBRequest, resp *Response, state types.OperationState, correlationID string) error {
 	operation.State = state
 	operation.CorrelationID = correlationID
+	if endpoint := usedBrokerEndpoint(ctx); len(endpoint) != 0 {
+		operation.BrokerEndpoint = endpoint
+	}
 	if len(resp.Error) != 0 || len(resp.Description) != 0 {
 		errorBytes, err := json.Marshal(&util.HTTPError{
 			ErrorType:   fmt.Sprintf("BrokerError:%s", resp.Error),
@@ -494,12 +501,16 @@ func (ssi *StoreServiceInstancePlugin) storeOperation(ctx context.Context, stora
 			UpdatedAt: req.GetTimestamp(),
 			Labels:    make(map[string][]string),
 		},
-		Type:          category,
-		State:         state,
-		ResourceID:    req.GetInstanceID(),
-		ResourceType:  "/v1/service_instances",
-		CorrelationID: correlationID,
-		ExternalID:    resp.OperationData,
+		Type:           category,
+		State:          state,
+		ResourceID:     req.GetInstanceID(),
+		ResourceType:   "/v1/service_instances",
+		CorrelationID:  correlationID,
+		ExternalID:     resp.OperationData,
+		BrokerEndpoint: usedBrokerEndpoint(ctx),
+	}
+	if applied := appliedDefaultParameters(ctx); len(applied) != 0 {
+		operation.Labels[AppliedDefaultParametersLabel] = applied
 	}
 
 	if _, err := storage.Create(ctx, operation); err != nil {
@@ -0,0 +1,185 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Peripli/service-manager/api/osb"
+	"github.com/Peripli/service-manager/pkg/log"
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/pkg/web"
+	"github.com/Peripli/service-manager/platforms"
+)
+
+// PlatformController implements api.Controller by providing platforms API logic
+type PlatformController struct {
+	*BaseController
+
+	credentialsGracePeriod time.Duration
+	brokerStaging          *osb.BrokerStaging
+}
+
+// NewPlatformController returns a new platforms controller
+func NewPlatformController(options *Options) *PlatformController {
+	return &PlatformController{
+		BaseController: NewController(options, web.PlatformsURL, types.PlatformType, func() types.Object {
+			return &types.Platform{}
+		}),
+		credentialsGracePeriod: options.APISettings.PlatformCredentialsGracePeriod,
+		brokerStaging: &osb.BrokerStaging{
+			Repository: options.Repository,
+			Label:      options.APISettings.BrokerStagingLabel,
+		},
+	}
+}
+
+// Routes returns the common routes for platforms, the route for listing the inactive platforms,
+// the route for rotating the platform credentials and the route for the marketplace of a platform
+func (c *PlatformController) Routes() []web.Route {
+	// the inactive platforms route is registered first, so that it is not matched as a platform ID
+	routes := []web.Route{
+		{
+			Endpoint: web.Endpoint{
+				Method: http.MethodGet,
+				Path:   web.InactivePlatformsURL,
+			},
+			Handler: c.ListInactivePlatforms,
+		},
+	}
+	return append(append(routes, c.BaseController.Routes()...),
+		web.Route{
+			Endpoint: web.Endpoint{
+				Method: http.MethodPost,
+				Path:   fmt.Sprintf("%s/{%s}%s", web.PlatformsURL, PathParamResourceID, web.RotateCredentialsURL),
+			},
+			Handler: c.RotateCredentials,
+		},
+		web.Route{
+			Endpoint: web.Endpoint{
+				Method: http.MethodGet,
+				Path:   fmt.Sprintf("%s/{%s}%s", web.PlatformsURL, PathParamResourceID, web.PlatformMarketplaceURL),
+			},
+			Handler: c.Marketplace,
+		},
+	)
+}
+
+// inactivePlatform is the representation of a platform in the inactive platforms list
+type inactivePlatform struct {
+	ID             string    `json:"id"`
+	Name           string    `json:"name"`
+	Type           string    `json:"type"`
+	Suspended      bool      `json:"suspended"`
+	NeverConnected bool      `json:"never_connected"`
+	InactiveSince  time.Time `json:"inactive_since"`
+}
+
+// ListInactivePlatforms returns the platforms which are not connected to the Service Manager
+// starting with the platforms which are inactive for the longest time
+func (c *PlatformController) ListInactivePlatforms(r *web.Request) (*web.Response, error) {
+	ctx := r.Context()
+	log.C(ctx).Debugf("Getting inactive %ss", c.objectType)
+
+	inactivePlatforms, err := platforms.InactivePlatforms(ctx, c.repository, query.CriteriaForContext(ctx)...)
+	if err != nil {
+		return nil, util.HandleStorageError(err, c.objectType.String())
+	}
+
+	items := make([]*inactivePlatform, 0, len(inactivePlatforms))
+	for _, platform := range inactivePlatforms {
+		items = append(items, &inactivePlatform{
+			ID:             platform.ID,
+			Name:           platform.Name,
+			Type:           platform.Type,
+			Suspended:      platform.Suspended,
+			NeverConnected: platform.NeverConnected(),
+			InactiveSince:  platform.InactiveSince(),
+		})
+	}
+
+	return util.NewJSONResponse(http.StatusOK, struct {
+		Items []*inactivePlatform `json:"items"`
+	}{Items: items})
+}
+
+// RotateCredentials issues new credentials for a platform. The replaced credentials remain valid for the configured
+// grace period, so that the platform can switch to the new credentials without downtime.
+func (c *PlatformController) RotateCredentials(r *web.Request) (*web.Response, error) {
+	platformID := r.PathParams[PathParamResourceID]
+	ctx := r.Context()
+	log.C(ctx).Debugf("Rotating credentials of %s with id %s", c.objectType, platformID)
+
+	byID := query.ByField(query.EqualsOperator, "id", platformID)
+	object, err := c.repository.Get(ctx, c.objectType, byID)
+	if err != nil {
+		return nil, util.HandleStorageError(err, c.objectType.String())
+	}
+	platform := object.(*types.Platform)
+
+	credentials := platform.Credentials
+	if credentials == nil {
+		credentials = &types.Credentials{}
+	}
+	if platform.Credentials, err = credentials.Rotate(c.credentialsGracePeriod); err != nil {
+		log.C(ctx).Error("Could not generate credentials for platform")
+		return nil, err
+	}
+	// only the hash of the new password is stored, so the password is returned only in this response
+	password := platform.Credentials.Basic.Password
+	if err := platform.Credentials.Basic.HashPassword(); err != nil {
+		log.C(ctx).Error("Could not hash credentials for platform")
+		return nil, err
+	}
+
+	object, err = c.repository.Update(ctx, platform, query.LabelChanges{}, byID)
+	if err != nil {
+		return nil, util.HandleStorageError(err, c.objectType.String())
+	}
+	log.C(ctx).Infof("Rotated credentials of platform %s with id %s", platform.Name, platformID)
+
+	object.(*types.Platform).Credentials.Basic.Password = password
+	return util.NewJSONResponse(http.StatusOK, object)
+}
+
+// Marketplace returns the service offerings and plans which are visible to a platform. For each plan the reason
+// for its visibility and the visibility which grants it are returned.
+func (c *PlatformController) Marketplace(r *web.Request) (*web.Response, error) {
+	platformID := r.PathParams[PathParamResourceID]
+	ctx := r.Context()
+	log.C(ctx).Debugf("Getting marketplace of %s with id %s", c.objectType, platformID)
+
+	byID := query.ByField(query.EqualsOperator, "id", platformID)
+	object, err := c.repository.Get(ctx, c.objectType, byID)
+	if err != nil {
+		return nil, util.HandleStorageError(err, c.objectType.String())
+	}
+
+	offerings, err := osb.PlatformMarketplace(ctx, c.repository, c.brokerStaging, object.(*types.Platform))
+	if err != nil {
+		return nil, util.HandleStorageError(err, types.ServiceOfferingType.String())
+	}
+
+	return util.NewJSONResponse(http.StatusOK, struct {
+		PlatformID string                     `json:"platform_id"`
+		Services   []*osb.MarketplaceOffering `json:"services"`
+	}{PlatformID: platformID, Services: offerings})
+}
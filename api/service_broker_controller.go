@@ -0,0 +1,169 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/Peripli/service-manager/api/osb"
+	"github.com/Peripli/service-manager/pkg/log"
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/pkg/web"
+	"github.com/Peripli/service-manager/storage/interceptors"
+	"github.com/tidwall/sjson"
+)
+
+// ServiceBrokerController implements api.Controller by providing service brokers API logic
+type ServiceBrokerController struct {
+	*BaseController
+}
+
+// NewServiceBrokerController returns a new service brokers controller
+func NewServiceBrokerController(ctx context.Context, options *Options) *ServiceBrokerController {
+	return &ServiceBrokerController{
+		BaseController: NewAsyncController(ctx, options, web.ServiceBrokersURL, types.ServiceBrokerType, func() types.Object {
+			return &types.ServiceBroker{}
+		}),
+	}
+}
+
+// Routes returns the common routes for brokers, the route for promoting brokers in staging state and the routes
+// for managing the fulfillments of the requests for catalog-only brokers
+func (c *ServiceBrokerController) Routes() []web.Route {
+	return append(c.BaseController.Routes(),
+		web.Route{
+			Endpoint: web.Endpoint{
+				Method: http.MethodPost,
+				Path:   fmt.Sprintf("%s/{%s}%s", web.ServiceBrokersURL, PathParamResourceID, web.PromoteURL),
+			},
+			Handler: c.Promote,
+		},
+		web.Route{
+			Endpoint: web.Endpoint{
+				Method: http.MethodGet,
+				Path:   fmt.Sprintf("%s/{%s}%s", web.ServiceBrokersURL, PathParamResourceID, web.FulfillmentsURL),
+			},
+			Handler: c.ListFulfillments,
+		},
+		web.Route{
+			Endpoint: web.Endpoint{
+				Method: http.MethodPatch,
+				Path:   fmt.Sprintf("%s/{%s}%s/{%s}", web.ServiceBrokersURL, PathParamResourceID, web.FulfillmentsURL, PathParamID),
+			},
+			Handler: c.CompleteFulfillment,
+		},
+	)
+}
+
+// Promote makes the plans of a broker in staging state visible according to the visibilities
+func (c *ServiceBrokerController) Promote(r *web.Request) (*web.Response, error) {
+	brokerID := r.PathParams[PathParamResourceID]
+	ctx := r.Context()
+	log.C(ctx).Debugf("Promoting %s with id %s", c.objectType, brokerID)
+
+	byID := query.ByField(query.EqualsOperator, "id", brokerID)
+	object, err := c.repository.Get(ctx, c.objectType, byID)
+	if err != nil {
+		return nil, util.HandleStorageError(err, c.objectType.String())
+	}
+	broker := object.(*types.ServiceBroker)
+	if !broker.IsStaging() {
+		return nil, &util.HTTPError{
+			ErrorType:   "Conflict",
+			Description: fmt.Sprintf("broker %s is not in %s state", broker.Name, types.BrokerStaging),
+			StatusCode:  http.StatusConflict,
+		}
+	}
+
+	broker.State = types.BrokerActive
+	// only the state of the broker changes, so its catalog is not refetched
+	object, err = c.repository.Update(interceptors.ContextWithoutCatalogRefresh(ctx), broker, query.LabelChanges{}, byID)
+	if err != nil {
+		return nil, util.HandleStorageError(err, c.objectType.String())
+	}
+	log.C(ctx).Infof("Promoted broker %s with id %s", broker.Name, brokerID)
+
+	stripCredentials(ctx, object)
+	return util.NewJSONResponse(http.StatusOK, object)
+}
+
+// ListFulfillments returns the operations which track the fulfillment of the requests for a catalog-only broker
+func (c *ServiceBrokerController) ListFulfillments(r *web.Request) (*web.Response, error) {
+	brokerID := r.PathParams[PathParamResourceID]
+	ctx := r.Context()
+	log.C(ctx).Debugf("Listing fulfillments for %s with id %s", c.objectType, brokerID)
+
+	var err error
+	ctx, err = query.AddCriteria(ctx,
+		query.ByField(query.EqualsOperator, "resource_id", brokerID),
+		query.ByField(query.EqualsOperator, "resource_type", types.FulfillmentResourceType),
+	)
+	if err != nil {
+		return nil, err
+	}
+	operations, err := c.repository.List(ctx, types.OperationType, query.CriteriaForContext(ctx)...)
+	if err != nil {
+		return nil, util.HandleStorageError(err, types.OperationType.String())
+	}
+	// the binding credentials are returned only to the platforms which fetch the service bindings
+	for _, operation := range operations.(*types.Operations).Operations {
+		if len(operation.Fulfillment) == 0 {
+			continue
+		}
+		if operation.Fulfillment, err = sjson.DeleteBytes(operation.Fulfillment, "response.credentials"); err != nil {
+			return nil, err
+		}
+	}
+	return util.NewJSONResponse(http.StatusOK, operations)
+}
+
+// CompleteFulfillment completes the fulfillment of a request for a catalog-only broker
+func (c *ServiceBrokerController) CompleteFulfillment(r *web.Request) (*web.Response, error) {
+	brokerID := r.PathParams[PathParamResourceID]
+	operationID := r.PathParams[PathParamID]
+	ctx := r.Context()
+	log.C(ctx).Debugf("Completing fulfillment %s for %s with id %s", operationID, c.objectType, brokerID)
+
+	completion := &osb.FulfillmentCompletion{}
+	if err := util.BytesToObject(r.Body, completion); err != nil {
+		return nil, err
+	}
+
+	object, err := c.repository.Get(ctx, types.OperationType,
+		query.ByField(query.EqualsOperator, "id", operationID),
+		query.ByField(query.EqualsOperator, "resource_id", brokerID),
+	)
+	if err != nil {
+		return nil, util.HandleStorageError(err, types.OperationType.String())
+	}
+	operation := object.(*types.Operation)
+	if err := completion.Complete(operation); err != nil {
+		return nil, err
+	}
+
+	object, err = c.repository.Update(ctx, operation, query.LabelChanges{})
+	if err != nil {
+		return nil, util.HandleStorageError(err, types.OperationType.String())
+	}
+	log.C(ctx).Infof("Fulfillment %s for broker with id %s completed with state %s", operationID, brokerID, completion.State)
+
+	return util.NewJSONResponse(http.StatusOK, object)
+}
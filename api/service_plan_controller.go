@@ -17,16 +17,27 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"time"
 
+	"github.com/Peripli/service-manager/pkg/log"
+	"github.com/Peripli/service-manager/pkg/query"
 	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/util"
 	"github.com/Peripli/service-manager/pkg/web"
+	"github.com/Peripli/service-manager/storage"
+	"github.com/gofrs/uuid"
 )
 
 // ServicePlanController implements api.Controller by providing service plans API logic
 type ServicePlanController struct {
 	*BaseController
+
+	transactionalRepository storage.TransactionalRepository
 }
 
 func NewServicePlanController(options *Options) *ServicePlanController {
@@ -34,6 +45,7 @@ func NewServicePlanController(options *Options) *ServicePlanController {
 		BaseController: NewController(options, web.ServicePlansURL, types.ServicePlanType, func() types.Object {
 			return &types.ServicePlan{}
 		}),
+		transactionalRepository: options.Repository,
 	}
 }
 
@@ -60,5 +72,201 @@ func (c *ServicePlanController) Routes() []web.Route {
 			},
 			Handler: c.PatchObject,
 		},
+		{
+			Endpoint: web.Endpoint{
+				Method: http.MethodPut,
+				Path:   fmt.Sprintf("%s/{%s}%s", web.ServicePlansURL, PathParamResourceID, web.PlanVisibilitiesURL),
+			},
+			Handler: c.SyncVisibilities,
+		},
+	}
+}
+
+// planVisibility is a visibility in the desired set of visibilities of a service plan
+type planVisibility struct {
+	PlatformID string       `json:"platform_id"`
+	Selector   string       `json:"selector"`
+	ValidFrom  *time.Time   `json:"valid_from"`
+	ValidUntil *time.Time   `json:"valid_until"`
+	Labels     types.Labels `json:"labels"`
+}
+
+// key identifies the visibility in the set of visibilities of a service plan
+func (v *planVisibility) key() string {
+	return visibilityKey(v.PlatformID, v.Selector)
+}
+
+func visibilityKey(platformID, selector string) string {
+	switch {
+	case platformID != "":
+		return "platform_id=" + platformID
+	case selector != "":
+		return "selector=" + selector
+	default:
+		return ""
+	}
+}
+
+// planVisibilities is the desired set of visibilities of a service plan
+type planVisibilities struct {
+	Visibilities []*planVisibility `json:"visibilities"`
+}
+
+// Validate implements InputValidator and verifies that the desired visibilities are valid and unique
+func (v *planVisibilities) Validate() error {
+	keys := make(map[string]bool, len(v.Visibilities))
+	for _, visibility := range v.Visibilities {
+		if visibility == nil {
+			return errors.New("visibility cannot be null")
+		}
+		if visibility.PlatformID != "" && visibility.Selector != "" {
+			return errors.New("visibility cannot specify both platform id and selector")
+		}
+		if visibility.Selector != "" {
+			if _, err := query.ParseSelector(visibility.Selector); err != nil {
+				return fmt.Errorf("invalid visibility selector %s: %s", visibility.Selector, err)
+			}
+		}
+		if visibility.ValidFrom != nil && visibility.ValidUntil != nil && !visibility.ValidUntil.After(*visibility.ValidFrom) {
+			return errors.New("visibility valid until must be after valid from")
+		}
+		if err := visibility.Labels.Validate(); err != nil {
+			return err
+		}
+		key := visibility.key()
+		if keys[key] {
+			return fmt.Errorf("duplicate visibility %s", key)
+		}
+		keys[key] = true
+	}
+	if keys[""] && len(keys) > 1 {
+		return errors.New("public visibility cannot be combined with other visibilities")
+	}
+	return nil
+}
+
+// planVisibilitiesChanges reports the visibilities of a service plan changed by a sync
+type planVisibilitiesChanges struct {
+	Created  []*types.Visibility `json:"created"`
+	Modified []*types.Visibility `json:"modified"`
+	Deleted  []*types.Visibility `json:"deleted"`
+}
+
+// SyncVisibilities replaces the visibilities of a service plan with the desired set of visibilities.
+// Only the visibilities which differ from the desired ones are created, modified or deleted, so that
+// the platforms are notified only about the actual changes.
+func (c *ServicePlanController) SyncVisibilities(r *web.Request) (*web.Response, error) {
+	planID := r.PathParams[PathParamResourceID]
+	ctx := r.Context()
+	log.C(ctx).Debugf("Synchronizing visibilities of %s with id %s", c.objectType, planID)
+
+	desired := &planVisibilities{}
+	if err := util.BytesToObject(r.Body, desired); err != nil {
+		return nil, err
+	}
+
+	byID := query.ByField(query.EqualsOperator, "id", planID)
+	if _, err := c.repository.Get(ctx, c.objectType, byID); err != nil {
+		return nil, util.HandleStorageError(err, c.objectType.String())
+	}
+
+	changes := &planVisibilitiesChanges{
+		Created:  []*types.Visibility{},
+		Modified: []*types.Visibility{},
+		Deleted:  []*types.Visibility{},
+	}
+	if err := c.transactionalRepository.InTransaction(ctx, func(ctx context.Context, repository storage.Repository) error {
+		return syncPlanVisibilities(ctx, repository, planID, desired.Visibilities, changes)
+	}); err != nil {
+		return nil, util.HandleStorageError(err, types.VisibilityType.String())
+	}
+	log.C(ctx).Infof("Synchronized visibilities of %s with id %s: %d created, %d modified, %d deleted",
+		c.objectType, planID, len(changes.Created), len(changes.Modified), len(changes.Deleted))
+
+	return util.NewJSONResponse(http.StatusOK, changes)
+}
+
+func syncPlanVisibilities(ctx context.Context, repository storage.Repository, planID string, desired []*planVisibility, changes *planVisibilitiesChanges) error {
+	byPlanID := query.ByField(query.EqualsOperator, "service_plan_id", planID)
+	objectList, err := repository.List(ctx, types.VisibilityType, byPlanID)
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]*types.Visibility, objectList.Len())
+	for i := 0; i < objectList.Len(); i++ {
+		visibility := objectList.ItemAt(i).(*types.Visibility)
+		current[visibilityKey(visibility.PlatformID, visibility.Selector)] = visibility
+	}
+	desiredByKey := make(map[string]*planVisibility, len(desired))
+	for _, visibility := range desired {
+		desiredByKey[visibility.key()] = visibility
+	}
+
+	// the visibilities are deleted first, so that a public visibility can replace the platform specific ones and vice versa
+	for _, key := range sortedKeys(current) {
+		if _, found := desiredByKey[key]; found {
+			continue
+		}
+		visibility := current[key]
+		byID := query.ByField(query.EqualsOperator, "id", visibility.ID)
+		if err := repository.Delete(ctx, types.VisibilityType, byID); err != nil {
+			return err
+		}
+		changes.Deleted = append(changes.Deleted, visibility)
+	}
+
+	currentTime := time.Now().UTC()
+	for _, visibility := range desired {
+		existing, found := current[visibility.key()]
+		if !found {
+			UUID, err := uuid.NewV4()
+			if err != nil {
+				return fmt.Errorf("could not generate GUID for %s: %s", types.VisibilityType, err)
+			}
+			created, err := repository.Create(ctx, &types.Visibility{
+				Base: types.Base{
+					ID:        UUID.String(),
+					CreatedAt: currentTime,
+					UpdatedAt: currentTime,
+					Labels:    visibility.Labels,
+				},
+				PlatformID:    visibility.PlatformID,
+				ServicePlanID: planID,
+				Selector:      visibility.Selector,
+				ValidFrom:     visibility.ValidFrom,
+				ValidUntil:    visibility.ValidUntil,
+			})
+			if err != nil {
+				return err
+			}
+			changes.Created = append(changes.Created, created.(*types.Visibility))
+			continue
+		}
+
+		labelChanges := query.LabelChangesBetween(existing.Labels, visibility.Labels)
+		validityChanged := !existing.HasSameValidity(&types.Visibility{ValidFrom: visibility.ValidFrom, ValidUntil: visibility.ValidUntil})
+		if len(labelChanges) == 0 && !validityChanged {
+			continue
+		}
+		existing.ValidFrom = visibility.ValidFrom
+		existing.ValidUntil = visibility.ValidUntil
+		existing.UpdatedAt = currentTime
+		byID := query.ByField(query.EqualsOperator, "id", existing.ID)
+		modified, err := repository.Update(ctx, existing, labelChanges, byID)
+		if err != nil {
+			return err
+		}
+		changes.Modified = append(changes.Modified, modified.(*types.Visibility))
+	}
+	return nil
+}
+
+func sortedKeys(visibilities map[string]*types.Visibility) []string {
+	keys := make([]string, 0, len(visibilities))
+	for key := range visibilities {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
+	return keys
 }
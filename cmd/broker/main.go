@@ -0,0 +1,48 @@
+/*
+ *    Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"net/http"
+
+	"github.com/Peripli/service-manager/pkg/broker"
+	"github.com/Peripli/service-manager/pkg/log"
+)
+
+func main() {
+	configFile := flag.String("config", "", "path to a YAML file with the broker settings and catalog - see broker.yml")
+	address := flag.String("address", ":8080", "address on which the broker listens")
+	flag.Parse()
+
+	settings := broker.DefaultSettings()
+	if len(*configFile) != 0 {
+		var err error
+		if settings, err = broker.LoadSettings(*configFile); err != nil {
+			panic(err)
+		}
+	}
+	b, err := broker.New(settings)
+	if err != nil {
+		panic(err)
+	}
+
+	log.D().Infof("Reference broker listening on %s", *address)
+	if err := http.ListenAndServe(*address, b); err != nil {
+		panic(err)
+	}
+}
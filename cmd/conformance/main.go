@@ -0,0 +1,125 @@
+/*
+ *    Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+
+	"github.com/Peripli/service-manager/pkg/broker"
+	"github.com/Peripli/service-manager/pkg/conformance"
+)
+
+func main() {
+	settings := conformance.DefaultSettings()
+	flag.StringVar(&settings.BrokerURL, "url", "", "url of the checked broker")
+	flag.StringVar(&settings.Username, "username", "", "username of the checked broker")
+	flag.StringVar(&settings.Password, "password", "", "password of the checked broker")
+	flag.StringVar(&settings.OSBVersion, "osb-version", settings.OSBVersion, "OSB version sent to the broker")
+	flag.StringVar(&settings.PlanID, "plan-id", "", "catalog id of the plan of the service instances created by the checks")
+	flag.StringVar(&settings.UpdatePlanID, "update-plan-id", "", "catalog id of the plan to which the service instance is updated")
+	flag.DurationVar(&settings.RequestTimeout, "request-timeout", settings.RequestTimeout, "timeout of the requests towards the broker")
+	flag.DurationVar(&settings.PollInterval, "poll-interval", settings.PollInterval, "interval for polling the last operation")
+	flag.DurationVar(&settings.PollTimeout, "poll-timeout", settings.PollTimeout, "maximum time to wait for asynchronous operations")
+	skip := flag.String("skip", "", "comma-separated names of the checks which should not run")
+	format := flag.String("format", "json", "format of the report - json or junit")
+	output := flag.String("output", "", "file to which the report is written - standard output if empty")
+	reference := flag.Bool("reference", false, "run the checks against the reference broker instead of the broker url")
+	referenceConfig := flag.String("reference-config", "", "path to a YAML file with the reference broker settings - see cmd/broker/broker.yml")
+	flag.Parse()
+
+	if *format != "json" && *format != "junit" {
+		exit(fmt.Errorf("unsupported report format %s", *format))
+	}
+	if len(*skip) != 0 {
+		for _, name := range strings.Split(*skip, ",") {
+			settings.Skip = append(settings.Skip, strings.TrimSpace(name))
+		}
+	}
+
+	report, err := run(settings, *reference, *referenceConfig)
+	if err != nil {
+		exit(err)
+	}
+	if err := writeReport(report, *format, *output); err != nil {
+		exit(err)
+	}
+	if !report.Successful() {
+		exit(fmt.Errorf("%d of %d conformance checks failed", report.Failed, len(report.Results)))
+	}
+}
+
+func run(settings *conformance.Settings, reference bool, referenceConfig string) (*conformance.Report, error) {
+	if reference {
+		server, err := startReferenceBroker(referenceConfig, settings)
+		if err != nil {
+			return nil, err
+		}
+		defer server.Close()
+	}
+	checker, err := conformance.NewChecker(settings)
+	if err != nil {
+		return nil, err
+	}
+	return checker.Run(context.Background()), nil
+}
+
+func writeReport(report *conformance.Report, format, output string) error {
+	var writer io.Writer = os.Stdout
+	if len(output) != 0 {
+		file, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		writer = file
+	}
+	if format == "junit" {
+		return report.WriteJUnit(writer)
+	}
+	return report.WriteJSON(writer)
+}
+
+// startReferenceBroker starts the reference broker and points the checks towards it
+func startReferenceBroker(configFile string, settings *conformance.Settings) (*httptest.Server, error) {
+	brokerSettings := broker.DefaultSettings()
+	if len(configFile) != 0 {
+		var err error
+		if brokerSettings, err = broker.LoadSettings(configFile); err != nil {
+			return nil, err
+		}
+	}
+	b, err := broker.New(brokerSettings)
+	if err != nil {
+		return nil, err
+	}
+	server := httptest.NewServer(b)
+	settings.BrokerURL = server.URL
+	settings.Username = brokerSettings.Username
+	settings.Password = brokerSettings.Password
+	return server, nil
+}
+
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -20,6 +20,8 @@ import (
 	"fmt"
 	"github.com/Peripli/service-manager/operations"
 	"github.com/Peripli/service-manager/pkg/health"
+	"github.com/Peripli/service-manager/pkg/types"
+	"net/http"
 	"testing"
 	"time"
 
@@ -202,6 +204,41 @@ var _ = Describe("config", func() {
 			})
 		})
 
+		Context("when API originating identity policy is unsupported", func() {
+			It("returns an error", func() {
+				config.API.OriginatingIdentityPolicy = "ignore"
+				assertErrorDuringValidate()
+			})
+		})
+
+		Context("when API OSB validation mode is unsupported", func() {
+			It("returns an error", func() {
+				config.API.OSBValidationMode = "lenient"
+				assertErrorDuringValidate()
+			})
+		})
+
+		Context("when API broker url denied CIDRs are invalid", func() {
+			It("returns an error", func() {
+				config.API.BrokerURLDeniedCIDRs = []string{"10.0.0.0/33"}
+				assertErrorDuringValidate()
+			})
+		})
+
+		Context("when API broker error mappings are invalid", func() {
+			It("returns an error", func() {
+				config.API.BrokerErrorMappings = []*types.BrokerErrorMapping{{StatusCodes: []int{http.StatusBadRequest}}}
+				assertErrorDuringValidate()
+			})
+		})
+
+		Context("when API platform credentials grace period is negative", func() {
+			It("returns an error", func() {
+				config.API.PlatformCredentialsGracePeriod = -time.Minute
+				assertErrorDuringValidate()
+			})
+		})
+
 		Context("when notification queues size is 0", func() {
 			It("returns an error", func() {
 				config.Storage.Notification.QueuesSize = 0
@@ -277,6 +314,27 @@ var _ = Describe("config", func() {
 				assertErrorDuringValidate()
 			})
 		})
+
+		Context("when platforms maintenance interval is <= 0", func() {
+			It("returns an error", func() {
+				config.Platforms.MaintenanceInterval = 0
+				assertErrorDuringValidate()
+			})
+		})
+
+		Context("when platforms suspension threshold is < 0", func() {
+			It("returns an error", func() {
+				config.Platforms.SuspensionThreshold = -time.Second
+				assertErrorDuringValidate()
+			})
+		})
+
+		Context("when visibilities activation interval is <= 0", func() {
+			It("returns an error", func() {
+				config.Visibilities.ActivationInterval = 0
+				assertErrorDuringValidate()
+			})
+		})
 	})
 
 	Describe("New", func() {
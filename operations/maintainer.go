@@ -88,10 +88,22 @@ func (om *Maintainer) processOrphanOperations() {
 
 func (om *Maintainer) cleanUpOldOperations() {
 	byDate := query.ByField(query.LessThanOperator, "created_at", util.ToRFCNanoFormat(time.Now().Add(-om.cleanupInterval)))
-	if err := om.repository.Delete(om.smCtx, types.OperationType, byDate); err != nil {
+	notFulfillment := query.ByField(query.NotEqualsOperator, "resource_type", types.FulfillmentResourceType)
+	if err := om.repository.Delete(om.smCtx, types.OperationType, byDate, notFulfillment); err != nil && err != util.ErrNotFoundInStorage {
 		log.D().Debugf("Failed to cleanup operations: %s", err)
 		return
 	}
+
+	// fulfillments are completed manually, so they are cleaned up only after their completion
+	completedFulfillments := []query.Criterion{
+		byDate,
+		query.ByField(query.EqualsOperator, "resource_type", types.FulfillmentResourceType),
+		query.ByField(query.NotEqualsOperator, "state", string(types.IN_PROGRESS)),
+	}
+	if err := om.repository.Delete(om.smCtx, types.OperationType, completedFulfillments...); err != nil && err != util.ErrNotFoundInStorage {
+		log.D().Debugf("Failed to cleanup fulfillment operations: %s", err)
+		return
+	}
 	log.D().Debug("Successfully cleaned up operations")
 }
 
@@ -99,6 +111,7 @@ func (om *Maintainer) markOrphanOperationsFailed() {
 	criteria := []query.Criterion{
 		query.ByField(query.EqualsOperator, "state", string(types.IN_PROGRESS)),
 		query.ByField(query.LessThanOperator, "created_at", util.ToRFCNanoFormat(time.Now().Add(-om.jobTimeout))),
+		query.ByField(query.NotEqualsOperator, "resource_type", types.FulfillmentResourceType),
 	}
 
 	objectList, err := om.repository.List(om.smCtx, types.OperationType, criteria...)
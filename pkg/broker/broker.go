@@ -0,0 +1,506 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+// Package broker contains a configurable in-memory OSB broker which can be used for testing and demos
+package broker
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Peripli/service-manager/pkg/log"
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/mux"
+)
+
+const (
+	apiVersionHeader = "X-Broker-API-Version"
+
+	instanceIDPathParam = "instance_id"
+	bindingIDPathParam  = "binding_id"
+
+	catalogURL                      = "/v2/catalog"
+	serviceInstanceURL              = "/v2/service_instances/{" + instanceIDPathParam + "}"
+	serviceInstanceLastOperationURL = serviceInstanceURL + "/last_operation"
+	serviceBindingURL               = serviceInstanceURL + "/service_bindings/{" + bindingIDPathParam + "}"
+	serviceBindingLastOperationURL  = serviceBindingURL + "/last_operation"
+
+	stateInProgress = "in progress"
+	stateSucceeded  = "succeeded"
+	stateFailed     = "failed"
+
+	// completedOperationRetention is the period for which completed operations can still be polled
+	completedOperationRetention = 1 * time.Hour
+)
+
+// Instance is a service instance provisioned by the broker
+type Instance struct {
+	ServiceID  string          `json:"service_id"`
+	PlanID     string          `json:"plan_id"`
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+}
+
+// Binding is a service binding created by the broker
+type Binding struct {
+	InstanceID  string            `json:"-"`
+	Parameters  json.RawMessage   `json:"parameters,omitempty"`
+	Credentials map[string]string `json:"credentials"`
+}
+
+type operation struct {
+	key         string
+	method      string
+	async       bool
+	state       string
+	completesAt time.Time
+	fails       bool
+	apply       func()
+}
+
+// Broker is an in-memory OSB broker which keeps its service instances and bindings in memory. The broker performs
+// the OSB operations either synchronously or asynchronously with the configured delays and failure rates.
+type Broker struct {
+	settings *Settings
+	catalog  json.RawMessage
+	plans    map[string]string
+
+	mutex      sync.Mutex
+	instances  map[string]*Instance
+	bindings   map[string]*Binding
+	operations map[string]*operation
+
+	router *mux.Router
+}
+
+// New creates a broker with the specified settings
+func New(settings *Settings) (*Broker, error) {
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+	catalog, err := settings.catalogJSON()
+	if err != nil {
+		return nil, fmt.Errorf("invalid catalog: %s", err)
+	}
+	plans, err := catalogPlans(catalog)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Broker{
+		settings:   settings,
+		catalog:    catalog,
+		plans:      plans,
+		instances:  make(map[string]*Instance),
+		bindings:   make(map[string]*Binding),
+		operations: make(map[string]*operation),
+	}
+	b.router = mux.NewRouter()
+	b.router.HandleFunc(catalogURL, b.getCatalog).Methods(http.MethodGet)
+	b.router.HandleFunc(serviceInstanceURL, b.provision).Methods(http.MethodPut)
+	b.router.HandleFunc(serviceInstanceURL, b.update).Methods(http.MethodPatch)
+	b.router.HandleFunc(serviceInstanceURL, b.deprovision).Methods(http.MethodDelete)
+	b.router.HandleFunc(serviceInstanceURL, b.fetchInstance).Methods(http.MethodGet)
+	b.router.HandleFunc(serviceInstanceLastOperationURL, b.lastOperation).Methods(http.MethodGet)
+	b.router.HandleFunc(serviceBindingURL, b.bind).Methods(http.MethodPut)
+	b.router.HandleFunc(serviceBindingURL, b.unbind).Methods(http.MethodDelete)
+	b.router.HandleFunc(serviceBindingURL, b.fetchBinding).Methods(http.MethodGet)
+	b.router.HandleFunc(serviceBindingLastOperationURL, b.lastOperation).Methods(http.MethodGet)
+	return b, nil
+}
+
+// ServeHTTP implements http.Handler
+func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(b.settings.Username) != 0 {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != b.settings.Username || password != b.settings.Password {
+			writeError(w, http.StatusUnauthorized, "Unauthorized", "invalid broker credentials")
+			return
+		}
+	}
+	if version := r.Header.Get(apiVersionHeader); !strings.HasPrefix(version, "2.") {
+		writeError(w, http.StatusPreconditionFailed, "PreconditionFailed", fmt.Sprintf("unsupported OSB version %q", version))
+		return
+	}
+	b.router.ServeHTTP(w, r)
+}
+
+// Instances returns the service instances of the broker
+func (b *Broker) Instances() map[string]Instance {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.completeOperations()
+
+	result := make(map[string]Instance, len(b.instances))
+	for id, instance := range b.instances {
+		result[id] = *instance
+	}
+	return result
+}
+
+// Bindings returns the service bindings of the broker
+func (b *Broker) Bindings() map[string]Binding {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.completeOperations()
+
+	result := make(map[string]Binding, len(b.bindings))
+	for id, binding := range b.bindings {
+		result[id] = *binding
+	}
+	return result
+}
+
+func (b *Broker) getCatalog(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, b.catalog)
+}
+
+func (b *Broker) provision(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)[instanceIDPathParam]
+	request := &struct {
+		ServiceID  string          `json:"service_id"`
+		PlanID     string          `json:"plan_id"`
+		Parameters json.RawMessage `json:"parameters"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", fmt.Sprintf("invalid request body: %s", err))
+		return
+	}
+	if serviceID, found := b.plans[request.PlanID]; !found || serviceID != request.ServiceID {
+		writeError(w, http.StatusBadRequest, "BadRequest", fmt.Sprintf("plan %s of service %s not found in catalog", request.PlanID, request.ServiceID))
+		return
+	}
+	instance := &Instance{
+		ServiceID:  request.ServiceID,
+		PlanID:     request.PlanID,
+		Parameters: request.Parameters,
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.completeOperations()
+	existing, found := b.instances[instanceID]
+	if found {
+		if reflect.DeepEqual(existing, instance) {
+			writeJSON(w, http.StatusOK, map[string]string{})
+		} else {
+			writeError(w, http.StatusConflict, "Conflict", fmt.Sprintf("service instance %s already exists", instanceID))
+		}
+		return
+	}
+
+	b.perform(w, r, instanceID, b.settings.Provision, http.StatusCreated, map[string]string{}, func() {
+		b.instances[instanceID] = instance
+	})
+}
+
+func (b *Broker) update(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)[instanceIDPathParam]
+	request := &struct {
+		ServiceID  string          `json:"service_id"`
+		PlanID     string          `json:"plan_id"`
+		Parameters json.RawMessage `json:"parameters"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", fmt.Sprintf("invalid request body: %s", err))
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.completeOperations()
+	instance, found := b.instances[instanceID]
+	if !found {
+		writeError(w, http.StatusNotFound, "NotFound", fmt.Sprintf("service instance %s not found", instanceID))
+		return
+	}
+	if len(request.PlanID) != 0 {
+		if serviceID, found := b.plans[request.PlanID]; !found || serviceID != instance.ServiceID {
+			writeError(w, http.StatusBadRequest, "BadRequest", fmt.Sprintf("plan %s of service %s not found in catalog", request.PlanID, instance.ServiceID))
+			return
+		}
+	}
+
+	b.perform(w, r, instanceID, b.settings.Update, http.StatusOK, map[string]string{}, func() {
+		if len(request.PlanID) != 0 {
+			instance.PlanID = request.PlanID
+		}
+		if len(request.Parameters) != 0 {
+			instance.Parameters = request.Parameters
+		}
+	})
+}
+
+func (b *Broker) deprovision(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)[instanceIDPathParam]
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.completeOperations()
+	_, found := b.instances[instanceID]
+	if !found {
+		writeJSON(w, http.StatusGone, map[string]string{})
+		return
+	}
+
+	b.perform(w, r, instanceID, b.settings.Deprovision, http.StatusOK, map[string]string{}, func() {
+		delete(b.instances, instanceID)
+		for bindingID, binding := range b.bindings {
+			if binding.InstanceID == instanceID {
+				delete(b.bindings, bindingID)
+			}
+		}
+	})
+}
+
+func (b *Broker) fetchInstance(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)[instanceIDPathParam]
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.completeOperations()
+	instance, found := b.instances[instanceID]
+	if !found {
+		writeError(w, http.StatusNotFound, "NotFound", fmt.Sprintf("service instance %s not found", instanceID))
+		return
+	}
+	writeJSON(w, http.StatusOK, instance)
+}
+
+func (b *Broker) bind(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)[instanceIDPathParam]
+	bindingID := mux.Vars(r)[bindingIDPathParam]
+	request := &struct {
+		Parameters json.RawMessage `json:"parameters"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", fmt.Sprintf("invalid request body: %s", err))
+		return
+	}
+
+	password, err := uuid.NewV4()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "InternalError", fmt.Sprintf("could not generate credentials: %s", err))
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.completeOperations()
+	_, instanceFound := b.instances[instanceID]
+	existing, bindingFound := b.bindings[bindingID]
+	if !instanceFound {
+		writeError(w, http.StatusNotFound, "NotFound", fmt.Sprintf("service instance %s not found", instanceID))
+		return
+	}
+	if bindingFound {
+		if existing.InstanceID == instanceID && reflect.DeepEqual(existing.Parameters, request.Parameters) {
+			writeJSON(w, http.StatusOK, existing)
+		} else {
+			writeError(w, http.StatusConflict, "Conflict", fmt.Sprintf("service binding %s already exists", bindingID))
+		}
+		return
+	}
+
+	binding := &Binding{
+		InstanceID: instanceID,
+		Parameters: request.Parameters,
+		Credentials: map[string]string{
+			"username": bindingID,
+			"password": password.String(),
+		},
+	}
+	b.perform(w, r, bindingID, b.settings.Bind, http.StatusCreated, binding, func() {
+		b.bindings[bindingID] = binding
+	})
+}
+
+func (b *Broker) unbind(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)[instanceIDPathParam]
+	bindingID := mux.Vars(r)[bindingIDPathParam]
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.completeOperations()
+	binding, found := b.bindings[bindingID]
+	if !found || binding.InstanceID != instanceID {
+		writeJSON(w, http.StatusGone, map[string]string{})
+		return
+	}
+
+	b.perform(w, r, bindingID, b.settings.Unbind, http.StatusOK, map[string]string{}, func() {
+		delete(b.bindings, bindingID)
+	})
+}
+
+func (b *Broker) fetchBinding(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)[instanceIDPathParam]
+	bindingID := mux.Vars(r)[bindingIDPathParam]
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.completeOperations()
+	binding, found := b.bindings[bindingID]
+	if !found || binding.InstanceID != instanceID {
+		writeError(w, http.StatusNotFound, "NotFound", fmt.Sprintf("service binding %s not found", bindingID))
+		return
+	}
+	writeJSON(w, http.StatusOK, binding)
+}
+
+func (b *Broker) lastOperation(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)[instanceIDPathParam]
+	if bindingID, found := mux.Vars(r)[bindingIDPathParam]; found {
+		key = bindingID
+	}
+	operationID := r.URL.Query().Get("operation")
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.completeOperations()
+	op, found := b.operations[operationID]
+	if !found || op.key != key {
+		writeError(w, http.StatusBadRequest, "BadRequest", fmt.Sprintf("operation %s not found", operationID))
+		return
+	}
+	response := map[string]string{
+		"state": op.state,
+	}
+	if op.state == stateFailed {
+		response["description"] = "injected failure"
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// perform performs an operation for the service instance or binding with the specified key. Asynchronous operations
+// are completed when their delay elapses and synchronous operations are completed before responding.
+// The caller should hold the lock of the broker so that the checks it made still hold when the operation is started.
+func (b *Broker) perform(w http.ResponseWriter, r *http.Request, key string, settings OperationSettings, status int, body interface{}, apply func()) {
+	for operationID, op := range b.operations {
+		if op.key != key || op.state != stateInProgress {
+			continue
+		}
+		if op.async && op.method == r.Method {
+			writeJSON(w, http.StatusAccepted, map[string]string{"operation": operationID})
+		} else {
+			writeError(w, http.StatusUnprocessableEntity, "ConcurrencyError", fmt.Sprintf("another operation for %s is in progress", key))
+		}
+		return
+	}
+
+	operationID, err := uuid.NewV4()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "InternalError", fmt.Sprintf("could not generate operation id: %s", err))
+		return
+	}
+	op := &operation{
+		key:         key,
+		method:      r.Method,
+		async:       settings.Async && r.URL.Query().Get("accepts_incomplete") == "true",
+		state:       stateInProgress,
+		completesAt: time.Now().Add(settings.Delay),
+		fails:       settings.FailureRate > 0 && rand.Float64() < settings.FailureRate,
+		apply:       apply,
+	}
+	b.operations[operationID.String()] = op
+	if op.async {
+		log.C(r.Context()).Infof("Started operation %s for %s %s", operationID, r.Method, r.URL.Path)
+		writeJSON(w, http.StatusAccepted, map[string]string{"operation": operationID.String()})
+		return
+	}
+
+	// synchronous operations stay registered while the broker waits so that concurrent requests for the same key are rejected
+	b.mutex.Unlock()
+	time.Sleep(settings.Delay)
+	b.mutex.Lock()
+	delete(b.operations, operationID.String())
+	if op.fails {
+		writeError(w, http.StatusInternalServerError, "InjectedFailure", "injected failure")
+		return
+	}
+	apply()
+	writeJSON(w, status, body)
+}
+
+// completeOperations completes the asynchronous operations whose delay has elapsed and removes the operations
+// which completed more than the retention period ago
+func (b *Broker) completeOperations() {
+	now := time.Now()
+	for operationID, op := range b.operations {
+		if op.state != stateInProgress {
+			if now.Sub(op.completesAt) > completedOperationRetention {
+				delete(b.operations, operationID)
+			}
+			continue
+		}
+		if !op.async || now.Before(op.completesAt) {
+			continue
+		}
+		if op.fails {
+			op.state = stateFailed
+			continue
+		}
+		op.apply()
+		op.state = stateSucceeded
+	}
+}
+
+func catalogPlans(catalog json.RawMessage) (map[string]string, error) {
+	services := &struct {
+		Services []struct {
+			ID    string `json:"id"`
+			Plans []struct {
+				ID string `json:"id"`
+			} `json:"plans"`
+		} `json:"services"`
+	}{}
+	if err := json.Unmarshal(catalog, services); err != nil {
+		return nil, fmt.Errorf("invalid catalog: %s", err)
+	}
+	plans := make(map[string]string)
+	for _, service := range services.Services {
+		if len(service.ID) == 0 {
+			return nil, fmt.Errorf("invalid catalog: missing service id")
+		}
+		for _, plan := range service.Plans {
+			if len(plan.ID) == 0 {
+				return nil, fmt.Errorf("invalid catalog: missing plan id of service %s", service.ID)
+			}
+			plans[plan.ID] = service.ID
+		}
+	}
+	return plans, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.D().WithError(err).Error("Could not write response")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, errorType, description string) {
+	writeJSON(w, status, map[string]string{
+		"error":       errorType,
+		"description": description,
+	})
+}
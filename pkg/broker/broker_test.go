@@ -0,0 +1,226 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package broker_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Peripli/service-manager/pkg/broker"
+	"github.com/tidwall/gjson"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	serviceID = "7f3a5ed6-2a5c-4b34-9a5e-6b6ab3e0b1a1"
+	planID    = "b8b2c6e4-96b8-4c4f-a4f5-2a3cf0b39e41"
+
+	instanceURL = "/v2/service_instances/instance-id"
+	bindingURL  = instanceURL + "/service_bindings/binding-id"
+)
+
+var _ = Describe("Reference broker", func() {
+	var (
+		settings *broker.Settings
+		b        *broker.Broker
+	)
+
+	send := func(method, url, body string) (int, string) {
+		request := httptest.NewRequest(method, url, strings.NewReader(body))
+		request.SetBasicAuth("admin", "secret")
+		request.Header.Set("X-Broker-API-Version", "2.14")
+		recorder := httptest.NewRecorder()
+		b.ServeHTTP(recorder, request)
+		return recorder.Code, recorder.Body.String()
+	}
+
+	provisionBody := `{"service_id":"` + serviceID + `","plan_id":"` + planID + `"}`
+
+	BeforeEach(func() {
+		settings = broker.DefaultSettings()
+		settings.Username = "admin"
+		settings.Password = "secret"
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		b, err = broker.New(settings)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("rejects requests with invalid credentials", func() {
+		request := httptest.NewRequest(http.MethodGet, "/v2/catalog", nil)
+		recorder := httptest.NewRecorder()
+		b.ServeHTTP(recorder, request)
+		Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("rejects requests for unsupported OSB versions", func() {
+		request := httptest.NewRequest(http.MethodGet, "/v2/catalog", nil)
+		request.SetBasicAuth("admin", "secret")
+		request.Header.Set("X-Broker-API-Version", "1.0")
+		recorder := httptest.NewRecorder()
+		b.ServeHTTP(recorder, request)
+		Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed))
+	})
+
+	It("serves the catalog", func() {
+		status, body := send(http.MethodGet, "/v2/catalog", "")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(gjson.Get(body, "services.0.plans.0.id").String()).To(Equal(planID))
+	})
+
+	Context("with synchronous operations", func() {
+		It("provisions, binds, unbinds and deprovisions", func() {
+			status, _ := send(http.MethodPut, instanceURL, provisionBody)
+			Expect(status).To(Equal(http.StatusCreated))
+			Expect(b.Instances()).To(HaveKey("instance-id"))
+
+			status, _ = send(http.MethodPut, instanceURL, provisionBody)
+			Expect(status).To(Equal(http.StatusOK))
+
+			status, _ = send(http.MethodPatch, instanceURL, `{"service_id":"`+serviceID+`","parameters":{"size":2}}`)
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(string(b.Instances()["instance-id"].Parameters)).To(MatchJSON(`{"size":2}`))
+
+			status, body := send(http.MethodPut, bindingURL, `{}`)
+			Expect(status).To(Equal(http.StatusCreated))
+			Expect(gjson.Get(body, "credentials.password").String()).ToNot(BeEmpty())
+
+			status, _ = send(http.MethodDelete, bindingURL, "")
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(b.Bindings()).To(BeEmpty())
+
+			status, _ = send(http.MethodDelete, instanceURL, "")
+			Expect(status).To(Equal(http.StatusOK))
+			status, _ = send(http.MethodDelete, instanceURL, "")
+			Expect(status).To(Equal(http.StatusGone))
+		})
+
+		It("rejects plans which are not in the catalog", func() {
+			status, _ := send(http.MethodPut, instanceURL, `{"service_id":"`+serviceID+`","plan_id":"unknown"}`)
+			Expect(status).To(Equal(http.StatusBadRequest))
+		})
+
+		Context("with delay", func() {
+			BeforeEach(func() {
+				settings.Provision.Delay = 500 * time.Millisecond
+			})
+
+			It("rejects concurrent operations for the same instance", func() {
+				statuses := make(chan int, 1)
+				go func() {
+					defer GinkgoRecover()
+					status, _ := send(http.MethodPut, instanceURL, provisionBody)
+					statuses <- status
+				}()
+
+				time.Sleep(100 * time.Millisecond)
+				status, _ := send(http.MethodPut, instanceURL, provisionBody)
+				Expect(status).To(Equal(http.StatusUnprocessableEntity))
+				Eventually(statuses).Should(Receive(Equal(http.StatusCreated)))
+				Expect(b.Instances()).To(HaveLen(1))
+			})
+		})
+
+		Context("with failure injection", func() {
+			BeforeEach(func() {
+				settings.Provision.FailureRate = 1
+			})
+
+			It("fails the operations", func() {
+				status, _ := send(http.MethodPut, instanceURL, provisionBody)
+				Expect(status).To(Equal(http.StatusInternalServerError))
+				Expect(b.Instances()).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("with asynchronous operations", func() {
+		BeforeEach(func() {
+			settings.Provision.Async = true
+		})
+
+		It("completes the operations when platforms poll the last operation", func() {
+			status, body := send(http.MethodPut, instanceURL+"?accepts_incomplete=true", provisionBody)
+			Expect(status).To(Equal(http.StatusAccepted))
+			operation := gjson.Get(body, "operation").String()
+
+			status, body = send(http.MethodGet, instanceURL+"/last_operation?operation="+operation, "")
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(gjson.Get(body, "state").String()).To(Equal("succeeded"))
+			Expect(b.Instances()).To(HaveKey("instance-id"))
+		})
+
+		It("performs the operations synchronously for platforms which do not accept incomplete operations", func() {
+			status, _ := send(http.MethodPut, instanceURL, provisionBody)
+			Expect(status).To(Equal(http.StatusCreated))
+		})
+
+		Context("with failure injection", func() {
+			BeforeEach(func() {
+				settings.Provision.FailureRate = 1
+			})
+
+			It("fails the operations", func() {
+				_, body := send(http.MethodPut, instanceURL+"?accepts_incomplete=true", provisionBody)
+				operation := gjson.Get(body, "operation").String()
+
+				_, body = send(http.MethodGet, instanceURL+"/last_operation?operation="+operation, "")
+				Expect(gjson.Get(body, "state").String()).To(Equal("failed"))
+				Expect(b.Instances()).To(BeEmpty())
+			})
+		})
+	})
+
+	It("loads settings and catalog from YAML", func() {
+		file, err := ioutil.TempFile("", "broker-*.yml")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.Remove(file.Name())
+		_, err = file.WriteString(`
+bind:
+  async: true
+  delay: 2s
+catalog:
+  services:
+    - id: service-id
+      name: service
+      plans:
+        - id: plan-id
+          name: plan
+`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+
+		settings, err := broker.LoadSettings(file.Name())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(settings.Bind.Async).To(BeTrue())
+		Expect(settings.Bind.Delay.Seconds()).To(Equal(2.0))
+
+		b, err = broker.New(settings)
+		Expect(err).ToNot(HaveOccurred())
+		status, body := send(http.MethodGet, "/v2/catalog", "")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(gjson.Get(body, "services.0.plans.0.id").String()).To(Equal("plan-id"))
+	})
+})
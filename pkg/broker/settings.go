@@ -0,0 +1,167 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package broker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const defaultCatalog = `{
+  "services": [{
+    "id": "7f3a5ed6-2a5c-4b34-9a5e-6b6ab3e0b1a1",
+    "name": "reference-service",
+    "description": "Service of the reference broker",
+    "bindable": true,
+    "plan_updateable": true,
+    "instances_retrievable": true,
+    "bindings_retrievable": true,
+    "plans": [{
+      "id": "b8b2c6e4-96b8-4c4f-a4f5-2a3cf0b39e41",
+      "name": "small",
+      "description": "Small plan of the reference service",
+      "free": true
+    }, {
+      "id": "0d1e4e6c-79f6-4a5b-b2d7-c6c0cb8c5c2f",
+      "name": "large",
+      "description": "Large plan of the reference service",
+      "free": false
+    }]
+  }]
+}`
+
+// OperationSettings configures how the broker performs an OSB operation
+type OperationSettings struct {
+	// Async makes the broker perform the operation asynchronously if the platform accepts incomplete operations
+	Async bool `yaml:"async"`
+	// Delay is the time the broker takes to perform the operation
+	Delay time.Duration `yaml:"delay"`
+	// FailureRate is the fraction of the operations which fail - 0 means no operation fails and 1 means all operations fail
+	FailureRate float64 `yaml:"failure_rate"`
+}
+
+// Validate validates the operation settings
+func (s *OperationSettings) Validate() error {
+	if s.Delay < 0 {
+		return fmt.Errorf("delay %s should not be negative", s.Delay)
+	}
+	if s.FailureRate < 0 || s.FailureRate > 1 {
+		return fmt.Errorf("failure rate %f should be between 0 and 1", s.FailureRate)
+	}
+	return nil
+}
+
+// Settings configures the reference broker
+type Settings struct {
+	// Username and Password are the basic credentials of the broker - the broker is not secured if the username is empty
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+
+	Provision   OperationSettings `yaml:"provision"`
+	Update      OperationSettings `yaml:"update"`
+	Deprovision OperationSettings `yaml:"deprovision"`
+	Bind        OperationSettings `yaml:"bind"`
+	Unbind      OperationSettings `yaml:"unbind"`
+
+	// Catalog is the OSB catalog of the broker
+	Catalog interface{} `yaml:"catalog"`
+}
+
+// DefaultSettings returns settings for a synchronous broker with a single service with two plans
+func DefaultSettings() *Settings {
+	return &Settings{
+		Catalog: json.RawMessage(defaultCatalog),
+	}
+}
+
+// LoadSettings reads the settings from a YAML file. Settings which are not present in the file have their default values.
+func LoadSettings(file string) (*Settings, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read broker settings from %s: %s", file, err)
+	}
+	settings := DefaultSettings()
+	if err := yaml.Unmarshal(content, settings); err != nil {
+		return nil, fmt.Errorf("could not parse broker settings from %s: %s", file, err)
+	}
+	return settings, nil
+}
+
+// Validate validates the broker settings
+func (s *Settings) Validate() error {
+	for name, operationSettings := range map[string]OperationSettings{
+		"provision":   s.Provision,
+		"update":      s.Update,
+		"deprovision": s.Deprovision,
+		"bind":        s.Bind,
+		"unbind":      s.Unbind,
+	} {
+		if err := operationSettings.Validate(); err != nil {
+			return fmt.Errorf("invalid %s settings: %s", name, err)
+		}
+	}
+	if s.Catalog == nil {
+		return errors.New("missing catalog")
+	}
+	return nil
+}
+
+// catalogJSON returns the catalog in JSON format
+func (s *Settings) catalogJSON() (json.RawMessage, error) {
+	catalog, err := jsonCompatible(s.Catalog)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(catalog)
+}
+
+// jsonCompatible converts the maps with interface{} keys produced by the YAML parser to maps with string keys
+func jsonCompatible(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			keyString, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("catalog key %v should be a string", key)
+			}
+			converted, err := jsonCompatible(item)
+			if err != nil {
+				return nil, err
+			}
+			result[keyString] = converted
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			converted, err := jsonCompatible(item)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, converted)
+		}
+		return result, nil
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,423 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package conformance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/tidwall/gjson"
+)
+
+// Names of the conformance checks
+const (
+	CatalogCheck              = "catalog"
+	APIVersionCheck           = "api-version"
+	AuthenticationCheck       = "authentication"
+	ProvisionCheck            = "provision"
+	ProvisionIdempotencyCheck = "provision-idempotency"
+	ProvisionConflictCheck    = "provision-conflict"
+	ProvisionInvalidPlanCheck = "provision-invalid-plan"
+	FetchInstanceCheck        = "fetch-instance"
+	UpdateCheck               = "update"
+	BindCheck                 = "bind"
+	BindIdempotencyCheck      = "bind-idempotency"
+	UnbindCheck               = "unbind"
+	UnbindGoneCheck           = "unbind-gone"
+	DeprovisionCheck          = "deprovision"
+	DeprovisionGoneCheck      = "deprovision-gone"
+)
+
+func (c *Checker) checks() []check {
+	return []check{
+		{name: CatalogCheck, run: c.checkCatalog},
+		{name: APIVersionCheck, run: c.checkAPIVersion},
+		{name: AuthenticationCheck, run: c.checkAuthentication},
+		{name: ProvisionCheck, requires: []string{CatalogCheck}, run: c.checkProvision},
+		{name: ProvisionIdempotencyCheck, requires: []string{ProvisionCheck}, run: c.checkProvisionIdempotency},
+		{name: ProvisionConflictCheck, requires: []string{ProvisionCheck}, run: c.checkProvisionConflict},
+		{name: ProvisionInvalidPlanCheck, requires: []string{CatalogCheck}, run: c.checkProvisionInvalidPlan},
+		{name: FetchInstanceCheck, requires: []string{ProvisionCheck}, run: c.checkFetchInstance},
+		{name: UpdateCheck, requires: []string{ProvisionCheck}, run: c.checkUpdate},
+		{name: BindCheck, requires: []string{ProvisionCheck}, run: c.checkBind},
+		{name: BindIdempotencyCheck, requires: []string{BindCheck}, run: c.checkBindIdempotency},
+		{name: UnbindCheck, requires: []string{BindCheck}, run: c.checkUnbind},
+		{name: UnbindGoneCheck, requires: []string{UnbindCheck}, run: c.checkUnbindGone},
+		{name: DeprovisionCheck, requires: []string{ProvisionCheck}, run: c.checkDeprovision},
+		{name: DeprovisionGoneCheck, requires: []string{DeprovisionCheck}, run: c.checkDeprovisionGone},
+	}
+}
+
+// checkCatalog validates the catalog with the validation which the Service Manager applies when registering brokers
+// and selects the plan of the service instances created by the subsequent checks
+func (c *Checker) checkCatalog(ctx context.Context) error {
+	response, err := c.send(ctx, http.MethodGet, "/v2/catalog", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	if err := expectStatus("catalog", response, http.StatusOK); err != nil {
+		return err
+	}
+	catalog := struct {
+		Services []*types.ServiceOffering `json:"services"`
+	}{}
+	if err := json.Unmarshal(response.Body, &catalog); err != nil {
+		return fmt.Errorf("catalog is invalid: %s", err)
+	}
+	if len(catalog.Services) == 0 {
+		return fmt.Errorf("catalog contains no services")
+	}
+
+	serviceIDs := make(map[string]bool)
+	serviceNames := make(map[string]bool)
+	planIDs := make(map[string]bool)
+	for _, service := range catalog.Services {
+		service.CatalogID = service.ID
+		service.CatalogName = service.Name
+		service.BrokerID = c.settings.BrokerURL
+		service.ID = ""
+		if err := service.Validate(); err != nil {
+			return fmt.Errorf("service %s is invalid: %s", service.CatalogName, err)
+		}
+		if serviceIDs[service.CatalogID] || serviceNames[service.CatalogName] {
+			return fmt.Errorf("service %s with id %s is not unique in catalog", service.CatalogName, service.CatalogID)
+		}
+		serviceIDs[service.CatalogID] = true
+		serviceNames[service.CatalogName] = true
+		if len(service.Plans) == 0 {
+			return fmt.Errorf("service %s has no plans", service.CatalogName)
+		}
+
+		planNames := make(map[string]bool)
+		for _, plan := range service.Plans {
+			plan.CatalogID = plan.ID
+			plan.CatalogName = plan.Name
+			plan.ServiceOfferingID = service.CatalogID
+			plan.ID = ""
+			if err := plan.Validate(); err != nil {
+				return fmt.Errorf("plan %s of service %s is invalid: %s", plan.CatalogName, service.CatalogName, err)
+			}
+			if planIDs[plan.CatalogID] || planNames[plan.CatalogName] {
+				return fmt.Errorf("plan %s with id %s of service %s is not unique in catalog", plan.CatalogName, plan.CatalogID, service.CatalogName)
+			}
+			planIDs[plan.CatalogID] = true
+			planNames[plan.CatalogName] = true
+			c.selectPlan(service, plan)
+		}
+	}
+
+	if c.plan == nil {
+		return fmt.Errorf("plan %s not found in catalog", c.settings.PlanID)
+	}
+	return nil
+}
+
+func (c *Checker) selectPlan(service *types.ServiceOffering, plan *types.ServicePlan) {
+	if len(c.settings.PlanID) != 0 {
+		if plan.CatalogID == c.settings.PlanID {
+			c.service, c.plan = service, plan
+		}
+		return
+	}
+	if c.plan == nil || (!c.bindable() && isBindable(service, plan)) {
+		c.service, c.plan = service, plan
+	}
+}
+
+func (c *Checker) bindable() bool {
+	return c.plan != nil && isBindable(c.service, c.plan)
+}
+
+func isBindable(service *types.ServiceOffering, plan *types.ServicePlan) bool {
+	return service.Bindable || plan.Bindable
+}
+
+// checkAPIVersion verifies that the broker rejects requests for unsupported OSB versions
+func (c *Checker) checkAPIVersion(ctx context.Context) error {
+	response, err := c.send(ctx, http.MethodGet, "/v2/catalog", nil, nil, func(request *http.Request) {
+		request.Header.Set(apiVersionHeader, "1.0")
+	})
+	if err != nil {
+		return err
+	}
+	return expectStatus("catalog with unsupported OSB version", response, http.StatusPreconditionFailed)
+}
+
+// checkAuthentication verifies that the broker rejects requests with invalid credentials
+func (c *Checker) checkAuthentication(ctx context.Context) error {
+	if len(c.settings.Username) == 0 {
+		return &skipError{reason: "broker credentials are not configured"}
+	}
+	response, err := c.send(ctx, http.MethodGet, "/v2/catalog", nil, nil, func(request *http.Request) {
+		request.SetBasicAuth(c.settings.Username, c.settings.Password+"-invalid")
+	})
+	if err != nil {
+		return err
+	}
+	return expectStatus("catalog with invalid credentials", response, http.StatusUnauthorized)
+}
+
+func (c *Checker) instancePath() string {
+	return "/v2/service_instances/" + c.instanceID
+}
+
+func (c *Checker) bindingPath() string {
+	return c.instancePath() + "/service_bindings/" + c.bindingID
+}
+
+func (c *Checker) provisionBody(planID string, parameters map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"service_id":        c.service.CatalogID,
+		"plan_id":           planID,
+		"organization_guid": "conformance-organization",
+		"space_guid":        "conformance-space",
+		"context": map[string]string{
+			"platform": "service-manager",
+		},
+		"parameters": parameters,
+	}
+}
+
+func acceptsIncomplete() url.Values {
+	return url.Values{"accepts_incomplete": []string{"true"}}
+}
+
+// checkProvision provisions the service instance used by the subsequent checks
+func (c *Checker) checkProvision(ctx context.Context) error {
+	var err error
+	if c.instanceID, err = newID(); err != nil {
+		return err
+	}
+	response, err := c.send(ctx, http.MethodPut, c.instancePath(), acceptsIncomplete(), c.provisionBody(c.plan.CatalogID, nil), nil)
+	if err != nil {
+		return err
+	}
+	if err := expectStatus("provision", response, http.StatusCreated, http.StatusAccepted); err != nil {
+		return err
+	}
+	if err := expectJSONObject("provision", response); err != nil {
+		return err
+	}
+	if response.StatusCode == http.StatusAccepted {
+		return c.poll(ctx, "provision", c.instancePath(), response, false)
+	}
+	return nil
+}
+
+// checkProvisionIdempotency verifies that provisioning an existing service instance with identical attributes succeeds
+func (c *Checker) checkProvisionIdempotency(ctx context.Context) error {
+	response, err := c.send(ctx, http.MethodPut, c.instancePath(), acceptsIncomplete(), c.provisionBody(c.plan.CatalogID, nil), nil)
+	if err != nil {
+		return err
+	}
+	return expectStatus("repeated provision", response, http.StatusOK)
+}
+
+// checkProvisionConflict verifies that provisioning an existing service instance with different attributes is rejected
+func (c *Checker) checkProvisionConflict(ctx context.Context) error {
+	parameters := map[string]interface{}{"conformance": "conflict"}
+	response, err := c.send(ctx, http.MethodPut, c.instancePath(), acceptsIncomplete(), c.provisionBody(c.plan.CatalogID, parameters), nil)
+	if err != nil {
+		return err
+	}
+	return expectStatus("provision with different parameters", response, http.StatusConflict)
+}
+
+// checkProvisionInvalidPlan verifies that provisioning a service instance with a plan which is not in the catalog is rejected
+func (c *Checker) checkProvisionInvalidPlan(ctx context.Context) error {
+	instanceID, err := newID()
+	if err != nil {
+		return err
+	}
+	response, err := c.send(ctx, http.MethodPut, "/v2/service_instances/"+instanceID, acceptsIncomplete(), c.provisionBody("conformance-invalid-plan", nil), nil)
+	if err != nil {
+		return err
+	}
+	return expectStatus("provision with invalid plan", response, http.StatusBadRequest)
+}
+
+// checkFetchInstance verifies that retrievable service instances can be fetched
+func (c *Checker) checkFetchInstance(ctx context.Context) error {
+	if !c.service.InstancesRetrievable {
+		return &skipError{reason: fmt.Sprintf("service instances of service %s are not retrievable", c.service.CatalogName)}
+	}
+	response, err := c.send(ctx, http.MethodGet, c.instancePath(), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	if err := expectStatus("fetch service instance", response, http.StatusOK); err != nil {
+		return err
+	}
+	return expectJSONObject("fetch service instance", response)
+}
+
+// checkUpdate updates the parameters and, if configured, the plan of the service instance
+func (c *Checker) checkUpdate(ctx context.Context) error {
+	updatedPlan := c.plan
+	if len(c.settings.UpdatePlanID) != 0 {
+		if !c.service.PlanUpdatable {
+			return &skipError{reason: fmt.Sprintf("plans of service %s are not updatable", c.service.CatalogName)}
+		}
+		updatedPlan = nil
+		for _, plan := range c.service.Plans {
+			if plan.CatalogID == c.settings.UpdatePlanID {
+				updatedPlan = plan
+			}
+		}
+		if updatedPlan == nil {
+			return fmt.Errorf("plan %s not found in service %s", c.settings.UpdatePlanID, c.service.CatalogName)
+		}
+	}
+	body := map[string]interface{}{
+		"service_id": c.service.CatalogID,
+		"plan_id":    updatedPlan.CatalogID,
+		"parameters": map[string]interface{}{"conformance": "update"},
+		"previous_values": map[string]string{
+			"service_id": c.service.CatalogID,
+			"plan_id":    c.plan.CatalogID,
+		},
+	}
+	response, err := c.send(ctx, http.MethodPatch, c.instancePath(), acceptsIncomplete(), body, nil)
+	if err != nil {
+		return err
+	}
+	if err := expectStatus("update", response, http.StatusOK, http.StatusAccepted); err != nil {
+		return err
+	}
+	if response.StatusCode == http.StatusAccepted {
+		if err := c.poll(ctx, "update", c.instancePath(), response, false); err != nil {
+			return err
+		}
+	}
+	// the subsequent checks work with the plan of the updated service instance
+	c.plan = updatedPlan
+	return nil
+}
+
+func (c *Checker) bindBody() map[string]interface{} {
+	return map[string]interface{}{
+		"service_id": c.service.CatalogID,
+		"plan_id":    c.plan.CatalogID,
+		"bind_resource": map[string]string{
+			"app_guid": "conformance-app",
+		},
+		"context": map[string]string{
+			"platform": "service-manager",
+		},
+	}
+}
+
+// checkBind creates the service binding used by the subsequent checks and verifies its credentials
+func (c *Checker) checkBind(ctx context.Context) error {
+	if !c.bindable() {
+		return &skipError{reason: fmt.Sprintf("plan %s is not bindable", c.plan.CatalogName)}
+	}
+	var err error
+	if c.bindingID, err = newID(); err != nil {
+		return err
+	}
+	response, err := c.send(ctx, http.MethodPut, c.bindingPath(), acceptsIncomplete(), c.bindBody(), nil)
+	if err != nil {
+		return err
+	}
+	if err := expectStatus("bind", response, http.StatusCreated, http.StatusAccepted); err != nil {
+		return err
+	}
+	if response.StatusCode == http.StatusAccepted {
+		if err := c.poll(ctx, "bind", c.bindingPath(), response, false); err != nil {
+			return err
+		}
+		if response, err = c.send(ctx, http.MethodGet, c.bindingPath(), nil, nil, nil); err != nil {
+			return err
+		}
+		if err := expectStatus("fetch service binding", response, http.StatusOK); err != nil {
+			return err
+		}
+	}
+	if credentials := gjson.GetBytes(response.Body, "credentials"); credentials.Exists() && !credentials.IsObject() {
+		return fmt.Errorf("bind: credentials should be a JSON object but broker responded with %s", response)
+	}
+	return nil
+}
+
+// checkBindIdempotency verifies that creating an existing service binding with identical attributes succeeds
+func (c *Checker) checkBindIdempotency(ctx context.Context) error {
+	response, err := c.send(ctx, http.MethodPut, c.bindingPath(), acceptsIncomplete(), c.bindBody(), nil)
+	if err != nil {
+		return err
+	}
+	return expectStatus("repeated bind", response, http.StatusOK)
+}
+
+func (c *Checker) deletionQuery() url.Values {
+	query := acceptsIncomplete()
+	query.Set("service_id", c.service.CatalogID)
+	query.Set("plan_id", c.plan.CatalogID)
+	return query
+}
+
+// checkUnbind deletes the service binding
+func (c *Checker) checkUnbind(ctx context.Context) error {
+	response, err := c.send(ctx, http.MethodDelete, c.bindingPath(), c.deletionQuery(), nil, nil)
+	if err != nil {
+		return err
+	}
+	if err := expectStatus("unbind", response, http.StatusOK, http.StatusAccepted); err != nil {
+		return err
+	}
+	if response.StatusCode == http.StatusAccepted {
+		return c.poll(ctx, "unbind", c.bindingPath(), response, true)
+	}
+	return nil
+}
+
+// checkUnbindGone verifies that deleting a service binding which does not exist responds with 410 Gone
+func (c *Checker) checkUnbindGone(ctx context.Context) error {
+	response, err := c.send(ctx, http.MethodDelete, c.bindingPath(), c.deletionQuery(), nil, nil)
+	if err != nil {
+		return err
+	}
+	return expectStatus("repeated unbind", response, http.StatusGone)
+}
+
+// checkDeprovision deletes the service instance
+func (c *Checker) checkDeprovision(ctx context.Context) error {
+	response, err := c.send(ctx, http.MethodDelete, c.instancePath(), c.deletionQuery(), nil, nil)
+	if err != nil {
+		return err
+	}
+	if err := expectStatus("deprovision", response, http.StatusOK, http.StatusAccepted); err != nil {
+		return err
+	}
+	if response.StatusCode == http.StatusAccepted {
+		return c.poll(ctx, "deprovision", c.instancePath(), response, true)
+	}
+	return nil
+}
+
+// checkDeprovisionGone verifies that deleting a service instance which does not exist responds with 410 Gone
+func (c *Checker) checkDeprovisionGone(ctx context.Context) error {
+	response, err := c.send(ctx, http.MethodDelete, c.instancePath(), c.deletionQuery(), nil, nil)
+	if err != nil {
+		return err
+	}
+	return expectStatus("repeated deprovision", response, http.StatusGone)
+}
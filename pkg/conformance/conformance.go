@@ -0,0 +1,303 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+// Package conformance contains checks which verify that an OSB broker behaves as the Service Manager expects
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Peripli/service-manager/pkg/log"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/gofrs/uuid"
+	"github.com/tidwall/gjson"
+)
+
+const apiVersionHeader = "X-Broker-API-Version"
+
+// Settings configures the conformance checks
+type Settings struct {
+	// BrokerURL is the URL of the checked broker
+	BrokerURL string
+	// Username and Password are the basic credentials of the broker
+	Username string
+	Password string
+	// OSBVersion is the OSB version sent to the broker
+	OSBVersion string
+	// PlanID is the catalog id of the plan of the service instances created by the checks - the first plan of the
+	// first bindable service is used if empty
+	PlanID string
+	// UpdatePlanID is the catalog id of the plan to which the service instance is updated - only the parameters
+	// of the service instance are updated if empty
+	UpdatePlanID string
+	// Skip contains the names of the checks which should not run
+	Skip []string
+	// RequestTimeout is the timeout of the requests towards the broker
+	RequestTimeout time.Duration
+	// PollInterval is the interval for polling the last operation of asynchronous operations
+	PollInterval time.Duration
+	// PollTimeout is the maximum time to wait for asynchronous operations
+	PollTimeout time.Duration
+}
+
+// DefaultSettings returns the default conformance check settings
+func DefaultSettings() *Settings {
+	return &Settings{
+		OSBVersion:     "2.14",
+		Skip:           []string{},
+		RequestTimeout: 30 * time.Second,
+		PollInterval:   time.Second,
+		PollTimeout:    5 * time.Minute,
+	}
+}
+
+// Validate validates the conformance check settings
+func (s *Settings) Validate() error {
+	parsedURL, err := url.Parse(s.BrokerURL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		return fmt.Errorf("invalid broker url %s: should be an absolute http or https url", s.BrokerURL)
+	}
+	if len(s.OSBVersion) == 0 {
+		return fmt.Errorf("missing OSB version")
+	}
+	if s.RequestTimeout <= 0 || s.PollInterval <= 0 || s.PollTimeout <= 0 {
+		return fmt.Errorf("request timeout, poll interval and poll timeout should be positive")
+	}
+	return nil
+}
+
+// skipError is returned by checks which do not apply to the checked broker
+type skipError struct {
+	reason string
+}
+
+func (e *skipError) Error() string {
+	return e.reason
+}
+
+type check struct {
+	name     string
+	requires []string
+	run      func(ctx context.Context) error
+}
+
+// Checker runs the conformance checks against a broker
+type Checker struct {
+	settings *Settings
+	client   *http.Client
+
+	service    *types.ServiceOffering
+	plan       *types.ServicePlan
+	instanceID string
+	bindingID  string
+}
+
+// NewChecker creates a checker for the broker with the specified settings
+func NewChecker(settings *Settings) (*Checker, error) {
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+	return &Checker{
+		settings: settings,
+		client:   &http.Client{Timeout: settings.RequestTimeout},
+	}, nil
+}
+
+// Run runs the checks in order. Checks which are configured to be skipped, checks which do not apply to the
+// broker and checks which require checks that did not pass are reported as skipped.
+func (c *Checker) Run(ctx context.Context) *Report {
+	report := &Report{
+		BrokerURL: c.settings.BrokerURL,
+		Results:   []*Result{},
+	}
+	statuses := make(map[string]Status)
+	for _, check := range c.checks() {
+		started := time.Now()
+		result := &Result{Name: check.name}
+		if c.skipped(check.name) {
+			result.Status = Skipped
+			result.Message = "skipped by configuration"
+		} else if required := failedRequirement(check, statuses); len(required) != 0 {
+			result.Status = Skipped
+			result.Message = fmt.Sprintf("requires check %s to pass", required)
+		} else if err := check.run(ctx); err != nil {
+			if _, ok := err.(*skipError); ok {
+				result.Status = Skipped
+			} else {
+				result.Status = Failed
+			}
+			result.Message = err.Error()
+		} else {
+			result.Status = Passed
+		}
+		result.Seconds = time.Since(started).Seconds()
+		statuses[check.name] = result.Status
+		report.add(result)
+		log.C(ctx).Infof("Conformance check %s %s %s", check.name, result.Status, result.Message)
+	}
+	return report
+}
+
+func (c *Checker) skipped(name string) bool {
+	for _, skipped := range c.settings.Skip {
+		if skipped == name {
+			return true
+		}
+	}
+	return false
+}
+
+func failedRequirement(check check, statuses map[string]Status) string {
+	for _, required := range check.requires {
+		if statuses[required] != Passed {
+			return required
+		}
+	}
+	return ""
+}
+
+// brokerResponse is a response of the broker
+type brokerResponse struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (r *brokerResponse) String() string {
+	return fmt.Sprintf("status %d and body %s", r.StatusCode, r.Body)
+}
+
+func (c *Checker) send(ctx context.Context, method, path string, query url.Values, body interface{}, decorate func(request *http.Request)) (*brokerResponse, error) {
+	var bodyReader *bytes.Reader
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		bodyReader = bytes.NewReader(bodyBytes)
+	} else {
+		bodyReader = bytes.NewReader(nil)
+	}
+
+	requestURL := strings.TrimRight(c.settings.BrokerURL, "/") + path
+	if len(query) != 0 {
+		requestURL += "?" + query.Encode()
+	}
+	request, err := http.NewRequest(method, requestURL, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(apiVersionHeader, c.settings.OSBVersion)
+	if len(c.settings.Username) != 0 {
+		request.SetBasicAuth(c.settings.Username, c.settings.Password)
+	}
+	if decorate != nil {
+		decorate(request)
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed: %s", method, path, err)
+	}
+	defer response.Body.Close()
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read response of %s %s: %s", method, path, err)
+	}
+	return &brokerResponse{
+		StatusCode: response.StatusCode,
+		Body:       responseBody,
+	}, nil
+}
+
+// expectStatus returns an error if the response status is not one of the expected statuses
+func expectStatus(operation string, response *brokerResponse, statuses ...int) error {
+	for _, status := range statuses {
+		if response.StatusCode == status {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: expected status %v but broker responded with %s", operation, statuses, response)
+}
+
+// expectJSONObject returns an error if the response body is not a JSON object
+func expectJSONObject(operation string, response *brokerResponse) error {
+	if !gjson.ValidBytes(response.Body) || !gjson.ParseBytes(response.Body).IsObject() {
+		return fmt.Errorf("%s: expected a JSON object but broker responded with %s", operation, response)
+	}
+	return nil
+}
+
+// poll polls the last operation of an asynchronous operation until it completes. Deletions whose last operation
+// responds with 410 Gone are considered successful.
+func (c *Checker) poll(ctx context.Context, operation, path string, response *brokerResponse, deletion bool) error {
+	query := url.Values{
+		"service_id": []string{c.service.CatalogID},
+		"plan_id":    []string{c.plan.CatalogID},
+	}
+	if operationData := gjson.GetBytes(response.Body, "operation").String(); len(operationData) != 0 {
+		query.Set("operation", operationData)
+	}
+
+	deadline := time.Now().Add(c.settings.PollTimeout)
+	for {
+		lastOperation, err := c.send(ctx, http.MethodGet, path+"/last_operation", query, nil, nil)
+		if err != nil {
+			return err
+		}
+		if deletion && lastOperation.StatusCode == http.StatusGone {
+			return nil
+		}
+		if err := expectStatus(operation+" last operation", lastOperation, http.StatusOK); err != nil {
+			return err
+		}
+		switch state := gjson.GetBytes(lastOperation.Body, "state").String(); state {
+		case "succeeded":
+			return nil
+		case "failed":
+			return fmt.Errorf("%s failed: %s", operation, gjson.GetBytes(lastOperation.Body, "description").String())
+		case "in progress":
+		default:
+			return fmt.Errorf("%s last operation: unexpected state %q in %s", operation, state, lastOperation)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s did not complete within %s", operation, c.settings.PollTimeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.settings.PollInterval):
+		}
+	}
+}
+
+func newID() (string, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return "", fmt.Errorf("could not generate id: %s", err)
+	}
+	return id.String(), nil
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package conformance_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/Peripli/service-manager/pkg/broker"
+	"github.com/Peripli/service-manager/pkg/conformance"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Conformance checker", func() {
+	var (
+		brokerSettings *broker.Settings
+		handler        http.Handler
+		server         *httptest.Server
+		settings       *conformance.Settings
+	)
+
+	run := func() *conformance.Report {
+		checker, err := conformance.NewChecker(settings)
+		Expect(err).ToNot(HaveOccurred())
+		return checker.Run(context.Background())
+	}
+
+	BeforeEach(func() {
+		brokerSettings = broker.DefaultSettings()
+		brokerSettings.Username = "admin"
+		brokerSettings.Password = "secret"
+		handler = nil
+	})
+
+	JustBeforeEach(func() {
+		if handler == nil {
+			b, err := broker.New(brokerSettings)
+			Expect(err).ToNot(HaveOccurred())
+			handler = b
+		}
+		server = httptest.NewServer(handler)
+
+		settings = conformance.DefaultSettings()
+		settings.BrokerURL = server.URL
+		settings.Username = "admin"
+		settings.Password = "secret"
+		settings.PollInterval = 10 * time.Millisecond
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("fails to create a checker with an invalid broker url", func() {
+		settings.BrokerURL = "broker"
+		_, err := conformance.NewChecker(settings)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("passes all checks against the reference broker", func() {
+		report := run()
+		Expect(report.Successful()).To(BeTrue(), "%+v", report.Results)
+		Expect(report.Skipped).To(Equal(0))
+		Expect(report.Passed).To(Equal(len(report.Results)))
+	})
+
+	Context("when the reference broker performs the operations asynchronously", func() {
+		BeforeEach(func() {
+			async := broker.OperationSettings{Async: true, Delay: 20 * time.Millisecond}
+			brokerSettings.Provision = async
+			brokerSettings.Update = async
+			brokerSettings.Bind = async
+			brokerSettings.Unbind = async
+			brokerSettings.Deprovision = async
+		})
+
+		It("passes all checks", func() {
+			report := run()
+			Expect(report.Successful()).To(BeTrue(), "%+v", report.Results)
+		})
+	})
+
+	It("skips the configured checks and the checks which require them", func() {
+		settings.Skip = []string{conformance.BindCheck}
+		report := run()
+		Expect(report.Successful()).To(BeTrue())
+		Expect(report.Result(conformance.BindCheck).Status).To(Equal(conformance.Skipped))
+		Expect(report.Result(conformance.UnbindCheck).Status).To(Equal(conformance.Skipped))
+		Expect(report.Result(conformance.DeprovisionCheck).Status).To(Equal(conformance.Passed))
+	})
+
+	Context("when the broker catalog is invalid", func() {
+		BeforeEach(func() {
+			handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"services":[{"id":"service-id","name":"service","plans":[]}]}`))
+			})
+		})
+
+		It("fails the catalog check and skips the checks which require it", func() {
+			report := run()
+			Expect(report.Successful()).To(BeFalse())
+			Expect(report.Result(conformance.CatalogCheck).Status).To(Equal(conformance.Failed))
+			Expect(report.Result(conformance.ProvisionCheck).Status).To(Equal(conformance.Skipped))
+			Expect(report.Result(conformance.APIVersionCheck).Status).To(Equal(conformance.Failed))
+		})
+	})
+
+	Describe("reports", func() {
+		var report *conformance.Report
+
+		JustBeforeEach(func() {
+			settings.Skip = []string{conformance.UpdateCheck}
+			report = run()
+		})
+
+		It("writes JSON reports", func() {
+			buffer := &bytes.Buffer{}
+			Expect(report.WriteJSON(buffer)).To(Succeed())
+			decoded := &conformance.Report{}
+			Expect(json.Unmarshal(buffer.Bytes(), decoded)).To(Succeed())
+			Expect(decoded.Skipped).To(Equal(1))
+			Expect(decoded.Results).To(HaveLen(len(report.Results)))
+		})
+
+		It("writes JUnit reports", func() {
+			buffer := &bytes.Buffer{}
+			Expect(report.WriteJUnit(buffer)).To(Succeed())
+			Expect(buffer.String()).To(ContainSubstring(`<testsuite name="OSB conformance ` + server.URL + `"`))
+			Expect(buffer.String()).To(ContainSubstring(`<testcase name="update" classname="conformance"`))
+			Expect(buffer.String()).To(ContainSubstring(`<skipped message="skipped by configuration">`))
+		})
+	})
+})
@@ -0,0 +1,148 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package conformance
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Status is the outcome of a conformance check
+type Status string
+
+const (
+	// Passed means the broker behaved as expected
+	Passed Status = "passed"
+	// Failed means the broker did not behave as expected
+	Failed Status = "failed"
+	// Skipped means the check was not run
+	Skipped Status = "skipped"
+)
+
+// Result is the result of a conformance check
+type Result struct {
+	Name    string  `json:"name"`
+	Status  Status  `json:"status"`
+	Message string  `json:"message,omitempty"`
+	Seconds float64 `json:"seconds"`
+}
+
+// Report contains the results of the conformance checks run against a broker
+type Report struct {
+	BrokerURL string    `json:"broker_url"`
+	Passed    int       `json:"passed"`
+	Failed    int       `json:"failed"`
+	Skipped   int       `json:"skipped"`
+	Results   []*Result `json:"results"`
+}
+
+func (r *Report) add(result *Result) {
+	switch result.Status {
+	case Passed:
+		r.Passed++
+	case Failed:
+		r.Failed++
+	case Skipped:
+		r.Skipped++
+	}
+	r.Results = append(r.Results, result)
+}
+
+// Successful returns true if none of the checks failed
+func (r *Report) Successful() bool {
+	return r.Failed == 0
+}
+
+// Result returns the result of the check with the specified name or nil if there is no such check
+func (r *Report) Result(name string) *Result {
+	for _, result := range r.Results {
+		if result.Name == name {
+			return result
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the report as JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+type junitTestSuite struct {
+	XMLName  xml.Name         `xml:"testsuite"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Cases    []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit writes the report in JUnit XML format
+func (r *Report) WriteJUnit(w io.Writer) error {
+	suite := &junitTestSuite{
+		Name:     "OSB conformance " + r.BrokerURL,
+		Tests:    len(r.Results),
+		Failures: r.Failed,
+		Skipped:  r.Skipped,
+		Cases:    make([]*junitTestCase, 0, len(r.Results)),
+	}
+	seconds := 0.0
+	for _, result := range r.Results {
+		testCase := &junitTestCase{
+			Name:      result.Name,
+			ClassName: "conformance",
+			Time:      fmt.Sprintf("%.3f", result.Seconds),
+		}
+		switch result.Status {
+		case Failed:
+			testCase.Failure = &junitMessage{Message: result.Message}
+		case Skipped:
+			testCase.Skipped = &junitMessage{Message: result.Message}
+		}
+		seconds += result.Seconds
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	suite.Time = fmt.Sprintf("%.3f", seconds)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
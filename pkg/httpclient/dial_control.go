@@ -0,0 +1,47 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package httpclient
+
+import (
+	"context"
+	"net"
+	"syscall"
+)
+
+// DialControlFunc is called after the address of a connection is resolved and before the connection is established.
+// Returning an error aborts the connection.
+type DialControlFunc func(network, address string, c syscall.RawConn) error
+
+type dialControlKey struct{}
+
+// ContextWithDialControl returns a context which makes the transports built by this package check the connections
+// of the requests with this context using the provided control function
+func ContextWithDialControl(ctx context.Context, control DialControlFunc) context.Context {
+	return context.WithValue(ctx, dialControlKey{}, control)
+}
+
+func dialContext(dialer *net.Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		control, ok := ctx.Value(dialControlKey{}).(DialControlFunc)
+		if !ok || control == nil {
+			return dialer.DialContext(ctx, network, address)
+		}
+		controlledDialer := *dialer
+		controlledDialer.Control = control
+		return controlledDialer.DialContext(ctx, network, address)
+	}
+}
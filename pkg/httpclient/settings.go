@@ -68,7 +68,7 @@ func Configure(settings *Settings) {
 	transport.ResponseHeaderTimeout = settings.ResponseHeaderTimeout
 	transport.TLSHandshakeTimeout = settings.TLSHandshakeTimeout
 	transport.IdleConnTimeout = settings.IdleConnTimeout
-	transport.DialContext = (&net.Dialer{Timeout: settings.DialTimeout}).DialContext
+	transport.DialContext = dialContext(&net.Dialer{Timeout: settings.DialTimeout})
 
 	http.DefaultClient.Transport = transport
 }
@@ -17,7 +17,9 @@
 package httpclient
 
 import (
+	"net/http"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -77,3 +79,65 @@ var _ = Describe("HTTPClient settings", func() {
 		})
 	})
 })
+
+var _ = Describe("HTTPClient client cache", func() {
+	var cache *ClientCache
+
+	BeforeEach(func() {
+		cache = NewClientCache(DefaultSettings())
+	})
+
+	Context("when no transport settings are provided", func() {
+		It("should return the default client", func() {
+			client, err := cache.Get("key", nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client).To(Equal(http.DefaultClient))
+		})
+	})
+
+	Context("when transport settings are provided", func() {
+		var overrides *TransportSettings
+
+		BeforeEach(func() {
+			overrides = &TransportSettings{
+				RequestTimeout:    time.Minute,
+				SkipSSLValidation: true,
+				ProxyURL:          "http://proxy:8080",
+			}
+		})
+
+		It("should build a client with the provided settings", func() {
+			client, err := cache.Get("key", overrides)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client).ToNot(Equal(http.DefaultClient))
+			Expect(client.Timeout).To(Equal(time.Minute))
+			Expect(client.Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify).To(BeTrue())
+		})
+
+		It("should reuse the client while the settings remain the same", func() {
+			client, err := cache.Get("key", overrides)
+			Expect(err).ToNot(HaveOccurred())
+			sameSettings := *overrides
+			cachedClient, err := cache.Get("key", &sameSettings)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cachedClient).To(BeIdenticalTo(client))
+		})
+
+		It("should rebuild the client when the settings change", func() {
+			client, err := cache.Get("key", overrides)
+			Expect(err).ToNot(HaveOccurred())
+			overrides.RequestTimeout = time.Second
+			rebuiltClient, err := cache.Get("key", overrides)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rebuiltClient).ToNot(BeIdenticalTo(client))
+			Expect(rebuiltClient.Timeout).To(Equal(time.Second))
+		})
+
+		It("should fail when the CA certificates are invalid", func() {
+			overrides.CACertificates = "invalid"
+			_, err := cache.Get("key", overrides)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("could not parse CA certificates"))
+		})
+	})
+})
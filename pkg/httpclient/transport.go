@@ -0,0 +1,152 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// TransportSettings holds settings that override the global httpclient settings for the calls towards a single destination
+type TransportSettings struct {
+	RequestTimeout    time.Duration
+	CACertificates    string
+	SkipSSLValidation bool
+	ProxyURL          string
+}
+
+// NewClient creates a new http client configured with the provided global settings and the specified overrides
+func NewClient(settings *Settings, overrides *TransportSettings) (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: settings.SkipSSLValidation}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialContext(&net.Dialer{Timeout: settings.DialTimeout, KeepAlive: 30 * time.Second}),
+		MaxIdleConns:          100,
+		IdleConnTimeout:       settings.IdleConnTimeout,
+		TLSHandshakeTimeout:   settings.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: settings.ResponseHeaderTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       tlsConfig,
+	}
+	client := &http.Client{Transport: transport}
+	if overrides == nil {
+		return client, nil
+	}
+
+	if overrides.SkipSSLValidation {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	if len(overrides.CACertificates) != 0 {
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil || rootCAs == nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if !rootCAs.AppendCertsFromPEM([]byte(overrides.CACertificates)) {
+			return nil, fmt.Errorf("could not parse CA certificates: no valid PEM encoded certificates found")
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+	if len(overrides.ProxyURL) != 0 {
+		proxyURL, err := url.Parse(overrides.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse proxy url: %s", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	client.Timeout = overrides.RequestTimeout
+
+	return client, nil
+}
+
+// ClientCache builds http clients per destination and reuses them for as long as the destination's transport settings remain the same
+type ClientCache struct {
+	settings *Settings
+
+	mutex   sync.RWMutex
+	clients map[string]*cachedClient
+}
+
+type cachedClient struct {
+	overrides TransportSettings
+	client    *http.Client
+}
+
+// NewClientCache creates a new client cache which builds clients on top of the provided global settings
+func NewClientCache(settings *Settings) *ClientCache {
+	return &ClientCache{
+		settings: settings,
+		clients:  make(map[string]*cachedClient),
+	}
+}
+
+// Get returns the client for the destination with the specified key. If no overrides are provided, http.DefaultClient is returned.
+// If the overrides differ from the ones the cached client was built with, a new client is built and cached.
+func (c *ClientCache) Get(key string, overrides *TransportSettings) (*http.Client, error) {
+	c.mutex.RLock()
+	cached, found := c.clients[key]
+	c.mutex.RUnlock()
+
+	if overrides == nil {
+		if found {
+			c.Remove(key)
+		}
+		return http.DefaultClient, nil
+	}
+	if found && reflect.DeepEqual(cached.overrides, *overrides) {
+		return cached.client, nil
+	}
+
+	client, err := NewClient(c.settings, overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if previous, found := c.clients[key]; found {
+		closeIdleConnections(previous.client)
+	}
+	c.clients[key] = &cachedClient{
+		overrides: *overrides,
+		client:    client,
+	}
+
+	return client, nil
+}
+
+// Remove removes the client for the destination with the specified key from the cache
+func (c *ClientCache) Remove(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if cached, found := c.clients[key]; found {
+		closeIdleConnections(cached.client)
+		delete(c.clients, key)
+	}
+}
+
+func closeIdleConnections(client *http.Client) {
+	if transport, ok := client.Transport.(*http.Transport); ok {
+		transport.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Peripli/service-manager/pkg/types"
+)
+
+// ParseSelector parses a label query which selects objects by their labels
+func ParseSelector(selector string) ([]Criterion, error) {
+	criteria, err := Parse(LabelQuery, selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(criteria) == 0 {
+		return nil, fmt.Errorf("selector %s contains no label queries", selector)
+	}
+	return criteria, nil
+}
+
+// MatchSelector returns whether the labels satisfy the label query of the selector
+func MatchSelector(selector string, labels types.Labels) (bool, error) {
+	criteria, err := ParseSelector(selector)
+	if err != nil {
+		return false, err
+	}
+	return MatchLabels(labels, criteria...), nil
+}
+
+// MatchLabels returns whether the labels satisfy all label criteria. As with the label queries of the storage,
+// a criterion is satisfied if the label has a value which satisfies the operator of the criterion.
+func MatchLabels(labels types.Labels, criteria ...Criterion) bool {
+	for _, criterion := range criteria {
+		if criterion.Type != LabelQuery {
+			continue
+		}
+		values, found := labels[criterion.LeftOp]
+		if !found && criterion.Operator.IsNullable() {
+			continue
+		}
+		matches := false
+		for _, value := range values {
+			if matchValue(criterion.Operator, value, criterion.RightOp) {
+				matches = true
+				break
+			}
+		}
+		if !matches {
+			return false
+		}
+	}
+	return true
+}
+
+func matchValue(operator Operator, value string, rightOp []string) bool {
+	switch operator {
+	case EqualsOperator, EqualsOrNilOperator:
+		return value == rightOp[0]
+	case NotEqualsOperator:
+		return value != rightOp[0]
+	case InOperator:
+		return contains(rightOp, value)
+	case NotInOperator:
+		return !contains(rightOp, value)
+	case GreaterThanOperator:
+		return compareValues(value, rightOp[0]) > 0
+	case GreaterThanOrEqualOperator:
+		return compareValues(value, rightOp[0]) >= 0
+	case LessThanOperator:
+		return compareValues(value, rightOp[0]) < 0
+	case LessThanOrEqualOperator:
+		return compareValues(value, rightOp[0]) <= 0
+	default:
+		return false
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// compareValues compares the values as numbers if both are numeric and as strings otherwise
+func compareValues(left, right string) int {
+	leftNumber, leftErr := strconv.ParseFloat(left, 64)
+	rightNumber, rightErr := strconv.ParseFloat(right, 64)
+	if leftErr == nil && rightErr == nil {
+		switch {
+		case leftNumber < rightNumber:
+			return -1
+		case leftNumber > rightNumber:
+			return 1
+		default:
+			return 0
+		}
+	}
+	switch {
+	case left < right:
+		return -1
+	case left > right:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query_test
+
+import (
+	. "github.com/Peripli/service-manager/pkg/query"
+	. "github.com/onsi/ginkgo/extensions/table"
+
+	"github.com/Peripli/service-manager/pkg/types"
+
+	. "github.com/onsi/gomega"
+
+	. "github.com/onsi/ginkgo"
+)
+
+var _ = Describe("Labels", func() {
+	labels := types.Labels{
+		"env":    {"prod"},
+		"region": {"eu", "us"},
+		"size":   {"10"},
+	}
+
+	DescribeTable("MatchSelector",
+		func(selector string, expected bool) {
+			matches, err := MatchSelector(selector, labels)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(matches).To(Equal(expected))
+		},
+		Entry("eq matches", "env eq 'prod'", true),
+		Entry("eq does not match", "env eq 'dev'", false),
+		Entry("missing label does not match", "team eq 'a'", false),
+		Entry("ne matches other values", "region ne 'eu'", true),
+		Entry("in matches", "region in ('eu','asia')", true),
+		Entry("in does not match", "env in ('dev','test')", false),
+		Entry("notin matches", "env notin ('dev','test')", true),
+		Entry("numeric gt matches", "size gt 9", true),
+		Entry("numeric lt does not match", "size lt 9", false),
+		Entry("all criteria match", "env eq 'prod' and region in ('eu','us')", true),
+		Entry("one criterion does not match", "env eq 'prod' and region in ('asia')", false),
+	)
+
+	Describe("ParseSelector", func() {
+		It("rejects empty selectors", func() {
+			_, err := ParseSelector("")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects invalid selectors", func() {
+			_, err := ParseSelector("env equals 'prod'")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -19,6 +19,7 @@ package query
 import (
 	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/Peripli/service-manager/pkg/types"
 
@@ -143,3 +144,50 @@ func ApplyLabelChangesToLabels(changes LabelChanges, labels types.Labels) (types
 
 	return mergedLabels, labelsToAdd, labelsToRemove
 }
+
+// LabelChangesBetween returns the label changes which transform the current labels into the desired labels
+func LabelChangesBetween(current, desired types.Labels) LabelChanges {
+	keys := make([]string, 0, len(current)+len(desired))
+	for key := range current {
+		keys = append(keys, key)
+	}
+	for key := range desired {
+		if _, found := current[key]; !found {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	changes := LabelChanges{}
+	for _, key := range keys {
+		currentValues, inCurrent := current[key]
+		desiredValues, inDesired := desired[key]
+		switch {
+		case !inDesired || len(desiredValues) == 0:
+			if inCurrent {
+				changes = append(changes, &LabelChange{Operation: RemoveLabelOperation, Key: key})
+			}
+		case !inCurrent:
+			changes = append(changes, &LabelChange{Operation: AddLabelOperation, Key: key, Values: desiredValues})
+		default:
+			if valuesToRemove := missingValues(currentValues, desiredValues); len(valuesToRemove) != 0 {
+				changes = append(changes, &LabelChange{Operation: RemoveLabelValuesOperation, Key: key, Values: valuesToRemove})
+			}
+			if valuesToAdd := missingValues(desiredValues, currentValues); len(valuesToAdd) != 0 {
+				changes = append(changes, &LabelChange{Operation: AddLabelValuesOperation, Key: key, Values: valuesToAdd})
+			}
+		}
+	}
+	return changes
+}
+
+// missingValues returns the values which are not present in the other values
+func missingValues(values, otherValues []string) []string {
+	var missing []string
+	for _, value := range values {
+		if !contains(otherValues, value) {
+			missing = append(missing, value)
+		}
+	}
+	return missing
+}
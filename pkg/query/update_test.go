@@ -286,4 +286,37 @@ var _ = Describe("Update", func() {
 			}, entries...)
 		})
 	})
+
+	Describe("LabelChangesBetween", func() {
+		current := types.Labels{
+			"unchanged": {"value"},
+			"removed":   {"value"},
+			"changed":   {"value0", "value1"},
+		}
+		desired := types.Labels{
+			"unchanged": {"value"},
+			"changed":   {"value1", "value2"},
+			"added":     {"value"},
+		}
+
+		It("returns the label changes which transform the current labels into the desired labels", func() {
+			changes := LabelChangesBetween(current, desired)
+			Expect(changes).To(Equal(LabelChanges{
+				&LabelChange{Operation: AddLabelOperation, Key: "added", Values: []string{"value"}},
+				&LabelChange{Operation: RemoveLabelValuesOperation, Key: "changed", Values: []string{"value0"}},
+				&LabelChange{Operation: AddLabelValuesOperation, Key: "changed", Values: []string{"value2"}},
+				&LabelChange{Operation: RemoveLabelOperation, Key: "removed"},
+			}))
+
+			mergedLabels, _, _ := ApplyLabelChangesToLabels(changes, current)
+			Expect(mergedLabels).To(HaveLen(len(desired)))
+			for key, values := range desired {
+				Expect(mergedLabels[key]).To(ConsistOf(values))
+			}
+		})
+
+		It("returns no label changes for equal labels", func() {
+			Expect(LabelChangesBetween(desired, desired)).To(BeEmpty())
+		})
+	})
 })
@@ -17,10 +17,13 @@
 package authenticators
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync"
 
+	"github.com/Peripli/service-manager/pkg/log"
 	"github.com/Peripli/service-manager/pkg/types"
 
 	"github.com/Peripli/service-manager/pkg/query"
@@ -34,6 +37,9 @@ import (
 // Basic for basic security
 type Basic struct {
 	Repository storage.Repository
+
+	verifiedPasswordsOnce sync.Once
+	verifiedPasswords     *verifiedPasswords
 }
 
 // Authenticate authenticates by using the provided Basic credentials
@@ -50,6 +56,16 @@ func (a *Basic) Authenticate(request *http.Request) (*web.UserContext, httpsec.D
 		return nil, httpsec.Abstain, fmt.Errorf("could not get credentials entity from storage: %s", err)
 	}
 
+	// credentials replaced by a rotation remain valid until their grace period expires
+	previous := false
+	if objectList.Len() == 0 {
+		byPreviousUsername := query.ByField(query.EqualsOperator, "previous_username", username)
+		if objectList, err = a.Repository.List(ctx, types.PlatformType, byPreviousUsername); err != nil {
+			return nil, httpsec.Abstain, fmt.Errorf("could not get credentials entity from storage: %s", err)
+		}
+		previous = true
+	}
+
 	if objectList.Len() != 1 {
 		return nil, httpsec.Deny, fmt.Errorf("provided credentials are invalid")
 	}
@@ -60,8 +76,15 @@ func (a *Basic) Authenticate(request *http.Request) (*web.UserContext, httpsec.D
 		return nil, httpsec.Abstain, fmt.Errorf("object of type %s is used in authentication and must be secured", obj.GetType())
 	}
 
-	if securedObj.GetCredentials().Basic.Password != password {
+	credentials := securedObj.GetCredentials()
+	if previous {
+		if !credentials.PreviousValid() || !a.verifyPassword(ctx, credentials.Previous, password) {
+			return nil, httpsec.Deny, fmt.Errorf("provided credentials are invalid")
+		}
+	} else if !a.verifyPassword(ctx, credentials.Basic, password) {
 		return nil, httpsec.Deny, fmt.Errorf("provided credentials are invalid")
+	} else if len(credentials.Basic.PasswordHash) == 0 {
+		a.hashPassword(ctx, obj, credentials)
 	}
 
 	bytes, err := json.Marshal(obj)
@@ -78,3 +101,43 @@ func (a *Basic) Authenticate(request *http.Request) (*web.UserContext, httpsec.D
 		AccessLevel:        web.NoAccess,
 	}, httpsec.Allow, nil
 }
+
+// verifyPassword verifies the password against the basic credentials. Verifications against password hashes are
+// cached for a short period as comparing a password with its hash is intentionally slow.
+func (a *Basic) verifyPassword(ctx context.Context, basic *types.Basic, password string) bool {
+	if len(basic.PasswordHash) == 0 {
+		return basic.VerifyPassword(password)
+	}
+
+	a.verifiedPasswordsOnce.Do(func() {
+		var err error
+		if a.verifiedPasswords, err = newVerifiedPasswords(); err != nil {
+			log.C(ctx).WithError(err).Error("Could not create verified passwords cache. Passwords will be verified on every request")
+		}
+	})
+	if a.verifiedPasswords == nil {
+		return basic.VerifyPassword(password)
+	}
+
+	if a.verifiedPasswords.isVerified(basic.Username, basic.PasswordHash, password) {
+		return true
+	}
+	if !basic.VerifyPassword(password) {
+		return false
+	}
+	a.verifiedPasswords.add(basic.Username, basic.PasswordHash, password)
+	return true
+}
+
+// hashPassword replaces the stored password of credentials created before passwords were hashed with its hash
+func (a *Basic) hashPassword(ctx context.Context, obj types.Object, credentials *types.Credentials) {
+	if err := credentials.Basic.HashPassword(); err != nil {
+		log.C(ctx).WithError(err).Errorf("Could not hash password of %s with id %s", obj.GetType(), obj.GetID())
+		return
+	}
+	if _, err := a.Repository.Update(ctx, obj, query.LabelChanges{}); err != nil {
+		log.C(ctx).WithError(err).Errorf("Could not store password hash of %s with id %s", obj.GetType(), obj.GetID())
+		return
+	}
+	log.C(ctx).Infof("Replaced password of %s with id %s with its hash", obj.GetType(), obj.GetID())
+}
@@ -17,9 +17,13 @@
 package authenticators_test
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"net/http"
+	"time"
+
+	"github.com/Peripli/service-manager/pkg/query"
 
 	"github.com/Peripli/service-manager/storage/storagefakes"
 
@@ -192,6 +196,128 @@ var _ = Describe("Basic Authenticator", func() {
 					Expect(user).To(Not(BeNil()))
 					Expect(decision).To(Equal(httpsec.Allow))
 				})
+
+				It("Should replace the stored password with its hash", func() {
+					_, _, err := authenticator.Authenticate(request)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(fakeRepository.UpdateCallCount()).To(Equal(1))
+					_, obj, _, _ := fakeRepository.UpdateArgsForCall(0)
+					basic := obj.(*types.Platform).Credentials.Basic
+					Expect(basic.Password).To(BeEmpty())
+					Expect(basic.VerifyPassword("password")).To(BeTrue())
+				})
+			})
+
+			Context("When password is hashed", func() {
+				var basic *types.Basic
+
+				BeforeEach(func() {
+					basic = &types.Basic{
+						Username: "username",
+						Password: "password",
+					}
+					Expect(basic.HashPassword()).To(Succeed())
+					fakeRepository.ListReturns(&types.Platforms{
+						Platforms: []*types.Platform{
+							{
+								Base: types.Base{
+									ID: "id1",
+								},
+								Credentials: &types.Credentials{
+									Basic: basic,
+								},
+							},
+						},
+					}, nil)
+				})
+
+				It("Should allow matching passwords", func() {
+					user, decision, err := authenticator.Authenticate(request)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(user).To(Not(BeNil()))
+					Expect(decision).To(Equal(httpsec.Allow))
+					Expect(fakeRepository.UpdateCallCount()).To(Equal(0))
+				})
+
+				It("Should deny a previously verified password after the password hash changed", func() {
+					_, decision, err := authenticator.Authenticate(request)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(decision).To(Equal(httpsec.Allow))
+
+					basic.Password = "new-password"
+					Expect(basic.HashPassword()).To(Succeed())
+					user, decision, err := authenticator.Authenticate(request)
+					Expect(err).To(HaveOccurred())
+					Expect(user).To(BeNil())
+					Expect(decision).To(Equal(httpsec.Deny))
+				})
+
+				It("Should deny passwords which do not match", func() {
+					basic.PasswordHash = "not-matching-hash"
+					user, decision, err := authenticator.Authenticate(request)
+					Expect(err).To(HaveOccurred())
+					Expect(user).To(BeNil())
+					Expect(decision).To(Equal(httpsec.Deny))
+				})
+			})
+
+			Context("When credentials were replaced by a rotation", func() {
+				var previousExpiresAt time.Time
+
+				BeforeEach(func() {
+					fakeRepository.ListStub = func(ctx context.Context, objectType types.ObjectType, criteria ...query.Criterion) (types.ObjectList, error) {
+						if criteria[0].LeftOp != "previous_username" {
+							return &types.Platforms{}, nil
+						}
+						return &types.Platforms{
+							Platforms: []*types.Platform{
+								{
+									Base: types.Base{
+										ID: "id1",
+									},
+									Credentials: &types.Credentials{
+										Basic: &types.Basic{
+											Username: "new-username",
+											Password: "new-password",
+										},
+										Previous: &types.Basic{
+											Username: "username",
+											Password: "password",
+										},
+										PreviousExpiresAt: previousExpiresAt,
+									},
+								},
+							},
+						}, nil
+					}
+				})
+
+				Context("and the grace period has not expired", func() {
+					BeforeEach(func() {
+						previousExpiresAt = time.Now().Add(time.Hour)
+					})
+
+					It("Should allow", func() {
+						user, decision, err := authenticator.Authenticate(request)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(user).To(Not(BeNil()))
+						Expect(decision).To(Equal(httpsec.Allow))
+						Expect(fakeRepository.ListCallCount()).To(Equal(2))
+					})
+				})
+
+				Context("and the grace period has expired", func() {
+					BeforeEach(func() {
+						previousExpiresAt = time.Now().Add(-time.Hour)
+					})
+
+					It("Should deny", func() {
+						user, decision, err := authenticator.Authenticate(request)
+						Expect(err).To(HaveOccurred())
+						Expect(user).To(BeNil())
+						Expect(decision).To(Equal(httpsec.Deny))
+					})
+				})
 			})
 		})
 	})
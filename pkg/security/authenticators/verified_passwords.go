@@ -0,0 +1,89 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package authenticators
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// verifiedPasswordTTL is the period for which a verified password is accepted without comparing it with the hash again
+const verifiedPasswordTTL = 5 * time.Minute
+
+type verifiedPassword struct {
+	mac          []byte
+	passwordHash string
+	expiresAt    time.Time
+}
+
+// verifiedPasswords caches the successful password verifications so that the costly hash comparison is not done on
+// every request. Only HMACs of the passwords with a random key generated on startup are kept in memory.
+type verifiedPasswords struct {
+	mutex    sync.Mutex
+	key      []byte
+	verified map[string]*verifiedPassword
+}
+
+func newVerifiedPasswords() (*verifiedPasswords, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return &verifiedPasswords{
+		key:      key,
+		verified: make(map[string]*verifiedPassword),
+	}, nil
+}
+
+// isVerified returns true if the password was verified against the same password hash of the user recently
+func (v *verifiedPasswords) isVerified(username, passwordHash, password string) bool {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	entry, found := v.verified[username]
+	if !found || entry.passwordHash != passwordHash || time.Now().After(entry.expiresAt) {
+		return false
+	}
+	return hmac.Equal(entry.mac, v.mac(password))
+}
+
+// add records that the password was verified against the password hash of the user
+func (v *verifiedPasswords) add(username, passwordHash, password string) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	now := time.Now()
+	for user, entry := range v.verified {
+		if now.After(entry.expiresAt) {
+			delete(v.verified, user)
+		}
+	}
+	v.verified[username] = &verifiedPassword{
+		mac:          v.mac(password),
+		passwordHash: passwordHash,
+		expiresAt:    now.Add(verifiedPasswordTTL),
+	}
+}
+
+func (v *verifiedPasswords) mac(password string) []byte {
+	mac := hmac.New(sha256.New, v.key)
+	mac.Write([]byte(password))
+	return mac.Sum(nil)
+}
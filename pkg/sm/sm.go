@@ -20,10 +20,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"net/http"
 	"sync"
 
 	"github.com/Peripli/service-manager/operations"
+	"github.com/Peripli/service-manager/platforms"
+	"github.com/Peripli/service-manager/visibilities"
 
 	"github.com/Peripli/service-manager/pkg/env"
 
@@ -61,7 +62,11 @@ type ServiceManagerBuilder struct {
 	Storage             *storage.InterceptableTransactionalRepository
 	Notificator         storage.Notificator
 	NotificationCleaner *storage.NotificationCleaner
+	CredentialsCleaner  *storage.CredentialsCleaner
 	OperationMaintainer *operations.Maintainer
+	PlatformMaintainer  *platforms.Maintainer
+	VisibilityScheduler *visibilities.Scheduler
+	PlatformTypes       *osb.PlatformTypeRegistry
 	ctx                 context.Context
 	wg                  *sync.WaitGroup
 	cfg                 *config.Settings
@@ -75,6 +80,7 @@ type ServiceManager struct {
 	Server              *server.Server
 	Notificator         storage.Notificator
 	NotificationCleaner *storage.NotificationCleaner
+	CredentialsCleaner  *storage.CredentialsCleaner
 }
 
 // New returns service-manager Server with default setup
@@ -123,6 +129,17 @@ func New(ctx context.Context, cancel context.CancelFunc, e env.Environment, cfg
 		return nil, fmt.Errorf("could not create notificator: %v", err)
 	}
 
+	brokerURLPolicy, err := cfg.API.BrokerURLPolicy()
+	if err != nil {
+		return nil, fmt.Errorf("could not create broker url policy: %s", err)
+	}
+	brokerClients := osb.NewBrokerClients(cfg.HTTPClient, brokerURLPolicy)
+
+	platformTypes, err := osb.NewPlatformTypeRegistry(osb.DefaultPlatformTypes()...)
+	if err != nil {
+		return nil, fmt.Errorf("could not create platform type registry: %s", err)
+	}
+
 	apiOptions := &api.Options{
 		Repository:        interceptableRepository,
 		APISettings:       cfg.API,
@@ -130,6 +147,9 @@ func New(ctx context.Context, cancel context.CancelFunc, e env.Environment, cfg
 		WSSettings:        cfg.WebSocket,
 		Notificator:       pgNotificator,
 		WaitGroup:         waitGroup,
+		BrokerClients:     brokerClients,
+		BrokerURLPolicy:   brokerURLPolicy,
+		PlatformTypes:     platformTypes,
 	}
 	API, err := api.New(ctx, e, apiOptions)
 	if err != nil {
@@ -152,44 +172,89 @@ func New(ctx context.Context, cancel context.CancelFunc, e env.Environment, cfg
 		Settings: *cfg.Storage,
 	}
 
+	credentialsCleaner := &storage.CredentialsCleaner{
+		Storage:       interceptableRepository,
+		CleanInterval: cfg.API.PlatformCredentialsCleanupInterval,
+	}
+
 	operationMaintainer := operations.NewMaintainer(ctx, interceptableRepository, cfg.Operations)
+	platformMaintainer := platforms.NewMaintainer(ctx, interceptableRepository, cfg.Platforms)
+	// the visibility scheduler creates the visibility notifications itself, so it does not use the interceptable repository
+	visibilityScheduler := visibilities.NewScheduler(ctx, transactionalRepository, cfg.Visibilities, cfg.API.BrokerStagingLabel)
 
 	smb := &ServiceManagerBuilder{
 		API:                 API,
 		Storage:             interceptableRepository,
 		Notificator:         pgNotificator,
 		NotificationCleaner: notificationCleaner,
+		CredentialsCleaner:  credentialsCleaner,
 		OperationMaintainer: operationMaintainer,
+		PlatformMaintainer:  platformMaintainer,
+		VisibilityScheduler: visibilityScheduler,
+		PlatformTypes:       platformTypes,
 		ctx:                 ctx,
 		wg:                  waitGroup,
 		cfg:                 cfg,
 		securityBuilder:     securityBuilder,
 	}
 
-	smb.RegisterPlugins(osb.NewCatalogFilterByVisibilityPlugin(interceptableRepository))
+	brokerStaging := &osb.BrokerStaging{
+		Repository: interceptableRepository,
+		Label:      cfg.API.BrokerStagingLabel,
+	}
+	smb.RegisterPlugins(osb.NewOSBVersionTranslationPlugin(interceptableRepository))
+	smb.RegisterPlugins(osb.NewCatalogFilterByVisibilityPlugin(interceptableRepository, brokerStaging))
+	smb.RegisterPlugins(osb.NewOSBValidationPlugin(interceptableRepository, osb.OSBValidationMode(cfg.API.OSBValidationMode), platformTypes))
 	smb.RegisterPluginsBefore(osb.CheckInstanceOwnerPluginName, osb.NewStoreServiceInstancesPlugin(interceptableRepository))
-	smb.RegisterPluginsBefore(osb.StoreServiceInstancePluginName, osb.NewCheckVisibilityPlugin(interceptableRepository))
+	smb.RegisterPluginsBefore(osb.StoreServiceInstancePluginName, osb.NewCheckVisibilityPlugin(interceptableRepository, brokerStaging))
+	smb.RegisterPluginsBefore(osb.StoreServiceInstancePluginName, osb.NewAsyncToSyncPlugin(API, cfg.API.AsyncToSyncPollInterval, cfg.API.AsyncToSyncTimeout))
+	smb.RegisterPluginsBefore(osb.StoreServiceInstancePluginName, osb.NewDefaultParametersPlugin(interceptableRepository))
 	smb.RegisterPlugins(osb.NewCheckPlatformIDPlugin(interceptableRepository))
+	smb.RegisterPlugins(osb.NewOriginatingIdentityPlugin(osb.OriginatingIdentityPolicy(cfg.API.OriginatingIdentityPolicy), cfg.API.OriginatingIdentityTenantClaim))
+	if cfg.API.OSBContextEnrichment {
+		smb.RegisterPlugins(osb.NewContextEnrichmentPlugin(interceptableRepository, cfg.API.OSBContextTenantLabel, cfg.API.OSBContextLabels))
+	}
 
 	// Register default interceptors that represent the core SM business logic
 	smb.
 		WithCreateInterceptorProvider(types.ServiceBrokerType, &interceptors.BrokerCreateCatalogInterceptorProvider{
-			CatalogFetcher: osb.CatalogFetcher(http.DefaultClient.Do, cfg.API.OSBVersion),
+			CatalogFetcher: osb.CatalogFetcher(brokerClients.DoRequestFunc, cfg.API.OSBVersion),
 		}).Register().
 		WithUpdateInterceptorProvider(types.ServiceBrokerType, &interceptors.BrokerUpdateCatalogInterceptorProvider{
-			CatalogFetcher: osb.CatalogFetcher(http.DefaultClient.Do, cfg.API.OSBVersion),
+			CatalogFetcher: osb.CatalogFetcher(brokerClients.DoRequestFunc, cfg.API.OSBVersion),
 			CatalogLoader:  catalog.Load,
 		}).Register().
 		WithDeleteInterceptorProvider(types.ServiceBrokerType, &interceptors.BrokerDeleteCatalogInterceptorProvider{
 			CatalogLoader: catalog.Load,
 		}).Register().
 		WithCreateAroundTxInterceptorProvider(types.PlatformType, &interceptors.GenerateCredentialsInterceptorProvider{}).Register().
-		WithCreateOnTxInterceptorProvider(types.VisibilityType, &interceptors.VisibilityCreateNotificationsInterceptorProvider{}).Register().
-		WithUpdateOnTxInterceptorProvider(types.VisibilityType, &interceptors.VisibilityUpdateNotificationsInterceptorProvider{}).Register().
-		WithDeleteOnTxInterceptorProvider(types.VisibilityType, &interceptors.VisibilityDeleteNotificationsInterceptorProvider{}).Register().
+		WithCreateOnTxInterceptorProvider(types.VisibilityType, &interceptors.VisibilityCreateActivationInterceptorProvider{}).Register().
+		WithUpdateOnTxInterceptorProvider(types.VisibilityType, &interceptors.VisibilityUpdateActivationInterceptorProvider{}).Register().
+		WithCreateOnTxInterceptorProvider(types.VisibilityType, &interceptors.VisibilityCreateExcludedPlansInterceptorProvider{}).Register().
+		WithUpdateOnTxInterceptorProvider(types.VisibilityType, &interceptors.VisibilityUpdateExcludedPlansInterceptorProvider{}).Register().
+		WithCreateOnTxInterceptorProvider(types.VisibilityType, &interceptors.VisibilityCreateNotificationsInterceptorProvider{
+			StagingLabel: cfg.API.BrokerStagingLabel,
+		}).Register().
+		WithUpdateOnTxInterceptorProvider(types.VisibilityType, &interceptors.VisibilityUpdateNotificationsInterceptorProvider{
+			StagingLabel: cfg.API.BrokerStagingLabel,
+		}).Register().
+		WithDeleteOnTxInterceptorProvider(types.VisibilityType, &interceptors.VisibilityDeleteNotificationsInterceptorProvider{
+			StagingLabel: cfg.API.BrokerStagingLabel,
+		}).Register().
+		WithUpdateOnTxInterceptorProvider(types.PlatformType, &interceptors.PlatformVisibilitiesNotificationsInterceptorProvider{
+			StagingLabel: cfg.API.BrokerStagingLabel,
+		}).Register().
+		WithCreateOnTxInterceptorProvider(types.ServicePlanType, &interceptors.PlanVisibilitiesNotificationsInterceptorProvider{
+			StagingLabel: cfg.API.BrokerStagingLabel,
+		}).Register().
+		WithUpdateOnTxInterceptorProvider(types.ServiceBrokerType, &interceptors.BrokerPromotionNotificationsInterceptorProvider{
+			StagingLabel: cfg.API.BrokerStagingLabel,
+		}).Register().
 		WithCreateOnTxInterceptorProvider(types.ServiceBrokerType, &interceptors.BrokerNotificationsCreateInterceptorProvider{}).Before(interceptors.BrokerCreateCatalogInterceptorName).Register().
 		WithUpdateOnTxInterceptorProvider(types.ServiceBrokerType, &interceptors.BrokerNotificationsUpdateInterceptorProvider{}).Before(interceptors.BrokerUpdateCatalogInterceptorName).Register().
-		WithDeleteOnTxInterceptorProvider(types.ServiceBrokerType, &interceptors.BrokerNotificationsDeleteInterceptorProvider{}).After(interceptors.BrokerDeleteCatalogInterceptorName).Register()
+		WithDeleteOnTxInterceptorProvider(types.ServiceBrokerType, &interceptors.BrokerNotificationsDeleteInterceptorProvider{}).After(interceptors.BrokerDeleteCatalogInterceptorName).Register().
+		WithUpdateOnTxInterceptorProvider(types.ServiceBrokerType, &osb.BrokerClientsUpdateInterceptorProvider{Clients: brokerClients}).Register().
+		WithDeleteOnTxInterceptorProvider(types.ServiceBrokerType, &osb.BrokerClientsDeleteInterceptorProvider{Clients: brokerClients}).Register()
 
 	return smb, nil
 }
@@ -211,12 +276,19 @@ func (smb *ServiceManagerBuilder) Build() *ServiceManager {
 	// start the operation maintainer
 	smb.OperationMaintainer.Run()
 
+	// start the inactive platforms maintainer
+	smb.PlatformMaintainer.Run()
+
+	// start the scheduler which activates and expires the visibilities
+	smb.VisibilityScheduler.Run()
+
 	return &ServiceManager{
 		ctx:                 smb.ctx,
 		wg:                  smb.wg,
 		Server:              srv,
 		Notificator:         smb.Notificator,
 		NotificationCleaner: smb.NotificationCleaner,
+		CredentialsCleaner:  smb.CredentialsCleaner,
 	}
 }
 
@@ -253,6 +325,9 @@ func (sm *ServiceManager) Run() {
 	if err := sm.NotificationCleaner.Start(sm.ctx, sm.wg); err != nil {
 		log.C(sm.ctx).WithError(err).Panicf("could not start Service Manager notification cleaner")
 	}
+	if err := sm.CredentialsCleaner.Start(sm.ctx, sm.wg); err != nil {
+		log.C(sm.ctx).WithError(err).Panicf("could not start Service Manager credentials cleaner")
+	}
 
 	sm.Server.Run(sm.ctx, sm.wg)
 
@@ -444,6 +519,16 @@ func (smb *ServiceManagerBuilder) EnableMultitenancy(labelKey string, extractTen
 	return smb
 }
 
+// WithFulfillmentHandler replaces the handler which fulfills the OSB requests for catalog-only brokers
+func (smb *ServiceManagerBuilder) WithFulfillmentHandler(handler osb.FulfillmentHandler) *ServiceManagerBuilder {
+	for _, controller := range smb.Controllers {
+		if osbController, ok := controller.(*osb.Controller); ok {
+			osbController.FulfillmentHandler = handler
+		}
+	}
+	return smb
+}
+
 // Security provides mechanism to apply authentication and authorization with a builder pattern
 func (smb *ServiceManagerBuilder) Security() *SecurityBuilder {
 	return smb.securityBuilder.Reset()
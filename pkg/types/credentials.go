@@ -18,20 +18,76 @@ package types
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 // Basic basic credentials
 type Basic struct {
 	Username string `json:"username,omitempty"`
 	Password string `json:"password,omitempty"`
+
+	// PasswordHash is the salted hash of the password. Credentials with a password hash are stored without the
+	// password, so that the password is known only to the holder of the credentials.
+	PasswordHash string `json:"-"`
+}
+
+// HashPassword replaces the password with its salted hash
+func (b *Basic) HashPassword() error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(b.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	b.PasswordHash = string(hash)
+	b.Password = ""
+	return nil
+}
+
+// VerifyPassword returns true if the provided password matches the password hash or, for credentials stored
+// before passwords were hashed, the password
+func (b *Basic) VerifyPassword(password string) bool {
+	if len(b.PasswordHash) != 0 {
+		return bcrypt.CompareHashAndPassword([]byte(b.PasswordHash), []byte(password)) == nil
+	}
+	return len(b.Password) != 0 && subtle.ConstantTimeCompare([]byte(b.Password), []byte(password)) == 1
 }
 
 // Credentials credentials
 type Credentials struct {
 	Basic *Basic `json:"basic,omitempty"`
+
+	// Previous are the basic credentials replaced by the last credentials rotation which remain valid until
+	// PreviousExpiresAt
+	Previous          *Basic    `json:"-"`
+	PreviousExpiresAt time.Time `json:"-"`
+}
+
+// PreviousValid returns true if the previous basic credentials have not expired yet
+func (c *Credentials) PreviousValid() bool {
+	return c.Previous != nil && time.Now().Before(c.PreviousExpiresAt)
+}
+
+// Rotate replaces the basic credentials with newly generated ones and keeps the current basic credentials valid
+// for the specified grace period
+func (c *Credentials) Rotate(gracePeriod time.Duration) (*Credentials, error) {
+	rotated, err := GenerateCredentials()
+	if err != nil {
+		return nil, err
+	}
+	if gracePeriod > 0 && c.Basic != nil {
+		rotated.Previous = &Basic{
+			Username:     c.Basic.Username,
+			Password:     c.Basic.Password,
+			PasswordHash: c.Basic.PasswordHash,
+		}
+		rotated.PreviousExpiresAt = time.Now().Add(gracePeriod)
+	}
+	return rotated, nil
 }
 
 func (c *Credentials) MarshalJSON() ([]byte, error) {
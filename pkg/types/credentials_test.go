@@ -0,0 +1,59 @@
+package types
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Credentials", func() {
+	Describe("Basic", func() {
+		var basic *Basic
+
+		BeforeEach(func() {
+			basic = &Basic{Username: "username", Password: "password"}
+		})
+
+		It("verifies passwords which are not hashed", func() {
+			Expect(basic.VerifyPassword("password")).To(BeTrue())
+			Expect(basic.VerifyPassword("other")).To(BeFalse())
+		})
+
+		It("replaces the password with its hash", func() {
+			Expect(basic.HashPassword()).To(Succeed())
+			Expect(basic.Password).To(BeEmpty())
+			Expect(basic.PasswordHash).ToNot(BeEmpty())
+			Expect(basic.VerifyPassword("password")).To(BeTrue())
+			Expect(basic.VerifyPassword("other")).To(BeFalse())
+		})
+
+		It("rejects empty passwords", func() {
+			basic.Password = ""
+			Expect(basic.VerifyPassword("")).To(BeFalse())
+		})
+	})
+
+	Describe("Rotate", func() {
+		var credentials *Credentials
+
+		BeforeEach(func() {
+			credentials = &Credentials{Basic: &Basic{Username: "username", PasswordHash: "hash"}}
+		})
+
+		It("keeps the replaced credentials for the grace period", func() {
+			rotated, err := credentials.Rotate(time.Hour)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rotated.Basic.Username).ToNot(Equal("username"))
+			Expect(rotated.Previous).To(Equal(credentials.Basic))
+			Expect(rotated.PreviousValid()).To(BeTrue())
+		})
+
+		It("drops the replaced credentials without grace period", func() {
+			rotated, err := credentials.Rotate(0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rotated.Previous).To(BeNil())
+			Expect(rotated.PreviousValid()).To(BeFalse())
+		})
+	})
+})
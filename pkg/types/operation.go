@@ -38,6 +38,10 @@ const (
 	DELETE OperationCategory = "delete"
 )
 
+// FulfillmentResourceType is the resource type of the operations which track the fulfillment of the OSB requests
+// for the service instances and bindings of catalog-only brokers
+const FulfillmentResourceType = "fulfillment"
+
 // OperationState is the state of an operation
 type OperationState string
 
@@ -64,6 +68,10 @@ type Operation struct {
 	Errors        json.RawMessage   `json:"errors"`
 	CorrelationID string            `json:"correlation_id"`
 	ExternalID    string            `json:"-"`
+
+	BrokerEndpoint string `json:"broker_endpoint,omitempty"`
+
+	Fulfillment json.RawMessage `json:"fulfillment,omitempty"`
 }
 
 func (e *Operation) Equals(obj Object) bool {
@@ -77,9 +85,11 @@ func (e *Operation) Equals(obj Object) bool {
 		e.ResourceType != operation.ResourceType ||
 		e.CorrelationID != operation.CorrelationID ||
 		e.ExternalID != operation.ExternalID ||
+		e.BrokerEndpoint != operation.BrokerEndpoint ||
 		e.State != operation.State ||
 		e.Type != operation.Type ||
-		!reflect.DeepEqual(e.Errors, operation.Errors) {
+		!reflect.DeepEqual(e.Errors, operation.Errors) ||
+		!reflect.DeepEqual(e.Fulfillment, operation.Fulfillment) {
 		return false
 	}
 
@@ -38,6 +38,7 @@ type Platform struct {
 	Credentials *Credentials `json:"credentials,omitempty"`
 	Active      bool         `json:"-"`
 	LastActive  time.Time    `json:"-"`
+	Suspended   bool         `json:"suspended"`
 }
 
 func (e *Platform) Equals(obj Object) bool {
@@ -50,6 +51,7 @@ func (e *Platform) Equals(obj Object) bool {
 		e.Type != platform.Type ||
 		e.Name != platform.Name ||
 		e.Active != platform.Active ||
+		e.Suspended != platform.Suspended ||
 		!e.LastActive.Equal(platform.LastActive) ||
 		!reflect.DeepEqual(e.Credentials, platform.Credentials) {
 		return false
@@ -58,6 +60,23 @@ func (e *Platform) Equals(obj Object) bool {
 	return true
 }
 
+// NeverConnected returns true if the platform has never been connected to the Service Manager
+func (e *Platform) NeverConnected() bool {
+	return !e.Active && e.LastActive.IsZero()
+}
+
+// InactiveSince returns the time since which the platform is not connected to the Service Manager or zero time
+// if it is connected. Platforms which have never been connected are inactive since their creation.
+func (e *Platform) InactiveSince() time.Time {
+	if e.Active {
+		return time.Time{}
+	}
+	if e.NeverConnected() {
+		return e.CreatedAt
+	}
+	return e.LastActive
+}
+
 func (e *Platform) SetCredentials(credentials *Credentials) {
 	e.Credentials = credentials
 }
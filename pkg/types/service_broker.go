@@ -18,15 +18,26 @@
 package types
 
 import (
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"reflect"
 	"strconv"
+	"time"
 )
 
 const maxNameLength = 255
 
+const (
+	// BrokerStaging is the state of brokers whose plans are visible only to the staging platforms
+	BrokerStaging = "staging"
+
+	// BrokerActive is the state of brokers whose plans are visible according to the visibilities
+	BrokerActive = "active"
+)
+
 //go:generate smgen api ServiceBroker
 // ServiceBroker broker struct
 type ServiceBroker struct {
@@ -36,6 +47,20 @@ type ServiceBroker struct {
 	Description string       `json:"description"`
 	BrokerURL   string       `json:"broker_url"`
 	Credentials *Credentials `json:"credentials,omitempty"`
+	OSBVersion  string       `json:"osb_version,omitempty"`
+
+	FailoverURLs    []string `json:"failover_urls,omitempty"`
+	StickyInstances bool     `json:"sticky_instances,omitempty"`
+
+	ShadowURL string `json:"shadow_url,omitempty"`
+
+	ErrorMappings []*BrokerErrorMapping `json:"error_mappings,omitempty"`
+
+	State string `json:"state,omitempty"`
+
+	TransportSettings *BrokerTransportSettings `json:"transport_settings,omitempty"`
+
+	StaticCatalog json.RawMessage `json:"static_catalog,omitempty"`
 
 	Catalog  json.RawMessage    `json:"-"`
 	Services []*ServiceOffering `json:"-"`
@@ -67,14 +92,62 @@ func (e *ServiceBroker) Validate() error {
 	if len(e.Name) > maxNameLength {
 		return fmt.Errorf("broker name cannot exceed %s symbols", strconv.Itoa(maxNameLength))
 	}
-	if e.BrokerURL == "" {
+	if e.BrokerURL == "" && len(e.StaticCatalog) == 0 {
 		return errors.New("missing broker url")
 	}
+	if e.BrokerURL != "" && len(e.StaticCatalog) != 0 {
+		return errors.New("broker url and static catalog cannot be specified together")
+	}
+	if len(e.StaticCatalog) != 0 {
+		if err := json.Unmarshal(e.StaticCatalog, &map[string]json.RawMessage{}); err != nil {
+			return fmt.Errorf("invalid broker static catalog: %s", err)
+		}
+		if len(e.FailoverURLs) != 0 || e.ShadowURL != "" {
+			return errors.New("brokers with static catalog cannot have failover urls or shadow url")
+		}
+	}
 
 	if err := e.Labels.Validate(); err != nil {
 		return err
 	}
 
+	for _, failoverURL := range e.FailoverURLs {
+		parsedURL, err := url.Parse(failoverURL)
+		if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+			return fmt.Errorf("invalid broker failover url %s: should be an absolute http or https url", failoverURL)
+		}
+	}
+
+	if e.ShadowURL != "" {
+		parsedURL, err := url.Parse(e.ShadowURL)
+		if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+			return fmt.Errorf("invalid broker shadow url %s: should be an absolute http or https url", e.ShadowURL)
+		}
+		if parsedURL.User != nil {
+			// mirrored requests are sent without credentials and the shadow url is returned by the API
+			return fmt.Errorf("invalid broker shadow url: should not contain credentials")
+		}
+	}
+
+	if e.State != "" && e.State != BrokerStaging && e.State != BrokerActive {
+		return fmt.Errorf("invalid broker state %s: should be either %s or %s", e.State, BrokerStaging, BrokerActive)
+	}
+
+	if e.TransportSettings != nil {
+		if err := e.TransportSettings.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, errorMapping := range e.ErrorMappings {
+		if errorMapping == nil {
+			return errors.New("broker error mappings should not contain null values")
+		}
+		if err := errorMapping.Validate(); err != nil {
+			return err
+		}
+	}
+
 	if e.Credentials == nil {
 		return errors.New("missing credentials")
 	}
@@ -90,10 +163,114 @@ func (e *ServiceBroker) Equals(obj Object) bool {
 	if e.Name != broker.Name ||
 		e.BrokerURL != broker.BrokerURL ||
 		e.Description != broker.Description ||
+		e.OSBVersion != broker.OSBVersion ||
+		e.StickyInstances != broker.StickyInstances ||
+		e.ShadowURL != broker.ShadowURL ||
+		e.State != broker.State ||
+		!reflect.DeepEqual(e.FailoverURLs, broker.FailoverURLs) ||
+		!reflect.DeepEqual(e.ErrorMappings, broker.ErrorMappings) ||
+		!reflect.DeepEqual(e.StaticCatalog, broker.StaticCatalog) ||
 		!reflect.DeepEqual(e.Catalog, broker.Catalog) ||
+		!reflect.DeepEqual(e.TransportSettings, broker.TransportSettings) ||
 		!reflect.DeepEqual(e.Credentials, broker.Credentials) {
 		return false
 	}
 
 	return true
 }
+
+// IsStaging returns true if the broker is in staging state
+func (e *ServiceBroker) IsStaging() bool {
+	return e.State == BrokerStaging
+}
+
+// IsCatalogOnly returns true if the broker has a static catalog and no broker url. The OSB operations for the
+// service instances and bindings of such brokers are fulfilled by the Service Manager instead of proxied.
+func (e *ServiceBroker) IsCatalogOnly() bool {
+	return e.BrokerURL == "" && len(e.StaticCatalog) != 0
+}
+
+// URLs returns the broker url followed by the broker failover urls
+func (e *ServiceBroker) URLs() []string {
+	return append([]string{e.BrokerURL}, e.FailoverURLs...)
+}
+
+// BrokerErrorMapping maps the error responses of a broker with matching status code and OSB error to a normalized
+// Service Manager error code, retryability hint and user-facing message
+type BrokerErrorMapping struct {
+	StatusCodes []int  `json:"status_codes,omitempty" mapstructure:"status_codes"`
+	Error       string `json:"error,omitempty" mapstructure:"error"`
+	ErrorCode   string `json:"error_code" mapstructure:"error_code"`
+	Retryable   bool   `json:"retryable" mapstructure:"retryable"`
+	Message     string `json:"message,omitempty" mapstructure:"message"`
+}
+
+// Matches returns true if the mapping applies to an error response with the provided status code and OSB error.
+// Empty status codes and error of the mapping match any status code and error respectively.
+func (m *BrokerErrorMapping) Matches(statusCode int, brokerError string) bool {
+	if len(m.Error) != 0 && m.Error != brokerError {
+		return false
+	}
+	if len(m.StatusCodes) == 0 {
+		return true
+	}
+	for _, code := range m.StatusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate implements InputValidator and verifies that the error mapping is valid
+func (m *BrokerErrorMapping) Validate() error {
+	if len(m.ErrorCode) == 0 {
+		return errors.New("missing broker error mapping error code")
+	}
+	for _, code := range m.StatusCodes {
+		if code < 400 || code > 599 {
+			return fmt.Errorf("invalid broker error mapping status code %d: should be between 400 and 599", code)
+		}
+	}
+	return nil
+}
+
+// BrokerTransportSettings holds broker specific settings which are used for the calls towards the broker instead of the global httpclient settings
+type BrokerTransportSettings struct {
+	RequestTimeout    string `json:"request_timeout,omitempty"`
+	CACertificates    string `json:"ca_certificates,omitempty"`
+	SkipSSLValidation bool   `json:"skip_ssl_validation,omitempty"`
+	ProxyURL          string `json:"proxy_url,omitempty"`
+}
+
+// Timeout returns the parsed request timeout or 0 if no request timeout is specified
+func (s *BrokerTransportSettings) Timeout() (time.Duration, error) {
+	if len(s.RequestTimeout) == 0 {
+		return 0, nil
+	}
+	return time.ParseDuration(s.RequestTimeout)
+}
+
+// Validate implements InputValidator and verifies that the transport settings are valid
+func (s *BrokerTransportSettings) Validate() error {
+	timeout, err := s.Timeout()
+	if err != nil {
+		return fmt.Errorf("invalid transport settings request timeout: %s", err)
+	}
+	if timeout < 0 {
+		return errors.New("transport settings request timeout should be >= 0")
+	}
+	if len(s.CACertificates) != 0 && !x509.NewCertPool().AppendCertsFromPEM([]byte(s.CACertificates)) {
+		return errors.New("transport settings ca certificates should contain at least one PEM encoded certificate")
+	}
+	if len(s.ProxyURL) != 0 {
+		proxyURL, err := url.Parse(s.ProxyURL)
+		if err != nil {
+			return fmt.Errorf("invalid transport settings proxy url: %s", err)
+		}
+		if proxyURL.Scheme != "http" && proxyURL.Scheme != "https" {
+			return errors.New("transport settings proxy url should use http or https scheme")
+		}
+	}
+	return nil
+}
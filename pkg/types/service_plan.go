@@ -20,8 +20,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/tidwall/gjson"
+	"github.com/xeipuuv/gojsonschema"
 )
 
 //go:generate smgen api ServicePlan
@@ -42,6 +45,8 @@ type ServicePlan struct {
 	MaximumPollingDuration int             `json:"maximum_polling_duration,omitempty"`
 	MaintenanceInfo        json.RawMessage `json:"maintenance_info,omitempty"`
 
+	DefaultParameters json.RawMessage `json:"default_parameters,omitempty"`
+
 	ServiceOfferingID string `json:"service_offering_id"`
 }
 
@@ -60,6 +65,7 @@ func (e *ServicePlan) Equals(obj Object) bool {
 		e.CatalogName != plan.CatalogName ||
 		e.Description != plan.Description ||
 		!reflect.DeepEqual(e.Schemas, plan.Schemas) ||
+		!reflect.DeepEqual(e.DefaultParameters, plan.DefaultParameters) ||
 		!reflect.DeepEqual(e.Metadata, plan.Metadata) {
 		return false
 	}
@@ -96,5 +102,51 @@ func (e *ServicePlan) Validate() error {
 		}
 	}
 
+	return e.ValidateDefaultParameters()
+}
+
+// ValidateDefaultParameters verifies that the default parameters of the plan are valid according to the plan schemas.
+// The default parameters hold the parameters for the create and update operations of the service instances.
+// Each default parameter is validated against the schema of the corresponding property in the plan schemas.
+func (e *ServicePlan) ValidateDefaultParameters() error {
+	if len(e.DefaultParameters) == 0 || string(e.DefaultParameters) == "null" {
+		return nil
+	}
+
+	defaults := make(map[string]map[string]json.RawMessage)
+	if err := json.Unmarshal(e.DefaultParameters, &defaults); err != nil {
+		return fmt.Errorf("service plan default parameters should be an object with create and update parameters objects")
+	}
+	for operation, parameters := range defaults {
+		if operation != "create" && operation != "update" {
+			return fmt.Errorf("service plan default parameters contain unsupported operation %s, supported operations are create and update", operation)
+		}
+
+		parametersSchema := gjson.GetBytes(e.Schemas, "service_instance."+operation+".parameters")
+		if !parametersSchema.Exists() {
+			continue
+		}
+		for name, value := range parameters {
+			propertySchema := parametersSchema.Get("properties." + gjsonEscape(name))
+			if !propertySchema.Exists() {
+				if parametersSchema.Get("additionalProperties").Type == gjson.False {
+					return fmt.Errorf("service plan default %s parameter %s is not allowed by the plan schema", operation, name)
+				}
+				continue
+			}
+
+			result, err := gojsonschema.Validate(gojsonschema.NewStringLoader(propertySchema.Raw), gojsonschema.NewBytesLoader(value))
+			if err != nil {
+				return fmt.Errorf("could not validate service plan default %s parameter %s: %s", operation, name, err)
+			}
+			if !result.Valid() {
+				return fmt.Errorf("service plan default %s parameter %s is invalid: %s", operation, name, result.Errors()[0].Description())
+			}
+		}
+	}
 	return nil
 }
+
+func gjsonEscape(key string) string {
+	return strings.NewReplacer(".", `\.`, "*", `\*`, "?", `\?`).Replace(key)
+}
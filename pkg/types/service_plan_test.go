@@ -0,0 +1,45 @@
+package types
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Service plan", func() {
+	Describe("ValidateDefaultParameters", func() {
+		var plan *ServicePlan
+
+		BeforeEach(func() {
+			plan = &ServicePlan{
+				Schemas: json.RawMessage(`{"service_instance":{"create":{"parameters":{
+					"type":"object",
+					"required":["name"],
+					"properties":{"region":{"type":"string","enum":["eu","us"]},"name":{"type":"string"}},
+					"additionalProperties":false
+				}}}}`),
+			}
+		})
+
+		It("accepts default parameters which match the plan schemas", func() {
+			plan.DefaultParameters = json.RawMessage(`{"create":{"region":"eu"},"update":{"backup":true}}`)
+			Expect(plan.ValidateDefaultParameters()).To(Succeed())
+		})
+
+		It("rejects default parameters with invalid values", func() {
+			plan.DefaultParameters = json.RawMessage(`{"create":{"region":"asia"}}`)
+			Expect(plan.ValidateDefaultParameters()).To(HaveOccurred())
+		})
+
+		It("rejects default parameters which are not allowed by the plan schemas", func() {
+			plan.DefaultParameters = json.RawMessage(`{"create":{"backup":true}}`)
+			Expect(plan.ValidateDefaultParameters()).To(HaveOccurred())
+		})
+
+		It("rejects default parameters for unsupported operations", func() {
+			plan.DefaultParameters = json.RawMessage(`{"delete":{}}`)
+			Expect(plan.ValidateDefaultParameters()).To(HaveOccurred())
+		})
+	})
+})
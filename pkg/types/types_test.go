@@ -254,14 +254,16 @@ func createOperation(now time.Time) Object {
 			CreatedAt: now,
 			UpdatedAt: now.Add(time.Second * 10),
 		},
-		Description:   "description",
-		Type:          OperationCategory("category"),
-		State:         OperationState("state"),
-		ResourceID:    "1",
-		ResourceType:  "type",
-		Errors:        []byte("errors"),
-		CorrelationID: "1",
-		ExternalID:    "1",
+		Description:    "description",
+		Type:           OperationCategory("category"),
+		State:          OperationState("state"),
+		ResourceID:     "1",
+		ResourceType:   "type",
+		Errors:         []byte("errors"),
+		CorrelationID:  "1",
+		ExternalID:     "1",
+		BrokerEndpoint: "http://broker",
+		Fulfillment:    []byte("fulfillment"),
 	}
 }
 
@@ -329,6 +331,7 @@ func createServicePlan(now time.Time) Object {
 		PlanUpdatable:     true,
 		Metadata:          []byte("metadata"),
 		Schemas:           []byte("schema"),
+		DefaultParameters: []byte("default_parameters"),
 		ServiceOfferingID: "1",
 	}
 }
@@ -375,6 +378,8 @@ func createVisibility(now time.Time) Object {
 		},
 		PlatformID:    "1",
 		ServicePlanID: "1",
+		Selector:      "env eq 'prod'",
+		Active:        true,
 	}
 }
 
@@ -396,12 +401,19 @@ func createPlatform(now time.Time) Object {
 		Description: "decription",
 		Credentials: &Credentials{
 			Basic: &Basic{
-				Username: "user",
-				Password: "password",
+				Username:     "user",
+				Password:     "password",
+				PasswordHash: "password_hash",
 			},
+			Previous: &Basic{
+				Username: "previous_user",
+				Password: "previous_password",
+			},
+			PreviousExpiresAt: now,
 		},
 		Active:     true,
 		LastActive: now,
+		Suspended:  true,
 	}
 }
 
@@ -427,7 +439,17 @@ func createBroker(now time.Time) Object {
 				Password: "password",
 			},
 		},
-		Catalog:  nil,
-		Services: nil,
+		OSBVersion:      "2.15",
+		StickyInstances: true,
+		ShadowURL:       "http://shadow",
+		State:           BrokerStaging,
+		TransportSettings: &BrokerTransportSettings{
+			RequestTimeout:    "60s",
+			SkipSSLValidation: true,
+			ProxyURL:          "http://proxy:8080",
+		},
+		StaticCatalog: []byte("catalog"),
+		Catalog:       nil,
+		Services:      nil,
 	}
 }
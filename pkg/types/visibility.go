@@ -19,6 +19,7 @@ package types
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/Peripli/service-manager/pkg/util"
 )
@@ -28,7 +29,20 @@ import (
 type Visibility struct {
 	Base
 	PlatformID    string `json:"platform_id"`
-	ServicePlanID string `json:"service_plan_id"`
+	ServicePlanID string `json:"service_plan_id,omitempty"`
+	// ServiceOfferingID makes the visibility apply to all current and future plans of the service offering
+	ServiceOfferingID string `json:"service_offering_id,omitempty"`
+	// ExcludedPlanIDs are the plans of the service offering to which the visibility does not apply
+	ExcludedPlanIDs []string `json:"excluded_plan_ids,omitempty"`
+	// Selector is a label query which selects the platforms to which the visibility applies
+	Selector string `json:"selector,omitempty"`
+	// ValidFrom is the time from which on the visibility applies
+	ValidFrom *time.Time `json:"valid_from,omitempty"`
+	// ValidUntil is the time until which the visibility applies
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+	// Active specifies whether the platforms have been notified about the visibility. It is managed by the
+	// Service Manager and changes when the visibility is activated or expired.
+	Active bool `json:"active"`
 }
 
 func (e *Visibility) Equals(obj Object) bool {
@@ -38,7 +52,12 @@ func (e *Visibility) Equals(obj Object) bool {
 
 	visibility := obj.(*Visibility)
 	if e.PlatformID != visibility.PlatformID ||
-		e.ServicePlanID != visibility.ServicePlanID {
+		e.ServicePlanID != visibility.ServicePlanID ||
+		e.ServiceOfferingID != visibility.ServiceOfferingID ||
+		!equalStrings(e.ExcludedPlanIDs, visibility.ExcludedPlanIDs) ||
+		e.Selector != visibility.Selector ||
+		!e.HasSameValidity(visibility) ||
+		e.Active != visibility.Active {
 		return false
 	}
 
@@ -47,8 +66,20 @@ func (e *Visibility) Equals(obj Object) bool {
 
 // Validate implements InputValidator and verifies all mandatory fields are populated
 func (e *Visibility) Validate() error {
-	if e.ServicePlanID == "" {
-		return errors.New("missing visibility service plan id")
+	if e.ServicePlanID == "" && e.ServiceOfferingID == "" {
+		return errors.New("missing visibility service plan id or service offering id")
+	}
+	if e.ServicePlanID != "" && e.ServiceOfferingID != "" {
+		return errors.New("visibility cannot specify both service plan id and service offering id")
+	}
+	if len(e.ExcludedPlanIDs) > 0 && e.ServiceOfferingID == "" {
+		return errors.New("visibility can exclude plans only when service offering id is specified")
+	}
+	if e.PlatformID != "" && e.Selector != "" {
+		return errors.New("visibility cannot specify both platform id and selector")
+	}
+	if e.ValidFrom != nil && e.ValidUntil != nil && !e.ValidUntil.After(*e.ValidFrom) {
+		return errors.New("visibility valid until must be after valid from")
 	}
 	if util.HasRFC3986ReservedSymbols(e.ID) {
 		return fmt.Errorf("%s contains invalid character(s)", e.ID)
@@ -58,3 +89,70 @@ func (e *Visibility) Validate() error {
 	}
 	return nil
 }
+
+// IsPublic returns whether the visibility applies to all platforms
+func (e *Visibility) IsPublic() bool {
+	return e.PlatformID == "" && e.Selector == ""
+}
+
+// CoversPlan returns whether the visibility is for the plan - either directly or through the service offering of the plan
+func (e *Visibility) CoversPlan(plan *ServicePlan) bool {
+	if e.ServiceOfferingID == "" {
+		return e.ServicePlanID == plan.ID
+	}
+	if e.ServiceOfferingID != plan.ServiceOfferingID {
+		return false
+	}
+	for _, excludedPlanID := range e.ExcludedPlanIDs {
+		if excludedPlanID == plan.ID {
+			return false
+		}
+	}
+	return true
+}
+
+// ForPlan returns a copy of the service offering visibility which is for the plan only. The id of the copy is
+// <visibility id>:<plan id> so that it is unique and stable across requests and notifications.
+func (e *Visibility) ForPlan(planID string) *Visibility {
+	result := *e
+	result.ID = e.ID + ":" + planID
+	result.ServicePlanID = planID
+	result.ServiceOfferingID = ""
+	result.ExcludedPlanIDs = nil
+	return &result
+}
+
+// IsActiveAt returns whether the visibility applies at the specified time
+func (e *Visibility) IsActiveAt(t time.Time) bool {
+	if e.ValidFrom != nil && t.Before(*e.ValidFrom) {
+		return false
+	}
+	if e.ValidUntil != nil && !t.Before(*e.ValidUntil) {
+		return false
+	}
+	return true
+}
+
+// HasSameValidity returns whether the visibility applies during the same period as the specified visibility
+func (e *Visibility) HasSameValidity(visibility *Visibility) bool {
+	return equalTimes(e.ValidFrom, visibility.ValidFrom) && equalTimes(e.ValidUntil, visibility.ValidUntil)
+}
+
+func equalTimes(t1, t2 *time.Time) bool {
+	if t1 == nil || t2 == nil {
+		return t1 == t2
+	}
+	return t1.Equal(*t2)
+}
+
+func equalStrings(s1, s2 []string) bool {
+	if len(s1) != len(s2) {
+		return false
+	}
+	for i := range s1 {
+		if s1[i] != s2[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -41,4 +41,22 @@ const (
 
 	// OperationsURL is the URL path fetch operations
 	OperationsURL = "/operations"
+
+	// PromoteURL is the URL path for promoting brokers in staging state
+	PromoteURL = "/promote"
+
+	// FulfillmentsURL is the URL path to manage the fulfillments of the requests for catalog-only brokers
+	FulfillmentsURL = "/fulfillments"
+
+	// RotateCredentialsURL is the URL path for rotating the credentials of platforms
+	RotateCredentialsURL = "/credentials/rotate"
+
+	// PlanVisibilitiesURL is the URL path for synchronizing the visibilities of a service plan
+	PlanVisibilitiesURL = "/visibilities"
+
+	// PlatformMarketplaceURL is the URL path for listing the service offerings and plans visible to a platform
+	PlatformMarketplaceURL = "/marketplace"
+
+	// InactivePlatformsURL is the URL path for listing the platforms which are not connected to the Service Manager
+	InactivePlatformsURL = PlatformsURL + "/inactive"
 )
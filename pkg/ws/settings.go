@@ -8,13 +8,17 @@ import (
 type Settings struct {
 	PingTimeout  time.Duration `mapstructure:"ping_timeout"`
 	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	// HeartbeatInterval is the interval of the heartbeats which are sent in place of pings to the platforms
+	// which receive notifications as server-sent events
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"`
 }
 
 // DefaultSettings return the default values for ws server
 func DefaultSettings() *Settings {
 	return &Settings{
-		PingTimeout:  time.Second * 30,
-		WriteTimeout: time.Second * 30,
+		PingTimeout:       time.Second * 30,
+		WriteTimeout:      time.Second * 30,
+		HeartbeatInterval: time.Second * 15,
 	}
 }
 
@@ -28,5 +32,9 @@ func (s *Settings) Validate() error {
 		return fmt.Errorf("validate ws settings: WriteTimeout should be > 0")
 	}
 
+	if s.HeartbeatInterval <= 0 {
+		return fmt.Errorf("validate ws settings: HeartbeatInterval should be > 0")
+	}
+
 	return nil
 }
@@ -0,0 +1,59 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package platforms
+
+import (
+	"fmt"
+	"time"
+)
+
+// Settings type to be loaded from the environment
+type Settings struct {
+	MaintenanceInterval     time.Duration `mapstructure:"maintenance_interval" description:"interval for checking the inactivity of the platforms"`
+	WarningThreshold        time.Duration `mapstructure:"warning_threshold" description:"inactivity after which warnings are logged for platforms"`
+	SuspensionThreshold     time.Duration `mapstructure:"suspension_threshold" description:"inactivity after which platforms are marked as suspended - platforms are not suspended if 0"`
+	DeleteNeverConnected    bool          `mapstructure:"delete_never_connected" description:"specifies if platforms which have never connected and own no service instances are deleted"`
+	NeverConnectedThreshold time.Duration `mapstructure:"never_connected_threshold" description:"time after their creation after which platforms which have never connected are deleted"`
+}
+
+// DefaultSettings returns default values for platforms settings
+func DefaultSettings() *Settings {
+	return &Settings{
+		MaintenanceInterval:     time.Hour,
+		WarningThreshold:        24 * time.Hour,
+		SuspensionThreshold:     0,
+		DeleteNeverConnected:    false,
+		NeverConnectedThreshold: 7 * 24 * time.Hour,
+	}
+}
+
+// Validate validates the platforms settings
+func (s *Settings) Validate() error {
+	if s.MaintenanceInterval <= 0 {
+		return fmt.Errorf("validate Settings: MaintenanceInterval must be larger than 0")
+	}
+	if s.WarningThreshold <= 0 {
+		return fmt.Errorf("validate Settings: WarningThreshold must be larger than 0")
+	}
+	if s.SuspensionThreshold < 0 {
+		return fmt.Errorf("validate Settings: SuspensionThreshold must not be negative")
+	}
+	if s.NeverConnectedThreshold <= 0 {
+		return fmt.Errorf("validate Settings: NeverConnectedThreshold must be larger than 0")
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package platforms
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Peripli/service-manager/pkg/log"
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/storage"
+)
+
+// Maintainer applies the inactivity policy to the platforms - it warns about stale platforms, suspends platforms
+// which are inactive for too long and deletes platforms which have never connected and own no service instances
+type Maintainer struct {
+	smCtx      context.Context
+	repository storage.TransactionalRepository
+	settings   *Settings
+}
+
+// NewMaintainer constructs a Maintainer
+func NewMaintainer(smCtx context.Context, repository storage.TransactionalRepository, settings *Settings) *Maintainer {
+	return &Maintainer{
+		smCtx:      smCtx,
+		repository: repository,
+		settings:   settings,
+	}
+}
+
+// Run starts the recurring job which applies the inactivity policy to the platforms
+func (m *Maintainer) Run() {
+	go m.processInactivePlatforms()
+}
+
+// processInactivePlatforms periodically applies the inactivity policy to the platforms
+func (m *Maintainer) processInactivePlatforms() {
+	ticker := time.NewTicker(m.settings.MaintenanceInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.maintainInactivePlatforms()
+		case <-m.smCtx.Done():
+			ticker.Stop()
+			log.C(m.smCtx).Info("Server is shutting down. Stopping inactive platforms maintainer...")
+			return
+		}
+	}
+}
+
+func (m *Maintainer) maintainInactivePlatforms() {
+	inactivePlatforms, err := InactivePlatforms(m.smCtx, m.repository)
+	if err != nil {
+		log.C(m.smCtx).WithError(err).Error("Failed to fetch inactive platforms")
+		return
+	}
+
+	now := time.Now()
+	stalePlatforms := make([]string, 0)
+	for _, platform := range inactivePlatforms {
+		inactivity := now.Sub(platform.InactiveSince())
+		switch {
+		case m.settings.DeleteNeverConnected && platform.NeverConnected() && inactivity >= m.settings.NeverConnectedThreshold:
+			m.deleteNeverConnected(platform)
+		case m.settings.SuspensionThreshold > 0 && inactivity >= m.settings.SuspensionThreshold:
+			if !platform.Suspended {
+				m.suspend(platform, inactivity)
+			}
+		case inactivity >= m.settings.WarningThreshold:
+			stalePlatforms = append(stalePlatforms, platform.Name)
+		}
+	}
+
+	if len(stalePlatforms) != 0 {
+		log.C(m.smCtx).Warnf("Platforms %s are inactive for more than %s", strings.Join(stalePlatforms, ", "), m.settings.WarningThreshold)
+	}
+}
+
+// suspend re-reads the platform in a transaction and suspends it unless it has connected or has been suspended
+// in the meantime
+func (m *Maintainer) suspend(platform *types.Platform, inactivity time.Duration) {
+	suspended := false
+	if err := m.repository.InTransaction(m.smCtx, func(ctx context.Context, storage storage.Repository) error {
+		obj, err := storage.Get(ctx, types.PlatformType, query.ByField(query.EqualsOperator, "id", platform.ID))
+		if err != nil {
+			return err
+		}
+
+		currentPlatform := obj.(*types.Platform)
+		if currentPlatform.Active || currentPlatform.Suspended {
+			return nil
+		}
+
+		currentPlatform.Suspended = true
+		if _, err := storage.Update(ctx, currentPlatform, nil); err != nil {
+			return err
+		}
+		suspended = true
+		return nil
+	}); err != nil {
+		log.C(m.smCtx).WithError(err).Errorf("Failed to suspend platform %s with id %s", platform.Name, platform.ID)
+		return
+	}
+	if suspended {
+		log.C(m.smCtx).Warnf("Suspended platform %s with id %s which is inactive for %s", platform.Name, platform.ID, inactivity.Round(time.Second))
+	}
+}
+
+func (m *Maintainer) deleteNeverConnected(platform *types.Platform) {
+	byPlatformID := query.ByField(query.EqualsOperator, "platform_id", platform.ID)
+	instancesCount, err := m.repository.Count(m.smCtx, types.ServiceInstanceType, byPlatformID)
+	if err != nil {
+		log.C(m.smCtx).WithError(err).Errorf("Failed to count the service instances of platform %s with id %s", platform.Name, platform.ID)
+		return
+	}
+	if instancesCount != 0 {
+		log.C(m.smCtx).Debugf("Platform %s with id %s has never connected but owns %d service instances", platform.Name, platform.ID, instancesCount)
+		return
+	}
+
+	byID := query.ByField(query.EqualsOperator, "id", platform.ID)
+	if err := m.repository.Delete(m.smCtx, types.PlatformType, byID); err != nil && err != util.ErrNotFoundInStorage {
+		log.C(m.smCtx).WithError(err).Errorf("Failed to delete platform %s with id %s which has never connected", platform.Name, platform.ID)
+		return
+	}
+	log.C(m.smCtx).Warnf("Deleted platform %s with id %s which has never connected since %s", platform.Name, platform.ID, platform.CreatedAt)
+}
+
+// InactivePlatforms returns the platforms which are not connected to the Service Manager ordered by their
+// inactivity starting with the platforms which are inactive for the longest time
+func InactivePlatforms(ctx context.Context, repository storage.Repository, criteria ...query.Criterion) ([]*types.Platform, error) {
+	objectList, err := repository.List(ctx, types.PlatformType, criteria...)
+	if err != nil {
+		return nil, err
+	}
+
+	inactivePlatforms := make([]*types.Platform, 0)
+	for _, platform := range objectList.(*types.Platforms).Platforms {
+		if !platform.Active {
+			inactivePlatforms = append(inactivePlatforms, platform)
+		}
+	}
+	sort.SliceStable(inactivePlatforms, func(i, j int) bool {
+		return inactivePlatforms[i].InactiveSince().Before(inactivePlatforms[j].InactiveSince())
+	})
+	return inactivePlatforms, nil
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package platforms_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/platforms"
+	"github.com/Peripli/service-manager/storage"
+	"github.com/Peripli/service-manager/storage/storagefakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Maintainer", func() {
+	var (
+		ctx         context.Context
+		cancel      context.CancelFunc
+		fakeStorage *storagefakes.FakeStorage
+		settings    *platforms.Settings
+		instances   int
+		reconnected bool
+	)
+
+	activePlatform := func() *types.Platform {
+		return &types.Platform{
+			Base:   types.Base{ID: "active", CreatedAt: time.Now().Add(-30 * 24 * time.Hour)},
+			Name:   "active",
+			Active: true,
+		}
+	}
+	stalePlatform := func() *types.Platform {
+		return &types.Platform{
+			Base:       types.Base{ID: "stale", CreatedAt: time.Now().Add(-30 * 24 * time.Hour)},
+			Name:       "stale",
+			LastActive: time.Now().Add(-2 * 24 * time.Hour),
+		}
+	}
+	abandonedPlatform := func() *types.Platform {
+		return &types.Platform{
+			Base:       types.Base{ID: "abandoned", CreatedAt: time.Now().Add(-30 * 24 * time.Hour)},
+			Name:       "abandoned",
+			LastActive: time.Now().Add(-20 * 24 * time.Hour),
+		}
+	}
+	neverConnectedPlatform := func() *types.Platform {
+		return &types.Platform{
+			Base: types.Base{ID: "never-connected", CreatedAt: time.Now().Add(-10 * 24 * time.Hour)},
+			Name: "never-connected",
+		}
+	}
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		instances = 0
+		reconnected = false
+		fakeStorage = &storagefakes.FakeStorage{}
+		fakeStorage.InTransactionStub = func(ctx context.Context, f func(ctx context.Context, storage storage.Repository) error) error {
+			return f(ctx, fakeStorage)
+		}
+		fakeStorage.ListStub = func(ctx context.Context, objectType types.ObjectType, criterion ...query.Criterion) (types.ObjectList, error) {
+			return &types.Platforms{
+				Platforms: []*types.Platform{activePlatform(), stalePlatform(), abandonedPlatform(), neverConnectedPlatform()},
+			}, nil
+		}
+		fakeStorage.GetStub = func(ctx context.Context, objectType types.ObjectType, criteria ...query.Criterion) (types.Object, error) {
+			platform := &types.Platform{Base: types.Base{ID: criteria[0].RightOp[0]}}
+			platform.Active = reconnected
+			return platform, nil
+		}
+		fakeStorage.CountStub = func(ctx context.Context, objectType types.ObjectType, criterion ...query.Criterion) (int, error) {
+			return instances, nil
+		}
+		fakeStorage.UpdateStub = func(ctx context.Context, obj types.Object, labelChanges query.LabelChanges, criterion ...query.Criterion) (types.Object, error) {
+			return obj, nil
+		}
+		settings = platforms.DefaultSettings()
+		settings.MaintenanceInterval = 10 * time.Millisecond
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	updatedPlatforms := func() []string {
+		var ids []string
+		for i := 0; i < fakeStorage.UpdateCallCount(); i++ {
+			_, obj, _, _ := fakeStorage.UpdateArgsForCall(i)
+			Expect(obj.(*types.Platform).Suspended).To(BeTrue())
+			ids = append(ids, obj.GetID())
+		}
+		return ids
+	}
+
+	Context("when suspension is enabled", func() {
+		It("suspends the platforms which are inactive for longer than the threshold", func() {
+			settings.SuspensionThreshold = 7 * 24 * time.Hour
+			platforms.NewMaintainer(ctx, fakeStorage, settings).Run()
+
+			Eventually(fakeStorage.UpdateCallCount).Should(BeNumerically(">", 1))
+			ids := updatedPlatforms()
+			Expect(ids).To(ContainElement("abandoned"))
+			Expect(ids).To(ContainElement("never-connected"))
+			Expect(ids).ToNot(ContainElement("stale"))
+			Expect(ids).ToNot(ContainElement("active"))
+		})
+
+		It("does not update platforms which are already suspended", func() {
+			settings.SuspensionThreshold = 7 * 24 * time.Hour
+			fakeStorage.ListStub = func(ctx context.Context, objectType types.ObjectType, criterion ...query.Criterion) (types.ObjectList, error) {
+				platform := abandonedPlatform()
+				platform.Suspended = true
+				return &types.Platforms{Platforms: []*types.Platform{platform}}, nil
+			}
+			platforms.NewMaintainer(ctx, fakeStorage, settings).Run()
+
+			Eventually(fakeStorage.ListCallCount).Should(BeNumerically(">", 1))
+			Expect(fakeStorage.UpdateCallCount()).To(Equal(0))
+		})
+
+		It("does not suspend platforms which have connected in the meantime", func() {
+			settings.SuspensionThreshold = 7 * 24 * time.Hour
+			reconnected = true
+			platforms.NewMaintainer(ctx, fakeStorage, settings).Run()
+
+			Eventually(fakeStorage.GetCallCount).Should(BeNumerically(">", 1))
+			Expect(fakeStorage.UpdateCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when suspension is disabled", func() {
+		It("does not suspend platforms", func() {
+			platforms.NewMaintainer(ctx, fakeStorage, settings).Run()
+
+			Eventually(fakeStorage.ListCallCount).Should(BeNumerically(">", 1))
+			Expect(fakeStorage.UpdateCallCount()).To(Equal(0))
+			Expect(fakeStorage.DeleteCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when deletion of platforms which have never connected is enabled", func() {
+		BeforeEach(func() {
+			settings.DeleteNeverConnected = true
+		})
+
+		It("deletes the platforms which have never connected and own no service instances", func() {
+			platforms.NewMaintainer(ctx, fakeStorage, settings).Run()
+
+			Eventually(fakeStorage.DeleteCallCount).Should(BeNumerically(">", 0))
+			_, objectType, criteria := fakeStorage.DeleteArgsForCall(0)
+			Expect(objectType).To(Equal(types.PlatformType))
+			Expect(criteria[0].RightOp).To(ConsistOf("never-connected"))
+
+			_, objectType, criteria = fakeStorage.CountArgsForCall(0)
+			Expect(objectType).To(Equal(types.ServiceInstanceType))
+			Expect(criteria[0].LeftOp).To(Equal("platform_id"))
+			Expect(criteria[0].RightOp).To(ConsistOf("never-connected"))
+		})
+
+		It("does not delete platforms which own service instances", func() {
+			instances = 1
+			platforms.NewMaintainer(ctx, fakeStorage, settings).Run()
+
+			Eventually(fakeStorage.CountCallCount).Should(BeNumerically(">", 1))
+			Expect(fakeStorage.DeleteCallCount()).To(Equal(0))
+		})
+	})
+})
+
+var _ = Describe("InactivePlatforms", func() {
+	It("returns the inactive platforms starting with the longest inactive", func() {
+		fakeStorage := &storagefakes.FakeStorage{}
+		fakeStorage.ListReturns(&types.Platforms{
+			Platforms: []*types.Platform{
+				{Base: types.Base{ID: "recent"}, LastActive: time.Now().Add(-time.Hour)},
+				{Base: types.Base{ID: "active"}, Active: true},
+				{Base: types.Base{ID: "never-connected", CreatedAt: time.Now().Add(-48 * time.Hour)}},
+				{Base: types.Base{ID: "old"}, LastActive: time.Now().Add(-24 * time.Hour)},
+			},
+		}, nil)
+
+		inactivePlatforms, err := platforms.InactivePlatforms(context.Background(), fakeStorage)
+		Expect(err).ToNot(HaveOccurred())
+		ids := make([]string, 0)
+		for _, platform := range inactivePlatforms {
+			ids = append(ids, platform.ID)
+		}
+		Expect(ids).To(Equal([]string{"never-connected", "old", "recent"}))
+	})
+})
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Peripli/service-manager/pkg/util"
+
+	"github.com/Peripli/service-manager/pkg/log"
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+)
+
+// CredentialsCleaner schedules a go routine which purges the expired platform credentials replaced by rotations
+type CredentialsCleaner struct {
+	started bool
+
+	Storage       TransactionalRepository
+	CleanInterval time.Duration
+}
+
+// Start schedules the cleaner. It cannot be used concurrently.
+func (cc *CredentialsCleaner) Start(ctx context.Context, group *sync.WaitGroup) error {
+	if cc.started {
+		return errors.New("credentials cleaner already started")
+	}
+	cc.started = true
+	group.Add(1)
+	go func() {
+		defer func() {
+			cc.started = false
+			group.Done()
+		}()
+		log.C(ctx).Infof("Scheduling expired platform credentials cleaning every %s", cc.CleanInterval.String())
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(cc.CleanInterval):
+				cc.clean(ctx)
+			}
+		}
+	}()
+	return nil
+}
+
+func (cc *CredentialsCleaner) clean(ctx context.Context) {
+	now := time.Now()
+	q := query.ByField(query.LessThanOperator, "previous_credentials_expires_at", util.ToRFCNanoFormat(now))
+	platforms, err := cc.Storage.List(ctx, types.PlatformType, q)
+	if err != nil {
+		log.C(ctx).WithError(err).Error("could not list platforms with expired credentials")
+		return
+	}
+
+	for i := 0; i < platforms.Len(); i++ {
+		platformID := platforms.ItemAt(i).GetID()
+		purged, err := cc.purge(ctx, platformID, now)
+		if err != nil {
+			log.C(ctx).WithError(err).Errorf("could not purge expired credentials of platform with id %s", platformID)
+			continue
+		}
+		if purged {
+			log.C(ctx).Infof("successfully purged expired credentials of platform with id %s", platformID)
+		}
+	}
+}
+
+// purge re-reads the platform in a transaction and removes its previous credentials if they are still expired,
+// so that credentials rotated after the platforms were listed are not lost
+func (cc *CredentialsCleaner) purge(ctx context.Context, platformID string, now time.Time) (bool, error) {
+	purged := false
+	err := cc.Storage.InTransaction(ctx, func(ctx context.Context, storage Repository) error {
+		obj, err := storage.Get(ctx, types.PlatformType, query.ByField(query.EqualsOperator, "id", platformID))
+		if err == util.ErrNotFoundInStorage {
+			// the platform was deleted in the meantime
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		platform := obj.(*types.Platform)
+		credentials := platform.Credentials
+		if credentials == nil || credentials.PreviousExpiresAt.IsZero() || !credentials.PreviousExpiresAt.Before(now) {
+			return nil
+		}
+
+		credentials.Previous = nil
+		credentials.PreviousExpiresAt = time.Time{}
+		if _, err := storage.Update(ctx, platform, nil); err != nil {
+			return err
+		}
+		purged = true
+		return nil
+	})
+	return purged, err
+}
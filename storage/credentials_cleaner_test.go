@@ -0,0 +1,156 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+
+	"github.com/Peripli/service-manager/storage"
+
+	"github.com/Peripli/service-manager/storage/storagefakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Credentials cleaner", func() {
+	var (
+		ctx         context.Context
+		cancel      context.CancelFunc
+		wg          *sync.WaitGroup
+		fakeStorage *storagefakes.FakeStorage
+		cc          *storage.CredentialsCleaner
+	)
+
+	BeforeEach(func() {
+		fakeStorage = &storagefakes.FakeStorage{}
+		ctx, cancel = context.WithCancel(context.Background())
+		wg = &sync.WaitGroup{}
+		cc = &storage.CredentialsCleaner{
+			Storage:       fakeStorage,
+			CleanInterval: time.Hour,
+		}
+	})
+
+	AfterEach(func() {
+		if ctx.Err() == nil {
+			cancel()
+		}
+		wg.Wait()
+	})
+
+	Describe("Start", func() {
+		Context("When already started", func() {
+			It("Should return error", func() {
+				err := cc.Start(ctx, wg)
+				Expect(err).ToNot(HaveOccurred())
+				err = cc.Start(ctx, wg)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("credentials cleaner already started"))
+			})
+		})
+	})
+
+	Describe("clean", func() {
+		var storedPlatform *types.Platform
+
+		BeforeEach(func() {
+			cc.CleanInterval = 0
+			storedPlatform = &types.Platform{
+				Base: types.Base{ID: "platform-id"},
+				Credentials: &types.Credentials{
+					Basic:             &types.Basic{Username: "username", Password: "password"},
+					Previous:          &types.Basic{Username: "previous-username", Password: "previous-password"},
+					PreviousExpiresAt: time.Now().Add(-time.Minute),
+				},
+			}
+			fakeStorage.InTransactionStub = func(ctx context.Context, f func(ctx context.Context, storage storage.Repository) error) error {
+				return f(ctx, fakeStorage)
+			}
+			fakeStorage.GetStub = func(ctx context.Context, objectType types.ObjectType, criteria ...query.Criterion) (types.Object, error) {
+				return storedPlatform, nil
+			}
+		})
+
+		It("Should purge the expired credentials of the platforms", func() {
+			var criteria []query.Criterion
+			fakeStorage.ListStub = func(ctx context.Context, objectType types.ObjectType, criterion ...query.Criterion) (types.ObjectList, error) {
+				criteria = criterion
+				return &types.Platforms{
+					Platforms: []*types.Platform{{Base: types.Base{ID: "platform-id"}}},
+				}, nil
+			}
+			var updated *types.Platform
+			fakeStorage.UpdateStub = func(ctx context.Context, obj types.Object, labelChanges query.LabelChanges, criterion ...query.Criterion) (types.Object, error) {
+				updated = obj.(*types.Platform)
+				cancel() // stop credentials cleaner
+				return obj, nil
+			}
+
+			err := cc.Start(ctx, wg)
+			Expect(err).ToNot(HaveOccurred())
+			wg.Wait()
+			Expect(criteria).To(HaveLen(1))
+			Expect(criteria[0].LeftOp).To(Equal("previous_credentials_expires_at"))
+			Expect(updated.Credentials.Basic.Username).To(Equal("username"))
+			Expect(updated.Credentials.Previous).To(BeNil())
+			Expect(updated.Credentials.PreviousExpiresAt.IsZero()).To(BeTrue())
+		})
+
+		It("Should not purge credentials which were rotated after the platforms were listed", func() {
+			storedPlatform.Credentials.PreviousExpiresAt = time.Now().Add(time.Hour)
+			fakeStorage.ListStub = func(ctx context.Context, objectType types.ObjectType, criterion ...query.Criterion) (types.ObjectList, error) {
+				return &types.Platforms{
+					Platforms: []*types.Platform{{Base: types.Base{ID: "platform-id"}}},
+				}, nil
+			}
+			fakeStorage.GetStub = func(ctx context.Context, objectType types.ObjectType, criteria ...query.Criterion) (types.Object, error) {
+				cancel() // stop credentials cleaner
+				return storedPlatform, nil
+			}
+
+			err := cc.Start(ctx, wg)
+			Expect(err).ToNot(HaveOccurred())
+			wg.Wait()
+			Expect(fakeStorage.GetCallCount()).To(BeNumerically(">", 0))
+			Expect(fakeStorage.UpdateCallCount()).To(Equal(0))
+		})
+
+		Context("When repository returns error", func() {
+			It("Should not stop", func() {
+				calls := 0
+				fakeStorage.ListStub = func(ctx context.Context, objectType types.ObjectType, criterion ...query.Criterion) (types.ObjectList, error) {
+					calls++
+					if calls > 1 {
+						cancel()
+					}
+					return nil, errors.New("*Expected*")
+				}
+				err := cc.Start(ctx, wg)
+				Expect(err).ToNot(HaveOccurred())
+				wg.Wait()
+				Expect(calls).To(BeNumerically(">", 1))
+			})
+		})
+	})
+})
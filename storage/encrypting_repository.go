@@ -3,6 +3,8 @@ package storage
 import (
 	"context"
 	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -98,7 +100,7 @@ type TransactionalEncryptingRepository struct {
 }
 
 func (er *encryptingRepository) Create(ctx context.Context, obj types.Object) (types.Object, error) {
-	if err := er.transformCredentials(ctx, obj, er.encrypter.Encrypt); err != nil {
+	if err := er.encrypt(ctx, obj); err != nil {
 		return nil, err
 	}
 
@@ -107,7 +109,7 @@ func (er *encryptingRepository) Create(ctx context.Context, obj types.Object) (t
 		return nil, err
 	}
 
-	if err := er.transformCredentials(ctx, newObj, er.encrypter.Decrypt); err != nil {
+	if err := er.decrypt(ctx, newObj); err != nil {
 		return nil, err
 	}
 
@@ -120,7 +122,7 @@ func (er *encryptingRepository) Get(ctx context.Context, objectType types.Object
 		return nil, err
 	}
 
-	if err := er.transformCredentials(ctx, obj, er.encrypter.Decrypt); err != nil {
+	if err := er.decrypt(ctx, obj); err != nil {
 		return nil, err
 	}
 
@@ -134,7 +136,7 @@ func (er *encryptingRepository) List(ctx context.Context, objectType types.Objec
 	}
 
 	for i := 0; i < objList.Len(); i++ {
-		if err := er.transformCredentials(ctx, objList.ItemAt(i), er.encrypter.Decrypt); err != nil {
+		if err := er.decrypt(ctx, objList.ItemAt(i)); err != nil {
 			return nil, err
 		}
 	}
@@ -147,7 +149,7 @@ func (er *encryptingRepository) Count(ctx context.Context, objectType types.Obje
 }
 
 func (er *encryptingRepository) Update(ctx context.Context, obj types.Object, labelChanges query.LabelChanges, criteria ...query.Criterion) (types.Object, error) {
-	if err := er.transformCredentials(ctx, obj, er.encrypter.Encrypt); err != nil {
+	if err := er.encrypt(ctx, obj); err != nil {
 		return nil, err
 	}
 
@@ -156,7 +158,7 @@ func (er *encryptingRepository) Update(ctx context.Context, obj types.Object, la
 		return nil, err
 	}
 
-	if err := er.transformCredentials(ctx, updatedObj, er.encrypter.Decrypt); err != nil {
+	if err := er.decrypt(ctx, updatedObj); err != nil {
 		return nil, err
 	}
 
@@ -170,7 +172,7 @@ func (er *encryptingRepository) DeleteReturning(ctx context.Context, objectType
 	}
 
 	for i := 0; i < objList.Len(); i++ {
-		if err := er.transformCredentials(ctx, objList.ItemAt(i), er.encrypter.Decrypt); err != nil {
+		if err := er.decrypt(ctx, objList.ItemAt(i)); err != nil {
 			return nil, err
 		}
 	}
@@ -186,16 +188,74 @@ func (er *encryptingRepository) Delete(ctx context.Context, objectType types.Obj
 	return nil
 }
 
+// encrypt encrypts the credentials of Secured objects and the fulfillments of operations
+func (er *encryptingRepository) encrypt(ctx context.Context, obj types.Object) error {
+	if err := er.transformCredentials(ctx, obj, er.encrypter.Encrypt); err != nil {
+		return err
+	}
+	return er.encryptFulfillment(ctx, obj)
+}
+
+// decrypt decrypts the credentials of Secured objects and the fulfillments of operations
+func (er *encryptingRepository) decrypt(ctx context.Context, obj types.Object) error {
+	if err := er.transformCredentials(ctx, obj, er.encrypter.Decrypt); err != nil {
+		return err
+	}
+	return er.decryptFulfillment(ctx, obj)
+}
+
+// encryptFulfillment encrypts the fulfillment of operations, as it contains the binding credentials once the bind
+// request is fulfilled. The encrypted fulfillment is stored as a JSON string with the base64 encoded ciphertext.
+func (er *encryptingRepository) encryptFulfillment(ctx context.Context, obj types.Object) error {
+	operation, isOperation := obj.(*types.Operation)
+	if !isOperation || len(operation.Fulfillment) == 0 {
+		return nil
+	}
+	ciphertext, err := er.encrypter.Encrypt(ctx, operation.Fulfillment, er.encryptionKey)
+	if err != nil {
+		return err
+	}
+	encryptedFulfillment, err := json.Marshal(base64.StdEncoding.EncodeToString(ciphertext))
+	if err != nil {
+		return err
+	}
+	operation.Fulfillment = encryptedFulfillment
+	return nil
+}
+
+// decryptFulfillment decrypts the fulfillment of operations
+func (er *encryptingRepository) decryptFulfillment(ctx context.Context, obj types.Object) error {
+	operation, isOperation := obj.(*types.Operation)
+	if !isOperation || len(operation.Fulfillment) == 0 {
+		return nil
+	}
+	var encodedCiphertext string
+	if err := json.Unmarshal(operation.Fulfillment, &encodedCiphertext); err != nil {
+		return fmt.Errorf("could not read encrypted fulfillment of operation %s: %s", operation.ID, err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(encodedCiphertext)
+	if err != nil {
+		return fmt.Errorf("could not decode encrypted fulfillment of operation %s: %s", operation.ID, err)
+	}
+	fulfillment, err := er.encrypter.Decrypt(ctx, ciphertext, er.encryptionKey)
+	if err != nil {
+		return err
+	}
+	operation.Fulfillment = fulfillment
+	return nil
+}
+
 func (er *encryptingRepository) transformCredentials(ctx context.Context, obj types.Object, transformationFunc func(context.Context, []byte, []byte) ([]byte, error)) error {
 	securedObj, isSecured := obj.(types.Secured)
 	if isSecured {
 		credentials := securedObj.GetCredentials()
 		if credentials != nil {
-			transformedPassword, err := transformationFunc(ctx, []byte(credentials.Basic.Password), er.encryptionKey)
-			if err != nil {
+			if err := er.transformPassword(ctx, credentials.Basic, transformationFunc); err != nil {
+				return err
+			}
+			if err := er.transformPassword(ctx, credentials.Previous, transformationFunc); err != nil {
 				return err
 			}
-			credentials.Basic.Password = string(transformedPassword)
 			securedObj.SetCredentials(credentials)
 		}
 	}
@@ -203,6 +263,20 @@ func (er *encryptingRepository) transformCredentials(ctx context.Context, obj ty
 	return nil
 }
 
+// transformPassword transforms the password of the basic credentials. Credentials with hashed passwords are stored
+// without password, so there is nothing to transform.
+func (er *encryptingRepository) transformPassword(ctx context.Context, basic *types.Basic, transformationFunc func(context.Context, []byte, []byte) ([]byte, error)) error {
+	if basic == nil || (len(basic.Password) == 0 && len(basic.PasswordHash) != 0) {
+		return nil
+	}
+	transformedPassword, err := transformationFunc(ctx, []byte(basic.Password), er.encryptionKey)
+	if err != nil {
+		return err
+	}
+	basic.Password = string(transformedPassword)
+	return nil
+}
+
 // InTransaction wraps repository passed in the transaction to also encypt/decrypt credentials
 func (er *TransactionalEncryptingRepository) InTransaction(ctx context.Context, f func(ctx context.Context, storage Repository) error) error {
 	return er.repository.InTransaction(ctx, func(ctx context.Context, storage Repository) error {
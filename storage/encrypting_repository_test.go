@@ -2,6 +2,7 @@ package storage_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -157,6 +158,25 @@ var _ = Describe("Encrypting Repository", func() {
 				Expect(isPassEncrypted).To(BeFalse())
 			})
 		})
+
+		Context("when the password is hashed", func() {
+			It("does not encrypt the credentials", func() {
+				platform := &types.Platform{
+					Credentials: &types.Credentials{
+						Basic: &types.Basic{
+							Username:     "admin",
+							PasswordHash: "hash",
+						},
+					},
+				}
+				fakeRepository.CreateReturns(platform, nil)
+
+				_, err = repository.Create(ctx, platform)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(fakeEncrypter.EncryptCallCount() - encryptCallsCountBeforeOp).To(Equal(0))
+				Expect(fakeEncrypter.DecryptCallCount() - decryptCallsCountBeforeOp).To(Equal(0))
+			})
+		})
 	})
 
 	Describe("List", func() {
@@ -356,6 +376,34 @@ var _ = Describe("Encrypting Repository", func() {
 		})
 	})
 
+	Describe("Operation fulfillments", func() {
+		var fulfillment json.RawMessage
+
+		BeforeEach(func() {
+			fulfillment = json.RawMessage(`{"response":{"credentials":{"password":"secret"}}}`)
+			fakeRepository.CreateStub = func(ctx context.Context, obj types.Object) (types.Object, error) {
+				stored := *obj.(*types.Operation)
+				return &stored, nil
+			}
+		})
+
+		It("stores the fulfillment encrypted and returns it decrypted", func() {
+			returnedObj, err := repository.Create(ctx, &types.Operation{
+				Base:        types.Base{ID: "operation-id"},
+				Fulfillment: fulfillment,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			_, objectArg := fakeRepository.CreateArgsForCall(0)
+			storedFulfillment := objectArg.(*types.Operation).Fulfillment
+			Expect(string(storedFulfillment)).ToNot(ContainSubstring("secret"))
+			var encodedCiphertext string
+			Expect(json.Unmarshal(storedFulfillment, &encodedCiphertext)).To(Succeed())
+
+			Expect(returnedObj.(*types.Operation).Fulfillment).To(MatchJSON(fulfillment))
+		})
+	})
+
 	Describe("In transaction", func() {
 		Context("when resource is created/updated/deleted/listed in transaction", func() {
 			It("triggers encryption/decryption", func() {
@@ -0,0 +1,146 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package interceptors
+
+import (
+	"context"
+
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/storage"
+)
+
+// BrokerPromotionNotificationsInterceptorProvider provides an interceptor which notifies the platforms without the
+// staging label about the visibilities of the plans of promoted brokers, as they were not notified while the
+// brokers were in staging state
+type BrokerPromotionNotificationsInterceptorProvider struct {
+	StagingLabel string
+}
+
+// Name returns the name of the provider
+func (*BrokerPromotionNotificationsInterceptorProvider) Name() string {
+	return "BrokerPromotionNotificationsInterceptorProvider"
+}
+
+// Provide returns the interceptor
+func (p *BrokerPromotionNotificationsInterceptorProvider) Provide() storage.UpdateOnTxInterceptor {
+	return &brokerPromotionNotificationsInterceptor{
+		stagingLabel: p.StagingLabel,
+	}
+}
+
+type brokerPromotionNotificationsInterceptor struct {
+	stagingLabel string
+}
+
+// OnTxUpdate creates visibility notifications for the platforms without the staging label if the broker is promoted
+func (i *brokerPromotionNotificationsInterceptor) OnTxUpdate(h storage.InterceptUpdateOnTxFunc) storage.InterceptUpdateOnTxFunc {
+	return func(ctx context.Context, repository storage.Repository, oldObject, newObject types.Object, labelChanges ...*query.LabelChange) (types.Object, error) {
+		updatedObject, err := h(ctx, repository, oldObject, newObject, labelChanges...)
+		if err != nil {
+			return nil, err
+		}
+		if len(i.stagingLabel) == 0 || !oldObject.(*types.ServiceBroker).IsStaging() || updatedObject.(*types.ServiceBroker).IsStaging() {
+			return updatedObject, nil
+		}
+
+		visibilities, err := brokerVisibilities(ctx, repository, updatedObject.GetID())
+		if err != nil {
+			return nil, err
+		}
+		visibilityNotifications := NewVisibilityNotificationsInterceptor(i.stagingLabel)
+		for _, visibility := range visibilities {
+			expandedVisibilities, err := visibilityNotifications.expand(ctx, repository, visibility)
+			if err != nil {
+				return nil, err
+			}
+			for _, key := range sortedObjectKeys(expandedVisibilities) {
+				expandedVisibility := expandedVisibilities[key]
+				platformIDs, err := visibilityPlatformIDs(ctx, expandedVisibility, repository)
+				if err != nil {
+					return nil, err
+				}
+				if len(platformIDs) == 0 {
+					continue
+				}
+				// the platforms with the staging label were notified while the broker was in staging state
+				if platformIDs, err = filterPlatformIDsByLabel(ctx, repository, i.stagingLabel, platformIDs, false); err != nil {
+					return nil, err
+				}
+				if len(platformIDs) == 0 {
+					continue
+				}
+				additionalDetails, err := visibilityNotifications.AdditionalDetailsFunc(ctx, types.NewObjectArray(expandedVisibility), repository)
+				if err != nil {
+					return nil, err
+				}
+				for _, platformID := range platformIDs {
+					if err := CreateNotification(ctx, repository, types.CREATED, types.VisibilityType, platformID, &Payload{
+						New: &ObjectPayload{
+							Resource:   expandedVisibility,
+							Additional: additionalDetails[expandedVisibility.GetID()],
+						},
+					}); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		return updatedObject, nil
+	}
+}
+
+// brokerVisibilities returns the active visibilities of the plans and service offerings of the broker
+func brokerVisibilities(ctx context.Context, repository storage.Repository, brokerID string) ([]*types.Visibility, error) {
+	objectList, err := repository.List(ctx, types.ServiceOfferingType, query.ByField(query.EqualsOperator, "broker_id", brokerID))
+	if err != nil {
+		return nil, err
+	}
+	if objectList.Len() == 0 {
+		return nil, nil
+	}
+	offeringIDs := make([]string, 0, objectList.Len())
+	for i := 0; i < objectList.Len(); i++ {
+		offeringIDs = append(offeringIDs, objectList.ItemAt(i).GetID())
+	}
+
+	byActive := query.ByField(query.EqualsOperator, "active", "true")
+	offeringVisibilities, err := repository.List(ctx, types.VisibilityType,
+		query.ByField(query.InOperator, "service_offering_id", offeringIDs...), byActive)
+	if err != nil {
+		return nil, err
+	}
+	result := offeringVisibilities.(*types.Visibilities).Visibilities
+
+	objectList, err = repository.List(ctx, types.ServicePlanType, query.ByField(query.InOperator, "service_offering_id", offeringIDs...))
+	if err != nil {
+		return nil, err
+	}
+	if objectList.Len() == 0 {
+		return result, nil
+	}
+	planIDs := make([]string, 0, objectList.Len())
+	for i := 0; i < objectList.Len(); i++ {
+		planIDs = append(planIDs, objectList.ItemAt(i).GetID())
+	}
+	planVisibilities, err := repository.List(ctx, types.VisibilityType,
+		query.ByField(query.InOperator, "service_plan_id", planIDs...), byActive)
+	if err != nil {
+		return nil, err
+	}
+	return append(result, planVisibilities.(*types.Visibilities).Visibilities...), nil
+}
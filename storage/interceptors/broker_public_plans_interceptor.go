@@ -118,7 +118,7 @@ func resync(ctx context.Context, broker *types.ServiceBroker, txStorage storage.
 				visibility := visibilitiesForPlan.ItemAt(i).(*types.Visibility)
 				byVisibilityID := query.ByField(query.EqualsOperator, "id", visibility.ID)
 				if isPublic {
-					if visibility.PlatformID == "" {
+					if visibility.IsPublic() {
 						hasPublicVisibility = true
 						continue
 					} else {
@@ -127,7 +127,7 @@ func resync(ctx context.Context, broker *types.ServiceBroker, txStorage storage.
 						}
 					}
 				} else {
-					if visibility.PlatformID == "" {
+					if visibility.IsPublic() {
 						if err := txStorage.Delete(ctx, types.VisibilityType, byVisibilityID); err != nil {
 							return err
 						}
@@ -32,6 +32,19 @@ import (
 
 const BrokerUpdateCatalogInterceptorName = "BrokerUpdateCatalogInterceptor"
 
+type skipCatalogRefreshKey struct{}
+
+// ContextWithoutCatalogRefresh returns a context in which broker updates change only the broker itself and
+// do not refetch and store the broker catalog
+func ContextWithoutCatalogRefresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipCatalogRefreshKey{}, true)
+}
+
+func catalogRefreshSkipped(ctx context.Context) bool {
+	skip, ok := ctx.Value(skipCatalogRefreshKey{}).(bool)
+	return ok && skip
+}
+
 // BrokerUpdateCatalogInterceptorProvider provides a broker interceptor for update operations
 type BrokerUpdateCatalogInterceptorProvider struct {
 	CatalogFetcher func(ctx context.Context, broker *types.ServiceBroker) ([]byte, error)
@@ -57,6 +70,9 @@ type brokerUpdateCatalogInterceptor struct {
 // AroundTxUpdate fetches the broker catalog before the transaction, so it can be stored later on in the transaction
 func (c *brokerUpdateCatalogInterceptor) AroundTxUpdate(h storage.InterceptUpdateAroundTxFunc) storage.InterceptUpdateAroundTxFunc {
 	return func(ctx context.Context, obj types.Object, labelChanges ...*query.LabelChange) (types.Object, error) {
+		if catalogRefreshSkipped(ctx) {
+			return h(ctx, obj, labelChanges...)
+		}
 		broker := obj.(*types.ServiceBroker)
 		if err := brokerCatalogAroundTx(ctx, broker, c.CatalogFetcher); err != nil {
 			return nil, err
@@ -69,6 +85,9 @@ func (c *brokerUpdateCatalogInterceptor) AroundTxUpdate(h storage.InterceptUpdat
 // OnTxUpdate stores the previously fetched broker catalog, in the transaction in which the broker is being updated
 func (c *brokerUpdateCatalogInterceptor) OnTxUpdate(f storage.InterceptUpdateOnTxFunc) storage.InterceptUpdateOnTxFunc {
 	return func(ctx context.Context, txStorage storage.Repository, oldObj, newObj types.Object, labelChanges ...*query.LabelChange) (types.Object, error) {
+		if catalogRefreshSkipped(ctx) {
+			return f(ctx, txStorage, oldObj, newObj, labelChanges...)
+		}
 		oldBroker := oldObj.(*types.ServiceBroker)
 
 		existingServiceOfferingsWithServicePlans, err := c.CatalogLoader(ctx, oldBroker.GetID(), txStorage)
@@ -165,6 +184,11 @@ func (c *brokerUpdateCatalogInterceptor) OnTxUpdate(f storage.InterceptUpdateOnT
 							existingPlanUpdated.ID = existingServicePlan.ID
 							existingPlanUpdated.CreatedAt = existingServicePlan.CreatedAt
 							existingPlanUpdated.UpdatedAt = existingServicePlan.UpdatedAt
+							existingPlanUpdated.DefaultParameters = existingServicePlan.DefaultParameters
+							if err := existingPlanUpdated.ValidateDefaultParameters(); err != nil {
+								log.C(ctx).WithError(err).Warnf("Default parameters of plan with id %s do not match the updated plan schemas and will be removed", existingServicePlan.ID)
+								existingPlanUpdated.DefaultParameters = nil
+							}
 						} else {
 							newPlansMapping = append(newPlansMapping, existingServicePlan)
 						}
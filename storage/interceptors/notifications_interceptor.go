@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/Peripli/service-manager/pkg/util"
@@ -32,7 +33,20 @@ type objectDetails map[string]util.InputValidator
 
 type NotificationsInterceptor struct {
 	PlatformIdProviderFunc func(ctx context.Context, object types.Object) string
-	AdditionalDetailsFunc  func(ctx context.Context, objects types.ObjectList, repository storage.Repository) (objectDetails, error)
+	// PlatformIDsProviderFunc provides the ids of all platforms which are notified about changes of the object.
+	// It takes precedence over PlatformIdProviderFunc if set.
+	PlatformIDsProviderFunc func(ctx context.Context, object types.Object, repository storage.Repository) ([]string, error)
+	AdditionalDetailsFunc   func(ctx context.Context, objects types.ObjectList, repository storage.Repository) (objectDetails, error)
+	// ExpandFunc provides the objects which the platforms are notified about instead of the object mapped by keys which
+	// identify them across updates of the object. The platforms are notified about the object itself if not set.
+	ExpandFunc func(ctx context.Context, object types.Object, repository storage.Repository) (map[string]types.Object, error)
+}
+
+func (ni *NotificationsInterceptor) platformIDs(ctx context.Context, repository storage.Repository, object types.Object) ([]string, error) {
+	if ni.PlatformIDsProviderFunc != nil {
+		return ni.PlatformIDsProviderFunc(ctx, object, repository)
+	}
+	return []string{ni.PlatformIdProviderFunc(ctx, object)}, nil
 }
 
 func (ni *NotificationsInterceptor) OnTxCreate(h storage.InterceptCreateOnTxFunc) storage.InterceptCreateOnTxFunc {
@@ -42,19 +56,16 @@ func (ni *NotificationsInterceptor) OnTxCreate(h storage.InterceptCreateOnTxFunc
 			return nil, err
 		}
 
-		additionalDetails, err := ni.AdditionalDetailsFunc(ctx, types.NewObjectArray(obj), repository)
+		expandedObjects, err := ni.expand(ctx, repository, newObj)
 		if err != nil {
 			return nil, err
 		}
-
-		platformID := ni.PlatformIdProviderFunc(ctx, newObj)
-
-		return newObj, CreateNotification(ctx, repository, types.CREATED, newObj.GetType(), platformID, &Payload{
-			New: &ObjectPayload{
-				Resource:   newObj,
-				Additional: additionalDetails[obj.GetID()],
-			},
-		})
+		for _, key := range sortedObjectKeys(expandedObjects) {
+			if err := ni.notifyCreate(ctx, repository, expandedObjects[key]); err != nil {
+				return nil, err
+			}
+		}
+		return newObj, nil
 	}
 }
 
@@ -65,45 +76,168 @@ func (ni *NotificationsInterceptor) OnTxUpdate(h storage.InterceptUpdateOnTxFunc
 			return nil, err
 		}
 
-		detailsMap, err := ni.AdditionalDetailsFunc(ctx, types.NewObjectArray(updatedObject), repository)
+		expandedOldObjects, err := ni.expand(ctx, repository, oldObject)
+		if err != nil {
+			return nil, err
+		}
+		expandedUpdatedObjects, err := ni.expand(ctx, repository, updatedObject)
 		if err != nil {
 			return nil, err
 		}
-		additionalDetails := detailsMap[updatedObject.GetID()]
 
-		oldPlatformID := ni.PlatformIdProviderFunc(ctx, oldObject)
-		updatedPlatformID := ni.PlatformIdProviderFunc(ctx, updatedObject)
+		// the objects which the update expanded to or removed are created or deleted for the platforms
+		for _, key := range sortedObjectKeys(expandedUpdatedObjects) {
+			expandedUpdatedObject := expandedUpdatedObjects[key]
+			expandedOldObject, found := expandedOldObjects[key]
+			if !found {
+				err = ni.notifyCreate(ctx, repository, expandedUpdatedObject)
+			} else {
+				err = ni.notifyUpdate(ctx, repository, expandedOldObject, expandedUpdatedObject, labelChanges)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, key := range sortedObjectKeys(expandedOldObjects) {
+			if _, found := expandedUpdatedObjects[key]; found {
+				continue
+			}
+			if err := ni.notifyDelete(ctx, repository, expandedOldObjects[key]); err != nil {
+				return nil, err
+			}
+		}
 
-		oldObjectLabels := oldObject.GetLabels()
-		updatedObjectLabels := updatedObject.GetLabels()
+		return updatedObject, nil
+	}
+}
 
-		if updatedObject.Equals(oldObject) {
-			updatedObject.SetLabels(nil)
+func (ni *NotificationsInterceptor) OnTxDelete(h storage.InterceptDeleteOnTxFunc) storage.InterceptDeleteOnTxFunc {
+	return func(ctx context.Context, repository storage.Repository, objects types.ObjectList, deletionCriteria ...query.Criterion) error {
+		expandedObjects := make([]types.Object, 0, objects.Len())
+		for i := 0; i < objects.Len(); i++ {
+			expanded, err := ni.expand(ctx, repository, objects.ItemAt(i))
+			if err != nil {
+				return err
+			}
+			for _, key := range sortedObjectKeys(expanded) {
+				expandedObjects = append(expandedObjects, expanded[key])
+			}
 		}
-		oldObject.SetLabels(nil)
 
-		// if the resource update contains change in the platform ID field this means that the notification would be processed by
-		// two platforms - one needs to perform a delete operation and the other needs to perform a create operation.
-		if oldPlatformID != updatedPlatformID {
-			if err := CreateNotification(ctx, repository, types.CREATED, updatedObject.GetType(), updatedPlatformID, &Payload{
-				New: &ObjectPayload{
-					Resource:   updatedObject,
-					Additional: additionalDetails,
-				},
-			}); err != nil {
-				return nil, err
+		additionalDetails, err := ni.expandedAdditionalDetails(ctx, repository, objects, expandedObjects)
+		if err != nil {
+			return err
+		}
+		platformIDs := make([][]string, len(expandedObjects))
+		for i, object := range expandedObjects {
+			if platformIDs[i], err = ni.platformIDs(ctx, repository, object); err != nil {
+				return err
 			}
-			if err := CreateNotification(ctx, repository, types.DELETED, updatedObject.GetType(), oldPlatformID, &Payload{
-				Old: &ObjectPayload{
-					Resource:   oldObject,
-					Additional: additionalDetails,
-				},
-			}); err != nil {
-				return nil, err
+		}
+
+		if err := h(ctx, repository, objects, deletionCriteria...); err != nil {
+			return err
+		}
+
+		for i, oldObject := range expandedObjects {
+			for _, platformID := range platformIDs[i] {
+				if err := CreateNotification(ctx, repository, types.DELETED, oldObject.GetType(), platformID, &Payload{
+					Old: &ObjectPayload{
+						Resource:   oldObject,
+						Additional: additionalDetails[i],
+					},
+				}); err != nil {
+					return err
+				}
 			}
 		}
 
-		if err := CreateNotification(ctx, repository, types.MODIFIED, updatedObject.GetType(), updatedPlatformID, &Payload{
+		return nil
+	}
+}
+
+// expand returns the objects which the platforms are notified about instead of the object mapped by keys which
+// identify them across updates of the object
+func (ni *NotificationsInterceptor) expand(ctx context.Context, repository storage.Repository, object types.Object) (map[string]types.Object, error) {
+	if ni.ExpandFunc == nil {
+		return map[string]types.Object{object.GetID(): object}, nil
+	}
+	return ni.ExpandFunc(ctx, object, repository)
+}
+
+func (ni *NotificationsInterceptor) notifyCreate(ctx context.Context, repository storage.Repository, newObj types.Object) error {
+	additionalDetails, err := ni.AdditionalDetailsFunc(ctx, types.NewObjectArray(newObj), repository)
+	if err != nil {
+		return err
+	}
+
+	platformIDs, err := ni.platformIDs(ctx, repository, newObj)
+	if err != nil {
+		return err
+	}
+
+	for _, platformID := range platformIDs {
+		if err := CreateNotification(ctx, repository, types.CREATED, newObj.GetType(), platformID, &Payload{
+			New: &ObjectPayload{
+				Resource:   newObj,
+				Additional: additionalDetails[newObj.GetID()],
+			},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ni *NotificationsInterceptor) notifyUpdate(ctx context.Context, repository storage.Repository, oldObject, updatedObject types.Object, labelChanges query.LabelChanges) error {
+	detailsMap, err := ni.AdditionalDetailsFunc(ctx, types.NewObjectArray(updatedObject), repository)
+	if err != nil {
+		return err
+	}
+	additionalDetails := detailsMap[updatedObject.GetID()]
+
+	oldPlatformIDs, err := ni.platformIDs(ctx, repository, oldObject)
+	if err != nil {
+		return err
+	}
+	updatedPlatformIDs, err := ni.platformIDs(ctx, repository, updatedObject)
+	if err != nil {
+		return err
+	}
+
+	oldObjectLabels := oldObject.GetLabels()
+	updatedObjectLabels := updatedObject.GetLabels()
+
+	if updatedObject.Equals(oldObject) {
+		updatedObject.SetLabels(nil)
+	}
+	oldObject.SetLabels(nil)
+
+	// if the resource update contains change in the platform ID field this means that the notification would be processed by
+	// two platforms - one needs to perform a delete operation and the other needs to perform a create operation.
+	for _, platformID := range difference(updatedPlatformIDs, oldPlatformIDs) {
+		if err := CreateNotification(ctx, repository, types.CREATED, updatedObject.GetType(), platformID, &Payload{
+			New: &ObjectPayload{
+				Resource:   updatedObject,
+				Additional: additionalDetails,
+			},
+		}); err != nil {
+			return err
+		}
+	}
+	for _, platformID := range difference(oldPlatformIDs, updatedPlatformIDs) {
+		if err := CreateNotification(ctx, repository, types.DELETED, updatedObject.GetType(), platformID, &Payload{
+			Old: &ObjectPayload{
+				Resource:   oldObject,
+				Additional: additionalDetails,
+			},
+		}); err != nil {
+			return err
+		}
+	}
+
+	for _, platformID := range updatedPlatformIDs {
+		if err := CreateNotification(ctx, repository, types.MODIFIED, updatedObject.GetType(), platformID, &Payload{
 			New: &ObjectPayload{
 				Resource:   updatedObject,
 				Additional: additionalDetails,
@@ -114,44 +248,62 @@ func (ni *NotificationsInterceptor) OnTxUpdate(h storage.InterceptUpdateOnTxFunc
 			},
 			LabelChanges: labelChanges,
 		}); err != nil {
-			return nil, err
+			return err
 		}
+	}
 
-		oldObject.SetLabels(oldObjectLabels)
-		updatedObject.SetLabels(updatedObjectLabels)
+	oldObject.SetLabels(oldObjectLabels)
+	updatedObject.SetLabels(updatedObjectLabels)
 
-		return updatedObject, nil
-	}
+	return nil
 }
 
-func (ni *NotificationsInterceptor) OnTxDelete(h storage.InterceptDeleteOnTxFunc) storage.InterceptDeleteOnTxFunc {
-	return func(ctx context.Context, repository storage.Repository, objects types.ObjectList, deletionCriteria ...query.Criterion) error {
-		additionalDetails, err := ni.AdditionalDetailsFunc(ctx, objects, repository)
-		if err != nil {
-			return err
-		}
+func (ni *NotificationsInterceptor) notifyDelete(ctx context.Context, repository storage.Repository, oldObject types.Object) error {
+	additionalDetails, err := ni.AdditionalDetailsFunc(ctx, types.NewObjectArray(oldObject), repository)
+	if err != nil {
+		return err
+	}
+	platformIDs, err := ni.platformIDs(ctx, repository, oldObject)
+	if err != nil {
+		return err
+	}
 
-		if err := h(ctx, repository, objects, deletionCriteria...); err != nil {
+	for _, platformID := range platformIDs {
+		if err := CreateNotification(ctx, repository, types.DELETED, oldObject.GetType(), platformID, &Payload{
+			Old: &ObjectPayload{
+				Resource:   oldObject,
+				Additional: additionalDetails[oldObject.GetID()],
+			},
+		}); err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		for i := 0; i < objects.Len(); i++ {
-			oldObject := objects.ItemAt(i)
-
-			platformID := ni.PlatformIdProviderFunc(ctx, oldObject)
-
-			if err := CreateNotification(ctx, repository, types.DELETED, oldObject.GetType(), platformID, &Payload{
-				Old: &ObjectPayload{
-					Resource:   oldObject,
-					Additional: additionalDetails[oldObject.GetID()],
-				},
-			}); err != nil {
-				return err
-			}
+// expandedAdditionalDetails returns the additional details of the expanded objects in their order. The details of
+// objects which are not expanded are fetched at once.
+func (ni *NotificationsInterceptor) expandedAdditionalDetails(ctx context.Context, repository storage.Repository, objects types.ObjectList, expandedObjects []types.Object) ([]util.InputValidator, error) {
+	additionalDetails := make([]util.InputValidator, len(expandedObjects))
+	if ni.ExpandFunc == nil {
+		details, err := ni.AdditionalDetailsFunc(ctx, objects, repository)
+		if err != nil {
+			return nil, err
+		}
+		for i, object := range expandedObjects {
+			additionalDetails[i] = details[object.GetID()]
 		}
+		return additionalDetails, nil
+	}
 
-		return nil
+	for i, object := range expandedObjects {
+		details, err := ni.AdditionalDetailsFunc(ctx, types.NewObjectArray(object), repository)
+		if err != nil {
+			return nil, err
+		}
+		additionalDetails[i] = details[object.GetID()]
 	}
+	return additionalDetails, nil
 }
 
 func CreateNotification(ctx context.Context, repository storage.Repository, op types.NotificationOperation, resource types.ObjectType, platformID string, payload *Payload) error {
@@ -199,3 +351,27 @@ func CreateNotification(ctx context.Context, repository storage.Repository, op t
 
 	return nil
 }
+
+// difference returns the platform ids which are in the first list and not in the second
+func difference(platformIDs, otherPlatformIDs []string) []string {
+	others := make(map[string]bool, len(otherPlatformIDs))
+	for _, platformID := range otherPlatformIDs {
+		others[platformID] = true
+	}
+	result := make([]string, 0)
+	for _, platformID := range platformIDs {
+		if !others[platformID] {
+			result = append(result, platformID)
+		}
+	}
+	return result
+}
+
+func sortedObjectKeys(objects map[string]types.Object) []string {
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package interceptors
+
+import (
+	"context"
+
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/storage"
+)
+
+// PlanVisibilitiesNotificationsInterceptorProvider provides an interceptor which notifies the platforms about
+// the service offering visibilities which apply to plans added to the service offering
+type PlanVisibilitiesNotificationsInterceptorProvider struct {
+	StagingLabel string
+}
+
+// Name returns the name of the provider
+func (*PlanVisibilitiesNotificationsInterceptorProvider) Name() string {
+	return "PlanVisibilitiesNotificationsInterceptorProvider"
+}
+
+// Provide returns the interceptor
+func (p *PlanVisibilitiesNotificationsInterceptorProvider) Provide() storage.CreateOnTxInterceptor {
+	return &planVisibilitiesNotificationsInterceptor{
+		stagingLabel: p.StagingLabel,
+	}
+}
+
+type planVisibilitiesNotificationsInterceptor struct {
+	stagingLabel string
+}
+
+// OnTxCreate creates visibility notifications for the plan if visibilities of its service offering apply to it
+func (i *planVisibilitiesNotificationsInterceptor) OnTxCreate(h storage.InterceptCreateOnTxFunc) storage.InterceptCreateOnTxFunc {
+	return func(ctx context.Context, repository storage.Repository, obj types.Object) (types.Object, error) {
+		newObj, err := h(ctx, repository, obj)
+		if err != nil {
+			return nil, err
+		}
+
+		plan := newObj.(*types.ServicePlan)
+		objectList, err := repository.List(ctx, types.VisibilityType,
+			query.ByField(query.EqualsOperator, "service_offering_id", plan.ServiceOfferingID),
+			query.ByField(query.EqualsOperator, "active", "true"))
+		if err != nil {
+			return nil, err
+		}
+
+		visibilityNotifications := NewVisibilityNotificationsInterceptor(i.stagingLabel)
+		for _, visibility := range objectList.(*types.Visibilities).Visibilities {
+			if !visibility.CoversPlan(plan) {
+				continue
+			}
+			if err := visibilityNotifications.notifyCreate(ctx, repository, visibility.ForPlan(plan.ID)); err != nil {
+				return nil, err
+			}
+		}
+		return newObj, nil
+	}
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package interceptors
+
+import (
+	"context"
+
+	"github.com/Peripli/service-manager/pkg/log"
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/storage"
+)
+
+// PlatformVisibilitiesNotificationsInterceptorProvider provides an interceptor which notifies the platforms about
+// the visibilities with selectors which start or stop applying to them because of changes of their labels
+type PlatformVisibilitiesNotificationsInterceptorProvider struct {
+	StagingLabel string
+}
+
+// Name returns the name of the provider
+func (*PlatformVisibilitiesNotificationsInterceptorProvider) Name() string {
+	return "PlatformVisibilitiesNotificationsInterceptorProvider"
+}
+
+// Provide returns the interceptor
+func (p *PlatformVisibilitiesNotificationsInterceptorProvider) Provide() storage.UpdateOnTxInterceptor {
+	return &platformVisibilitiesNotificationsInterceptor{
+		stagingLabel: p.StagingLabel,
+	}
+}
+
+type platformVisibilitiesNotificationsInterceptor struct {
+	stagingLabel string
+}
+
+// OnTxUpdate creates visibility notifications for the platform if its label changes affect the visibilities with selectors
+func (i *platformVisibilitiesNotificationsInterceptor) OnTxUpdate(h storage.InterceptUpdateOnTxFunc) storage.InterceptUpdateOnTxFunc {
+	return func(ctx context.Context, repository storage.Repository, oldObject, newObject types.Object, labelChanges ...*query.LabelChange) (types.Object, error) {
+		updatedObject, err := h(ctx, repository, oldObject, newObject, labelChanges...)
+		if err != nil || len(labelChanges) == 0 {
+			return updatedObject, err
+		}
+
+		oldLabels := oldObject.GetLabels()
+		updatedLabels, _, _ := query.ApplyLabelChangesToLabels(labelChanges, oldLabels)
+
+		objectList, err := repository.List(ctx, types.VisibilityType,
+			query.ByField(query.NotEqualsOperator, "selector", ""),
+			query.ByField(query.EqualsOperator, "active", "true"))
+		if err != nil {
+			return nil, err
+		}
+		var addedVisibilities, removedVisibilities []*types.Visibility
+		for _, visibility := range objectList.(*types.Visibilities).Visibilities {
+			criteria, err := query.ParseSelector(visibility.Selector)
+			if err != nil {
+				log.C(ctx).WithError(err).Errorf("Invalid selector of visibility with id %s", visibility.ID)
+				continue
+			}
+			appliedBefore := query.MatchLabels(oldLabels, criteria...)
+			appliesNow := query.MatchLabels(updatedLabels, criteria...)
+			if appliesNow && !appliedBefore {
+				addedVisibilities = append(addedVisibilities, visibility)
+			} else if appliedBefore && !appliesNow {
+				removedVisibilities = append(removedVisibilities, visibility)
+			}
+		}
+		if len(addedVisibilities) == 0 && len(removedVisibilities) == 0 {
+			return updatedObject, nil
+		}
+
+		platformID := updatedObject.GetID()
+		for _, visibility := range addedVisibilities {
+			if err := i.notifyPlatformAboutVisibility(ctx, repository, types.CREATED, platformID, updatedLabels, visibility); err != nil {
+				return nil, err
+			}
+		}
+		for _, visibility := range removedVisibilities {
+			if err := i.notifyPlatformAboutVisibility(ctx, repository, types.DELETED, platformID, oldLabels, visibility); err != nil {
+				return nil, err
+			}
+		}
+		return updatedObject, nil
+	}
+}
+
+// notifyPlatformAboutVisibility creates notifications for the platform about the visibility or about the visibilities
+// for each plan if the visibility is for a service offering. The platform is notified about the visibilities of plans
+// of brokers in staging state only if it has the staging label.
+func (i *platformVisibilitiesNotificationsInterceptor) notifyPlatformAboutVisibility(ctx context.Context, repository storage.Repository, op types.NotificationOperation, platformID string, platformLabels types.Labels, visibility *types.Visibility) error {
+	visibilityNotifications := NewVisibilityNotificationsInterceptor(i.stagingLabel)
+	expandedVisibilities, err := visibilityNotifications.expand(ctx, repository, visibility)
+	if err != nil {
+		return err
+	}
+	_, stagingPlatform := platformLabels[i.stagingLabel]
+	for _, key := range sortedObjectKeys(expandedVisibilities) {
+		expandedVisibility := expandedVisibilities[key]
+		if len(i.stagingLabel) != 0 && !stagingPlatform {
+			staging, err := isStagingPlan(ctx, repository, expandedVisibility.(*types.Visibility).ServicePlanID)
+			if err != nil {
+				return err
+			}
+			if staging {
+				continue
+			}
+		}
+		additionalDetails, err := visibilityNotifications.AdditionalDetailsFunc(ctx, types.NewObjectArray(expandedVisibility), repository)
+		if err != nil {
+			return err
+		}
+		objectPayload := &ObjectPayload{
+			Resource:   expandedVisibility,
+			Additional: additionalDetails[expandedVisibility.GetID()],
+		}
+		payload := &Payload{New: objectPayload}
+		if op == types.DELETED {
+			payload = &Payload{Old: objectPayload}
+		}
+		if err := CreateNotification(ctx, repository, op, types.VisibilityType, platformID, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
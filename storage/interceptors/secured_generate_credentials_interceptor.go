@@ -42,7 +42,8 @@ func (c *GenerateCredentialsInterceptorProvider) Name() string {
 
 type generateCredentialsInterceptor struct{}
 
-// AroundTxCreate generates new credentials for the secured object
+// AroundTxCreate generates new credentials for the secured object. Only the hash of the generated password is
+// stored, so the password is returned only with the created object.
 func (c *generateCredentialsInterceptor) AroundTxCreate(h storage.InterceptCreateAroundTxFunc) storage.InterceptCreateAroundTxFunc {
 	return func(ctx context.Context, obj types.Object) (types.Object, error) {
 		credentials, err := types.GenerateCredentials()
@@ -50,8 +51,20 @@ func (c *generateCredentialsInterceptor) AroundTxCreate(h storage.InterceptCreat
 			log.C(ctx).Error("Could not generate credentials for platform")
 			return nil, err
 		}
+		password := credentials.Basic.Password
+		if err := credentials.Basic.HashPassword(); err != nil {
+			log.C(ctx).Error("Could not hash credentials for platform")
+			return nil, err
+		}
 		(obj.(types.Secured)).SetCredentials(credentials)
 
-		return h(ctx, obj)
+		createdObj, err := h(ctx, obj)
+		if err != nil {
+			return nil, err
+		}
+		if createdCredentials := createdObj.(types.Secured).GetCredentials(); createdCredentials != nil && createdCredentials.Basic != nil {
+			createdCredentials.Basic.Password = password
+		}
+		return createdObj, nil
 	}
 }
@@ -0,0 +1,72 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package interceptors
+
+import (
+	"context"
+	"time"
+
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/storage"
+)
+
+// VisibilityCreateActivationInterceptorProvider provides an interceptor which activates the created visibilities
+// if they apply at the time of their creation
+type VisibilityCreateActivationInterceptorProvider struct {
+}
+
+func (*VisibilityCreateActivationInterceptorProvider) Name() string {
+	return "VisibilityCreateActivationInterceptorProvider"
+}
+
+func (*VisibilityCreateActivationInterceptorProvider) Provide() storage.CreateOnTxInterceptor {
+	return &visibilityActivationInterceptor{}
+}
+
+// VisibilityUpdateActivationInterceptorProvider provides an interceptor which keeps the activation state of the
+// updated visibilities, as it is changed only when the visibilities are activated or expired
+type VisibilityUpdateActivationInterceptorProvider struct {
+}
+
+func (*VisibilityUpdateActivationInterceptorProvider) Name() string {
+	return "VisibilityUpdateActivationInterceptorProvider"
+}
+
+func (*VisibilityUpdateActivationInterceptorProvider) Provide() storage.UpdateOnTxInterceptor {
+	return &visibilityActivationInterceptor{}
+}
+
+type visibilityActivationInterceptor struct {
+}
+
+// OnTxCreate activates the visibility if it applies at the time of its creation
+func (*visibilityActivationInterceptor) OnTxCreate(h storage.InterceptCreateOnTxFunc) storage.InterceptCreateOnTxFunc {
+	return func(ctx context.Context, repository storage.Repository, obj types.Object) (types.Object, error) {
+		visibility := obj.(*types.Visibility)
+		visibility.Active = visibility.IsActiveAt(time.Now())
+		return h(ctx, repository, visibility)
+	}
+}
+
+// OnTxUpdate keeps the activation state of the visibility
+func (*visibilityActivationInterceptor) OnTxUpdate(h storage.InterceptUpdateOnTxFunc) storage.InterceptUpdateOnTxFunc {
+	return func(ctx context.Context, repository storage.Repository, oldObject, newObject types.Object, labelChanges ...*query.LabelChange) (types.Object, error) {
+		newObject.(*types.Visibility).Active = oldObject.(*types.Visibility).Active
+		return h(ctx, repository, oldObject, newObject, labelChanges...)
+	}
+}
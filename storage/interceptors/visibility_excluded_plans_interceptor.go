@@ -0,0 +1,103 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package interceptors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/storage"
+)
+
+// VisibilityCreateExcludedPlansInterceptorProvider provides an interceptor which validates that the plans excluded
+// from the created service offering visibilities belong to the service offering
+type VisibilityCreateExcludedPlansInterceptorProvider struct {
+}
+
+func (*VisibilityCreateExcludedPlansInterceptorProvider) Name() string {
+	return "VisibilityCreateExcludedPlansInterceptorProvider"
+}
+
+func (*VisibilityCreateExcludedPlansInterceptorProvider) Provide() storage.CreateOnTxInterceptor {
+	return &visibilityExcludedPlansInterceptor{}
+}
+
+// VisibilityUpdateExcludedPlansInterceptorProvider provides an interceptor which validates that the plans excluded
+// from the updated service offering visibilities belong to the service offering
+type VisibilityUpdateExcludedPlansInterceptorProvider struct {
+}
+
+func (*VisibilityUpdateExcludedPlansInterceptorProvider) Name() string {
+	return "VisibilityUpdateExcludedPlansInterceptorProvider"
+}
+
+func (*VisibilityUpdateExcludedPlansInterceptorProvider) Provide() storage.UpdateOnTxInterceptor {
+	return &visibilityExcludedPlansInterceptor{}
+}
+
+type visibilityExcludedPlansInterceptor struct {
+}
+
+// OnTxCreate validates the excluded plans of the visibility before it is created
+func (*visibilityExcludedPlansInterceptor) OnTxCreate(h storage.InterceptCreateOnTxFunc) storage.InterceptCreateOnTxFunc {
+	return func(ctx context.Context, repository storage.Repository, obj types.Object) (types.Object, error) {
+		if err := validateExcludedPlans(ctx, repository, obj.(*types.Visibility)); err != nil {
+			return nil, err
+		}
+		return h(ctx, repository, obj)
+	}
+}
+
+// OnTxUpdate validates the excluded plans of the visibility before it is updated
+func (*visibilityExcludedPlansInterceptor) OnTxUpdate(h storage.InterceptUpdateOnTxFunc) storage.InterceptUpdateOnTxFunc {
+	return func(ctx context.Context, repository storage.Repository, oldObject, newObject types.Object, labelChanges ...*query.LabelChange) (types.Object, error) {
+		if err := validateExcludedPlans(ctx, repository, newObject.(*types.Visibility)); err != nil {
+			return nil, err
+		}
+		return h(ctx, repository, oldObject, newObject, labelChanges...)
+	}
+}
+
+func validateExcludedPlans(ctx context.Context, repository storage.Repository, visibility *types.Visibility) error {
+	if len(visibility.ExcludedPlanIDs) == 0 {
+		return nil
+	}
+
+	objectList, err := repository.List(ctx, types.ServicePlanType,
+		query.ByField(query.EqualsOperator, "service_offering_id", visibility.ServiceOfferingID))
+	if err != nil {
+		return err
+	}
+	offeringPlanIDs := make(map[string]bool)
+	for _, plan := range objectList.(*types.ServicePlans).ServicePlans {
+		offeringPlanIDs[plan.ID] = true
+	}
+	for _, excludedPlanID := range visibility.ExcludedPlanIDs {
+		if !offeringPlanIDs[excludedPlanID] {
+			return &util.HTTPError{
+				ErrorType:   "BadRequest",
+				Description: fmt.Sprintf("excluded plan %s is not a plan of service offering %s", excludedPlanID, visibility.ServiceOfferingID),
+				StatusCode:  http.StatusBadRequest,
+			}
+		}
+	}
+	return nil
+}
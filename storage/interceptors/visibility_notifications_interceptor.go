@@ -10,11 +10,19 @@ import (
 	"github.com/Peripli/service-manager/storage"
 )
 
-func NewVisibilityNotificationsInterceptor() *NotificationsInterceptor {
+// NewVisibilityNotificationsInterceptor returns an interceptor which notifies the platforms about the visibilities.
+// If a staging label is specified, only the platforms with the staging label are notified about the visibilities of
+// plans of brokers in staging state.
+func NewVisibilityNotificationsInterceptor(stagingLabel string) *NotificationsInterceptor {
 	return &NotificationsInterceptor{
-		PlatformIdProviderFunc: func(ctx context.Context, obj types.Object) string {
-			return obj.(*types.Visibility).PlatformID
+		PlatformIDsProviderFunc: func(ctx context.Context, obj types.Object, repository storage.Repository) ([]string, error) {
+			platformIDs, err := visibilityPlatformIDs(ctx, obj, repository)
+			if err != nil {
+				return nil, err
+			}
+			return stagingPlatformIDs(ctx, repository, stagingLabel, obj.(*types.Visibility).ServicePlanID, platformIDs)
 		},
+		ExpandFunc: expandVisibility,
 		AdditionalDetailsFunc: func(ctx context.Context, objects types.ObjectList, repository storage.Repository) (objectDetails, error) {
 			var visibilities []*types.Visibility
 			switch t := objects.(type) {
@@ -59,6 +67,107 @@ func NewVisibilityNotificationsInterceptor() *NotificationsInterceptor {
 	}
 }
 
+// visibilityPlatformIDs returns the platform of the visibility or the platforms which satisfy the selector of the visibility.
+// The platforms are not notified about visibilities which are not active.
+func visibilityPlatformIDs(ctx context.Context, obj types.Object, repository storage.Repository) ([]string, error) {
+	visibility := obj.(*types.Visibility)
+	if !visibility.Active {
+		return []string{}, nil
+	}
+	if len(visibility.Selector) == 0 {
+		return []string{visibility.PlatformID}, nil
+	}
+
+	criteria, err := query.ParseSelector(visibility.Selector)
+	if err != nil {
+		return nil, err
+	}
+	objectList, err := repository.List(ctx, types.PlatformType)
+	if err != nil {
+		return nil, err
+	}
+	platformIDs := make([]string, 0)
+	for _, platform := range objectList.(*types.Platforms).Platforms {
+		if query.MatchLabels(platform.Labels, criteria...) {
+			platformIDs = append(platformIDs, platform.ID)
+		}
+	}
+	return platformIDs, nil
+}
+
+// stagingPlatformIDs returns the platforms with the staging label out of the specified platforms if the plan belongs
+// to a broker in staging state and all of the specified platforms otherwise
+func stagingPlatformIDs(ctx context.Context, repository storage.Repository, stagingLabel, planID string, platformIDs []string) ([]string, error) {
+	if len(stagingLabel) == 0 || len(platformIDs) == 0 {
+		return platformIDs, nil
+	}
+	staging, err := isStagingPlan(ctx, repository, planID)
+	if err != nil {
+		return nil, err
+	}
+	if !staging {
+		return platformIDs, nil
+	}
+	return filterPlatformIDsByLabel(ctx, repository, stagingLabel, platformIDs, true)
+}
+
+// filterPlatformIDsByLabel returns the platforms out of the specified platforms which have the label if hasLabel
+// is true or which do not have it otherwise
+func filterPlatformIDsByLabel(ctx context.Context, repository storage.Repository, label string, platformIDs []string, hasLabel bool) ([]string, error) {
+	objectList, err := repository.List(ctx, types.PlatformType, query.ByField(query.InOperator, "id", platformIDs...))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(platformIDs))
+	for _, platform := range objectList.(*types.Platforms).Platforms {
+		if _, found := platform.Labels[label]; found == hasLabel {
+			result = append(result, platform.ID)
+		}
+	}
+	return result, nil
+}
+
+// isStagingPlan returns true if the plan belongs to a broker in staging state
+func isStagingPlan(ctx context.Context, repository storage.Repository, planID string) (bool, error) {
+	plan, err := repository.Get(ctx, types.ServicePlanType, query.ByField(query.EqualsOperator, "id", planID))
+	if err != nil {
+		return false, err
+	}
+	offering, err := repository.Get(ctx, types.ServiceOfferingType,
+		query.ByField(query.EqualsOperator, "id", plan.(*types.ServicePlan).ServiceOfferingID))
+	if err != nil {
+		return false, err
+	}
+	broker, err := repository.Get(ctx, types.ServiceBrokerType,
+		query.ByField(query.EqualsOperator, "id", offering.(*types.ServiceOffering).BrokerID))
+	if err != nil {
+		return false, err
+	}
+	return broker.(*types.ServiceBroker).IsStaging(), nil
+}
+
+// expandVisibility returns the visibilities which the platforms are notified about instead of the visibility. Visibilities
+// for service offerings are expanded to visibilities for each plan of the service offering which they apply to.
+func expandVisibility(ctx context.Context, obj types.Object, repository storage.Repository) (map[string]types.Object, error) {
+	visibility := obj.(*types.Visibility)
+	if visibility.ServiceOfferingID == "" {
+		return map[string]types.Object{visibility.ID: visibility}, nil
+	}
+
+	objectList, err := repository.List(ctx, types.ServicePlanType,
+		query.ByField(query.EqualsOperator, "service_offering_id", visibility.ServiceOfferingID))
+	if err != nil {
+		return nil, err
+	}
+	planVisibilities := make(map[string]types.Object)
+	for _, plan := range objectList.(*types.ServicePlans).ServicePlans {
+		if visibility.CoversPlan(plan) {
+			planVisibilities[plan.ID] = visibility.ForPlan(plan.ID)
+		}
+	}
+	return planVisibilities, nil
+}
+
 func fetchVisibilityPlans(ctx context.Context, repository storage.Repository, visibilities []*types.Visibility) (map[string]*types.ServicePlan, error) {
 	planSet := make(map[string]bool, len(visibilities))
 	for _, vis := range visibilities {
@@ -152,34 +261,37 @@ func (va VisibilityAdditional) Validate() error {
 }
 
 type VisibilityCreateNotificationsInterceptorProvider struct {
+	StagingLabel string
 }
 
 func (*VisibilityCreateNotificationsInterceptorProvider) Name() string {
 	return "VisibilityCreateNotificationsInterceptorProvider"
 }
 
-func (*VisibilityCreateNotificationsInterceptorProvider) Provide() storage.CreateOnTxInterceptor {
-	return NewVisibilityNotificationsInterceptor()
+func (p *VisibilityCreateNotificationsInterceptorProvider) Provide() storage.CreateOnTxInterceptor {
+	return NewVisibilityNotificationsInterceptor(p.StagingLabel)
 }
 
 type VisibilityUpdateNotificationsInterceptorProvider struct {
+	StagingLabel string
 }
 
 func (*VisibilityUpdateNotificationsInterceptorProvider) Name() string {
 	return "VisibilityUpdateNotificationsInterceptorProvider"
 }
 
-func (*VisibilityUpdateNotificationsInterceptorProvider) Provide() storage.UpdateOnTxInterceptor {
-	return NewVisibilityNotificationsInterceptor()
+func (p *VisibilityUpdateNotificationsInterceptorProvider) Provide() storage.UpdateOnTxInterceptor {
+	return NewVisibilityNotificationsInterceptor(p.StagingLabel)
 }
 
 type VisibilityDeleteNotificationsInterceptorProvider struct {
+	StagingLabel string
 }
 
 func (*VisibilityDeleteNotificationsInterceptorProvider) Name() string {
 	return "VisibilityDeleteNotificationsInterceptorProvider"
 }
 
-func (*VisibilityDeleteNotificationsInterceptorProvider) Provide() storage.DeleteOnTxInterceptor {
-	return NewVisibilityNotificationsInterceptor()
+func (p *VisibilityDeleteNotificationsInterceptorProvider) Provide() storage.DeleteOnTxInterceptor {
+	return NewVisibilityNotificationsInterceptor(p.StagingLabel)
 }
@@ -18,6 +18,7 @@ package postgres
 
 import (
 	"database/sql"
+	"encoding/json"
 
 	"github.com/Peripli/service-manager/storage"
 	sqlxtypes "github.com/jmoiron/sqlx/types"
@@ -35,6 +36,19 @@ type Broker struct {
 	Username    string             `db:"username"`
 	Password    string             `db:"password"`
 	Catalog     sqlxtypes.JSONText `db:"catalog"`
+	OSBVersion  sql.NullString     `db:"osb_version"`
+
+	FailoverURLs    sqlxtypes.JSONText `db:"failover_urls"`
+	StickyInstances bool               `db:"sticky_instances"`
+	ShadowURL       sql.NullString     `db:"shadow_url"`
+
+	ErrorMappings sqlxtypes.JSONText `db:"error_mappings"`
+
+	State string `db:"state"`
+
+	TransportSettings sqlxtypes.JSONText `db:"transport_settings"`
+
+	StaticCatalog sqlxtypes.JSONText `db:"static_catalog"`
 
 	Services []*ServiceOffering `db:"-"`
 }
@@ -61,8 +75,29 @@ func (e *Broker) ToObject() types.Object {
 				Password: e.Password,
 			},
 		},
-		Catalog:  getJSONRawMessage(e.Catalog),
-		Services: services,
+		OSBVersion:      e.OSBVersion.String,
+		StickyInstances: e.StickyInstances,
+		ShadowURL:       e.ShadowURL.String,
+		State:           e.State,
+		StaticCatalog:   getJSONRawMessage(e.StaticCatalog),
+		Catalog:         getJSONRawMessage(e.Catalog),
+		Services:        services,
+	}
+	if failoverURLs := getJSONRawMessage(e.FailoverURLs); failoverURLs != nil {
+		if err := json.Unmarshal(failoverURLs, &broker.FailoverURLs); err != nil || len(broker.FailoverURLs) == 0 {
+			broker.FailoverURLs = nil
+		}
+	}
+	if errorMappings := getJSONRawMessage(e.ErrorMappings); errorMappings != nil {
+		if err := json.Unmarshal(errorMappings, &broker.ErrorMappings); err != nil || len(broker.ErrorMappings) == 0 {
+			broker.ErrorMappings = nil
+		}
+	}
+	if transportSettings := getJSONRawMessage(e.TransportSettings); transportSettings != nil {
+		broker.TransportSettings = &types.BrokerTransportSettings{}
+		if err := json.Unmarshal(transportSettings, broker.TransportSettings); err != nil {
+			broker.TransportSettings = nil
+		}
 	}
 	return broker
 }
@@ -90,11 +125,38 @@ func (*Broker) FromObject(object types.Object) (storage.Entity, bool) {
 		Description: toNullString(broker.Description),
 		BrokerURL:   broker.BrokerURL,
 		Catalog:     getJSONText(broker.Catalog),
+		OSBVersion:  toNullString(broker.OSBVersion),
 		Services:    services,
+
+		StickyInstances: broker.StickyInstances,
+		ShadowURL:       toNullString(broker.ShadowURL),
+		State:           broker.State,
+		StaticCatalog:   getJSONText(broker.StaticCatalog),
 	}
 	if broker.Credentials != nil && broker.Credentials.Basic != nil {
 		b.Username = broker.Credentials.Basic.Username
 		b.Password = broker.Credentials.Basic.Password
 	}
+	b.FailoverURLs = sqlxtypes.JSONText("[]")
+	if len(broker.FailoverURLs) != 0 {
+		if failoverURLs, err := json.Marshal(broker.FailoverURLs); err == nil {
+			b.FailoverURLs = failoverURLs
+		}
+	}
+	if len(b.State) == 0 {
+		b.State = types.BrokerActive
+	}
+	b.ErrorMappings = sqlxtypes.JSONText("[]")
+	if len(broker.ErrorMappings) != 0 {
+		if errorMappings, err := json.Marshal(broker.ErrorMappings); err == nil {
+			b.ErrorMappings = errorMappings
+		}
+	}
+	b.TransportSettings = sqlxtypes.JSONText("{}")
+	if broker.TransportSettings != nil {
+		if transportSettings, err := json.Marshal(broker.TransportSettings); err == nil {
+			b.TransportSettings = transportSettings
+		}
+	}
 	return b, true
 }
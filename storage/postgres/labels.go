@@ -28,6 +28,7 @@ import (
 	"github.com/Peripli/service-manager/pkg/query"
 
 	"github.com/gofrs/uuid"
+	"github.com/lib/pq"
 
 	"github.com/Peripli/service-manager/pkg/log"
 	"github.com/jmoiron/sqlx"
@@ -129,9 +130,10 @@ func findTagType(tags []tagType, tagName string) reflect.Type {
 }
 
 var (
-	intType   = reflect.TypeOf(int(1))
-	int64Type = reflect.TypeOf(int64(1))
-	timeType  = reflect.TypeOf(time.Time{})
+	intType      = reflect.TypeOf(int(1))
+	int64Type    = reflect.TypeOf(int64(1))
+	timeType     = reflect.TypeOf(time.Time{})
+	nullTimeType = reflect.TypeOf(pq.NullTime{})
 )
 
 func determineCastByType(tagType reflect.Type) string {
@@ -142,6 +144,8 @@ func determineCastByType(tagType reflect.Type) string {
 	case int64Type:
 		fallthrough
 	case timeType:
+		fallthrough
+	case nullTimeType:
 		dbCast = ""
 
 	default:
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE brokers DROP COLUMN transport_settings;
+
+COMMIT;
\ No newline at end of file
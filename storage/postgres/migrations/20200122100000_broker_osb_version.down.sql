@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE brokers DROP COLUMN osb_version;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE brokers ADD COLUMN osb_version VARCHAR(20);
+
+COMMIT;
\ No newline at end of file
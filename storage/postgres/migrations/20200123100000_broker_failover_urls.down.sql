@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE brokers DROP COLUMN failover_urls;
+ALTER TABLE brokers DROP COLUMN sticky_instances;
+ALTER TABLE operations DROP COLUMN broker_endpoint;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE brokers ADD COLUMN failover_urls json NOT NULL DEFAULT '[]';
+ALTER TABLE brokers ADD COLUMN sticky_instances BOOLEAN NOT NULL DEFAULT '0';
+ALTER TABLE operations ADD COLUMN broker_endpoint TEXT;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE brokers ADD COLUMN shadow_url TEXT;
+
+COMMIT;
\ No newline at end of file
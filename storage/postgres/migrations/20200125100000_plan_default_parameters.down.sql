@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE service_plans DROP COLUMN default_parameters;
+
+COMMIT;
\ No newline at end of file
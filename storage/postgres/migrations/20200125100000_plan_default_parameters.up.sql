@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE service_plans ADD COLUMN default_parameters json NOT NULL DEFAULT '{}';
+
+COMMIT;
\ No newline at end of file
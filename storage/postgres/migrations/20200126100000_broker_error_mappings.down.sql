@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE brokers DROP COLUMN error_mappings;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE brokers ADD COLUMN error_mappings json NOT NULL DEFAULT '[]';
+
+COMMIT;
\ No newline at end of file
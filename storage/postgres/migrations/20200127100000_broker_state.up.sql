@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE brokers ADD COLUMN state VARCHAR(100) NOT NULL DEFAULT 'active';
+
+COMMIT;
\ No newline at end of file
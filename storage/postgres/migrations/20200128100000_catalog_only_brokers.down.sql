@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE operations DROP COLUMN fulfillment;
+ALTER TABLE brokers DROP COLUMN static_catalog;
+
+COMMIT;
\ No newline at end of file
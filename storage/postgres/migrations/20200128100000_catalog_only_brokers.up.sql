@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE brokers ADD COLUMN static_catalog json NOT NULL DEFAULT '{}';
+ALTER TABLE operations ADD COLUMN fulfillment json NOT NULL DEFAULT '{}';
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE platforms DROP COLUMN previous_username;
+ALTER TABLE platforms DROP COLUMN previous_password;
+ALTER TABLE platforms DROP COLUMN previous_credentials_expires_at;
+
+COMMIT;
\ No newline at end of file
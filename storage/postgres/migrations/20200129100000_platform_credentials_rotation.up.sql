@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE platforms ADD COLUMN previous_username varchar(255);
+ALTER TABLE platforms ADD COLUMN previous_password bytea;
+ALTER TABLE platforms ADD COLUMN previous_credentials_expires_at timestamptz;
+
+COMMIT;
\ No newline at end of file
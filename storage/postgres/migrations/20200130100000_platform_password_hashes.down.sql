@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE platforms DROP COLUMN previous_password_hash;
+ALTER TABLE platforms DROP COLUMN password_hash;
+
+COMMIT;
\ No newline at end of file
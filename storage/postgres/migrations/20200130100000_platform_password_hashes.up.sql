@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE platforms ADD COLUMN password_hash varchar(255);
+ALTER TABLE platforms ADD COLUMN previous_password_hash varchar(255);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE platforms DROP COLUMN suspended;
+
+COMMIT;
\ No newline at end of file
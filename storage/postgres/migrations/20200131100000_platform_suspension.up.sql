@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE platforms ADD COLUMN suspended boolean NOT NULL DEFAULT false;
+
+COMMIT;
\ No newline at end of file
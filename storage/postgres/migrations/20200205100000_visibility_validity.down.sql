@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE visibilities DROP COLUMN active;
+ALTER TABLE visibilities DROP COLUMN valid_until;
+ALTER TABLE visibilities DROP COLUMN valid_from;
+
+COMMIT;
\ No newline at end of file
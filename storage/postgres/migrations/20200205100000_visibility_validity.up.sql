@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE visibilities ADD COLUMN valid_from timestamptz;
+ALTER TABLE visibilities ADD COLUMN valid_until timestamptz;
+ALTER TABLE visibilities ADD COLUMN active boolean NOT NULL DEFAULT true;
+
+COMMIT;
\ No newline at end of file
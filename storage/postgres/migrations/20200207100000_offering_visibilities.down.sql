@@ -0,0 +1,9 @@
+BEGIN;
+
+DELETE FROM visibilities WHERE service_plan_id IS NULL;
+ALTER TABLE visibilities DROP CONSTRAINT visibility_plan_or_offering;
+ALTER TABLE visibilities DROP COLUMN excluded_plan_ids;
+ALTER TABLE visibilities DROP COLUMN service_offering_id;
+ALTER TABLE visibilities ALTER COLUMN service_plan_id SET NOT NULL;
+
+COMMIT;
\ No newline at end of file
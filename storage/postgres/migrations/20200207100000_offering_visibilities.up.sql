@@ -0,0 +1,8 @@
+BEGIN;
+
+ALTER TABLE visibilities ALTER COLUMN service_plan_id DROP NOT NULL;
+ALTER TABLE visibilities ADD COLUMN service_offering_id varchar(255) REFERENCES service_offerings(id) ON DELETE CASCADE;
+ALTER TABLE visibilities ADD COLUMN excluded_plan_ids text[];
+ALTER TABLE visibilities ADD CONSTRAINT visibility_plan_or_offering CHECK ((service_plan_id IS NULL) <> (service_offering_id IS NULL));
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE visibilities DROP CONSTRAINT IF EXISTS unique_public_offering_visibility;
+
+DROP FUNCTION IF EXISTS check_unique_public_offering(varchar, varchar, varchar, varchar);
+
+DROP INDEX IF EXISTS visibilities_platform_id_service_offering_id_uindex;
+
+COMMIT;
\ No newline at end of file
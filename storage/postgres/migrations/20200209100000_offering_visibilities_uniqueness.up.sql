@@ -0,0 +1,34 @@
+BEGIN;
+
+CREATE UNIQUE INDEX IF NOT EXISTS visibilities_platform_id_service_offering_id_uindex
+    ON visibilities (platform_id, service_offering_id);
+
+CREATE OR REPLACE FUNCTION check_unique_public_offering(visid varchar, soid varchar, pid varchar, sel varchar)
+    RETURNS boolean AS
+$$
+DECLARE
+    i int;
+BEGIN
+    IF (soid IS NULL) THEN
+        RETURN true;
+    END IF;
+
+    SELECT COUNT(*) INTO i FROM visibilities WHERE service_offering_id = soid AND platform_id IS NULL AND selector = '' AND id <> visid;
+    IF (i > 0) THEN
+        RETURN false;
+    END IF;
+
+    IF (pid IS NULL AND sel = '') THEN
+        SELECT COUNT(*) INTO i FROM visibilities WHERE service_offering_id = soid AND (platform_id IS NOT NULL OR selector <> '') AND id <> visid;
+        IF (i > 0) THEN
+            RETURN false;
+        END IF;
+    END IF;
+
+    RETURN true;
+END
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE visibilities ADD CONSTRAINT unique_public_offering_visibility CHECK (check_unique_public_offering(id, service_offering_id, platform_id, selector));
+
+COMMIT;
\ No newline at end of file
@@ -35,6 +35,9 @@ type Operation struct {
 	Errors        sqlxtypes.JSONText `db:"errors"`
 	CorrelationID sql.NullString     `db:"correlation_id"`
 	ExternalID    sql.NullString     `db:"external_id"`
+
+	BrokerEndpoint sql.NullString     `db:"broker_endpoint"`
+	Fulfillment    sqlxtypes.JSONText `db:"fulfillment"`
 }
 
 func (o *Operation) ToObject() types.Object {
@@ -53,6 +56,9 @@ func (o *Operation) ToObject() types.Object {
 		Errors:        getJSONRawMessage(o.Errors),
 		CorrelationID: o.CorrelationID.String,
 		ExternalID:    o.ExternalID.String,
+
+		BrokerEndpoint: o.BrokerEndpoint.String,
+		Fulfillment:    getJSONRawMessage(o.Fulfillment),
 	}
 }
 
@@ -77,6 +83,9 @@ func (*Operation) FromObject(object types.Object) (storage.Entity, bool) {
 		Errors:        getJSONText(operation.Errors),
 		CorrelationID: toNullString(operation.CorrelationID),
 		ExternalID:    toNullString(operation.ExternalID),
+
+		BrokerEndpoint: toNullString(operation.BrokerEndpoint),
+		Fulfillment:    getJSONText(operation.Fulfillment),
 	}
 	return o, true
 }
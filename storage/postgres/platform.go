@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/Peripli/service-manager/storage"
+	"github.com/lib/pq"
 
 	"github.com/Peripli/service-manager/pkg/types"
 )
@@ -36,6 +37,14 @@ type Platform struct {
 	Password    string         `db:"password"`
 	Active      bool           `db:"active"`
 	LastActive  time.Time      `db:"last_active"`
+	Suspended   bool           `db:"suspended"`
+
+	PasswordHash sql.NullString `db:"password_hash"`
+
+	PreviousUsername             sql.NullString `db:"previous_username"`
+	PreviousPassword             sql.NullString `db:"previous_password"`
+	PreviousPasswordHash         sql.NullString `db:"previous_password_hash"`
+	PreviousCredentialsExpiresAt pq.NullTime    `db:"previous_credentials_expires_at"`
 }
 
 func (p *Platform) FromObject(object types.Object) (storage.Entity, bool) {
@@ -55,6 +64,7 @@ func (p *Platform) FromObject(object types.Object) (storage.Entity, bool) {
 		Description: toNullString(platform.Description),
 		Active:      platform.Active,
 		LastActive:  platform.LastActive,
+		Suspended:   platform.Suspended,
 	}
 
 	if platform.Description != "" {
@@ -63,12 +73,19 @@ func (p *Platform) FromObject(object types.Object) (storage.Entity, bool) {
 	if platform.Credentials != nil && platform.Credentials.Basic != nil {
 		result.Username = platform.Credentials.Basic.Username
 		result.Password = platform.Credentials.Basic.Password
+		result.PasswordHash = toNullString(platform.Credentials.Basic.PasswordHash)
+	}
+	if platform.Credentials != nil && platform.Credentials.Previous != nil {
+		result.PreviousUsername = toNullString(platform.Credentials.Previous.Username)
+		result.PreviousPassword = toNullString(platform.Credentials.Previous.Password)
+		result.PreviousPasswordHash = toNullString(platform.Credentials.Previous.PasswordHash)
+		result.PreviousCredentialsExpiresAt = pq.NullTime{Time: platform.Credentials.PreviousExpiresAt, Valid: true}
 	}
 	return result, true
 }
 
 func (p *Platform) ToObject() types.Object {
-	return &types.Platform{
+	platform := &types.Platform{
 		Base: types.Base{
 			ID:             p.ID,
 			CreatedAt:      p.CreatedAt,
@@ -80,11 +97,22 @@ func (p *Platform) ToObject() types.Object {
 		Description: p.Description.String,
 		Credentials: &types.Credentials{
 			Basic: &types.Basic{
-				Username: p.Username,
-				Password: p.Password,
+				Username:     p.Username,
+				Password:     p.Password,
+				PasswordHash: p.PasswordHash.String,
 			},
 		},
 		Active:     p.Active,
 		LastActive: p.LastActive,
+		Suspended:  p.Suspended,
+	}
+	if p.PreviousUsername.Valid {
+		platform.Credentials.Previous = &types.Basic{
+			Username:     p.PreviousUsername.String,
+			Password:     p.PreviousPassword.String,
+			PasswordHash: p.PreviousPasswordHash.String,
+		}
+		platform.Credentials.PreviousExpiresAt = p.PreviousCredentialsExpiresAt.Time
 	}
+	return platform
 }
@@ -38,6 +38,7 @@ type ServicePlan struct {
 	Schemas                sqlxtypes.JSONText `db:"schemas"`
 	MaximumPollingDuration int                `db:"maximum_polling_duration"`
 	MaintenanceInfo        sqlxtypes.JSONText `db:"maintenance_info"`
+	DefaultParameters      sqlxtypes.JSONText `db:"default_parameters"`
 
 	ServiceOfferingID string `db:"service_offering_id"`
 }
@@ -61,6 +62,7 @@ func (sp *ServicePlan) ToObject() types.Object {
 		Schemas:                getJSONRawMessage(sp.Schemas),
 		MaximumPollingDuration: sp.MaximumPollingDuration,
 		MaintenanceInfo:        getJSONRawMessage(sp.MaintenanceInfo),
+		DefaultParameters:      getJSONRawMessage(sp.DefaultParameters),
 		ServiceOfferingID:      sp.ServiceOfferingID,
 	}
 }
@@ -88,6 +90,7 @@ func (sp *ServicePlan) FromObject(object types.Object) (storage.Entity, bool) {
 		Schemas:                getJSONText(plan.Schemas),
 		MaximumPollingDuration: plan.MaximumPollingDuration,
 		MaintenanceInfo:        getJSONText(plan.MaintenanceInfo),
+		DefaultParameters:      getJSONText(plan.DefaultParameters),
 		ServiceOfferingID:      plan.ServiceOfferingID,
 	}, true
 }
@@ -19,6 +19,8 @@ package postgres
 import (
 	"database/sql"
 
+	"github.com/lib/pq"
+
 	"github.com/Peripli/service-manager/storage"
 
 	"github.com/Peripli/service-manager/pkg/types"
@@ -27,8 +29,14 @@ import (
 //go:generate smgen storage Visibility github.com/Peripli/service-manager/pkg/types
 type Visibility struct {
 	BaseEntity
-	PlatformID    sql.NullString `db:"platform_id"`
-	ServicePlanID string         `db:"service_plan_id"`
+	PlatformID        sql.NullString `db:"platform_id"`
+	ServicePlanID     sql.NullString `db:"service_plan_id"`
+	ServiceOfferingID sql.NullString `db:"service_offering_id"`
+	ExcludedPlanIDs   pq.StringArray `db:"excluded_plan_ids"`
+	Selector          string         `db:"selector"`
+	ValidFrom         pq.NullTime    `db:"valid_from"`
+	ValidUntil        pq.NullTime    `db:"valid_until"`
+	Active            bool           `db:"active"`
 }
 
 func (v *Visibility) ToObject() types.Object {
@@ -40,8 +48,14 @@ func (v *Visibility) ToObject() types.Object {
 			Labels:         make(map[string][]string),
 			PagingSequence: v.PagingSequence,
 		},
-		PlatformID:    v.PlatformID.String,
-		ServicePlanID: v.ServicePlanID,
+		PlatformID:        v.PlatformID.String,
+		ServicePlanID:     v.ServicePlanID.String,
+		ServiceOfferingID: v.ServiceOfferingID.String,
+		ExcludedPlanIDs:   v.ExcludedPlanIDs,
+		Selector:          v.Selector,
+		ValidFrom:         fromNullTime(v.ValidFrom),
+		ValidUntil:        fromNullTime(v.ValidUntil),
+		Active:            v.Active,
 	}
 }
 
@@ -57,7 +71,13 @@ func (v *Visibility) FromObject(visibility types.Object) (storage.Entity, bool)
 			UpdatedAt:      vis.UpdatedAt,
 			PagingSequence: vis.PagingSequence,
 		},
-		PlatformID:    toNullString(vis.PlatformID),
-		ServicePlanID: vis.ServicePlanID,
+		PlatformID:        toNullString(vis.PlatformID),
+		ServicePlanID:     toNullString(vis.ServicePlanID),
+		ServiceOfferingID: toNullString(vis.ServiceOfferingID),
+		ExcludedPlanIDs:   vis.ExcludedPlanIDs,
+		Selector:          vis.Selector,
+		ValidFrom:         toNullTime(vis.ValidFrom),
+		ValidUntil:        toNullTime(vis.ValidUntil),
+		Active:            vis.Active,
 	}, true
 }
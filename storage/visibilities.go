@@ -0,0 +1,123 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package storage
+
+import (
+	"context"
+
+	"github.com/Peripli/service-manager/pkg/log"
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+)
+
+// VisibilityAppliesTo returns whether the visibility makes its service plan visible to the platform. Public visibilities
+// apply to all platforms and visibilities with a selector apply to the platforms whose labels satisfy the selector.
+// Visibilities apply only while they are active, as the platforms are notified about them when they are activated.
+func VisibilityAppliesTo(ctx context.Context, visibility *types.Visibility, platform *types.Platform) bool {
+	if !visibility.Active {
+		return false
+	}
+	if visibility.IsPublic() {
+		return true
+	}
+	if len(visibility.Selector) == 0 {
+		return visibility.PlatformID == platform.ID
+	}
+	matches, err := query.MatchSelector(visibility.Selector, platform.Labels)
+	if err != nil {
+		log.C(ctx).WithError(err).Errorf("Invalid selector of visibility with id %s", visibility.ID)
+		return false
+	}
+	return matches
+}
+
+// ListPlatformVisibilities returns the visibilities which apply to the platform and satisfy the provided criteria
+func ListPlatformVisibilities(ctx context.Context, repository Repository, platform *types.Platform, criteria ...query.Criterion) ([]*types.Visibility, error) {
+	criteria = append([]query.Criterion{query.ByField(query.EqualsOrNilOperator, "platform_id", platform.ID)}, criteria...)
+	objectList, err := repository.List(ctx, types.VisibilityType, criteria...)
+	if err != nil {
+		return nil, err
+	}
+
+	visibilities := make([]*types.Visibility, 0, objectList.Len())
+	for _, visibility := range objectList.(*types.Visibilities).Visibilities {
+		if VisibilityAppliesTo(ctx, visibility, platform) {
+			visibilities = append(visibilities, visibility)
+		}
+	}
+	return visibilities, nil
+}
+
+// ListPlansVisibilities returns the visibilities which satisfy the provided criteria and are for the plans, either directly
+// or through the service offerings of the plans. The visibilities are mapped by the ids of the plans they are for.
+func ListPlansVisibilities(ctx context.Context, repository Repository, plans []*types.ServicePlan, criteria ...query.Criterion) (map[string][]*types.Visibility, error) {
+	result := make(map[string][]*types.Visibility, len(plans))
+	if len(plans) == 0 {
+		return result, nil
+	}
+
+	planIDs := make([]string, 0, len(plans))
+	offeringIDs := make([]string, 0, len(plans))
+	offeringSet := make(map[string]bool)
+	for _, plan := range plans {
+		planIDs = append(planIDs, plan.ID)
+		if !offeringSet[plan.ServiceOfferingID] {
+			offeringSet[plan.ServiceOfferingID] = true
+			offeringIDs = append(offeringIDs, plan.ServiceOfferingID)
+		}
+	}
+
+	byPlanIDs := append([]query.Criterion{query.ByField(query.InOperator, "service_plan_id", planIDs...)}, criteria...)
+	planVisibilities, err := repository.List(ctx, types.VisibilityType, byPlanIDs...)
+	if err != nil {
+		return nil, err
+	}
+	byOfferingIDs := append([]query.Criterion{query.ByField(query.InOperator, "service_offering_id", offeringIDs...)}, criteria...)
+	offeringVisibilities, err := repository.List(ctx, types.VisibilityType, byOfferingIDs...)
+	if err != nil {
+		return nil, err
+	}
+
+	visibilities := append(planVisibilities.(*types.Visibilities).Visibilities, offeringVisibilities.(*types.Visibilities).Visibilities...)
+	for _, plan := range plans {
+		for _, visibility := range visibilities {
+			if visibility.CoversPlan(plan) {
+				result[plan.ID] = append(result[plan.ID], visibility)
+			}
+		}
+	}
+	return result, nil
+}
+
+// ListPlatformPlansVisibilities returns the visibilities which make the plans visible to the platform, either directly or
+// through the service offerings of the plans. The visibilities are mapped by the ids of the plans they are for.
+func ListPlatformPlansVisibilities(ctx context.Context, repository Repository, platform *types.Platform, plans []*types.ServicePlan) (map[string][]*types.Visibility, error) {
+	plansVisibilities, err := ListPlansVisibilities(ctx, repository, plans, query.ByField(query.EqualsOrNilOperator, "platform_id", platform.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]*types.Visibility, len(plansVisibilities))
+	for planID, visibilities := range plansVisibilities {
+		for _, visibility := range visibilities {
+			if VisibilityAppliesTo(ctx, visibility, platform) {
+				result[planID] = append(result[planID], visibility)
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package storage_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/storage"
+	"github.com/Peripli/service-manager/storage/storagefakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Visibilities", func() {
+	var platform *types.Platform
+
+	BeforeEach(func() {
+		platform = &types.Platform{
+			Base: types.Base{
+				ID:     "platform-id",
+				Labels: types.Labels{"env": {"prod"}},
+			},
+		}
+	})
+
+	Describe("VisibilityAppliesTo", func() {
+		It("applies public visibilities to all platforms", func() {
+			Expect(storage.VisibilityAppliesTo(context.Background(), &types.Visibility{Active: true}, platform)).To(BeTrue())
+		})
+
+		It("applies platform visibilities only to their platform", func() {
+			Expect(storage.VisibilityAppliesTo(context.Background(), &types.Visibility{Active: true, PlatformID: "platform-id"}, platform)).To(BeTrue())
+			Expect(storage.VisibilityAppliesTo(context.Background(), &types.Visibility{Active: true, PlatformID: "other-id"}, platform)).To(BeFalse())
+		})
+
+		It("applies visibilities with selectors to the platforms which satisfy them", func() {
+			Expect(storage.VisibilityAppliesTo(context.Background(), &types.Visibility{Active: true, Selector: "env eq 'prod'"}, platform)).To(BeTrue())
+			Expect(storage.VisibilityAppliesTo(context.Background(), &types.Visibility{Active: true, Selector: "env eq 'dev'"}, platform)).To(BeFalse())
+		})
+
+		It("does not apply visibilities with invalid selectors", func() {
+			Expect(storage.VisibilityAppliesTo(context.Background(), &types.Visibility{Active: true, Selector: "env is 'prod'"}, platform)).To(BeFalse())
+		})
+
+		It("applies only active visibilities", func() {
+			future := time.Now().Add(time.Hour)
+			Expect(storage.VisibilityAppliesTo(context.Background(), &types.Visibility{Active: false}, platform)).To(BeFalse())
+			// the activation state is changed by the visibility scheduler, so the validity period itself is not checked
+			Expect(storage.VisibilityAppliesTo(context.Background(), &types.Visibility{Active: true, ValidFrom: &future}, platform)).To(BeTrue())
+		})
+	})
+
+	Describe("ListPlatformVisibilities", func() {
+		It("returns the visibilities which apply to the platform", func() {
+			fakeStorage := &storagefakes.FakeStorage{}
+			fakeStorage.ListReturns(&types.Visibilities{
+				Visibilities: []*types.Visibility{
+					{Base: types.Base{ID: "public"}, Active: true},
+					{Base: types.Base{ID: "platform"}, Active: true, PlatformID: "platform-id"},
+					{Base: types.Base{ID: "selected"}, Active: true, Selector: "env in ('prod','test')"},
+					{Base: types.Base{ID: "not-selected"}, Active: true, Selector: "env eq 'dev'"},
+				},
+			}, nil)
+
+			visibilities, err := storage.ListPlatformVisibilities(context.Background(), fakeStorage, platform,
+				query.ByField(query.EqualsOperator, "service_plan_id", "plan-id"))
+			Expect(err).ToNot(HaveOccurred())
+			ids := make([]string, 0)
+			for _, visibility := range visibilities {
+				ids = append(ids, visibility.ID)
+			}
+			Expect(ids).To(ConsistOf("public", "platform", "selected"))
+
+			_, objectType, criteria := fakeStorage.ListArgsForCall(0)
+			Expect(objectType).To(Equal(types.VisibilityType))
+			Expect(criteria).To(HaveLen(2))
+			Expect(criteria[0].LeftOp).To(Equal("platform_id"))
+		})
+	})
+
+	Describe("ListPlatformPlansVisibilities", func() {
+		It("maps the visibilities of the plans and of their service offerings to the plans", func() {
+			plans := []*types.ServicePlan{
+				{Base: types.Base{ID: "plan-1"}, ServiceOfferingID: "offering-id"},
+				{Base: types.Base{ID: "plan-2"}, ServiceOfferingID: "offering-id"},
+			}
+			fakeStorage := &storagefakes.FakeStorage{}
+			fakeStorage.ListReturnsOnCall(0, &types.Visibilities{
+				Visibilities: []*types.Visibility{
+					{Base: types.Base{ID: "plan-visibility"}, Active: true, PlatformID: "platform-id", ServicePlanID: "plan-1"},
+				},
+			}, nil)
+			fakeStorage.ListReturnsOnCall(1, &types.Visibilities{
+				Visibilities: []*types.Visibility{
+					{Base: types.Base{ID: "offering-visibility"}, Active: true, ServiceOfferingID: "offering-id"},
+					{Base: types.Base{ID: "excluding-visibility"}, Active: true, ServiceOfferingID: "offering-id", ExcludedPlanIDs: []string{"plan-1"}},
+					{Base: types.Base{ID: "not-selected"}, Active: true, ServiceOfferingID: "offering-id", Selector: "env eq 'dev'"},
+				},
+			}, nil)
+
+			plansVisibilities, err := storage.ListPlatformPlansVisibilities(context.Background(), fakeStorage, platform, plans)
+			Expect(err).ToNot(HaveOccurred())
+			ids := func(visibilities []*types.Visibility) []string {
+				result := make([]string, 0)
+				for _, visibility := range visibilities {
+					result = append(result, visibility.ID)
+				}
+				return result
+			}
+			Expect(plansVisibilities).To(HaveLen(2))
+			Expect(ids(plansVisibilities["plan-1"])).To(ConsistOf("plan-visibility", "offering-visibility"))
+			Expect(ids(plansVisibilities["plan-2"])).To(ConsistOf("offering-visibility", "excluding-visibility"))
+
+			_, _, criteria := fakeStorage.ListArgsForCall(0)
+			Expect(criteria[0].LeftOp).To(Equal("service_plan_id"))
+			Expect(criteria[0].RightOp).To(ConsistOf("plan-1", "plan-2"))
+			_, _, criteria = fakeStorage.ListArgsForCall(1)
+			Expect(criteria[0].LeftOp).To(Equal("service_offering_id"))
+			Expect(criteria[0].RightOp).To(ConsistOf("offering-id"))
+			Expect(criteria[1].LeftOp).To(Equal("platform_id"))
+		})
+	})
+})
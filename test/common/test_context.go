@@ -76,6 +76,7 @@ type TestContextBuilder struct {
 type TestContext struct {
 	wg            *sync.WaitGroup
 	wsConnections []*websocket.Conn
+	eventStreams  []*http.Response
 
 	SM          *SMExpect
 	SMWithOAuth *SMExpect
@@ -561,6 +562,11 @@ func (ctx *TestContext) CleanupAdditionalResources() {
 		conn.Close()
 	}
 	ctx.wsConnections = nil
+
+	for _, stream := range ctx.eventStreams {
+		stream.Body.Close()
+	}
+	ctx.eventStreams = nil
 }
 
 func (ctx *TestContext) ConnectWebSocket(platform *types.Platform, queryParams map[string]string) (*websocket.Conn, *http.Response, error) {
@@ -586,6 +592,34 @@ func (ctx *TestContext) ConnectWebSocket(platform *types.Platform, queryParams m
 	return conn, resp, err
 }
 
+// ConnectEventStream requests the notifications of the platform as server-sent events
+func (ctx *TestContext) ConnectEventStream(platform *types.Platform, queryParams map[string]string, headers map[string]string) (*http.Response, error) {
+	smEndpoint, _ := url.Parse(ctx.Servers[SMServer].URL())
+	smEndpoint.Path = web.NotificationsURL
+	q := smEndpoint.Query()
+	for k, v := range queryParams {
+		q.Add(k, v)
+	}
+	smEndpoint.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, smEndpoint.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(platform.Credentials.Basic.Username, platform.Credentials.Basic.Password)
+	req.Header.Set("Accept", "text/event-stream")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	ctx.eventStreams = append(ctx.eventStreams, resp)
+	return resp, nil
+}
+
 func (ctx *TestContext) CloseWebSocket(conn *websocket.Conn) {
 	if conn == nil {
 		return
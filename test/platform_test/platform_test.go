@@ -18,6 +18,7 @@ package platform_test
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"testing"
 
@@ -350,6 +351,136 @@ var _ = test.DescribeTestsFor(test.TestCase{
 				})
 			})
 
+			Describe("POST credentials rotate", func() {
+				var platform common.Object
+
+				BeforeEach(func() {
+					platform = ctx.SMWithOAuth.POST(web.PlatformsURL).
+						WithJSON(common.GenerateRandomPlatform()).
+						Expect().
+						Status(http.StatusCreated).JSON().Object().Raw()
+				})
+
+				basicCredentials := func(platform common.Object) (string, string) {
+					basic := platform["credentials"].(map[string]interface{})["basic"].(map[string]interface{})
+					return basic["username"].(string), basic["password"].(string)
+				}
+
+				It("returns new credentials and keeps the previous credentials valid", func() {
+					rotated := ctx.SMWithOAuth.POST(web.PlatformsURL + "/" + platform["id"].(string) + web.RotateCredentialsURL).
+						Expect().
+						Status(http.StatusOK).JSON().Object().Raw()
+
+					username, password := basicCredentials(platform)
+					newUsername, newPassword := basicCredentials(rotated)
+					Expect(newUsername).ToNot(Equal(username))
+					Expect(newPassword).ToNot(Equal(password))
+
+					ctx.SM.GET(web.ServiceOfferingsURL).WithBasicAuth(username, password).
+						Expect().
+						Status(http.StatusOK)
+					ctx.SM.GET(web.ServiceOfferingsURL).WithBasicAuth(newUsername, newPassword).
+						Expect().
+						Status(http.StatusOK)
+				})
+
+				It("returns 404 for missing platforms", func() {
+					ctx.SMWithOAuth.POST(web.PlatformsURL + "/123" + web.RotateCredentialsURL).
+						Expect().
+						Status(http.StatusNotFound)
+				})
+			})
+
+			Describe("suspended flag", func() {
+				It("is ignored on create and update", func() {
+					platformJSON := common.GenerateRandomPlatform()
+					platformJSON["suspended"] = true
+					platform := ctx.SMWithOAuth.POST(web.PlatformsURL).
+						WithJSON(platformJSON).
+						Expect().
+						Status(http.StatusCreated).JSON().Object()
+					platform.ValueEqual("suspended", false)
+
+					ctx.SMWithOAuth.PATCH(web.PlatformsURL+"/"+platform.Value("id").String().Raw()).
+						WithJSON(common.Object{"suspended": true}).
+						Expect().
+						Status(http.StatusOK).JSON().Object().ValueEqual("suspended", false)
+				})
+			})
+
+			Describe("GET inactive", func() {
+				It("returns the platforms which have never connected", func() {
+					platform := ctx.SMWithOAuth.POST(web.PlatformsURL).
+						WithJSON(common.GenerateRandomPlatform()).
+						Expect().
+						Status(http.StatusCreated).JSON().Object().Raw()
+
+					items := ctx.SMWithOAuth.GET(web.InactivePlatformsURL).
+						WithQuery("fieldQuery", fmt.Sprintf("id eq '%s'", platform["id"])).
+						Expect().
+						Status(http.StatusOK).JSON().Object().Value("items").Array()
+					items.Length().Equal(1)
+					items.First().Object().ContainsMap(map[string]interface{}{
+						"id":              platform["id"],
+						"name":            platform["name"],
+						"suspended":       false,
+						"never_connected": true,
+					})
+				})
+
+				It("supports field queries", func() {
+					ctx.SMWithOAuth.POST(web.PlatformsURL).
+						WithJSON(common.GenerateRandomPlatform()).
+						Expect().
+						Status(http.StatusCreated)
+
+					ctx.SMWithOAuth.GET(web.InactivePlatformsURL).
+						WithQuery("fieldQuery", "name eq 'missing'").
+						Expect().
+						Status(http.StatusOK).JSON().Object().Value("items").Array().Empty()
+				})
+			})
+
+			Describe("GET marketplace", func() {
+				It("returns the plans visible to the platform with the visibilities which grant them", func() {
+					platformJSON := common.GenerateRandomPlatform()
+					platformJSON["type"] = types.K8sPlatformType
+					platform := common.RegisterPlatformInSM(platformJSON, ctx.SMWithOAuth, map[string]string{})
+					catalog := common.NewEmptySBCatalog()
+					catalog.AddService(common.GenerateTestServiceWithPlans(common.GeneratePaidTestPlan()))
+					brokerID, _, _ := ctx.RegisterBrokerWithCatalog(catalog)
+					offeringID := ctx.SMWithOAuth.ListWithQuery(web.ServiceOfferingsURL, fmt.Sprintf("fieldQuery=broker_id eq '%s'", brokerID)).
+						First().Object().Value("id").String().Raw()
+					planID := ctx.SMWithOAuth.ListWithQuery(web.ServicePlansURL, fmt.Sprintf("fieldQuery=service_offering_id eq '%s'", offeringID)).
+						First().Object().Value("id").String().Raw()
+					visibilityID := ctx.SMWithOAuth.POST(web.VisibilitiesURL).
+						WithJSON(common.Object{"platform_id": platform.ID, "service_plan_id": planID}).
+						Expect().
+						Status(http.StatusCreated).JSON().Object().Value("id").String().Raw()
+
+					marketplace := ctx.SMWithOAuth.GET(fmt.Sprintf("%s/%s%s", web.PlatformsURL, platform.ID, web.PlatformMarketplaceURL)).
+						Expect().
+						Status(http.StatusOK).JSON().Object()
+					marketplace.ValueEqual("platform_id", platform.ID)
+					var grantedBy map[string]interface{}
+					for _, service := range marketplace.Value("services").Array().Iter() {
+						for _, plan := range service.Object().Value("plans").Array().Iter() {
+							if plan.Object().Value("id").Raw() == planID {
+								grantedBy = plan.Object().Value("granted_by").Object().Raw()
+							}
+						}
+					}
+					Expect(grantedBy).To(HaveKeyWithValue("reason", "visibility"))
+					Expect(grantedBy["visibility"]).To(HaveKeyWithValue("id", visibilityID))
+				})
+
+				It("returns 404 for a missing platform", func() {
+					ctx.SMWithOAuth.GET(fmt.Sprintf("%s/%s%s", web.PlatformsURL, "missing", web.PlatformMarketplaceURL)).
+						Expect().
+						Status(http.StatusNotFound)
+				})
+			})
+
 			Describe("DELETE", func() {
 				const platformID = "p1"
 				var platform common.Object
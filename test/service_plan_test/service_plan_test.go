@@ -501,6 +501,98 @@ var _ = test.DescribeTestsFor(test.TestCase{
 					})
 				})
 			})
+
+			Describe("PUT visibilities", func() {
+				var planID string
+				var platformID1, platformID2 string
+
+				syncVisibilities := func(visibilities ...common.Object) *httpexpect.Object {
+					return ctx.SMWithOAuth.PUT(fmt.Sprintf("%s/%s%s", web.ServicePlansURL, planID, web.PlanVisibilitiesURL)).
+						WithJSON(common.Object{"visibilities": visibilities}).
+						Expect().
+						Status(http.StatusOK).JSON().Object()
+				}
+
+				BeforeEach(func() {
+					planID = blueprint(ctx, ctx.SMWithOAuth, false)["id"].(string)
+					platformID1 = common.RegisterPlatformInSM(common.GenerateRandomPlatform(), ctx.SMWithOAuth, map[string]string{}).ID
+					platformID2 = common.RegisterPlatformInSM(common.GenerateRandomPlatform(), ctx.SMWithOAuth, map[string]string{}).ID
+
+					ctx.SMWithOAuth.POST(web.VisibilitiesURL).
+						WithJSON(common.Object{
+							"platform_id":     platformID1,
+							"service_plan_id": planID,
+							"labels":          common.Object{"org": common.Array{"org1"}},
+						}).
+						Expect().
+						Status(http.StatusCreated)
+				})
+
+				AfterEach(func() {
+					ctx.CleanupAdditionalResources()
+				})
+
+				It("should apply only the differences to the visibilities of the plan", func() {
+					desired := []common.Object{
+						{"platform_id": platformID1, "labels": common.Object{"org": common.Array{"org2"}}},
+						{"platform_id": platformID2},
+						{"selector": "env eq 'dev'"},
+					}
+
+					changes := syncVisibilities(desired...)
+					changes.Value("created").Array().Length().Equal(2)
+					changes.Value("modified").Array().Length().Equal(1)
+					changes.Value("modified").Array().First().Object().
+						ContainsMap(common.Object{"platform_id": platformID1}).
+						Path("$.labels.org").Array().ContainsOnly("org2")
+					changes.Value("deleted").Array().Empty()
+
+					ctx.SMWithOAuth.ListWithQuery(web.VisibilitiesURL, fmt.Sprintf("fieldQuery=service_plan_id eq '%s'", planID)).
+						Length().Equal(3)
+
+					By("syncing the same visibilities again")
+					changes = syncVisibilities(desired...)
+					changes.Value("created").Array().Empty()
+					changes.Value("modified").Array().Empty()
+					changes.Value("deleted").Array().Empty()
+
+					By("syncing a public visibility")
+					changes = syncVisibilities(common.Object{})
+					changes.Value("created").Array().Length().Equal(1)
+					changes.Value("deleted").Array().Length().Equal(3)
+
+					ctx.SMWithOAuth.ListWithQuery(web.VisibilitiesURL, fmt.Sprintf("fieldQuery=service_plan_id eq '%s'", planID)).
+						Element(0).Object().Value("platform_id").Equal("")
+				})
+
+				Context("when the plan does not exist", func() {
+					It("should return 404", func() {
+						ctx.SMWithOAuth.PUT(fmt.Sprintf("%s/%s%s", web.ServicePlansURL, "non-existing-id", web.PlanVisibilitiesURL)).
+							WithJSON(common.Object{"visibilities": common.Array{}}).
+							Expect().
+							Status(http.StatusNotFound)
+					})
+				})
+
+				Context("when the visibilities are not valid", func() {
+					It("should return 400 and not change the visibilities of the plan", func() {
+						for _, visibilities := range []common.Array{
+							{common.Object{"platform_id": platformID2}, common.Object{"platform_id": platformID2}},
+							{common.Object{"platform_id": platformID2}, common.Object{}},
+							{common.Object{"platform_id": platformID2, "selector": "env eq 'dev'"}},
+							{common.Object{"selector": "invalid selector"}},
+						} {
+							ctx.SMWithOAuth.PUT(fmt.Sprintf("%s/%s%s", web.ServicePlansURL, planID, web.PlanVisibilitiesURL)).
+								WithJSON(common.Object{"visibilities": visibilities}).
+								Expect().
+								Status(http.StatusBadRequest)
+						}
+
+						ctx.SMWithOAuth.ListWithQuery(web.VisibilitiesURL, fmt.Sprintf("fieldQuery=service_plan_id eq '%s'", planID)).
+							Element(0).Object().Value("platform_id").Equal(platformID1)
+					})
+				})
+			})
 		})
 	},
 })
@@ -17,9 +17,11 @@
 package visibility_test
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/Peripli/service-manager/pkg/web"
 
@@ -31,6 +33,7 @@ import (
 	"github.com/Peripli/service-manager/test/common"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/tidwall/gjson"
 )
 
 func TestVisibilities(t *testing.T) {
@@ -240,6 +243,237 @@ var _ = test.DescribeTestsFor(test.TestCase{
 						})
 					})
 				})
+				Context("with selector", func() {
+					It("returns 201 for valid selectors", func() {
+						visibility := common.Object{
+							"service_plan_id": existingPlanIDs[0],
+							"selector":        "env eq 'prod' and region in ('eu','us')",
+						}
+						ctx.SMWithOAuth.POST(web.VisibilitiesURL).
+							WithJSON(visibility).
+							Expect().Status(http.StatusCreated).JSON().Object().ContainsMap(visibility)
+					})
+
+					It("allows multiple selectors for the same plan", func() {
+						for _, selector := range []string{"env eq 'prod'", "env eq 'dev'"} {
+							ctx.SMWithOAuth.POST(web.VisibilitiesURL).
+								WithJSON(common.Object{
+									"service_plan_id": existingPlanIDs[0],
+									"selector":        selector,
+								}).
+								Expect().Status(http.StatusCreated)
+						}
+					})
+
+					It("returns 400 for invalid selectors", func() {
+						ctx.SMWithOAuth.POST(web.VisibilitiesURL).
+							WithJSON(common.Object{
+								"service_plan_id": existingPlanIDs[0],
+								"selector":        "env equals 'prod'",
+							}).
+							Expect().Status(http.StatusBadRequest).JSON().Object().Keys().Contains("error", "description")
+					})
+
+					It("returns 400 if platform id is also specified", func() {
+						ctx.SMWithOAuth.POST(web.VisibilitiesURL).
+							WithJSON(common.Object{
+								"service_plan_id": existingPlanIDs[0],
+								"platform_id":     existingPlatformID,
+								"selector":        "env eq 'prod'",
+							}).
+							Expect().Status(http.StatusBadRequest).JSON().Object().Keys().Contains("error", "description")
+					})
+
+					It("returns 400 if a public visibility for the plan exists", func() {
+						ctx.SMWithOAuth.POST(web.VisibilitiesURL).
+							WithJSON(common.Object{
+								"service_plan_id": existingPlanIDs[0],
+							}).
+							Expect().Status(http.StatusCreated)
+
+						ctx.SMWithOAuth.POST(web.VisibilitiesURL).
+							WithJSON(common.Object{
+								"service_plan_id": existingPlanIDs[0],
+								"selector":        "env eq 'prod'",
+							}).
+							Expect().Status(http.StatusBadRequest).JSON().Object().Keys().Contains("error", "description")
+					})
+				})
+
+				Context("with validity period", func() {
+					past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+					future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+					It("is active if created during its validity period", func() {
+						postVisibilityRequestNoLabels["valid_from"] = past
+						postVisibilityRequestNoLabels["valid_until"] = future
+						id := ctx.SMWithOAuth.POST(web.VisibilitiesURL).
+							WithJSON(postVisibilityRequestNoLabels).
+							Expect().Status(http.StatusCreated).JSON().Object().
+							ValueEqual("active", true).
+							Value("id").String().Raw()
+
+						ctx.SMWithBasic.List(web.VisibilitiesURL).Path("$[*].id").Array().Contains(id)
+					})
+
+					It("is not active and not visible to the platform before its validity period", func() {
+						postVisibilityRequestNoLabels["valid_from"] = future
+						id := ctx.SMWithOAuth.POST(web.VisibilitiesURL).
+							WithJSON(postVisibilityRequestNoLabels).
+							Expect().Status(http.StatusCreated).JSON().Object().
+							ValueEqual("active", false).
+							Value("id").String().Raw()
+
+						ctx.SMWithBasic.List(web.VisibilitiesURL).Path("$[*].id").Array().NotContains(id)
+					})
+
+					It("returns 400 if valid until is not after valid from", func() {
+						postVisibilityRequestNoLabels["valid_from"] = future
+						postVisibilityRequestNoLabels["valid_until"] = past
+						ctx.SMWithOAuth.POST(web.VisibilitiesURL).
+							WithJSON(postVisibilityRequestNoLabels).
+							Expect().Status(http.StatusBadRequest).JSON().Object().Keys().Contains("error", "description")
+					})
+				})
+				Context("for service offering", func() {
+					var (
+						brokerServer *common.BrokerServer
+						brokerID     string
+						offeringID   string
+						planIDs      []string
+					)
+
+					visibilityNotificationPlanIDs := func(visibilityID string) []string {
+						objectList, err := ctx.SMRepository.List(context.Background(), types.NotificationType,
+							query.ByField(query.EqualsOperator, "resource", string(types.VisibilityType)),
+							query.ByField(query.EqualsOperator, "type", string(types.CREATED)),
+							query.ByField(query.EqualsOperator, "platform_id", existingPlatformID))
+						Expect(err).ToNot(HaveOccurred())
+						result := make([]string, 0)
+						for _, notification := range objectList.(*types.Notifications).Notifications {
+							planID := gjson.GetBytes(notification.Payload, "new.resource.service_plan_id").String()
+							if gjson.GetBytes(notification.Payload, "new.resource.id").String() == visibilityID+":"+planID {
+								result = append(result, planID)
+							}
+						}
+						return result
+					}
+
+					BeforeEach(func() {
+						brokerID, _, brokerServer = ctx.RegisterBrokerWithCatalog(common.NewRandomSBCatalog())
+						offeringID = ctx.SMWithOAuth.ListWithQuery(web.ServiceOfferingsURL, fmt.Sprintf("fieldQuery=broker_id eq '%s'", brokerID)).
+							First().Object().Value("id").String().Raw()
+						planIDs = make([]string, 0)
+						for _, plan := range ctx.SMWithOAuth.ListWithQuery(web.ServicePlansURL, fmt.Sprintf("fieldQuery=service_offering_id eq '%s'", offeringID)).Iter() {
+							planIDs = append(planIDs, plan.Object().Value("id").String().Raw())
+						}
+						Expect(planIDs).To(HaveLen(3))
+					})
+
+					It("applies to the plans of the service offering which are not excluded", func() {
+						visibilityID := ctx.SMWithOAuth.POST(web.VisibilitiesURL).
+							WithJSON(common.Object{
+								"platform_id":         existingPlatformID,
+								"service_offering_id": offeringID,
+								"excluded_plan_ids":   common.Array{planIDs[0]},
+							}).
+							Expect().Status(http.StatusCreated).JSON().Object().
+							ValueEqual("service_offering_id", offeringID).
+							Value("id").String().Raw()
+
+						Expect(visibilityNotificationPlanIDs(visibilityID)).To(ConsistOf(planIDs[1], planIDs[2]))
+
+						platformPlanIDs := make([]string, 0)
+						for _, visibility := range ctx.SMWithBasic.List(web.VisibilitiesURL).Iter() {
+							planID := visibility.Object().Value("service_plan_id").String().Raw()
+							if visibility.Object().Value("id").String().Raw() == visibilityID+":"+planID {
+								platformPlanIDs = append(platformPlanIDs, planID)
+							}
+						}
+						Expect(platformPlanIDs).To(ConsistOf(planIDs[1], planIDs[2]))
+					})
+
+					It("applies to plans added to the service offering", func() {
+						visibilityID := ctx.SMWithOAuth.POST(web.VisibilitiesURL).
+							WithJSON(common.Object{
+								"platform_id":         existingPlatformID,
+								"service_offering_id": offeringID,
+							}).
+							Expect().Status(http.StatusCreated).JSON().Object().
+							Value("id").String().Raw()
+
+						brokerServer.Catalog.AddPlanToService(common.GeneratePaidTestPlan(), 0)
+						ctx.SMWithOAuth.PATCH(web.ServiceBrokersURL + "/" + brokerID).
+							WithJSON(common.Object{}).
+							Expect().Status(http.StatusOK)
+
+						newPlanID := ""
+						for _, plan := range ctx.SMWithOAuth.ListWithQuery(web.ServicePlansURL, fmt.Sprintf("fieldQuery=service_offering_id eq '%s'", offeringID)).Iter() {
+							id := plan.Object().Value("id").String().Raw()
+							if id != planIDs[0] && id != planIDs[1] && id != planIDs[2] {
+								newPlanID = id
+							}
+						}
+						Expect(newPlanID).ToNot(BeEmpty())
+						Expect(visibilityNotificationPlanIDs(visibilityID)).To(ConsistOf(append(planIDs, newPlanID)))
+					})
+
+					It("returns 400 if service plan id is also specified", func() {
+						ctx.SMWithOAuth.POST(web.VisibilitiesURL).
+							WithJSON(common.Object{
+								"platform_id":         existingPlatformID,
+								"service_plan_id":     planIDs[0],
+								"service_offering_id": offeringID,
+							}).
+							Expect().Status(http.StatusBadRequest).JSON().Object().Keys().Contains("error", "description")
+					})
+
+					It("returns 400 if plans are excluded from a plan visibility", func() {
+						ctx.SMWithOAuth.POST(web.VisibilitiesURL).
+							WithJSON(common.Object{
+								"platform_id":       existingPlatformID,
+								"service_plan_id":   planIDs[0],
+								"excluded_plan_ids": common.Array{planIDs[1]},
+							}).
+							Expect().Status(http.StatusBadRequest).JSON().Object().Keys().Contains("error", "description")
+					})
+
+					It("returns 400 if the excluded plans are not plans of the service offering", func() {
+						ctx.SMWithOAuth.POST(web.VisibilitiesURL).
+							WithJSON(common.Object{
+								"platform_id":         existingPlatformID,
+								"service_offering_id": offeringID,
+								"excluded_plan_ids":   common.Array{"unknown-plan-id"},
+							}).
+							Expect().Status(http.StatusBadRequest).JSON().Object().Keys().Contains("error", "description")
+					})
+
+					It("returns 409 if a visibility for the platform and service offering already exists", func() {
+						visibility := common.Object{
+							"platform_id":         existingPlatformID,
+							"service_offering_id": offeringID,
+						}
+						ctx.SMWithOAuth.POST(web.VisibilitiesURL).WithJSON(visibility).
+							Expect().Status(http.StatusCreated)
+						ctx.SMWithOAuth.POST(web.VisibilitiesURL).WithJSON(visibility).
+							Expect().Status(http.StatusConflict)
+					})
+
+					It("returns 400 if a public visibility for the service offering is created next to a platform one", func() {
+						ctx.SMWithOAuth.POST(web.VisibilitiesURL).
+							WithJSON(common.Object{
+								"platform_id":         existingPlatformID,
+								"service_offering_id": offeringID,
+							}).
+							Expect().Status(http.StatusCreated)
+						ctx.SMWithOAuth.POST(web.VisibilitiesURL).
+							WithJSON(common.Object{
+								"service_offering_id": offeringID,
+							}).
+							Expect().Status(http.StatusBadRequest)
+					})
+				})
+
 				Context("Labelled", func() {
 					Context("When labels are valid", func() {
 						It("should return 201", func() {
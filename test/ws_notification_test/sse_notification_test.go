@@ -0,0 +1,227 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package ws_notification_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Peripli/service-manager/api/notifications"
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/storage"
+	"github.com/Peripli/service-manager/test/common"
+	"github.com/spf13/pflag"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var heartbeatInterval = 1 * time.Second
+
+type event struct {
+	ID         string
+	Data       string
+	IsComment  bool
+	CommentMsg string
+}
+
+var _ = Describe("SSE", func() {
+	var ctx *common.TestContext
+	var repository storage.Repository
+	var platform *types.Platform
+	var resp *http.Response
+	var reader *bufio.Reader
+	queryParams := map[string]string{}
+	headers := map[string]string{}
+
+	readEvent := func() event {
+		result := event{}
+		for {
+			line, err := reader.ReadString('\n')
+			Expect(err).ShouldNot(HaveOccurred())
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "":
+				return result
+			case strings.HasPrefix(line, ":"):
+				result.IsComment = true
+				result.CommentMsg = strings.TrimSpace(strings.TrimPrefix(line, ":"))
+			case strings.HasPrefix(line, "id: "):
+				result.ID = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				result.Data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}
+
+	expectNotificationEvent := func(notification *types.Notification) {
+		e := readEvent()
+		for e.IsComment {
+			e = readEvent()
+		}
+		Expect(e.ID).To(Equal(strconv.FormatInt(notification.Revision, 10)))
+		var data map[string]interface{}
+		Expect(json.Unmarshal([]byte(e.Data), &data)).ShouldNot(HaveOccurred())
+		Expect(data["id"]).To(Equal(notification.ID))
+		Expect(data["platform_id"]).To(Equal(notification.PlatformID))
+	}
+
+	BeforeEach(func() {
+		queryParams = map[string]string{}
+		headers = map[string]string{}
+
+		ctx = common.NewTestContextBuilderWithSecurity().
+			WithEnvPreExtensions(func(set *pflag.FlagSet) {
+				Expect(set.Set("websocket.heartbeat_interval", heartbeatInterval.String())).ShouldNot(HaveOccurred())
+			}).Build()
+		repository = ctx.SMRepository
+		Expect(repository).ToNot(BeNil())
+
+		platform = common.RegisterPlatformInSM(common.GenerateRandomPlatform(), ctx.SMWithOAuth, map[string]string{})
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		resp, err = ctx.ConnectEventStream(platform, queryParams, headers)
+		Expect(err).ShouldNot(HaveOccurred())
+		reader = bufio.NewReader(resp.Body)
+	})
+
+	AfterEach(func() {
+		if repository != nil {
+			err := repository.Delete(context.Background(), types.NotificationType)
+			if err != nil {
+				Expect(err).To(Equal(util.ErrNotFoundInStorage))
+			}
+		}
+		ctx.Cleanup()
+	})
+
+	Context("when event stream is requested", func() {
+		It("should respond with an event stream", func() {
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get("Content-Type")).To(Equal(notifications.EventStreamContentType))
+			Expect(resp.Header.Get(notifications.HeartbeatPeriodHeader)).To(Equal(heartbeatInterval.String()))
+		})
+
+		It("should send new notifications as events with their revisions as ids", func() {
+			notification := createNotification(repository, platform.ID)
+			expectNotificationEvent(notification)
+		})
+
+		It("should send heartbeats", func(done Done) {
+			e := readEvent()
+			Expect(e.IsComment).To(BeTrue())
+			Expect(e.CommentMsg).To(Equal("heartbeat"))
+			close(done)
+		}, heartbeatInterval.Seconds()+2)
+	})
+
+	Context("when notifications are created prior to connection", func() {
+		var notification *types.Notification
+		BeforeEach(func() {
+			notification = createNotification(repository, platform.ID)
+		})
+
+		It("should receive last known revision response header", func() {
+			lastKnownRevision, err := strconv.ParseInt(resp.Header.Get(notifications.LastKnownRevisionHeader), 10, 64)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(lastKnownRevision).To(BeNumerically(">=", notification.Revision))
+		})
+
+		Context("and proxy knows some notification revision from query parameter", func() {
+			var notification2 *types.Notification
+			BeforeEach(func() {
+				notification2 = createNotification(repository, platform.ID)
+				queryParams[notifications.LastKnownRevisionQueryParam] = strconv.FormatInt(notification.Revision, 10)
+			})
+
+			It("should receive only these after the revision that it knowns", func() {
+				expectNotificationEvent(notification2)
+			})
+		})
+
+		Context("and proxy reconnects with last event id", func() {
+			var notification2 *types.Notification
+			BeforeEach(func() {
+				notification2 = createNotification(repository, platform.ID)
+				queryParams[notifications.LastKnownRevisionQueryParam] = strconv.FormatInt(notification.Revision-1, 10)
+				headers[notifications.LastEventIDHeader] = strconv.FormatInt(notification.Revision, 10)
+			})
+
+			It("should resume after the last event id", func() {
+				expectNotificationEvent(notification2)
+			})
+		})
+
+		Context("and last event id is not known to sm anymore", func() {
+			BeforeEach(func() {
+				headers[notifications.LastEventIDHeader] = strconv.FormatInt(notification.Revision-1, 10)
+			})
+
+			It("should receive 410 Gone", func() {
+				Expect(resp.StatusCode).To(Equal(http.StatusGone))
+			})
+		})
+	})
+
+	Context("when last event id is invalid number", func() {
+		BeforeEach(func() {
+			headers[notifications.LastEventIDHeader] = "not_a_number"
+		})
+
+		It("should return status 400", func() {
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Context("platform health", func() {
+		var idCriteria query.Criterion
+
+		getPlatform := func() *types.Platform {
+			obj, err := repository.Get(context.TODO(), types.PlatformType, idCriteria)
+			Expect(err).ShouldNot(HaveOccurred())
+			return obj.(*types.Platform)
+		}
+
+		BeforeEach(func() {
+			Expect(platform.Active).To(BeFalse())
+			idCriteria = query.ByField(query.EqualsOperator, "id", platform.ID)
+		})
+
+		It("should switch platform's active status to true when connected", func() {
+			Eventually(func() bool { return getPlatform().Active }).Should(BeTrue())
+		})
+
+		It("should switch platform's active status to false when disconnected", func() {
+			Eventually(func() bool { return getPlatform().Active }).Should(BeTrue())
+
+			Expect(resp.Body.Close()).ShouldNot(HaveOccurred())
+			Eventually(func() bool {
+				p := getPlatform()
+				return !p.Active && !p.LastActive.IsZero()
+			}, heartbeatInterval*3).Should(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,42 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package visibilities
+
+import (
+	"fmt"
+	"time"
+)
+
+// Settings type to be loaded from the environment
+type Settings struct {
+	ActivationInterval time.Duration `mapstructure:"activation_interval" description:"interval for activating and expiring the visibilities with validity period"`
+}
+
+// DefaultSettings returns default values for visibilities settings
+func DefaultSettings() *Settings {
+	return &Settings{
+		ActivationInterval: time.Minute,
+	}
+}
+
+// Validate validates the visibilities settings
+func (s *Settings) Validate() error {
+	if s.ActivationInterval <= 0 {
+		return fmt.Errorf("validate Settings: ActivationInterval must be larger than 0")
+	}
+	return nil
+}
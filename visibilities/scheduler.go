@@ -0,0 +1,165 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package visibilities
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Peripli/service-manager/pkg/log"
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/pkg/util"
+	"github.com/Peripli/service-manager/storage"
+	"github.com/Peripli/service-manager/storage/interceptors"
+)
+
+// errAlreadyScheduled is returned if the visibility has been activated or expired concurrently
+var errAlreadyScheduled = errors.New("visibility has already been activated or expired")
+
+// Scheduler activates the visibilities when their validity period starts and expires them when it ends.
+// The platforms are notified about the activated and expired visibilities as if they were created and deleted.
+type Scheduler struct {
+	smCtx               context.Context
+	repository          storage.TransactionalRepository
+	settings            *Settings
+	createNotifications storage.CreateOnTxInterceptor
+	deleteNotifications storage.DeleteOnTxInterceptor
+}
+
+// NewScheduler constructs a Scheduler. The repository should not run interceptors,
+// as the scheduler creates the visibility notifications itself. The staging label restricts the notifications
+// about visibilities of plans of brokers in staging state to the staging platforms.
+func NewScheduler(smCtx context.Context, repository storage.TransactionalRepository, settings *Settings, stagingLabel string) *Scheduler {
+	return &Scheduler{
+		smCtx:               smCtx,
+		repository:          repository,
+		settings:            settings,
+		createNotifications: (&interceptors.VisibilityCreateNotificationsInterceptorProvider{StagingLabel: stagingLabel}).Provide(),
+		deleteNotifications: (&interceptors.VisibilityDeleteNotificationsInterceptorProvider{StagingLabel: stagingLabel}).Provide(),
+	}
+}
+
+// Run starts the recurring job which activates and expires the visibilities
+func (s *Scheduler) Run() {
+	go s.processVisibilities()
+}
+
+// processVisibilities periodically activates and expires the visibilities
+func (s *Scheduler) processVisibilities() {
+	ticker := time.NewTicker(s.settings.ActivationInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.scheduleVisibilities(time.Now().UTC())
+		case <-s.smCtx.Done():
+			ticker.Stop()
+			log.C(s.smCtx).Info("Server is shutting down. Stopping visibilities scheduler...")
+			return
+		}
+	}
+}
+
+func (s *Scheduler) scheduleVisibilities(now time.Time) {
+	inactiveVisibilities, err := s.listVisibilities(query.ByField(query.EqualsOperator, "active", "false"))
+	if err != nil {
+		log.C(s.smCtx).WithError(err).Error("Failed to fetch inactive visibilities")
+		return
+	}
+	for _, visibility := range inactiveVisibilities {
+		if visibility.IsActiveAt(now) {
+			s.schedule(visibility, true)
+		}
+	}
+
+	nowString := util.ToRFCNanoFormat(now)
+	expiredVisibilities, err := s.listVisibilities(
+		query.ByField(query.EqualsOperator, "active", "true"),
+		query.ByField(query.LessThanOrEqualOperator, "valid_until", nowString))
+	if err != nil {
+		log.C(s.smCtx).WithError(err).Error("Failed to fetch expired visibilities")
+		return
+	}
+	notYetValidVisibilities, err := s.listVisibilities(
+		query.ByField(query.EqualsOperator, "active", "true"),
+		query.ByField(query.GreaterThanOperator, "valid_from", nowString))
+	if err != nil {
+		log.C(s.smCtx).WithError(err).Error("Failed to fetch not yet valid visibilities")
+		return
+	}
+	for _, visibility := range append(expiredVisibilities, notYetValidVisibilities...) {
+		s.schedule(visibility, false)
+	}
+}
+
+func (s *Scheduler) listVisibilities(criteria ...query.Criterion) ([]*types.Visibility, error) {
+	objectList, err := s.repository.List(s.smCtx, types.VisibilityType, criteria...)
+	if err != nil {
+		return nil, err
+	}
+	return objectList.(*types.Visibilities).Visibilities, nil
+}
+
+// schedule activates or expires the visibility and notifies the platforms in a single transaction
+func (s *Scheduler) schedule(visibility *types.Visibility, activate bool) {
+	err := s.repository.InTransaction(s.smCtx, func(ctx context.Context, repository storage.Repository) error {
+		if activate {
+			_, err := s.createNotifications.OnTxCreate(func(ctx context.Context, repository storage.Repository, obj types.Object) (types.Object, error) {
+				return setActive(ctx, repository, obj.(*types.Visibility), true)
+			})(ctx, repository, visibility)
+			return err
+		}
+		byID := query.ByField(query.EqualsOperator, "id", visibility.ID)
+		return s.deleteNotifications.OnTxDelete(func(ctx context.Context, repository storage.Repository, objects types.ObjectList, _ ...query.Criterion) error {
+			_, err := setActive(ctx, repository, visibility, false)
+			return err
+		})(ctx, repository, types.NewObjectArray(visibility), byID)
+	})
+
+	switch {
+	case err == errAlreadyScheduled:
+		log.C(s.smCtx).Debugf("Visibility with id %s has already been activated or expired", visibility.ID)
+	case err != nil:
+		log.C(s.smCtx).WithError(err).Errorf("Failed to activate or expire visibility with id %s", visibility.ID)
+	case activate:
+		log.C(s.smCtx).Infof("Activated visibility with id %s", visibility.ID)
+	default:
+		log.C(s.smCtx).Infof("Expired visibility with id %s", visibility.ID)
+	}
+}
+
+// setActive changes the activation state of the visibility, unless it has already been changed concurrently
+func setActive(ctx context.Context, repository storage.Repository, visibility *types.Visibility, active bool) (types.Object, error) {
+	byID := query.ByField(query.EqualsOperator, "id", visibility.ID)
+	object, err := repository.Get(ctx, types.VisibilityType, byID)
+	if err != nil {
+		return nil, err
+	}
+	current := object.(*types.Visibility)
+	if current.Active == active {
+		return nil, errAlreadyScheduled
+	}
+
+	current.Active = active
+	current.UpdatedAt = time.Now().UTC()
+	if _, err := repository.Update(ctx, current, query.LabelChanges{}, byID); err != nil {
+		return nil, err
+	}
+	return current, nil
+}
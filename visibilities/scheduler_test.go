@@ -0,0 +1,132 @@
+/*
+ * Copyright 2018 The Service Manager Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package visibilities_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/Peripli/service-manager/pkg/query"
+	"github.com/Peripli/service-manager/pkg/types"
+	"github.com/Peripli/service-manager/storage"
+	"github.com/Peripli/service-manager/storage/storagefakes"
+	"github.com/Peripli/service-manager/visibilities"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Scheduler", func() {
+	var (
+		ctx          context.Context
+		cancel       context.CancelFunc
+		fakeStorage  *storagefakes.FakeStorage
+		settings     *visibilities.Settings
+		visibilityDB map[string]*types.Visibility
+	)
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		visibilityDB = map[string]*types.Visibility{
+			"started":     {Base: types.Base{ID: "started"}, PlatformID: "platform-id", ServicePlanID: "plan-id", ValidFrom: &past},
+			"not-started": {Base: types.Base{ID: "not-started"}, PlatformID: "platform-id", ServicePlanID: "plan-id", ValidFrom: &future},
+			"expired":     {Base: types.Base{ID: "expired"}, PlatformID: "platform-id", ServicePlanID: "plan-id", ValidUntil: &past, Active: true},
+		}
+
+		fakeStorage = &storagefakes.FakeStorage{}
+		fakeStorage.InTransactionStub = func(ctx context.Context, f func(ctx context.Context, storage storage.Repository) error) error {
+			return f(ctx, fakeStorage)
+		}
+		fakeStorage.ListStub = func(ctx context.Context, objectType types.ObjectType, criteria ...query.Criterion) (types.ObjectList, error) {
+			switch objectType {
+			case types.VisibilityType:
+				result := &types.Visibilities{}
+				active := criteria[0].RightOp[0] == "true"
+				for _, visibility := range visibilityDB {
+					if visibility.Active != active {
+						continue
+					}
+					if active && (len(criteria) < 2 || criteria[1].LeftOp != "valid_until") {
+						continue
+					}
+					copied := *visibility
+					result.Visibilities = append(result.Visibilities, &copied)
+				}
+				return result, nil
+			case types.ServicePlanType:
+				return &types.ServicePlans{ServicePlans: []*types.ServicePlan{
+					{Base: types.Base{ID: "plan-id"}, ServiceOfferingID: "offering-id"},
+				}}, nil
+			case types.ServiceOfferingType:
+				return &types.ServiceOfferings{ServiceOfferings: []*types.ServiceOffering{
+					{Base: types.Base{ID: "offering-id"}, BrokerID: "broker-id"},
+				}}, nil
+			case types.ServiceBrokerType:
+				return &types.ServiceBrokers{ServiceBrokers: []*types.ServiceBroker{
+					{Base: types.Base{ID: "broker-id"}, Name: "broker"},
+				}}, nil
+			}
+			return nil, nil
+		}
+		fakeStorage.GetStub = func(ctx context.Context, objectType types.ObjectType, criteria ...query.Criterion) (types.Object, error) {
+			copied := *visibilityDB[criteria[0].RightOp[0]]
+			return &copied, nil
+		}
+		fakeStorage.UpdateStub = func(ctx context.Context, obj types.Object, labelChanges query.LabelChanges, criteria ...query.Criterion) (types.Object, error) {
+			visibilityDB[obj.GetID()] = obj.(*types.Visibility)
+			return obj, nil
+		}
+		fakeStorage.CreateStub = func(ctx context.Context, obj types.Object) (types.Object, error) {
+			return obj, nil
+		}
+
+		settings = visibilities.DefaultSettings()
+		settings.ActivationInterval = 10 * time.Millisecond
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	notifications := func() []types.NotificationOperation {
+		operations := make([]types.NotificationOperation, 0)
+		for i := 0; i < fakeStorage.CreateCallCount(); i++ {
+			_, obj := fakeStorage.CreateArgsForCall(i)
+			notification := obj.(*types.Notification)
+			Expect(notification.Resource).To(Equal(types.VisibilityType))
+			Expect(notification.PlatformID).To(Equal("platform-id"))
+			operations = append(operations, notification.Type)
+		}
+		return operations
+	}
+
+	It("activates the visibilities whose validity period has started and expires the visibilities whose validity period has ended", func() {
+		visibilities.NewScheduler(ctx, fakeStorage, settings, "").Run()
+
+		Eventually(fakeStorage.CreateCallCount).Should(Equal(2))
+		Consistently(fakeStorage.CreateCallCount, 50*time.Millisecond).Should(Equal(2))
+
+		Expect(visibilityDB["started"].Active).To(BeTrue())
+		Expect(visibilityDB["not-started"].Active).To(BeFalse())
+		Expect(visibilityDB["expired"].Active).To(BeFalse())
+
+		Expect(notifications()).To(ConsistOf(types.CREATED, types.DELETED))
+	})
+})